@@ -0,0 +1,59 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterModuleSpec describes a Module deployed by the cluster administrator.
+type ClusterModuleSpec struct {
+	// ModuleSpec describes how the Module is deployed.
+	ModuleSpec ModuleSpec `json:"moduleSpec"`
+
+	// Namespace is the namespace in which the Module, its DaemonSets and its build Jobs are created.
+	// The ImageRepoSecret, ServiceAccounts and Secrets referenced by ModuleSpec must exist in that namespace.
+	// +kubebuilder:validation:MinLength=1
+	Namespace string `json:"namespace"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:subresource:status
+
+// ClusterModule is the Schema for the clustermodules API.
+// It is deployed as a Module with the same name in the namespace set in its spec.
+type ClusterModule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterModuleSpec `json:"spec,omitempty"`
+	Status ModuleStatus      `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterModuleList contains a list of ClusterModule
+type ClusterModuleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterModule `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterModule{}, &ClusterModuleList{})
+}
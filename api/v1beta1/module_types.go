@@ -82,6 +82,12 @@ type KernelMapping struct {
 	// ContainerImage is the name of the DriverContainer image that should be used to deploy the module.
 	ContainerImage string `json:"containerImage"`
 
+	// +optional
+	// DevicePluginImage overrides the image of the device plugin for kernels matching this mapping.
+	// It can contain the same variables as ContainerImage.
+	// It requires the device plugin to be deployed per kernel.
+	DevicePluginImage string `json:"devicePluginImage,omitempty"`
+
 	// +optional
 	// Literal defines a literal target kernel version to be matched exactly against node kernels.
 	Literal string `json:"literal"`
@@ -108,6 +114,8 @@ type ModprobeSpec struct {
 	// Parameters is an optional list of kernel module parameters to be provided to modprobe.
 	// They should be in the form of key=value and will be separated by spaces in the modprobe command.
 	// The resulting loading command will be: `modprobe module_name ${Parameters}`.
+	// Changing them does not recreate the module loader pods: parameters writable under
+	// /sys/module/<moduleName>/parameters are updated in place, and the kernel module is reloaded otherwise.
 	Parameters []string `json:"parameters,omitempty"`
 
 	// DirName is the root directory for modules.
@@ -127,15 +135,55 @@ type ModprobeSpec struct {
 	RawArgs *ModprobeArgs `json:"rawArgs,omitempty"`
 }
 
+// HealthCheck configures the probes of the module loader container.
+// They check that /sys/module/<moduleName> exists, and that Command succeeds if it is set.
+// If the Module uses modprobe.rawArgs, only Command is run, and no probes are added if it is not set.
+// When they keep failing, the pod becomes not ready, so that the node loses its ready label, and the container is
+// restarted, which reloads the kernel module.
+type HealthCheck struct {
+	// Disabled removes the probes from the module loader container.
+	// +optional
+	Disabled bool `json:"disabled,omitempty"`
+
+	// Command is an additional check run in the module loader container.
+	// The kernel module is unhealthy if it exits with a non-zero status.
+	// +optional
+	Command []string `json:"command,omitempty"`
+
+	// PeriodSeconds is how often the checks run.
+	// +kubebuilder:default=10
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	PeriodSeconds int32 `json:"periodSeconds,omitempty"`
+
+	// FailureThreshold is the number of consecutive failed checks after which the pod becomes not ready and the
+	// container is restarted.
+	// +kubebuilder:default=3
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	FailureThreshold int32 `json:"failureThreshold,omitempty"`
+}
+
 type ModuleLoaderContainerSpec struct {
 	// Build contains build instructions.
 	// +optional
 	Build *Build `json:"build,omitempty"`
 
+	// HealthCheck configures how the module loader checks that the kernel module is still loaded.
+	// The module loader container has no probes if it is not set.
+	// +optional
+	HealthCheck *HealthCheck `json:"healthCheck,omitempty"`
+
 	// ContainerImage is a top-level field
 	// +optional
 	ContainerImage string `json:"containerImage,omitempty"`
 
+	// List of environment variables to set in the container.
+	// +optional
+	// +patchMergeKey=name
+	// +patchStrategy=merge
+	Env []v1.EnvVar `json:"env,omitempty" patchStrategy:"merge" patchMergeKey:"name"`
+
 	// Image pull policy.
 	// One of Always, Never, IfNotPresent.
 	// Defaults to Always if :latest tag is specified, or IfNotPresent otherwise.
@@ -150,14 +198,94 @@ type ModuleLoaderContainerSpec struct {
 	// +kubebuilder:validation:MinItems=1
 	KernelMappings []KernelMapping `json:"kernelMappings"`
 
+	// LoadAtBoot persists the modprobe configuration of the kernel module, its files for the running kernel and its
+	// parameters on the nodes, so that the kernel module is loaded early at boot instead of when the module loader pod
+	// starts.
+	// The configuration is removed when the module loader pod is deleted.
+	// It cannot be used with modprobe.rawArgs.
+	// +optional
+	LoadAtBoot bool `json:"loadAtBoot,omitempty"`
+
 	// Modprobe is a set of properties to customize which module modprobe loads and with which properties.
 	Modprobe ModprobeSpec `json:"modprobe"`
+
+	// Compute Resources required by this container.
+	// More info: https://kubernetes.io/docs/concepts/configuration/manage-resources-containers/
+	// +optional
+	Resources v1.ResourceRequirements `json:"resources,omitempty"`
+
+	// InitContainers run in the module loader pod before the kernel module is loaded, for instance to prepare host
+	// configuration.
+	// +optional
+	InitContainers []v1.Container `json:"initContainers,omitempty"`
+
+	// VolumeMounts is a list of volume mounts that are appended to the default ones.
+	// They cannot be mounted on or under /lib/modules and /usr/lib/modules, which are managed by the operator.
+	// +optional
+	VolumeMounts []v1.VolumeMount `json:"volumeMounts,omitempty"`
+
+	// Volumes is a list of volumes that are appended to the default ones in the module loader pod.
+	// +optional
+	Volumes []v1.Volume `json:"volumes,omitempty"`
+}
+
+// PodOptions are properties of the pods created by the operator that can be customized.
+type PodOptions struct {
+	// Tolerations are added to the pods, so that they can run on tainted nodes.
+	// +optional
+	Tolerations []v1.Toleration `json:"tolerations,omitempty"`
+
+	// Affinity is added to the pods.
+	// Required node affinity terms are combined with the node requirements set by the operator.
+	// +optional
+	Affinity *v1.Affinity `json:"affinity,omitempty"`
+
+	// PriorityClassName is the priority class of the pods.
+	// Defaults to system-node-critical.
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// PodAnnotations are added to the pods.
+	// +optional
+	PodAnnotations map[string]string `json:"podAnnotations,omitempty"`
+
+	// PodLabels are added to the pods.
+	// Labels set by the operator take precedence.
+	// +optional
+	PodLabels map[string]string `json:"podLabels,omitempty"`
+}
+
+// Capability is a Linux capability that can be added to containers created by the operator.
+// +kubebuilder:validation:Enum=CHOWN;DAC_OVERRIDE;FOWNER;IPC_LOCK;MKNOD;NET_ADMIN;NET_RAW;SYS_ADMIN;SYS_MODULE;SYS_NICE;SYS_RAWIO;SYS_RESOURCE
+type Capability string
+
+// SELinuxType is an SELinux type that containers created by the operator can run with.
+// +kubebuilder:validation:Enum=spc_t;container_t;container_device_t;container_device_plugin_t
+type SELinuxType string
+
+// SecurityOptions customize the security context of a container created by the operator.
+type SecurityOptions struct {
+	// Capabilities are added to the container.
+	// The module loader container always has SYS_MODULE.
+	// +optional
+	Capabilities []Capability `json:"capabilities,omitempty"`
+
+	// SELinuxType is the SELinux type of the container.
+	// Defaults to spc_t.
+	// +optional
+	SELinuxType SELinuxType `json:"seLinuxType,omitempty"`
 }
 
 type ModuleLoaderSpec struct {
 	// Container holds the properties for the module loader container that runs modprobe.
 	Container ModuleLoaderContainerSpec `json:"container"`
 
+	PodOptions `json:",inline"`
+
+	// SecurityOptions customize the security context of the module loader container.
+	// +optional
+	SecurityOptions *SecurityOptions `json:"securityOptions,omitempty"`
+
 	// +optional
 	// ServiceAccountName is the name of the ServiceAccount to use to run this pod.
 	// More info: https://kubernetes.io/docs/tasks/configure-pod-container/configure-service-account/
@@ -219,6 +347,20 @@ type DevicePluginContainerSpec struct {
 type DevicePluginSpec struct {
 	Container DevicePluginContainerSpec `json:"container"`
 
+	// PerKernel deploys a device plugin DaemonSet for each kernel version, instead of one for all nodes.
+	// Their image is the devicePluginImage of the kernel mapping, or Container.Image; in both cases, the variables
+	// supported in kernel mappings' containerImage are substituted.
+	// +optional
+	PerKernel bool `json:"perKernel,omitempty"`
+
+	PodOptions `json:",inline"`
+
+	// SecurityOptions run the device plugin container unprivileged, as root, with the capabilities and SELinux type
+	// they specify.
+	// The container is privileged if they are not set.
+	// +optional
+	SecurityOptions *SecurityOptions `json:"securityOptions,omitempty"`
+
 	// +optional
 	// ServiceAccountName is the name of the ServiceAccount to use to run this pod.
 	// More info: https://kubernetes.io/docs/tasks/configure-pod-container/configure-service-account/
@@ -245,6 +387,39 @@ type ModuleSpec struct {
 
 	// Selector describes on which nodes the Module should be loaded and optionally built.
 	Selector map[string]string `json:"selector"`
+
+	// SelectorExpressions are additional requirements on the labels of the nodes on which the Module should be
+	// loaded and optionally built.
+	// Nodes must match both Selector and all SelectorExpressions.
+	// +optional
+	SelectorExpressions []metav1.LabelSelectorRequirement `json:"selectorExpressions,omitempty"`
+
+	// UnmappedNodes configures how nodes targeted by the Module, but whose kernel matches none of its kernel
+	// mappings, are marked.
+	// +optional
+	UnmappedNodes *UnmappedNodesSpec `json:"unmappedNodes,omitempty"`
+
+	// Version is the version of the kernel module.
+	// It is set as the value of the kmm.node.kubernetes.io/<module-name>.version label on nodes where the kernel
+	// module is loaded.
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^(([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9])?$`
+	// +optional
+	Version string `json:"version,omitempty"`
+}
+
+// UnmappedNodesSpec describes the markers set on nodes whose kernel matches none of the Module's kernel mappings.
+// Both markers use the kmm.node.kubernetes.io/<module name>.unmapped key, and are removed once a mapping matches
+// the node's kernel again.
+type UnmappedNodesSpec struct {
+	// Label, if true, labels unmapped nodes.
+	// +optional
+	Label bool `json:"label,omitempty"`
+
+	// TaintEffect, if set, taints unmapped nodes with that effect.
+	// +kubebuilder:validation:Enum=NoSchedule;PreferNoSchedule;NoExecute
+	// +optional
+	TaintEffect v1.TaintEffect `json:"taintEffect,omitempty"`
 }
 
 // DaemonSetStatus contains the status for a daemonset deployed during
@@ -265,6 +440,34 @@ type ModuleStatus struct {
 	DevicePlugin DaemonSetStatus `json:"devicePlugin,omitempty"`
 	// ModuleLoader contains the status of the ModuleLoader daemonset
 	ModuleLoader DaemonSetStatus `json:"moduleLoader"`
+	// UnmappedNodes lists the nodes targeted by the selector whose kernel matches none of the kernel mappings.
+	// +optional
+	UnmappedNodes []UnmappedNode `json:"unmappedNodes,omitempty"`
+	// Conflicts lists the other Modules that load the same kernel module on some of the same nodes.
+	// +optional
+	Conflicts []ModuleConflict `json:"conflicts,omitempty"`
+}
+
+// ModuleConflict is another Module that loads the same kernel module on some of the same nodes.
+type ModuleConflict struct {
+	// Name is the name of the other Module.
+	Name string `json:"name"`
+	// Namespace is the namespace of the other Module.
+	Namespace string `json:"namespace"`
+	// NodesNumber is the number of nodes targeted by both Modules.
+	NodesNumber int32 `json:"nodesNumber"`
+	// Yielded is true if the other Module was created first: the kernel module is then not loaded by this Module on
+	// the nodes targeted by both, and those nodes are not counted in the moduleLoader and devicePlugin statuses.
+	// +optional
+	Yielded bool `json:"yielded,omitempty"`
+}
+
+// UnmappedNode is a node on which the Module cannot be loaded because no kernel mapping matches its kernel.
+type UnmappedNode struct {
+	// Name is the name of the node.
+	Name string `json:"name"`
+	// KernelVersion is the kernel version running on the node.
+	KernelVersion string `json:"kernelVersion"`
 }
 
 //+kubebuilder:object:root=true
@@ -17,6 +17,7 @@ limitations under the License.
 package v1beta1
 
 import (
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -25,6 +26,9 @@ const (
 	VerificationFalse      string = "False"
 	VerificationStageImage string = "Image"
 	VerificationStageBuild string = "Build"
+
+	PreflightPhaseInProgress string = "InProgress"
+	PreflightPhaseCompleted  string = "Completed"
 )
 
 // PreflightValidationSpec describes the desired state of the resource, such as the kernel version
@@ -33,8 +37,34 @@ const (
 // +kubebuilder:validation:Required
 type PreflightValidationSpec struct {
 	// KernelImage describes the kernel image that all Modules need to be checked against.
-	// +kubebuilder:validation:Required
-	KernelVersion string `json:"kernelVersion"`
+	// Exactly one of KernelVersion and ReleaseImage must be set.
+	// +optional
+	KernelVersion string `json:"kernelVersion,omitempty"`
+
+	// ReleaseImage is an OpenShift release payload image. The kernel versions that Modules need to be checked
+	// against, including the real-time kernel if any, are read from the driver-toolkit image of that release.
+	// Exactly one of KernelVersion and ReleaseImage must be set.
+	// +optional
+	ReleaseImage string `json:"releaseImage,omitempty"`
+
+	// ReleaseImagePullSecret is an optional secret used to pull the release image and its driver-toolkit image.
+	// +optional
+	ReleaseImagePullSecret *v1.SecretReference `json:"releaseImagePullSecret,omitempty"`
+
+	// PushBuiltImage determines whether images built during the build verification stage should be pushed
+	// to their registry. If false, the build is only used to check that the kernel module compiles.
+	// +optional
+	PushBuiltImage bool `json:"pushBuiltImage"`
+
+	// Selector restricts the verification to Modules whose labels match it.
+	// If not set, Modules are not filtered on their labels.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// NamespaceSelector restricts the verification to Modules in namespaces whose labels match it.
+	// If not set, Modules from all namespaces are verified.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
 }
 
 type CRStatus struct {
@@ -63,17 +93,79 @@ type CRStatus struct {
 	// +kubebuilder:validation:Type=string
 	// +kubebuilder:validation:Format=date-time
 	LastTransitionTime metav1.Time `json:"lastTransitionTime" protobuf:"bytes,4,opt,name=lastTransitionTime"`
+
+	// CheckedImages lists the images that were looked up during the image verification stage.
+	// +optional
+	CheckedImages []CheckedImage `json:"checkedImages,omitempty"`
+
+	// ModuleGeneration is the generation of the Module that is verified.
+	// The verification starts over when the Module changes.
+	// +optional
+	ModuleGeneration int64 `json:"moduleGeneration,omitempty"`
+}
+
+// CheckedImage describes an image that was looked up for the kernel module during the image verification stage.
+type CheckedImage struct {
+	// KernelVersion is the kernel version that the image was checked for.
+	KernelVersion string `json:"kernelVersion"`
+
+	// Image is the image that was checked.
+	Image string `json:"image"`
+
+	// Digest is the digest of the image manifest, if it could be fetched.
+	// +optional
+	Digest string `json:"digest,omitempty"`
+
+	// Layer is the digest of the layer that contains the kernel module, if any.
+	// +optional
+	Layer string `json:"layer,omitempty"`
 }
 
 // PreflightValidationStatus is the most recently observed status of the PreflightValidation.
 // It is populated by the system and is read-only.
 // More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#spec-and-status
 type PreflightValidationStatus struct {
-	// CRStatuses contain observations about each SpecialResource's preflight upgradability validation
+	// CRStatuses contain observations about each Module's preflight upgradability validation.
+	// They are keyed by the Module's namespace and name, in the namespace/name format.
 	// +patchMergeKey=type
 	// +patchStrategy=merge
 	// +optional
 	CRStatuses map[string]*CRStatus `json:"crStatuses,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+
+	// Phase is InProgress while the verification of at least one Module is not over yet, and Completed once all
+	// Modules are either verified or failed verification.
+	// +optional
+	// +kubebuilder:validation:Enum=InProgress;Completed
+	Phase string `json:"phase,omitempty"`
+
+	// CompletionTime is the last time the PreflightValidation reached the Completed phase.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Summary counts the Modules by verification result.
+	// +optional
+	Summary PreflightSummary `json:"summary,omitempty"`
+
+	// ObservedGeneration is the generation of the PreflightValidation that Summary was computed for.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// ReportConfigMap is the ConfigMap, in the namespace of the PreflightValidation, that holds the JSON and JUnit
+	// reports of the verification.
+	// +optional
+	ReportConfigMap *v1.LocalObjectReference `json:"reportConfigMap,omitempty"`
+}
+
+// PreflightSummary counts the Modules by verification result.
+type PreflightSummary struct {
+	// Verified is the number of Modules that were verified.
+	Verified int32 `json:"verified"`
+
+	// Failed is the number of Modules that failed verification.
+	Failed int32 `json:"failed"`
+
+	// InProgress is the number of Modules whose verification is not over yet.
+	InProgress int32 `json:"inProgress"`
 }
 
 // +kubebuilder:object:root=true
@@ -23,6 +23,7 @@ package v1beta1
 
 import (
 	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -72,6 +73,11 @@ func (in *BuildArg) DeepCopy() *BuildArg {
 func (in *CRStatus) DeepCopyInto(out *CRStatus) {
 	*out = *in
 	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	if in.CheckedImages != nil {
+		in, out := &in.CheckedImages, &out.CheckedImages
+		*out = make([]CheckedImage, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CRStatus.
@@ -84,6 +90,96 @@ func (in *CRStatus) DeepCopy() *CRStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CheckedImage) DeepCopyInto(out *CheckedImage) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CheckedImage.
+func (in *CheckedImage) DeepCopy() *CheckedImage {
+	if in == nil {
+		return nil
+	}
+	out := new(CheckedImage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterModule) DeepCopyInto(out *ClusterModule) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterModule.
+func (in *ClusterModule) DeepCopy() *ClusterModule {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterModule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterModule) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterModuleList) DeepCopyInto(out *ClusterModuleList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterModule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterModuleList.
+func (in *ClusterModuleList) DeepCopy() *ClusterModuleList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterModuleList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterModuleList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterModuleSpec) DeepCopyInto(out *ClusterModuleSpec) {
+	*out = *in
+	in.ModuleSpec.DeepCopyInto(&out.ModuleSpec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterModuleSpec.
+func (in *ClusterModuleSpec) DeepCopy() *ClusterModuleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterModuleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DaemonSetStatus) DeepCopyInto(out *DaemonSetStatus) {
 	*out = *in
@@ -143,6 +239,12 @@ func (in *DevicePluginContainerSpec) DeepCopy() *DevicePluginContainerSpec {
 func (in *DevicePluginSpec) DeepCopyInto(out *DevicePluginSpec) {
 	*out = *in
 	in.Container.DeepCopyInto(&out.Container)
+	in.PodOptions.DeepCopyInto(&out.PodOptions)
+	if in.SecurityOptions != nil {
+		in, out := &in.SecurityOptions, &out.SecurityOptions
+		*out = new(SecurityOptions)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Volumes != nil {
 		in, out := &in.Volumes, &out.Volumes
 		*out = make([]v1.Volume, len(*in))
@@ -162,6 +264,26 @@ func (in *DevicePluginSpec) DeepCopy() *DevicePluginSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HealthCheck) DeepCopyInto(out *HealthCheck) {
+	*out = *in
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HealthCheck.
+func (in *HealthCheck) DeepCopy() *HealthCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(HealthCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KernelMapping) DeepCopyInto(out *KernelMapping) {
 	*out = *in
@@ -243,7 +365,7 @@ func (in *Module) DeepCopyInto(out *Module) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Module.
@@ -264,6 +386,21 @@ func (in *Module) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ModuleConflict) DeepCopyInto(out *ModuleConflict) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ModuleConflict.
+func (in *ModuleConflict) DeepCopy() *ModuleConflict {
+	if in == nil {
+		return nil
+	}
+	out := new(ModuleConflict)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ModuleList) DeepCopyInto(out *ModuleList) {
 	*out = *in
@@ -304,6 +441,18 @@ func (in *ModuleLoaderContainerSpec) DeepCopyInto(out *ModuleLoaderContainerSpec
 		*out = new(Build)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.HealthCheck != nil {
+		in, out := &in.HealthCheck, &out.HealthCheck
+		*out = new(HealthCheck)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make([]v1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.KernelMappings != nil {
 		in, out := &in.KernelMappings, &out.KernelMappings
 		*out = make([]KernelMapping, len(*in))
@@ -312,6 +461,28 @@ func (in *ModuleLoaderContainerSpec) DeepCopyInto(out *ModuleLoaderContainerSpec
 		}
 	}
 	in.Modprobe.DeepCopyInto(&out.Modprobe)
+	in.Resources.DeepCopyInto(&out.Resources)
+	if in.InitContainers != nil {
+		in, out := &in.InitContainers, &out.InitContainers
+		*out = make([]v1.Container, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.VolumeMounts != nil {
+		in, out := &in.VolumeMounts, &out.VolumeMounts
+		*out = make([]v1.VolumeMount, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Volumes != nil {
+		in, out := &in.Volumes, &out.Volumes
+		*out = make([]v1.Volume, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ModuleLoaderContainerSpec.
@@ -328,6 +499,12 @@ func (in *ModuleLoaderContainerSpec) DeepCopy() *ModuleLoaderContainerSpec {
 func (in *ModuleLoaderSpec) DeepCopyInto(out *ModuleLoaderSpec) {
 	*out = *in
 	in.Container.DeepCopyInto(&out.Container)
+	in.PodOptions.DeepCopyInto(&out.PodOptions)
+	if in.SecurityOptions != nil {
+		in, out := &in.SecurityOptions, &out.SecurityOptions
+		*out = new(SecurityOptions)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ModuleLoaderSpec.
@@ -361,6 +538,18 @@ func (in *ModuleSpec) DeepCopyInto(out *ModuleSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.SelectorExpressions != nil {
+		in, out := &in.SelectorExpressions, &out.SelectorExpressions
+		*out = make([]metav1.LabelSelectorRequirement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.UnmappedNodes != nil {
+		in, out := &in.UnmappedNodes, &out.UnmappedNodes
+		*out = new(UnmappedNodesSpec)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ModuleSpec.
@@ -378,6 +567,16 @@ func (in *ModuleStatus) DeepCopyInto(out *ModuleStatus) {
 	*out = *in
 	out.DevicePlugin = in.DevicePlugin
 	out.ModuleLoader = in.ModuleLoader
+	if in.UnmappedNodes != nil {
+		in, out := &in.UnmappedNodes, &out.UnmappedNodes
+		*out = make([]UnmappedNode, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conflicts != nil {
+		in, out := &in.Conflicts, &out.Conflicts
+		*out = make([]ModuleConflict, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ModuleStatus.
@@ -390,12 +589,68 @@ func (in *ModuleStatus) DeepCopy() *ModuleStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodOptions) DeepCopyInto(out *PodOptions) {
+	*out = *in
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]v1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(v1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodAnnotations != nil {
+		in, out := &in.PodAnnotations, &out.PodAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.PodLabels != nil {
+		in, out := &in.PodLabels, &out.PodLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodOptions.
+func (in *PodOptions) DeepCopy() *PodOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(PodOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PreflightSummary) DeepCopyInto(out *PreflightSummary) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PreflightSummary.
+func (in *PreflightSummary) DeepCopy() *PreflightSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(PreflightSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PreflightValidation) DeepCopyInto(out *PreflightValidation) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 }
 
@@ -452,6 +707,21 @@ func (in *PreflightValidationList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PreflightValidationSpec) DeepCopyInto(out *PreflightValidationSpec) {
 	*out = *in
+	if in.ReleaseImagePullSecret != nil {
+		in, out := &in.ReleaseImagePullSecret, &out.ReleaseImagePullSecret
+		*out = new(v1.SecretReference)
+		**out = **in
+	}
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PreflightValidationSpec.
@@ -482,6 +752,16 @@ func (in *PreflightValidationStatus) DeepCopyInto(out *PreflightValidationStatus
 			(*out)[key] = outVal
 		}
 	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	out.Summary = in.Summary
+	if in.ReportConfigMap != nil {
+		in, out := &in.ReportConfigMap, &out.ReportConfigMap
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PreflightValidationStatus.
@@ -523,3 +803,53 @@ func (in *PushOptions) DeepCopy() *PushOptions {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecurityOptions) DeepCopyInto(out *SecurityOptions) {
+	*out = *in
+	if in.Capabilities != nil {
+		in, out := &in.Capabilities, &out.Capabilities
+		*out = make([]Capability, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecurityOptions.
+func (in *SecurityOptions) DeepCopy() *SecurityOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(SecurityOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UnmappedNode) DeepCopyInto(out *UnmappedNode) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UnmappedNode.
+func (in *UnmappedNode) DeepCopy() *UnmappedNode {
+	if in == nil {
+		return nil
+	}
+	out := new(UnmappedNode)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UnmappedNodesSpec) DeepCopyInto(out *UnmappedNodesSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UnmappedNodesSpec.
+func (in *UnmappedNodesSpec) DeepCopy() *UnmappedNodesSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(UnmappedNodesSpec)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	"github.com/qbarrand/oot-operator/internal/constants"
+	"github.com/qbarrand/oot-operator/internal/filter"
+	"github.com/qbarrand/oot-operator/internal/metrics"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+//+kubebuilder:rbac:groups="batch",resources=jobs,verbs=get;list;watch
+//+kubebuilder:rbac:groups=kmm.sigs.k8s.io,resources=modules,verbs=get;list;watch
+//+kubebuilder:rbac:groups="core",resources=events,verbs=create;patch
+
+const (
+	EventReasonBuildSucceeded = "BuildSucceeded"
+	EventReasonBuildFailed    = "BuildFailed"
+)
+
+// BuildMetricsReconciler records the duration and the result of build Jobs once they are finished, and emits an event
+// on their Module.
+type BuildMetricsReconciler struct {
+	client     client.Client
+	metricsAPI metrics.Metrics
+	recorder   record.EventRecorder
+}
+
+func NewBuildMetricsReconciler(client client.Client, metricsAPI metrics.Metrics, recorder record.EventRecorder) *BuildMetricsReconciler {
+	return &BuildMetricsReconciler{
+		client:     client,
+		metricsAPI: metricsAPI,
+		recorder:   recorder,
+	}
+}
+
+func (r *BuildMetricsReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	job := batchv1.Job{}
+
+	if err := r.client.Get(ctx, req.NamespacedName, &job); err != nil {
+		if k8serrors.IsNotFound(err) {
+			logger.Info("Job not found")
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, fmt.Errorf("could not get Job %s: %v", req.NamespacedName, err)
+	}
+
+	result, finishTime := jobResult(&job)
+	if result == "" {
+		logger.Info("Job is not finished")
+		return ctrl.Result{}, nil
+	}
+
+	moduleName := job.Labels[constants.ModuleNameLabel]
+	kernelVersion := job.Labels[constants.TargetKernelTarget]
+
+	// get the Module first, so that the metrics are not recorded twice if this fails and the Job is reconciled again
+	mod := &kmmv1beta1.Module{}
+
+	if err := r.client.Get(ctx, types.NamespacedName{Name: moduleName, Namespace: job.Namespace}, mod); err != nil {
+		if !k8serrors.IsNotFound(err) {
+			return ctrl.Result{}, fmt.Errorf("could not get Module %s/%s: %v", job.Namespace, moduleName, err)
+		}
+
+		logger.Info("Module not found; not emitting an event", "module", moduleName)
+		mod = nil
+	}
+
+	if job.Status.StartTime != nil {
+		r.metricsAPI.ObserveBuildDuration(moduleName, job.Namespace, result, finishTime.Sub(job.Status.StartTime.Time))
+	}
+
+	if result == metrics.BuildResultFailed {
+		r.metricsAPI.IncBuildFailures(moduleName, job.Namespace, kernelVersion)
+	}
+
+	if mod == nil {
+		return ctrl.Result{}, nil
+	}
+
+	if result == metrics.BuildResultFailed {
+		r.recorder.Eventf(mod, v1.EventTypeWarning, EventReasonBuildFailed, "Build Job %s for kernel %s failed", job.Name, kernelVersion)
+	} else {
+		r.recorder.Eventf(mod, v1.EventTypeNormal, EventReasonBuildSucceeded, "Build Job %s for kernel %s succeeded", job.Name, kernelVersion)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// jobResult returns the result of job and the time at which it finished, or an empty result if job is still running.
+func jobResult(job *batchv1.Job) (string, time.Time) {
+	for _, c := range job.Status.Conditions {
+		if c.Status != v1.ConditionTrue {
+			continue
+		}
+
+		switch c.Type {
+		case batchv1.JobComplete:
+			return metrics.BuildResultSucceeded, c.LastTransitionTime.Time
+		case batchv1.JobFailed:
+			return metrics.BuildResultFailed, c.LastTransitionTime.Time
+		}
+	}
+
+	return "", time.Time{}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *BuildMetricsReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.
+		NewControllerManagedBy(mgr).
+		Named("build-metrics").
+		For(&batchv1.Job{}).
+		WithEventFilter(
+			predicate.And(
+				filter.HasLabel(constants.ModuleNameLabel),
+				filter.JobFinishedPredicate(),
+			),
+		).
+		Complete(r)
+}
@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	"github.com/qbarrand/oot-operator/internal/client"
+	"github.com/qbarrand/oot-operator/internal/constants"
+	"github.com/qbarrand/oot-operator/internal/metrics"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("BuildMetricsReconciler", func() {
+	Describe("Reconcile", func() {
+		const (
+			jobName       = "job-name"
+			moduleName    = "module-name"
+			kernelVersion = "1.2.3"
+		)
+
+		var (
+			clnt        *client.MockClient
+			mockMetrics *metrics.MockMetrics
+			recorder    *record.FakeRecorder
+			r           *BuildMetricsReconciler
+		)
+
+		BeforeEach(func() {
+			ctrl := gomock.NewController(GinkgoT())
+			clnt = client.NewMockClient(ctrl)
+			mockMetrics = metrics.NewMockMetrics(ctrl)
+			recorder = record.NewFakeRecorder(10)
+			r = NewBuildMetricsReconciler(clnt, mockMetrics, recorder)
+		})
+
+		ctx := context.Background()
+		nn := types.NamespacedName{Name: jobName, Namespace: namespace}
+		req := ctrl.Request{NamespacedName: nn}
+
+		startTime := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		finishedJob := func(t batchv1.JobConditionType) batchv1.Job {
+			return batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      jobName,
+					Namespace: namespace,
+					Labels: map[string]string{
+						constants.ModuleNameLabel:    moduleName,
+						constants.TargetKernelTarget: kernelVersion,
+					},
+				},
+				Status: batchv1.JobStatus{
+					StartTime: &metav1.Time{Time: startTime},
+					Conditions: []batchv1.JobCondition{
+						{
+							Type:               t,
+							Status:             v1.ConditionTrue,
+							LastTransitionTime: metav1.Time{Time: startTime.Add(3 * time.Minute)},
+						},
+					},
+				},
+			}
+		}
+
+		It("should do nothing if the Job does not exist", func() {
+			clnt.EXPECT().Get(ctx, nn, gomock.Any()).Return(apierrors.NewNotFound(schema.GroupResource{}, jobName))
+
+			Expect(r.Reconcile(ctx, req)).To(Equal(ctrl.Result{}))
+		})
+
+		It("should do nothing if the Job is still running", func() {
+			clnt.EXPECT().Get(ctx, nn, gomock.Any())
+
+			Expect(r.Reconcile(ctx, req)).To(Equal(ctrl.Result{}))
+		})
+
+		modNN := types.NamespacedName{Name: moduleName, Namespace: namespace}
+
+		It("should record the duration of successful builds and emit an event", func() {
+			job := finishedJob(batchv1.JobComplete)
+
+			gomock.InOrder(
+				clnt.EXPECT().Get(ctx, nn, gomock.Any()).SetArg(2, job),
+				clnt.EXPECT().Get(ctx, modNN, gomock.AssignableToTypeOf(&kmmv1beta1.Module{})),
+				mockMetrics.EXPECT().ObserveBuildDuration(moduleName, namespace, metrics.BuildResultSucceeded, 3*time.Minute),
+			)
+
+			Expect(r.Reconcile(ctx, req)).To(Equal(ctrl.Result{}))
+			Expect(recorder.Events).To(Receive(Equal("Normal " + EventReasonBuildSucceeded + " Build Job " + jobName + " for kernel " + kernelVersion + " succeeded")))
+		})
+
+		It("should record the duration of failed builds, count the failure and emit an event", func() {
+			job := finishedJob(batchv1.JobFailed)
+
+			gomock.InOrder(
+				clnt.EXPECT().Get(ctx, nn, gomock.Any()).SetArg(2, job),
+				clnt.EXPECT().Get(ctx, modNN, gomock.AssignableToTypeOf(&kmmv1beta1.Module{})),
+				mockMetrics.EXPECT().ObserveBuildDuration(moduleName, namespace, metrics.BuildResultFailed, 3*time.Minute),
+				mockMetrics.EXPECT().IncBuildFailures(moduleName, namespace, kernelVersion),
+			)
+
+			Expect(r.Reconcile(ctx, req)).To(Equal(ctrl.Result{}))
+			Expect(recorder.Events).To(Receive(Equal("Warning " + EventReasonBuildFailed + " Build Job " + jobName + " for kernel " + kernelVersion + " failed")))
+		})
+
+		It("should record the metrics without emitting an event if the Module does not exist", func() {
+			job := finishedJob(batchv1.JobComplete)
+
+			gomock.InOrder(
+				clnt.EXPECT().Get(ctx, nn, gomock.Any()).SetArg(2, job),
+				clnt.EXPECT().Get(ctx, modNN, gomock.Any()).Return(apierrors.NewNotFound(schema.GroupResource{}, moduleName)),
+				mockMetrics.EXPECT().ObserveBuildDuration(moduleName, namespace, metrics.BuildResultSucceeded, 3*time.Minute),
+			)
+
+			Expect(r.Reconcile(ctx, req)).To(Equal(ctrl.Result{}))
+			Expect(recorder.Events).NotTo(Receive())
+		})
+	})
+})
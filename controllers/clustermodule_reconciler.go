@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	"github.com/qbarrand/oot-operator/internal/conflicts"
+	"github.com/qbarrand/oot-operator/internal/filter"
+	"github.com/qbarrand/oot-operator/internal/statusupdater"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	EventReasonModuleCreated  = "ModuleCreated"
+	EventReasonModuleUpdated  = "ModuleUpdated"
+	EventReasonModuleExists   = "ModuleExists"
+	EventReasonModuleConflict = "ModuleConflict"
+	EventReasonModuleDeleted  = "ModuleDeleted"
+)
+
+var errModuleNotManaged = errors.New("the Module exists and is not managed by the ClusterModule")
+
+//+kubebuilder:rbac:groups=kmm.sigs.k8s.io,resources=clustermodules,verbs=get;list;watch
+//+kubebuilder:rbac:groups=kmm.sigs.k8s.io,resources=clustermodules/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=kmm.sigs.k8s.io,resources=clustermodules/finalizers,verbs=update
+//+kubebuilder:rbac:groups=kmm.sigs.k8s.io,resources=modules,verbs=get;list;watch;create;patch;delete
+
+// ClusterModuleReconciler deploys each ClusterModule as a Module with the same name in the namespace set in its spec,
+// and reports the status of that Module on the ClusterModule.
+type ClusterModuleReconciler struct {
+	client           client.Client
+	conflictsAPI     conflicts.Detector
+	filter           *filter.Filter
+	statusUpdaterAPI statusupdater.ClusterModuleStatusUpdater
+	scheme           *runtime.Scheme
+	recorder         record.EventRecorder
+}
+
+func NewClusterModuleReconciler(
+	client client.Client,
+	conflictsAPI conflicts.Detector,
+	filter *filter.Filter,
+	statusUpdaterAPI statusupdater.ClusterModuleStatusUpdater,
+	scheme *runtime.Scheme,
+	recorder record.EventRecorder) *ClusterModuleReconciler {
+	return &ClusterModuleReconciler{
+		client:           client,
+		conflictsAPI:     conflictsAPI,
+		filter:           filter,
+		statusUpdaterAPI: statusUpdaterAPI,
+		scheme:           scheme,
+		recorder:         recorder,
+	}
+}
+
+func (r *ClusterModuleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	cm := kmmv1beta1.ClusterModule{}
+
+	if err := r.client.Get(ctx, req.NamespacedName, &cm); err != nil {
+		if k8serrors.IsNotFound(err) {
+			// the Module is garbage-collected through its owner reference
+			logger.Info("ClusterModule deleted")
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, fmt.Errorf("could not get ClusterModule %s: %w", req.Name, err)
+	}
+
+	if !r.filter.ModuleNamespaceAllowed(cm.Spec.Namespace) {
+		logger.Info("Modules are not allowed in the ClusterModule's namespace; skipping", "namespace", cm.Spec.Namespace)
+		r.recorder.Eventf(&cm, v1.EventTypeWarning, EventReasonNamespaceNotAllowed, "Modules are not allowed in namespace %s", cm.Spec.Namespace)
+		return ctrl.Result{}, nil
+	}
+
+	mod := &kmmv1beta1.Module{
+		ObjectMeta: metav1.ObjectMeta{Name: cm.Name, Namespace: cm.Spec.Namespace},
+	}
+
+	opRes, err := controllerutil.CreateOrPatch(ctx, r.client, mod, func() error {
+		if !mod.CreationTimestamp.IsZero() && !metav1.IsControlledBy(mod, &cm) {
+			return errModuleNotManaged
+		}
+
+		cm.Spec.ModuleSpec.DeepCopyInto(&mod.Spec)
+
+		return controllerutil.SetControllerReference(&cm, mod, r.scheme)
+	})
+
+	if err != nil {
+		if errors.Is(err, errModuleNotManaged) {
+			r.recorder.Eventf(&cm, v1.EventTypeWarning, EventReasonModuleExists, "Module %s/%s exists and is not managed by this ClusterModule", mod.Namespace, mod.Name)
+		}
+
+		return ctrl.Result{}, fmt.Errorf("could not reconcile Module %s/%s: %w", mod.Namespace, mod.Name, err)
+	}
+
+	switch opRes {
+	case controllerutil.OperationResultCreated:
+		r.recorder.Eventf(&cm, v1.EventTypeNormal, EventReasonModuleCreated, "Created Module %s/%s", mod.Namespace, mod.Name)
+	case controllerutil.OperationResultUpdated:
+		r.recorder.Eventf(&cm, v1.EventTypeNormal, EventReasonModuleUpdated, "Updated Module %s/%s", mod.Namespace, mod.Name)
+	}
+
+	if err = r.deleteStaleModules(ctx, &cm); err != nil {
+		return ctrl.Result{}, fmt.Errorf("could not delete the Modules of ClusterModule %s in other namespaces: %w", cm.Name, err)
+	}
+
+	found, err := r.conflictsAPI.FindConflicts(ctx, mod)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("could not look for conflicting Modules: %w", err)
+	}
+
+	r.recordConflicts(ctx, &cm, found)
+
+	if err = r.statusUpdaterAPI.ClusterModuleUpdateStatus(ctx, &cm, mod, found); err != nil {
+		return ctrl.Result{}, fmt.Errorf("could not update the status of ClusterModule %s: %w", cm.Name, err)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// deleteStaleModules deletes the Modules controlled by cm outside of its namespace.
+// Those are left behind when spec.namespace is changed.
+func (r *ClusterModuleReconciler) deleteStaleModules(ctx context.Context, cm *kmmv1beta1.ClusterModule) error {
+	modList := kmmv1beta1.ModuleList{}
+
+	if err := r.client.List(ctx, &modList); err != nil {
+		return fmt.Errorf("could not list Modules: %w", err)
+	}
+
+	for i := range modList.Items {
+		mod := &modList.Items[i]
+
+		if mod.Name != cm.Name || mod.Namespace == cm.Spec.Namespace || !metav1.IsControlledBy(mod, cm) {
+			continue
+		}
+
+		if err := r.client.Delete(ctx, mod); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("could not delete Module %s/%s: %w", mod.Namespace, mod.Name, err)
+		}
+
+		r.recorder.Eventf(cm, v1.EventTypeNormal, EventReasonModuleDeleted, "Deleted Module %s/%s", mod.Namespace, mod.Name)
+	}
+
+	return nil
+}
+
+// recordConflicts emits events on cm and on the conflicting Modules for the conflicts that are not in the status
+// of cm yet.
+func (r *ClusterModuleReconciler) recordConflicts(ctx context.Context, cm *kmmv1beta1.ClusterModule, found []kmmv1beta1.ModuleConflict) {
+	known := make(map[kmmv1beta1.ModuleConflict]bool, len(cm.Status.Conflicts))
+
+	for _, c := range cm.Status.Conflicts {
+		known[c] = true
+	}
+
+	kernelModule := cm.Spec.ModuleSpec.ModuleLoader.Container.Modprobe.ModuleName
+
+	for _, c := range found {
+		if known[c] {
+			continue
+		}
+
+		r.recorder.Eventf(
+			cm,
+			v1.EventTypeWarning,
+			EventReasonModuleConflict,
+			"Module %s/%s also loads kernel module %s on %d node(s)",
+			c.Namespace,
+			c.Name,
+			kernelModule,
+			c.NodesNumber,
+		)
+
+		other := kmmv1beta1.Module{}
+
+		if err := r.client.Get(ctx, client.ObjectKey{Name: c.Name, Namespace: c.Namespace}, &other); err != nil {
+			log.FromContext(ctx).Info("Could not get the conflicting Module", "name", c.Name, "namespace", c.Namespace, "error", err)
+			continue
+		}
+
+		r.recorder.Eventf(
+			&other,
+			v1.EventTypeWarning,
+			EventReasonModuleConflict,
+			"ClusterModule %s also loads kernel module %s on %d node(s)",
+			cm.Name,
+			kernelModule,
+			c.NodesNumber,
+		)
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterModuleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&kmmv1beta1.ClusterModule{}).
+		Owns(&kmmv1beta1.Module{}).
+		Watches(
+			&source.Kind{Type: &kmmv1beta1.Module{}},
+			handler.EnqueueRequestsFromMapFunc(r.filter.FindClusterModulesForModule),
+		).
+		Named("clustermodule").
+		Complete(r)
+}
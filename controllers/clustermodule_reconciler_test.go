@@ -0,0 +1,236 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	"github.com/qbarrand/oot-operator/internal/client"
+	"github.com/qbarrand/oot-operator/internal/conflicts"
+	"github.com/qbarrand/oot-operator/internal/filter"
+	"github.com/qbarrand/oot-operator/internal/statusupdater"
+	"github.com/qbarrand/oot-operator/internal/test"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("ClusterModuleReconciler", func() {
+	Describe("Reconcile", func() {
+		const (
+			clusterModuleName  = "test-cluster-module"
+			operandNamespace   = "operand-namespace"
+			kernelModuleName   = "kmod"
+			otherModuleName    = "other-module"
+			otherNamespaceName = "other-namespace"
+		)
+
+		var (
+			ctrl     *gomock.Controller
+			clnt     *client.MockClient
+			mockCD   *conflicts.MockDetector
+			mockSU   *statusupdater.MockClusterModuleStatusUpdater
+			recorder *record.FakeRecorder
+			scheme   *runtime.Scheme
+		)
+
+		BeforeEach(func() {
+			ctrl = gomock.NewController(GinkgoT())
+			clnt = client.NewMockClient(ctrl)
+			mockCD = conflicts.NewMockDetector(ctrl)
+			mockSU = statusupdater.NewMockClusterModuleStatusUpdater(ctrl)
+			recorder = record.NewFakeRecorder(10)
+
+			var err error
+
+			scheme, err = test.TestScheme()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		ctx := context.Background()
+
+		cmNSN := types.NamespacedName{Name: clusterModuleName}
+		modNSN := types.NamespacedName{Name: clusterModuleName, Namespace: operandNamespace}
+
+		req := reconcile.Request{NamespacedName: cmNSN}
+
+		cm := kmmv1beta1.ClusterModule{
+			ObjectMeta: metav1.ObjectMeta{Name: clusterModuleName, UID: "cm-uid"},
+			Spec: kmmv1beta1.ClusterModuleSpec{
+				Namespace: operandNamespace,
+				ModuleSpec: kmmv1beta1.ModuleSpec{
+					ModuleLoader: kmmv1beta1.ModuleLoaderSpec{
+						Container: kmmv1beta1.ModuleLoaderContainerSpec{
+							Modprobe: kmmv1beta1.ModprobeSpec{ModuleName: kernelModuleName},
+						},
+					},
+					Selector: map[string]string{"key": "value"},
+				},
+			},
+		}
+
+		getClusterModule := func() *gomock.Call {
+			return clnt.EXPECT().Get(gomock.Any(), cmNSN, gomock.Any()).DoAndReturn(
+				func(_ interface{}, _ interface{}, c *kmmv1beta1.ClusterModule) error {
+					cm.DeepCopyInto(c)
+					return nil
+				},
+			)
+		}
+
+		It("should do nothing if the ClusterModule is not available anymore", func() {
+			clnt.EXPECT().Get(gomock.Any(), cmNSN, gomock.Any()).Return(apierrors.NewNotFound(schema.GroupResource{}, clusterModuleName))
+
+			r := NewClusterModuleReconciler(clnt, mockCD, allNamespaces, mockSU, scheme, recorder)
+			Expect(
+				r.Reconcile(ctx, req),
+			).To(
+				Equal(reconcile.Result{}),
+			)
+		})
+
+		It("should not create a Module in a namespace that is not allowed", func() {
+			getClusterModule()
+
+			f := filter.New(nil, logr.Discard(), nil, []string{otherNamespaceName})
+
+			r := NewClusterModuleReconciler(clnt, mockCD, f, mockSU, scheme, recorder)
+			Expect(
+				r.Reconcile(ctx, req),
+			).To(
+				Equal(reconcile.Result{}),
+			)
+			Expect(recorder.Events).To(Receive(Equal("Warning " + EventReasonNamespaceNotAllowed + " Modules are not allowed in namespace " + operandNamespace)))
+		})
+
+		It("should create the Module, report new conflicts and update the status", func() {
+			conflict := kmmv1beta1.ModuleConflict{Name: otherModuleName, Namespace: otherNamespaceName, NodesNumber: 2}
+
+			gomock.InOrder(
+				getClusterModule(),
+				clnt.EXPECT().Get(gomock.Any(), modNSN, gomock.Any()).Return(apierrors.NewNotFound(schema.GroupResource{}, clusterModuleName)),
+				clnt.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(_ interface{}, m *kmmv1beta1.Module, _ ...interface{}) error {
+						Expect(m.Spec).To(Equal(cm.Spec.ModuleSpec))
+						Expect(m.OwnerReferences).To(HaveLen(1))
+						Expect(m.OwnerReferences[0].UID).To(Equal(cm.UID))
+						Expect(*m.OwnerReferences[0].Controller).To(BeTrue())
+						return nil
+					},
+				),
+				clnt.EXPECT().List(gomock.Any(), gomock.AssignableToTypeOf(&kmmv1beta1.ModuleList{})),
+				mockCD.EXPECT().FindConflicts(gomock.Any(), gomock.Any()).Return([]kmmv1beta1.ModuleConflict{conflict}, nil),
+				clnt.EXPECT().Get(gomock.Any(), types.NamespacedName{Name: otherModuleName, Namespace: otherNamespaceName}, gomock.Any()),
+				mockSU.EXPECT().ClusterModuleUpdateStatus(gomock.Any(), gomock.Any(), gomock.Any(), []kmmv1beta1.ModuleConflict{conflict}),
+			)
+
+			r := NewClusterModuleReconciler(clnt, mockCD, allNamespaces, mockSU, scheme, recorder)
+			Expect(
+				r.Reconcile(ctx, req),
+			).To(
+				Equal(reconcile.Result{}),
+			)
+
+			Expect(recorder.Events).To(Receive(Equal("Normal " + EventReasonModuleCreated + " Created Module " + operandNamespace + "/" + clusterModuleName)))
+			Expect(recorder.Events).To(Receive(Equal("Warning " + EventReasonModuleConflict + " Module " + otherNamespaceName + "/" + otherModuleName + " also loads kernel module kmod on 2 node(s)")))
+			Expect(recorder.Events).To(Receive(Equal("Warning " + EventReasonModuleConflict + " ClusterModule " + clusterModuleName + " also loads kernel module kmod on 2 node(s)")))
+		})
+
+		It("should not report conflicts that are already in the status", func() {
+			conflict := kmmv1beta1.ModuleConflict{Name: otherModuleName, Namespace: otherNamespaceName, NodesNumber: 2}
+
+			gomock.InOrder(
+				clnt.EXPECT().Get(gomock.Any(), cmNSN, gomock.Any()).DoAndReturn(
+					func(_ interface{}, _ interface{}, c *kmmv1beta1.ClusterModule) error {
+						cm.DeepCopyInto(c)
+						c.Status.Conflicts = []kmmv1beta1.ModuleConflict{conflict}
+						return nil
+					},
+				),
+				clnt.EXPECT().Get(gomock.Any(), modNSN, gomock.Any()).Return(apierrors.NewNotFound(schema.GroupResource{}, clusterModuleName)),
+				clnt.EXPECT().Create(gomock.Any(), gomock.Any()),
+				clnt.EXPECT().List(gomock.Any(), gomock.AssignableToTypeOf(&kmmv1beta1.ModuleList{})),
+				mockCD.EXPECT().FindConflicts(gomock.Any(), gomock.Any()).Return([]kmmv1beta1.ModuleConflict{conflict}, nil),
+				mockSU.EXPECT().ClusterModuleUpdateStatus(gomock.Any(), gomock.Any(), gomock.Any(), []kmmv1beta1.ModuleConflict{conflict}),
+			)
+
+			r := NewClusterModuleReconciler(clnt, mockCD, allNamespaces, mockSU, scheme, recorder)
+			Expect(
+				r.Reconcile(ctx, req),
+			).To(
+				Equal(reconcile.Result{}),
+			)
+
+			Expect(recorder.Events).To(Receive(HavePrefix("Normal " + EventReasonModuleCreated)))
+			Expect(recorder.Events).NotTo(Receive())
+		})
+
+		It("should delete its Modules in other namespaces", func() {
+			controlled := func(name, namespace string) kmmv1beta1.Module {
+				mod := kmmv1beta1.Module{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+				Expect(controllerutil.SetControllerReference(&cm, &mod, scheme)).To(Succeed())
+				return mod
+			}
+
+			stale := controlled(clusterModuleName, otherNamespaceName)
+
+			gomock.InOrder(
+				getClusterModule(),
+				clnt.EXPECT().Get(gomock.Any(), modNSN, gomock.Any()).Return(apierrors.NewNotFound(schema.GroupResource{}, clusterModuleName)),
+				clnt.EXPECT().Create(gomock.Any(), gomock.Any()),
+				clnt.EXPECT().List(gomock.Any(), gomock.AssignableToTypeOf(&kmmv1beta1.ModuleList{})).DoAndReturn(
+					func(_ interface{}, ml *kmmv1beta1.ModuleList, _ ...interface{}) error {
+						ml.Items = []kmmv1beta1.Module{
+							controlled(clusterModuleName, operandNamespace),
+							stale,
+							{ObjectMeta: metav1.ObjectMeta{Name: clusterModuleName, Namespace: "not-controlled"}},
+							controlled(otherModuleName, otherNamespaceName),
+						}
+						return nil
+					},
+				),
+				clnt.EXPECT().Delete(gomock.Any(), &stale),
+				mockCD.EXPECT().FindConflicts(gomock.Any(), gomock.Any()),
+				mockSU.EXPECT().ClusterModuleUpdateStatus(gomock.Any(), gomock.Any(), gomock.Any(), nil),
+			)
+
+			r := NewClusterModuleReconciler(clnt, mockCD, allNamespaces, mockSU, scheme, recorder)
+			Expect(
+				r.Reconcile(ctx, req),
+			).To(
+				Equal(reconcile.Result{}),
+			)
+
+			Expect(recorder.Events).To(Receive(HavePrefix("Normal " + EventReasonModuleCreated)))
+			Expect(recorder.Events).To(Receive(Equal("Normal " + EventReasonModuleDeleted + " Deleted Module " + otherNamespaceName + "/" + clusterModuleName)))
+		})
+
+		It("should not take over a Module that it does not control", func() {
+			gomock.InOrder(
+				getClusterModule(),
+				clnt.EXPECT().Get(gomock.Any(), modNSN, gomock.Any()).DoAndReturn(
+					func(_ interface{}, _ interface{}, m *kmmv1beta1.Module) error {
+						m.Name = clusterModuleName
+						m.Namespace = operandNamespace
+						m.CreationTimestamp = metav1.Now()
+						return nil
+					},
+				),
+			)
+
+			r := NewClusterModuleReconciler(clnt, mockCD, allNamespaces, mockSU, scheme, recorder)
+
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).To(HaveOccurred())
+			Expect(recorder.Events).To(Receive(Equal("Warning " + EventReasonModuleExists + " Module " + operandNamespace + "/" + clusterModuleName + " exists and is not managed by this ClusterModule")))
+		})
+	})
+})
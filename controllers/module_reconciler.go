@@ -19,14 +19,19 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
 	"github.com/qbarrand/oot-operator/internal/build"
+	"github.com/qbarrand/oot-operator/internal/conflicts"
 	"github.com/qbarrand/oot-operator/internal/daemonset"
 	"github.com/qbarrand/oot-operator/internal/filter"
 	"github.com/qbarrand/oot-operator/internal/metrics"
 	"github.com/qbarrand/oot-operator/internal/module"
+	"github.com/qbarrand/oot-operator/internal/nodemarker"
 	"github.com/qbarrand/oot-operator/internal/statusupdater"
+	"github.com/qbarrand/oot-operator/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
@@ -35,43 +40,66 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
+const (
+	EventReasonNoKernelMapping     = "NoKernelMapping"
+	EventReasonDaemonSetCreated    = "DaemonSetCreated"
+	EventReasonDaemonSetUpdated    = "DaemonSetUpdated"
+	EventReasonDaemonSetDeleted    = "DaemonSetDeleted"
+	EventReasonNamespaceNotAllowed = "NamespaceNotAllowed"
+	EventReasonParametersUpdated   = "ParametersUpdated"
+)
+
 // ModuleReconciler reconciles a Module object
 type ModuleReconciler struct {
 	client.Client
 
 	buildAPI         build.Manager
+	conflictsAPI     conflicts.Detector
 	daemonAPI        daemonset.DaemonSetCreator
 	kernelAPI        module.KernelMapper
 	metricsAPI       metrics.Metrics
 	filter           *filter.Filter
 	statusUpdaterAPI statusupdater.ModuleStatusUpdater
+	nodeMarkerAPI    nodemarker.NodeMarker
+	tracer           trace.Tracer
+	recorder         record.EventRecorder
 }
 
 func NewModuleReconciler(
 	client client.Client,
 	buildAPI build.Manager,
+	conflictsAPI conflicts.Detector,
 	daemonAPI daemonset.DaemonSetCreator,
 	kernelAPI module.KernelMapper,
 	metricsAPI metrics.Metrics,
 	filter *filter.Filter,
-	statusUpdaterAPI statusupdater.ModuleStatusUpdater) *ModuleReconciler {
+	statusUpdaterAPI statusupdater.ModuleStatusUpdater,
+	nodeMarkerAPI nodemarker.NodeMarker,
+	tracer trace.Tracer,
+	recorder record.EventRecorder) *ModuleReconciler {
 	return &ModuleReconciler{
 		Client:           client,
 		buildAPI:         buildAPI,
+		conflictsAPI:     conflictsAPI,
 		daemonAPI:        daemonAPI,
 		kernelAPI:        kernelAPI,
 		metricsAPI:       metricsAPI,
 		filter:           filter,
 		statusUpdaterAPI: statusUpdaterAPI,
+		nodeMarkerAPI:    nodeMarkerAPI,
+		tracer:           tracer,
+		recorder:         recorder,
 	}
 }
 
@@ -79,14 +107,30 @@ func NewModuleReconciler(
 //+kubebuilder:rbac:groups=kmm.sigs.k8s.io,resources=modules/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=kmm.sigs.k8s.io,resources=modules/finalizers,verbs=update
 //+kubebuilder:rbac:groups=apps,resources=daemonsets,verbs=create;delete;get;list;patch;watch
-//+kubebuilder:rbac:groups="core",resources=nodes,verbs=get;list;watch
+//+kubebuilder:rbac:groups="core",resources=nodes,verbs=get;list;patch;watch
 //+kubebuilder:rbac:groups="core",resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups="core",resources=configmaps,verbs=create;get;list;patch;watch
 //+kubebuilder:rbac:groups="batch",resources=jobs,verbs=create;list;watch
+//+kubebuilder:rbac:groups="core",resources=events,verbs=create;patch
 
 // Reconcile lists all nodes and looks for kernels that match its mappings.
 // For each mapping that matches at least one node in the cluster, it creates a DaemonSet running the container image
 // on the nodes with a compatible kernel.
 func (r *ModuleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx, span := r.tracer.Start(
+		ctx,
+		"ModuleReconciler.Reconcile",
+		trace.WithAttributes(tracing.ModuleAttributes(req.Name, req.Namespace)...),
+	)
+
+	res, err := r.reconcile(ctx, req)
+
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (r *ModuleReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	res := ctrl.Result{}
 
 	logger := log.FromContext(ctx)
@@ -95,12 +139,25 @@ func (r *ModuleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	if err != nil {
 		if k8serrors.IsNotFound(err) {
 			logger.Info("Module deleted")
+
+			r.metricsAPI.DeleteModuleSeries(req.Name, req.Namespace)
+
+			if err = r.nodeMarkerAPI.SyncUnmappedNodes(ctx, req.Name, req.Namespace, nil, sets.NewString()); err != nil {
+				return res, fmt.Errorf("could not remove unmapped node markers for %s: %w", req.NamespacedName, err)
+			}
+
 			return ctrl.Result{}, nil
 		}
 
 		return res, fmt.Errorf("failed to get the requested %s KMMO CR: %w", req.NamespacedName, err)
 	}
 
+	if !r.filter.ModuleNamespaceAllowed(mod.Namespace) {
+		logger.Info("Modules are not allowed in this namespace; skipping")
+		r.recorder.Eventf(mod, v1.EventTypeWarning, EventReasonNamespaceNotAllowed, "Modules are not allowed in namespace %s", mod.Namespace)
+		return res, nil
+	}
+
 	r.setKMMOMetrics(ctx)
 
 	targetedNodes, err := r.getNodesListBySelector(ctx, mod)
@@ -108,16 +165,45 @@ func (r *ModuleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		return res, fmt.Errorf("could get targeted nodes for module %s: %w", mod.Name, err)
 	}
 
+	moduleConflicts, yieldedNodes, err := r.conflictsAPI.FindConflictsOnNodes(ctx, mod, targetedNodes)
+	if err != nil {
+		return res, fmt.Errorf("could not look for Modules conflicting with module %s: %w", mod.Name, err)
+	}
+
+	r.recordYieldedNodes(mod, moduleConflicts)
+
+	// Another Module loads the same kernel module on the yielded nodes; this one leaves them alone.
+	targetedNodes = withoutNodes(targetedNodes, yieldedNodes)
+
 	mappings, nodesWithMapping, err := r.getRelevantKernelMappingsAndNodes(ctx, mod, targetedNodes)
 	if err != nil {
 		return res, fmt.Errorf("could get kernel mappings and nodes for modules %s: %w", mod.Name, err)
 	}
 
+	unmapped := nodeNames(targetedNodes).Difference(nodeNames(nodesWithMapping))
+
+	r.recordUnmappedNodes(mod, targetedNodes, unmapped)
+
+	if err = r.nodeMarkerAPI.SyncUnmappedNodes(ctx, mod.Name, mod.Namespace, mod.Spec.UnmappedNodes, unmapped); err != nil {
+		return res, fmt.Errorf("could not mark unmapped nodes for module %s: %w", mod.Name, err)
+	}
+
 	dsByKernelVersion, err := r.daemonAPI.ModuleDaemonSetsByKernelVersion(ctx, mod.Name, mod.Namespace)
 	if err != nil {
 		return res, fmt.Errorf("could get DaemonSets for module %s: %v", mod.Name, err)
 	}
 
+	devicePluginDSByKernelVersion, err := r.daemonAPI.DevicePluginDaemonSetsByKernelVersion(ctx, mod.Name, mod.Namespace)
+	if err != nil {
+		return res, fmt.Errorf("could get device plugin DaemonSets for module %s: %v", mod.Name, err)
+	}
+
+	if err = r.handleModuleParameters(ctx, mod); err != nil {
+		return res, fmt.Errorf("could not handle the parameters of module %s: %w", mod.Name, err)
+	}
+
+	perKernelDevicePlugin := mod.Spec.DevicePlugin != nil && mod.Spec.DevicePlugin.PerKernel
+
 	for kernelVersion, m := range mappings {
 		requeue, err := r.handleBuild(ctx, mod, m, kernelVersion)
 		if err != nil {
@@ -129,14 +215,21 @@ func (r *ModuleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 			continue
 		}
 
-		err = r.handleDriverContainer(ctx, mod, m, dsByKernelVersion, kernelVersion)
+		err = r.handleDriverContainer(ctx, mod, m, dsByKernelVersion, kernelVersion, yieldedNodes)
 		if err != nil {
 			return res, fmt.Errorf("failed to handle driver container for kernel version %s: %v", kernelVersion, err)
 		}
+
+		if perKernelDevicePlugin {
+			err = r.handleKernelDevicePlugin(ctx, mod, m, devicePluginDSByKernelVersion, kernelVersion)
+			if err != nil {
+				return res, fmt.Errorf("failed to handle device plugin for kernel version %s: %v", kernelVersion, err)
+			}
+		}
 	}
 
 	logger.Info("Handle device plugin")
-	err = r.handleDevicePlugin(ctx, mod)
+	err = r.handleDevicePlugin(ctx, mod, dsByKernelVersion)
 	if err != nil {
 		return res, fmt.Errorf("could handle device plugin: %w", err)
 	}
@@ -151,7 +244,43 @@ func (r *ModuleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		return res, fmt.Errorf("could not garbage collect DaemonSets: %v", err)
 	}
 
-	err = r.statusUpdaterAPI.ModuleUpdateStatus(ctx, mod, nodesWithMapping, targetedNodes, dsByKernelVersion)
+	for _, name := range deleted {
+		r.recorder.Eventf(mod, v1.EventTypeNormal, EventReasonDaemonSetDeleted, "Deleted DaemonSet %s as no node runs its kernel anymore", name)
+	}
+
+	validDevicePluginKernels := sets.NewString()
+	if perKernelDevicePlugin {
+		validDevicePluginKernels = validKernels
+	}
+
+	if len(devicePluginDSByKernelVersion) > 0 {
+		deletedDevicePlugins, err := r.daemonAPI.GarbageCollect(ctx, devicePluginDSByKernelVersion, validDevicePluginKernels)
+		if err != nil {
+			return res, fmt.Errorf("could not garbage collect device plugin DaemonSets: %v", err)
+		}
+
+		for _, name := range deletedDevicePlugins {
+			r.recorder.Eventf(mod, v1.EventTypeNormal, EventReasonDaemonSetDeleted, "Deleted per-kernel device plugin DaemonSet %s", name)
+		}
+
+		deleted = append(deleted, deletedDevicePlugins...)
+
+		for kernelVersion := range devicePluginDSByKernelVersion {
+			if !validDevicePluginKernels.Has(kernelVersion) {
+				delete(devicePluginDSByKernelVersion, kernelVersion)
+			}
+		}
+	}
+
+	// Do not report garbage-collected DaemonSets in the status, and drop the metrics of their kernel.
+	for kernelVersion := range dsByKernelVersion {
+		if !daemonset.IsDevicePluginKernelVersion(kernelVersion) && !validKernels.Has(kernelVersion) {
+			delete(dsByKernelVersion, kernelVersion)
+			r.metricsAPI.DeleteKernelSeries(mod.Name, mod.Namespace, kernelVersion)
+		}
+	}
+
+	err = r.statusUpdaterAPI.ModuleUpdateStatus(ctx, mod, nodesWithMapping, targetedNodes, dsByKernelVersion, devicePluginDSByKernelVersion, moduleConflicts)
 	if err != nil {
 		return res, fmt.Errorf("failed to update status of the module: %w", err)
 	}
@@ -161,6 +290,94 @@ func (r *ModuleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	return res, nil
 }
 
+// recordYieldedNodes emits an event on mod for each Module created first that it did not yield nodes to yet.
+func (r *ModuleReconciler) recordYieldedNodes(mod *kmmv1beta1.Module, moduleConflicts []kmmv1beta1.ModuleConflict) {
+	known := make(map[kmmv1beta1.ModuleConflict]bool, len(mod.Status.Conflicts))
+
+	for _, c := range mod.Status.Conflicts {
+		known[c] = true
+	}
+
+	for _, c := range moduleConflicts {
+		if !c.Yielded || known[c] {
+			continue
+		}
+
+		r.recorder.Eventf(
+			mod,
+			v1.EventTypeWarning,
+			EventReasonModuleConflict,
+			"Not loading kernel module %s on %d node(s) already targeted by Module %s/%s",
+			mod.Spec.ModuleLoader.Container.Modprobe.ModuleName,
+			c.NodesNumber,
+			c.Namespace,
+			c.Name,
+		)
+	}
+}
+
+// recordUnmappedNodes emits one event on mod listing the kernels of the unmapped nodes, if they are not the ones already
+// reported in its status.
+func (r *ModuleReconciler) recordUnmappedNodes(mod *kmmv1beta1.Module, targetedNodes []v1.Node, unmapped sets.String) {
+	if unmapped.Len() == 0 {
+		return
+	}
+
+	known := sets.NewString()
+
+	for _, n := range mod.Status.UnmappedNodes {
+		known.Insert(n.Name)
+	}
+
+	if known.Equal(unmapped) {
+		return
+	}
+
+	kernels := sets.NewString()
+
+	for _, n := range targetedNodes {
+		if unmapped.Has(n.Name) {
+			kernels.Insert(n.Status.NodeInfo.KernelVersion)
+		}
+	}
+
+	r.recorder.Eventf(
+		mod,
+		v1.EventTypeWarning,
+		EventReasonNoKernelMapping,
+		"No kernel mapping found for %d node(s) running kernel(s) %s",
+		unmapped.Len(),
+		strings.Join(kernels.List(), ", "),
+	)
+}
+
+// withoutNodes returns the nodes whose name is not in excluded.
+func withoutNodes(nodes []v1.Node, excluded sets.String) []v1.Node {
+	if excluded.Len() == 0 {
+		return nodes
+	}
+
+	kept := make([]v1.Node, 0, len(nodes))
+
+	for _, n := range nodes {
+		if !excluded.Has(n.Name) {
+			kept = append(kept, n)
+		}
+	}
+
+	return kept
+}
+
+func nodeNames(nodes []v1.Node) sets.String {
+	names := sets.NewString()
+
+	for _, n := range nodes {
+		names.Insert(n.Name)
+	}
+
+	return names
+}
+
 func (r *ModuleReconciler) getRelevantKernelMappingsAndNodes(ctx context.Context,
 	mod *kmmv1beta1.Module,
 	targetedNodes []v1.Node) (map[string]*kmmv1beta1.KernelMapping, []v1.Node, error) {
@@ -191,6 +408,11 @@ func (r *ModuleReconciler) getRelevantKernelMappingsAndNodes(ctx context.Context
 			continue
 		}
 
+		if dp := mod.Spec.DevicePlugin; dp != nil && dp.PerKernel && m.DevicePluginImage == "" {
+			m = m.DeepCopy()
+			m.DevicePluginImage = dp.Container.Image
+		}
+
 		m, err = r.kernelAPI.PrepareKernelMapping(m, osConfig)
 		if err != nil {
 			nodes = append(nodes, node)
@@ -211,10 +433,15 @@ func (r *ModuleReconciler) getRelevantKernelMappingsAndNodes(ctx context.Context
 
 func (r *ModuleReconciler) getNodesListBySelector(ctx context.Context, mod *kmmv1beta1.Module) ([]v1.Node, error) {
 	logger := log.FromContext(ctx)
-	logger.V(1).Info("Listing nodes", "selector", mod.Spec.Selector)
+	sel, err := module.NodeSelector(&mod.Spec)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.V(1).Info("Listing nodes", "selector", sel.String())
 
 	nodes := v1.NodeList{}
-	opt := client.MatchingLabels(mod.Spec.Selector)
+	opt := client.MatchingLabelsSelector{Selector: sel}
 	if err := r.Client.List(ctx, &nodes, opt); err != nil {
 		logger.Error(err, "Could not list nodes")
 		return nil, fmt.Errorf("could not list nodes: %v", err)
@@ -222,19 +449,35 @@ func (r *ModuleReconciler) getNodesListBySelector(ctx context.Context, mod *kmmv
 	return nodes.Items, nil
 }
 
+// startKernelSpan starts a span for an operation on the kernel mapping km of mod.
+func (r *ModuleReconciler) startKernelSpan(ctx context.Context, name string, mod *kmmv1beta1.Module, km *kmmv1beta1.KernelMapping, kernelVersion string) (context.Context, trace.Span) {
+	attrs := append(
+		tracing.ModuleAttributes(mod.Name, mod.Namespace),
+		tracing.KernelVersionKey.String(kernelVersion),
+		tracing.ImageKey.String(km.ContainerImage),
+	)
+
+	return r.tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
 func (r *ModuleReconciler) handleBuild(ctx context.Context,
 	mod *kmmv1beta1.Module,
 	km *kmmv1beta1.KernelMapping,
-	kernelVersion string) (bool, error) {
+	kernelVersion string) (requeue bool, err error) {
 	if mod.Spec.ModuleLoader.Container.Build == nil && km.Build == nil {
 		return false, nil
 	}
 
+	ctx, span := r.startKernelSpan(ctx, "ModuleReconciler.handleBuild", mod, km, kernelVersion)
+	defer func() {
+		tracing.End(span, err)
+	}()
+
 	// [TODO] check access to the image - execute build only if needed (image is inaccessible)
 	logger := log.FromContext(ctx).WithValues("kernel version", kernelVersion, "image", km.ContainerImage)
 	buildCtx := log.IntoContext(ctx, logger)
 
-	buildRes, err := r.buildAPI.Sync(buildCtx, *mod, *km, kernelVersion)
+	buildRes, err := r.buildAPI.Sync(buildCtx, *mod, *km, kernelVersion, true)
 	if err != nil {
 		return false, fmt.Errorf("could not synchronize the build: %w", err)
 	}
@@ -249,11 +492,43 @@ func (r *ModuleReconciler) handleBuild(ctx context.Context,
 	return buildRes.Requeue, nil
 }
 
+// handleModuleParameters writes the kernel module parameters of mod into the ConfigMap mounted in the module loader
+// pods, which apply their changes without being recreated.
+func (r *ModuleReconciler) handleModuleParameters(ctx context.Context, mod *kmmv1beta1.Module) error {
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      daemonset.ModuleParametersConfigMapName(mod.Name),
+			Namespace: mod.Namespace,
+		},
+	}
+
+	opRes, err := controllerutil.CreateOrPatch(ctx, r.Client, cm, func() error {
+		return r.daemonAPI.SetModuleParametersAsDesired(cm, mod)
+	})
+	if err != nil {
+		return fmt.Errorf("could not create or patch ConfigMap %s: %w", cm.Name, err)
+	}
+
+	if opRes == controllerutil.OperationResultUpdated {
+		r.recorder.Eventf(mod, v1.EventTypeNormal, EventReasonParametersUpdated, "Updated the kernel module parameters in ConfigMap %s", cm.Name)
+	}
+
+	log.FromContext(ctx).Info("Reconciled module parameters", "name", cm.Name, "result", opRes)
+
+	return nil
+}
+
 func (r *ModuleReconciler) handleDriverContainer(ctx context.Context,
 	mod *kmmv1beta1.Module,
 	km *kmmv1beta1.KernelMapping,
 	dsByKernelVersion map[string]*appsv1.DaemonSet,
-	kernelVersion string) error {
+	kernelVersion string,
+	excludedNodes sets.String) (err error) {
+	ctx, span := r.startKernelSpan(ctx, "ModuleReconciler.handleDriverContainer", mod, km, kernelVersion)
+	defer func() {
+		tracing.End(span, err)
+	}()
+
 	ds := &appsv1.DaemonSet{
 		ObjectMeta: metav1.ObjectMeta{Namespace: mod.Namespace},
 	}
@@ -268,25 +543,46 @@ func (r *ModuleReconciler) handleDriverContainer(ctx context.Context,
 	}
 
 	opRes, err := controllerutil.CreateOrPatch(ctx, r.Client, ds, func() error {
-		return r.daemonAPI.SetDriverContainerAsDesired(ctx, ds, km.ContainerImage, *mod, kernelVersion)
+		return r.daemonAPI.SetDriverContainerAsDesired(ctx, ds, km.ContainerImage, *mod, kernelVersion, excludedNodes)
 	})
 
 	if err == nil {
 		if opRes == controllerutil.OperationResultCreated {
 			r.metricsAPI.SetCompletedStage(mod.Name, mod.Namespace, kernelVersion, metrics.ModuleLoaderStage, false)
 		}
+		r.recordDaemonSetEvent(mod, ds, opRes, "module loader for kernel "+kernelVersion)
 		logger.Info("Reconciled Driver Container", "name", ds.Name, "result", opRes)
 	}
 
 	return err
 }
 
-func (r *ModuleReconciler) handleDevicePlugin(ctx context.Context, mod *kmmv1beta1.Module) error {
+// handleDevicePlugin reconciles the device plugin DaemonSet shared by all kernels.
+// It is deleted if the device plugin is deployed per kernel.
+func (r *ModuleReconciler) handleDevicePlugin(ctx context.Context, mod *kmmv1beta1.Module, dsByKernelVersion map[string]*appsv1.DaemonSet) error {
 	if mod.Spec.DevicePlugin == nil {
 		return nil
 	}
 
 	logger := log.FromContext(ctx)
+
+	if mod.Spec.DevicePlugin.PerKernel {
+		ds := dsByKernelVersion[daemonset.GetDevicePluginKernelVersion()]
+		if ds == nil {
+			return nil
+		}
+
+		if err := r.Client.Delete(ctx, ds); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("could not delete the device plugin DaemonSet %s: %w", ds.Name, err)
+		}
+
+		delete(dsByKernelVersion, daemonset.GetDevicePluginKernelVersion())
+		r.recorder.Eventf(mod, v1.EventTypeNormal, EventReasonDaemonSetDeleted, "Deleted device plugin DaemonSet %s as the device plugin is deployed per kernel", ds.Name)
+		logger.Info("Deleted the shared device plugin DaemonSet", "name", ds.Name)
+
+		return nil
+	}
+
 	ds := &appsv1.DaemonSet{
 		ObjectMeta: metav1.ObjectMeta{Namespace: mod.Namespace},
 	}
@@ -298,19 +594,69 @@ func (r *ModuleReconciler) handleDevicePlugin(ctx context.Context, mod *kmmv1bet
 	}
 
 	opRes, err := controllerutil.CreateOrPatch(ctx, r.Client, ds, func() error {
-		return r.daemonAPI.SetDevicePluginAsDesired(ctx, ds, mod)
+		return r.daemonAPI.SetDevicePluginAsDesired(ctx, ds, mod, mod.Spec.DevicePlugin.Container.Image, daemonset.GetDevicePluginKernelVersion())
 	})
 
 	if err == nil {
 		if opRes == controllerutil.OperationResultCreated {
 			r.metricsAPI.SetCompletedStage(mod.Name, mod.Namespace, "", metrics.DevicePluginStage, false)
 		}
+		r.recordDaemonSetEvent(mod, ds, opRes, "device plugin")
 		logger.Info("Reconciled Device Plugin", "name", ds.Name, "result", opRes)
 	}
 
 	return err
 }
 
+// handleKernelDevicePlugin reconciles the device plugin DaemonSet for kernelVersion, running the device plugin image
+// of km.
+func (r *ModuleReconciler) handleKernelDevicePlugin(ctx context.Context,
+	mod *kmmv1beta1.Module,
+	km *kmmv1beta1.KernelMapping,
+	devicePluginDSByKernelVersion map[string]*appsv1.DaemonSet,
+	kernelVersion string) (err error) {
+	ctx, span := r.startKernelSpan(ctx, "ModuleReconciler.handleKernelDevicePlugin", mod, km, kernelVersion)
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	logger := log.FromContext(ctx)
+
+	ds := devicePluginDSByKernelVersion[kernelVersion]
+	if ds == nil {
+		ds = &appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: mod.Name + "-device-plugin-",
+				Namespace:    mod.Namespace,
+			},
+		}
+	}
+
+	opRes, err := controllerutil.CreateOrPatch(ctx, r.Client, ds, func() error {
+		return r.daemonAPI.SetDevicePluginAsDesired(ctx, ds, mod, km.DevicePluginImage, kernelVersion)
+	})
+
+	if err == nil {
+		if opRes == controllerutil.OperationResultCreated {
+			r.metricsAPI.SetCompletedStage(mod.Name, mod.Namespace, kernelVersion, metrics.DevicePluginStage, false)
+		}
+		r.recordDaemonSetEvent(mod, ds, opRes, "device plugin for kernel "+kernelVersion)
+		logger.Info("Reconciled Device Plugin", "name", ds.Name, "kernel version", kernelVersion, "result", opRes)
+	}
+
+	return err
+}
+
+// recordDaemonSetEvent emits an event on mod if ds was created or updated.
+func (r *ModuleReconciler) recordDaemonSetEvent(mod *kmmv1beta1.Module, ds *appsv1.DaemonSet, opRes controllerutil.OperationResult, role string) {
+	switch opRes {
+	case controllerutil.OperationResultCreated:
+		r.recorder.Eventf(mod, v1.EventTypeNormal, EventReasonDaemonSetCreated, "Created %s DaemonSet %s", role, ds.Name)
+	case controllerutil.OperationResultUpdated, controllerutil.OperationResultUpdatedStatus:
+		r.recorder.Eventf(mod, v1.EventTypeNormal, EventReasonDaemonSetUpdated, "Updated %s DaemonSet %s", role, ds.Name)
+	}
+}
+
 func (r *ModuleReconciler) setKMMOMetrics(ctx context.Context) {
 	logger := log.FromContext(ctx)
 
@@ -338,6 +684,12 @@ func (r *ModuleReconciler) SetupWithManager(mgr ctrl.Manager, kernelLabel string
 		For(&kmmv1beta1.Module{}).
 		Owns(&appsv1.DaemonSet{}).
 		Owns(&batchv1.Job{}).
+		Owns(&v1.ConfigMap{}).
+		Watches(
+			&source.Kind{Type: &kmmv1beta1.Module{}},
+			handler.EnqueueRequestsFromMapFunc(r.filter.FindModulesLoadingSameKernelModule),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+		).
 		Watches(
 			&source.Kind{Type: &v1.Node{}},
 			handler.EnqueueRequestsFromMapFunc(r.filter.FindModulesForNode),
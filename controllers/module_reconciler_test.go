@@ -2,17 +2,24 @@ package controllers
 
 import (
 	"context"
+	"errors"
 
+	"github.com/go-logr/logr"
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
 	"github.com/qbarrand/oot-operator/internal/build"
 	"github.com/qbarrand/oot-operator/internal/client"
+	"github.com/qbarrand/oot-operator/internal/conflicts"
 	"github.com/qbarrand/oot-operator/internal/daemonset"
+	"github.com/qbarrand/oot-operator/internal/filter"
 	"github.com/qbarrand/oot-operator/internal/metrics"
 	"github.com/qbarrand/oot-operator/internal/module"
+	"github.com/qbarrand/oot-operator/internal/nodemarker"
 	"github.com/qbarrand/oot-operator/internal/statusupdater"
+	"github.com/qbarrand/oot-operator/internal/test"
+	"github.com/qbarrand/oot-operator/internal/tracing"
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -20,6 +27,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -27,26 +35,34 @@ const (
 	namespace = "namespace"
 )
 
+var allNamespaces = filter.New(nil, logr.Discard(), nil, nil)
+
 var _ = Describe("ModuleReconciler", func() {
 	Describe("Reconcile", func() {
 		var (
 			ctrl        *gomock.Controller
 			clnt        *client.MockClient
 			mockBM      *build.MockManager
+			mockCD      *conflicts.MockDetector
 			mockDC      *daemonset.MockDaemonSetCreator
 			mockKM      *module.MockKernelMapper
 			mockMetrics *metrics.MockMetrics
 			mockSU      *statusupdater.MockModuleStatusUpdater
+			mockNM      *nodemarker.MockNodeMarker
+			recorder    *record.FakeRecorder
 		)
 
 		BeforeEach(func() {
 			ctrl = gomock.NewController(GinkgoT())
 			clnt = client.NewMockClient(ctrl)
 			mockBM = build.NewMockManager(ctrl)
+			mockCD = conflicts.NewMockDetector(ctrl)
 			mockDC = daemonset.NewMockDaemonSetCreator(ctrl)
 			mockKM = module.NewMockKernelMapper(ctrl)
 			mockMetrics = metrics.NewMockMetrics(ctrl)
 			mockSU = statusupdater.NewMockModuleStatusUpdater(ctrl)
+			mockNM = nodemarker.NewMockNodeMarker(ctrl)
+			recorder = record.NewFakeRecorder(10)
 		})
 
 		const moduleName = "test-module"
@@ -58,22 +74,51 @@ var _ = Describe("ModuleReconciler", func() {
 
 		req := reconcile.Request{NamespacedName: nsn}
 
+		parametersNSN := types.NamespacedName{
+			Name:      moduleName + "-module-parameters",
+			Namespace: namespace,
+		}
+
 		ctx := context.Background()
 
-		It("should do nothing if the Module is not available anymore", func() {
-			clnt.
-				EXPECT().
-				Get(ctx, nsn, &kmmv1beta1.Module{}).
-				Return(
-					apierrors.NewNotFound(schema.GroupResource{}, moduleName),
-				)
+		It("should only clean up unmapped nodes if the Module is not available anymore", func() {
+			gomock.InOrder(
+				clnt.
+					EXPECT().
+					Get(gomock.Any(), nsn, &kmmv1beta1.Module{}).
+					Return(
+						apierrors.NewNotFound(schema.GroupResource{}, moduleName),
+					),
+				mockMetrics.EXPECT().DeleteModuleSeries(moduleName, namespace),
+				mockNM.EXPECT().SyncUnmappedNodes(gomock.Any(), moduleName, namespace, nil, sets.NewString()),
+			)
+
+			mr := NewModuleReconciler(clnt, mockBM, mockCD, mockDC, mockKM, mockMetrics, allNamespaces, mockSU, mockNM, test.NoopTracer(), recorder)
+			Expect(
+				mr.Reconcile(ctx, req),
+			).To(
+				Equal(reconcile.Result{}),
+			)
+		})
+
+		It("should not reconcile Modules in namespaces that are not allowed", func() {
+			clnt.EXPECT().Get(gomock.Any(), nsn, gomock.Any()).DoAndReturn(
+				func(_ interface{}, _ interface{}, m *kmmv1beta1.Module) error {
+					m.Name = moduleName
+					m.Namespace = namespace
+					return nil
+				},
+			)
 
-			mr := NewModuleReconciler(clnt, mockBM, mockDC, mockKM, mockMetrics, nil, mockSU)
+			f := filter.New(nil, logr.Discard(), nil, []string{"other-namespace"})
+
+			mr := NewModuleReconciler(clnt, mockBM, mockCD, mockDC, mockKM, mockMetrics, f, mockSU, mockNM, test.NoopTracer(), recorder)
 			Expect(
 				mr.Reconcile(ctx, req),
 			).To(
 				Equal(reconcile.Result{}),
 			)
+			Expect(recorder.Events).To(Receive(Equal("Warning " + EventReasonNamespaceNotAllowed + " Modules are not allowed in namespace " + namespace)))
 		})
 
 		It("should do nothing when no nodes match the selector", func() {
@@ -88,36 +133,42 @@ var _ = Describe("ModuleReconciler", func() {
 			}
 
 			gomock.InOrder(
-				clnt.EXPECT().Get(ctx, req.NamespacedName, gomock.Any()).DoAndReturn(
+				clnt.EXPECT().Get(gomock.Any(), req.NamespacedName, gomock.Any()).DoAndReturn(
 					func(_ interface{}, _ interface{}, m *kmmv1beta1.Module) error {
 						m.ObjectMeta = mod.ObjectMeta
 						m.Spec = mod.Spec
 						return nil
 					},
 				),
-				clnt.EXPECT().List(ctx, gomock.Any(), gomock.Any()).DoAndReturn(
+				clnt.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
 					func(_ interface{}, list *kmmv1beta1.ModuleList, _ ...interface{}) error {
 						list.Items = []kmmv1beta1.Module{mod}
 						return nil
 					},
 				),
 				mockMetrics.EXPECT().SetExistingKMMOModules(1),
-				clnt.EXPECT().List(ctx, gomock.Any(), gomock.Any()).DoAndReturn(
+				clnt.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
 					func(_ interface{}, list *v1.NodeList, _ ...interface{}) error {
 						list.Items = []v1.Node{}
 						return nil
 					},
 				),
+				mockCD.EXPECT().FindConflictsOnNodes(gomock.Any(), &mod, gomock.Any()).Return(nil, sets.NewString(), nil),
 			)
 
-			mr := NewModuleReconciler(clnt, mockBM, mockDC, mockKM, mockMetrics, nil, mockSU)
+			mr := NewModuleReconciler(clnt, mockBM, mockCD, mockDC, mockKM, mockMetrics, allNamespaces, mockSU, mockNM, test.NoopTracer(), recorder)
 
 			dsByKernelVersion := make(map[string]*appsv1.DaemonSet)
 
 			gomock.InOrder(
-				mockDC.EXPECT().ModuleDaemonSetsByKernelVersion(ctx, moduleName, namespace).Return(dsByKernelVersion, nil),
-				mockDC.EXPECT().GarbageCollect(ctx, dsByKernelVersion, sets.NewString()),
-				mockSU.EXPECT().ModuleUpdateStatus(ctx, &mod, []v1.Node{}, []v1.Node{}, dsByKernelVersion).Return(nil),
+				mockNM.EXPECT().SyncUnmappedNodes(gomock.Any(), moduleName, namespace, nil, sets.NewString()),
+				mockDC.EXPECT().ModuleDaemonSetsByKernelVersion(gomock.Any(), moduleName, namespace).Return(dsByKernelVersion, nil),
+				mockDC.EXPECT().DevicePluginDaemonSetsByKernelVersion(gomock.Any(), moduleName, namespace),
+				clnt.EXPECT().Get(gomock.Any(), parametersNSN, gomock.AssignableToTypeOf(&v1.ConfigMap{})).Return(apierrors.NewNotFound(schema.GroupResource{}, parametersNSN.Name)),
+				mockDC.EXPECT().SetModuleParametersAsDesired(gomock.AssignableToTypeOf(&v1.ConfigMap{}), gomock.Any()),
+				clnt.EXPECT().Create(gomock.Any(), gomock.AssignableToTypeOf(&v1.ConfigMap{})),
+				mockDC.EXPECT().GarbageCollect(gomock.Any(), dsByKernelVersion, sets.NewString()),
+				mockSU.EXPECT().ModuleUpdateStatus(gomock.Any(), &mod, []v1.Node{}, []v1.Node{}, dsByKernelVersion, nil, nil).Return(nil),
 			)
 
 			res, err := mr.Reconcile(context.Background(), req)
@@ -125,6 +176,72 @@ var _ = Describe("ModuleReconciler", func() {
 			Expect(res).To(Equal(reconcile.Result{}))
 		})
 
+		It("should record an event when the kernel module parameters are updated", func() {
+			mod := kmmv1beta1.Module{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      moduleName,
+					Namespace: namespace,
+				},
+				Spec: kmmv1beta1.ModuleSpec{
+					Selector: map[string]string{"key": "value"},
+				},
+			}
+
+			gomock.InOrder(
+				clnt.EXPECT().Get(gomock.Any(), req.NamespacedName, gomock.Any()).DoAndReturn(
+					func(_ interface{}, _ interface{}, m *kmmv1beta1.Module) error {
+						m.ObjectMeta = mod.ObjectMeta
+						m.Spec = mod.Spec
+						return nil
+					},
+				),
+				clnt.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+					func(_ interface{}, list *kmmv1beta1.ModuleList, _ ...interface{}) error {
+						list.Items = []kmmv1beta1.Module{mod}
+						return nil
+					},
+				),
+				mockMetrics.EXPECT().SetExistingKMMOModules(1),
+				clnt.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+					func(_ interface{}, list *v1.NodeList, _ ...interface{}) error {
+						list.Items = []v1.Node{}
+						return nil
+					},
+				),
+				mockCD.EXPECT().FindConflictsOnNodes(gomock.Any(), &mod, gomock.Any()).Return(nil, sets.NewString(), nil),
+			)
+
+			mr := NewModuleReconciler(clnt, mockBM, mockCD, mockDC, mockKM, mockMetrics, allNamespaces, mockSU, mockNM, test.NoopTracer(), recorder)
+
+			dsByKernelVersion := make(map[string]*appsv1.DaemonSet)
+
+			gomock.InOrder(
+				mockNM.EXPECT().SyncUnmappedNodes(gomock.Any(), moduleName, namespace, nil, sets.NewString()),
+				mockDC.EXPECT().ModuleDaemonSetsByKernelVersion(gomock.Any(), moduleName, namespace).Return(dsByKernelVersion, nil),
+				mockDC.EXPECT().DevicePluginDaemonSetsByKernelVersion(gomock.Any(), moduleName, namespace),
+				clnt.EXPECT().Get(gomock.Any(), parametersNSN, gomock.AssignableToTypeOf(&v1.ConfigMap{})).DoAndReturn(
+					func(_ interface{}, _ interface{}, cm *v1.ConfigMap) error {
+						cm.Name = parametersNSN.Name
+						cm.Namespace = parametersNSN.Namespace
+						cm.Data = map[string]string{"parameters": "a=1"}
+						return nil
+					},
+				),
+				mockDC.EXPECT().SetModuleParametersAsDesired(gomock.AssignableToTypeOf(&v1.ConfigMap{}), gomock.Any()).Do(
+					func(cm *v1.ConfigMap, _ *kmmv1beta1.Module) {
+						cm.Data = map[string]string{"parameters": "a=2"}
+					},
+				),
+				clnt.EXPECT().Patch(gomock.Any(), gomock.AssignableToTypeOf(&v1.ConfigMap{}), gomock.Any()),
+				mockDC.EXPECT().GarbageCollect(gomock.Any(), dsByKernelVersion, sets.NewString()),
+				mockSU.EXPECT().ModuleUpdateStatus(gomock.Any(), &mod, []v1.Node{}, []v1.Node{}, dsByKernelVersion, nil, nil).Return(nil),
+			)
+
+			_, err := mr.Reconcile(context.Background(), req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.Events).To(Receive(Equal("Normal " + EventReasonParametersUpdated + " Updated the kernel module parameters in ConfigMap " + parametersNSN.Name)))
+		})
+
 		It("should remove obsolete DaemonSets when no nodes match the selector", func() {
 			const kernelVersion = "1.2.3"
 
@@ -146,36 +263,44 @@ var _ = Describe("ModuleReconciler", func() {
 			}
 
 			gomock.InOrder(
-				clnt.EXPECT().Get(ctx, req.NamespacedName, gomock.Any()).DoAndReturn(
+				clnt.EXPECT().Get(gomock.Any(), req.NamespacedName, gomock.Any()).DoAndReturn(
 					func(_ interface{}, _ interface{}, m *kmmv1beta1.Module) error {
 						m.ObjectMeta = mod.ObjectMeta
 						m.Spec = mod.Spec
 						return nil
 					},
 				),
-				clnt.EXPECT().List(ctx, gomock.Any(), gomock.Any()).DoAndReturn(
+				clnt.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
 					func(_ interface{}, list *kmmv1beta1.ModuleList, _ ...interface{}) error {
 						list.Items = []kmmv1beta1.Module{mod}
 						return nil
 					},
 				),
 				mockMetrics.EXPECT().SetExistingKMMOModules(1),
-				clnt.EXPECT().List(ctx, gomock.Any(), gomock.Any()).DoAndReturn(
+				clnt.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
 					func(_ interface{}, list *v1.NodeList, _ ...interface{}) error {
 						list.Items = []v1.Node{}
 						return nil
 					},
 				),
+				mockCD.EXPECT().FindConflictsOnNodes(gomock.Any(), &mod, gomock.Any()).Return(nil, sets.NewString(), nil),
 			)
 
-			mr := NewModuleReconciler(clnt, mockBM, mockDC, mockKM, mockMetrics, nil, mockSU)
+			mr := NewModuleReconciler(clnt, mockBM, mockCD, mockDC, mockKM, mockMetrics, allNamespaces, mockSU, mockNM, test.NoopTracer(), recorder)
 
 			dsByKernelVersion := map[string]*appsv1.DaemonSet{kernelVersion: &ds}
 
 			gomock.InOrder(
-				mockDC.EXPECT().ModuleDaemonSetsByKernelVersion(ctx, moduleName, namespace).Return(dsByKernelVersion, nil),
-				mockDC.EXPECT().GarbageCollect(ctx, dsByKernelVersion, sets.NewString()),
-				mockSU.EXPECT().ModuleUpdateStatus(ctx, &mod, []v1.Node{}, []v1.Node{}, dsByKernelVersion).Return(nil),
+				mockNM.EXPECT().SyncUnmappedNodes(gomock.Any(), moduleName, namespace, nil, sets.NewString()),
+				mockDC.EXPECT().ModuleDaemonSetsByKernelVersion(gomock.Any(), moduleName, namespace).Return(dsByKernelVersion, nil),
+				mockDC.EXPECT().DevicePluginDaemonSetsByKernelVersion(gomock.Any(), moduleName, namespace),
+				clnt.EXPECT().Get(gomock.Any(), parametersNSN, gomock.AssignableToTypeOf(&v1.ConfigMap{})).Return(apierrors.NewNotFound(schema.GroupResource{}, parametersNSN.Name)),
+				mockDC.EXPECT().SetModuleParametersAsDesired(gomock.AssignableToTypeOf(&v1.ConfigMap{}), gomock.Any()),
+				clnt.EXPECT().Create(gomock.Any(), gomock.AssignableToTypeOf(&v1.ConfigMap{})),
+				mockDC.EXPECT().GarbageCollect(gomock.Any(), dsByKernelVersion, sets.NewString()),
+				mockMetrics.EXPECT().DeleteKernelSeries(moduleName, namespace, kernelVersion),
+				// The garbage-collected DaemonSet is not reported in the status anymore
+				mockSU.EXPECT().ModuleUpdateStatus(gomock.Any(), &mod, []v1.Node{}, []v1.Node{}, map[string]*appsv1.DaemonSet{}, nil, nil).Return(nil),
 			)
 
 			res, err := mr.Reconcile(context.Background(), req)
@@ -229,7 +354,9 @@ var _ = Describe("ModuleReconciler", func() {
 
 			dsByKernelVersion := make(map[string]*appsv1.DaemonSet)
 
-			mr := NewModuleReconciler(clnt, mockBM, mockDC, mockKM, mockMetrics, nil, mockSU)
+			tracer, spanRecorder := test.TestTracer()
+
+			mr := NewModuleReconciler(clnt, mockBM, mockCD, mockDC, mockKM, mockMetrics, allNamespaces, mockSU, mockNM, tracer, recorder)
 
 			ds := appsv1.DaemonSet{
 				ObjectMeta: metav1.ObjectMeta{
@@ -239,40 +366,148 @@ var _ = Describe("ModuleReconciler", func() {
 			}
 
 			gomock.InOrder(
-				clnt.EXPECT().Get(ctx, req.NamespacedName, gomock.Any()).DoAndReturn(
+				clnt.EXPECT().Get(gomock.Any(), req.NamespacedName, gomock.Any()).DoAndReturn(
 					func(_ interface{}, _ interface{}, m *kmmv1beta1.Module) error {
 						m.ObjectMeta = mod.ObjectMeta
 						m.Spec = mod.Spec
 						return nil
 					},
 				),
-				clnt.EXPECT().List(ctx, gomock.Any(), gomock.Any()).DoAndReturn(
+				clnt.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
 					func(_ interface{}, list *kmmv1beta1.ModuleList, _ ...interface{}) error {
 						return nil
 					},
 				),
 				mockMetrics.EXPECT().SetExistingKMMOModules(0),
-				clnt.EXPECT().List(ctx, gomock.Any(), gomock.Any()).DoAndReturn(
+				clnt.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
 					func(_ interface{}, list *v1.NodeList, _ ...interface{}) error {
 						list.Items = nodeList.Items
 						return nil
 					},
 				),
+				mockCD.EXPECT().FindConflictsOnNodes(gomock.Any(), &mod, gomock.Any()).Return(nil, sets.NewString(), nil),
 				mockKM.EXPECT().GetNodeOSConfig(&nodeList.Items[0]).Return(&osConfig),
 				mockKM.EXPECT().FindMappingForKernel(mappings, kernelVersion).Return(&mappings[0], nil),
 				mockKM.EXPECT().PrepareKernelMapping(&mappings[0], &osConfig).Return(&mappings[0], nil),
-				mockDC.EXPECT().ModuleDaemonSetsByKernelVersion(ctx, moduleName, namespace).Return(dsByKernelVersion, nil),
-				clnt.EXPECT().Get(ctx, gomock.Any(), gomock.Any()).Return(apierrors.NewNotFound(schema.GroupResource{}, "whatever")),
-				mockDC.EXPECT().SetDriverContainerAsDesired(context.Background(), &ds, imageName, gomock.AssignableToTypeOf(mod), kernelVersion),
-				clnt.EXPECT().Create(ctx, gomock.Any()).Return(nil),
+				mockNM.EXPECT().SyncUnmappedNodes(gomock.Any(), moduleName, namespace, nil, sets.NewString()),
+				mockDC.EXPECT().ModuleDaemonSetsByKernelVersion(gomock.Any(), moduleName, namespace).Return(dsByKernelVersion, nil),
+				mockDC.EXPECT().DevicePluginDaemonSetsByKernelVersion(gomock.Any(), moduleName, namespace),
+				clnt.EXPECT().Get(gomock.Any(), parametersNSN, gomock.AssignableToTypeOf(&v1.ConfigMap{})).Return(apierrors.NewNotFound(schema.GroupResource{}, parametersNSN.Name)),
+				mockDC.EXPECT().SetModuleParametersAsDesired(gomock.AssignableToTypeOf(&v1.ConfigMap{}), gomock.Any()),
+				clnt.EXPECT().Create(gomock.Any(), gomock.AssignableToTypeOf(&v1.ConfigMap{})),
+				clnt.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).Return(apierrors.NewNotFound(schema.GroupResource{}, "whatever")),
+				mockDC.EXPECT().SetDriverContainerAsDesired(gomock.Any(), &ds, imageName, gomock.AssignableToTypeOf(mod), kernelVersion, sets.NewString()),
+				clnt.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil),
 				mockMetrics.EXPECT().SetCompletedStage(moduleName, namespace, kernelVersion, metrics.ModuleLoaderStage, false),
-				mockDC.EXPECT().GarbageCollect(ctx, dsByKernelVersion, sets.NewString(kernelVersion)),
-				mockSU.EXPECT().ModuleUpdateStatus(ctx, &mod, nodeList.Items, nodeList.Items, dsByKernelVersion).Return(nil),
+				mockDC.EXPECT().GarbageCollect(gomock.Any(), dsByKernelVersion, sets.NewString(kernelVersion)),
+				mockSU.EXPECT().ModuleUpdateStatus(gomock.Any(), &mod, nodeList.Items, nodeList.Items, dsByKernelVersion, nil, nil).Return(nil),
 			)
 
 			res, err := mr.Reconcile(context.Background(), req)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(res).To(Equal(reconcile.Result{}))
+			Expect(recorder.Events).To(Receive(HavePrefix("Normal " + EventReasonDaemonSetCreated)))
+
+			spans := spanRecorder.Ended()
+			Expect(spans).To(HaveLen(2))
+			Expect(spans[0].Name()).To(Equal("ModuleReconciler.handleDriverContainer"))
+			Expect(spans[0].Attributes()).To(ContainElements(
+				tracing.ModuleNameKey.String(moduleName),
+				tracing.KernelVersionKey.String(kernelVersion),
+				tracing.ImageKey.String(imageName),
+			))
+			Expect(spans[1].Name()).To(Equal("ModuleReconciler.Reconcile"))
+			Expect(spans[0].Parent().SpanID()).To(Equal(spans[1].SpanContext().SpanID()))
+		})
+
+		It("should not load the kernel module on nodes yielded to another Module", func() {
+			const (
+				imageName     = "test-image"
+				kernelVersion = "1.2.3"
+			)
+
+			mappings := []kmmv1beta1.KernelMapping{
+				{
+					ContainerImage: imageName,
+					Literal:        kernelVersion,
+				},
+			}
+
+			osConfig := module.NodeOSConfig{}
+
+			mod := kmmv1beta1.Module{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      moduleName,
+					Namespace: namespace,
+				},
+				Spec: kmmv1beta1.ModuleSpec{
+					ModuleLoader: kmmv1beta1.ModuleLoaderSpec{
+						Container: kmmv1beta1.ModuleLoaderContainerSpec{
+							KernelMappings: mappings,
+							Modprobe:       kmmv1beta1.ModprobeSpec{ModuleName: "kmod"},
+						},
+					},
+					Selector: map[string]string{"key": "value"},
+				},
+			}
+
+			node := func(name string) v1.Node {
+				return v1.Node{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:   name,
+						Labels: map[string]string{"key": "value"},
+					},
+					Status: v1.NodeStatus{
+						NodeInfo: v1.NodeSystemInfo{KernelVersion: kernelVersion},
+					},
+				}
+			}
+
+			nodes := []v1.Node{node("node1"), node("node2")}
+
+			conflict := kmmv1beta1.ModuleConflict{Name: "other", Namespace: "other-namespace", NodesNumber: 1, Yielded: true}
+
+			dsByKernelVersion := make(map[string]*appsv1.DaemonSet)
+
+			mr := NewModuleReconciler(clnt, mockBM, mockCD, mockDC, mockKM, mockMetrics, allNamespaces, mockSU, mockNM, test.NoopTracer(), recorder)
+
+			gomock.InOrder(
+				clnt.EXPECT().Get(gomock.Any(), req.NamespacedName, gomock.Any()).DoAndReturn(
+					func(_ interface{}, _ interface{}, m *kmmv1beta1.Module) error {
+						m.ObjectMeta = mod.ObjectMeta
+						m.Spec = mod.Spec
+						return nil
+					},
+				),
+				clnt.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()),
+				mockMetrics.EXPECT().SetExistingKMMOModules(0),
+				clnt.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+					func(_ interface{}, list *v1.NodeList, _ ...interface{}) error {
+						list.Items = nodes
+						return nil
+					},
+				),
+				mockCD.EXPECT().FindConflictsOnNodes(gomock.Any(), &mod, nodes).Return([]kmmv1beta1.ModuleConflict{conflict}, sets.NewString("node2"), nil),
+				mockKM.EXPECT().GetNodeOSConfig(&nodes[0]).Return(&osConfig),
+				mockKM.EXPECT().FindMappingForKernel(mappings, kernelVersion).Return(&mappings[0], nil),
+				mockKM.EXPECT().PrepareKernelMapping(&mappings[0], &osConfig).Return(&mappings[0], nil),
+				mockNM.EXPECT().SyncUnmappedNodes(gomock.Any(), moduleName, namespace, nil, sets.NewString()),
+				mockDC.EXPECT().ModuleDaemonSetsByKernelVersion(gomock.Any(), moduleName, namespace).Return(dsByKernelVersion, nil),
+				mockDC.EXPECT().DevicePluginDaemonSetsByKernelVersion(gomock.Any(), moduleName, namespace),
+				clnt.EXPECT().Get(gomock.Any(), parametersNSN, gomock.AssignableToTypeOf(&v1.ConfigMap{})).Return(apierrors.NewNotFound(schema.GroupResource{}, parametersNSN.Name)),
+				mockDC.EXPECT().SetModuleParametersAsDesired(gomock.AssignableToTypeOf(&v1.ConfigMap{}), gomock.Any()),
+				clnt.EXPECT().Create(gomock.Any(), gomock.AssignableToTypeOf(&v1.ConfigMap{})),
+				clnt.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).Return(apierrors.NewNotFound(schema.GroupResource{}, "whatever")),
+				mockDC.EXPECT().SetDriverContainerAsDesired(gomock.Any(), gomock.Any(), imageName, gomock.AssignableToTypeOf(mod), kernelVersion, sets.NewString("node2")),
+				clnt.EXPECT().Create(gomock.Any(), gomock.Any()),
+				mockMetrics.EXPECT().SetCompletedStage(moduleName, namespace, kernelVersion, metrics.ModuleLoaderStage, false),
+				mockDC.EXPECT().GarbageCollect(gomock.Any(), dsByKernelVersion, sets.NewString(kernelVersion)),
+				mockSU.EXPECT().ModuleUpdateStatus(gomock.Any(), &mod, nodes[:1], nodes[:1], dsByKernelVersion, nil, []kmmv1beta1.ModuleConflict{conflict}),
+			)
+
+			_, err := mr.Reconcile(context.Background(), req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.Events).To(Receive(Equal("Warning " + EventReasonModuleConflict + " Not loading kernel module kmod on 1 node(s) already targeted by Module other-namespace/other")))
 		})
 
 		It("should patch the DaemonSet when it already exists", func() {
@@ -334,31 +569,30 @@ var _ = Describe("ModuleReconciler", func() {
 			}
 
 			gomock.InOrder(
-				clnt.EXPECT().Get(ctx, req.NamespacedName, gomock.Any()).DoAndReturn(
+				clnt.EXPECT().Get(gomock.Any(), req.NamespacedName, gomock.Any()).DoAndReturn(
 					func(_ interface{}, _ interface{}, m *kmmv1beta1.Module) error {
 						m.ObjectMeta = mod.ObjectMeta
 						m.Spec = mod.Spec
 						return nil
 					},
 				),
-				clnt.EXPECT().List(ctx, gomock.Any(), gomock.Any()).DoAndReturn(
+				clnt.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
 					func(_ interface{}, list *kmmv1beta1.ModuleList, _ ...interface{}) error {
 						list.Items = []kmmv1beta1.Module{mod}
 						return nil
 					},
 				),
 				mockMetrics.EXPECT().SetExistingKMMOModules(1),
-				clnt.EXPECT().List(ctx, gomock.Any(), gomock.Any()).DoAndReturn(
+				clnt.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
 					func(_ interface{}, list *v1.NodeList, _ ...interface{}) error {
 						list.Items = nodeList.Items
 						return nil
 					},
 				),
-				clnt.EXPECT().Get(ctx, gomock.Any(), gomock.Any()),
-				clnt.EXPECT().Patch(ctx, gomock.Any(), gomock.Any()),
+				mockCD.EXPECT().FindConflictsOnNodes(gomock.Any(), &mod, gomock.Any()).Return(nil, sets.NewString(), nil),
 			)
 
-			mr := NewModuleReconciler(clnt, mockBM, mockDC, mockKM, mockMetrics, nil, mockSU)
+			mr := NewModuleReconciler(clnt, mockBM, mockCD, mockDC, mockKM, mockMetrics, allNamespaces, mockSU, mockNM, test.NoopTracer(), recorder)
 
 			dsByKernelVersion := map[string]*appsv1.DaemonSet{kernelVersion: &ds}
 
@@ -366,18 +600,26 @@ var _ = Describe("ModuleReconciler", func() {
 				mockKM.EXPECT().GetNodeOSConfig(&nodeList.Items[0]).Return(&osConfig),
 				mockKM.EXPECT().FindMappingForKernel(mappings, kernelVersion).Return(&mappings[0], nil),
 				mockKM.EXPECT().PrepareKernelMapping(&mappings[0], &osConfig).Return(&mappings[0], nil),
-				mockDC.EXPECT().ModuleDaemonSetsByKernelVersion(ctx, moduleName, namespace).Return(dsByKernelVersion, nil),
-				mockDC.EXPECT().SetDriverContainerAsDesired(context.Background(), &ds, imageName, gomock.AssignableToTypeOf(mod), kernelVersion).Do(
-					func(ctx context.Context, d *appsv1.DaemonSet, _ string, _ kmmv1beta1.Module, _ string) {
+				mockNM.EXPECT().SyncUnmappedNodes(gomock.Any(), moduleName, namespace, nil, sets.NewString()),
+				mockDC.EXPECT().ModuleDaemonSetsByKernelVersion(gomock.Any(), moduleName, namespace).Return(dsByKernelVersion, nil),
+				mockDC.EXPECT().DevicePluginDaemonSetsByKernelVersion(gomock.Any(), moduleName, namespace),
+				clnt.EXPECT().Get(gomock.Any(), parametersNSN, gomock.AssignableToTypeOf(&v1.ConfigMap{})).Return(apierrors.NewNotFound(schema.GroupResource{}, parametersNSN.Name)),
+				mockDC.EXPECT().SetModuleParametersAsDesired(gomock.AssignableToTypeOf(&v1.ConfigMap{}), gomock.Any()),
+				clnt.EXPECT().Create(gomock.Any(), gomock.AssignableToTypeOf(&v1.ConfigMap{})),
+				clnt.EXPECT().Get(gomock.Any(), gomock.Any(), &ds),
+				mockDC.EXPECT().SetDriverContainerAsDesired(gomock.Any(), &ds, imageName, gomock.AssignableToTypeOf(mod), kernelVersion, sets.NewString()).Do(
+					func(ctx context.Context, d *appsv1.DaemonSet, _ string, _ kmmv1beta1.Module, _ string, _ sets.String) {
 						d.SetLabels(map[string]string{"test": "test"})
 					}),
-				mockDC.EXPECT().GarbageCollect(ctx, dsByKernelVersion, sets.NewString(kernelVersion)),
-				mockSU.EXPECT().ModuleUpdateStatus(ctx, &mod, nodeList.Items, nodeList.Items, dsByKernelVersion).Return(nil),
+				clnt.EXPECT().Patch(gomock.Any(), gomock.Any(), gomock.Any()),
+				mockDC.EXPECT().GarbageCollect(gomock.Any(), dsByKernelVersion, sets.NewString(kernelVersion)),
+				mockSU.EXPECT().ModuleUpdateStatus(gomock.Any(), &mod, nodeList.Items, nodeList.Items, dsByKernelVersion, nil, nil).Return(nil),
 			)
 
 			res, err := mr.Reconcile(context.Background(), req)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(res).To(Equal(reconcile.Result{}))
+			Expect(recorder.Events).To(Receive(Equal("Normal " + EventReasonDaemonSetUpdated + " Updated module loader for kernel 1.2.3 DaemonSet some-daemonset")))
 		})
 
 		It("should create a Device plugin if defined in the module", func() {
@@ -409,7 +651,7 @@ var _ = Describe("ModuleReconciler", func() {
 				},
 			}
 
-			mr := NewModuleReconciler(clnt, mockBM, mockDC, mockKM, mockMetrics, nil, mockSU)
+			mr := NewModuleReconciler(clnt, mockBM, mockCD, mockDC, mockKM, mockMetrics, allNamespaces, mockSU, mockNM, test.NoopTracer(), recorder)
 
 			ds := appsv1.DaemonSet{
 				ObjectMeta: metav1.ObjectMeta{
@@ -419,38 +661,347 @@ var _ = Describe("ModuleReconciler", func() {
 			}
 
 			gomock.InOrder(
-				clnt.EXPECT().Get(ctx, req.NamespacedName, gomock.Any()).DoAndReturn(
+				clnt.EXPECT().Get(gomock.Any(), req.NamespacedName, gomock.Any()).DoAndReturn(
 					func(_ interface{}, _ interface{}, m *kmmv1beta1.Module) error {
 						m.ObjectMeta = mod.ObjectMeta
 						m.Spec = mod.Spec
 						return nil
 					},
 				),
-				clnt.EXPECT().List(ctx, gomock.Any(), gomock.Any()).DoAndReturn(
+				clnt.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
 					func(_ interface{}, list *kmmv1beta1.ModuleList, _ ...interface{}) error {
 						return nil
 					},
 				),
 				mockMetrics.EXPECT().SetExistingKMMOModules(0),
-				clnt.EXPECT().List(ctx, gomock.Any(), gomock.Any()).DoAndReturn(
+				clnt.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
 					func(_ interface{}, list *v1.NodeList, _ ...interface{}) error {
 						list.Items = []v1.Node{}
 						return nil
 					},
 				),
-				mockDC.EXPECT().ModuleDaemonSetsByKernelVersion(ctx, moduleName, namespace).Return(nil, nil),
-				clnt.EXPECT().Get(ctx, gomock.Any(), gomock.Any()).Return(apierrors.NewNotFound(schema.GroupResource{}, "whatever")),
-				clnt.EXPECT().Get(ctx, gomock.Any(), gomock.Any()).Return(apierrors.NewNotFound(schema.GroupResource{}, "whatever")),
-				mockDC.EXPECT().SetDevicePluginAsDesired(context.Background(), &ds, gomock.AssignableToTypeOf(&mod)),
-				clnt.EXPECT().Create(ctx, gomock.Any()).Return(nil),
+				mockCD.EXPECT().FindConflictsOnNodes(gomock.Any(), &mod, gomock.Any()).Return(nil, sets.NewString(), nil),
+				mockNM.EXPECT().SyncUnmappedNodes(gomock.Any(), moduleName, namespace, nil, sets.NewString()),
+				mockDC.EXPECT().ModuleDaemonSetsByKernelVersion(gomock.Any(), moduleName, namespace).Return(nil, nil),
+				mockDC.EXPECT().DevicePluginDaemonSetsByKernelVersion(gomock.Any(), moduleName, namespace),
+				clnt.EXPECT().Get(gomock.Any(), parametersNSN, gomock.AssignableToTypeOf(&v1.ConfigMap{})).Return(apierrors.NewNotFound(schema.GroupResource{}, parametersNSN.Name)),
+				mockDC.EXPECT().SetModuleParametersAsDesired(gomock.AssignableToTypeOf(&v1.ConfigMap{}), gomock.Any()),
+				clnt.EXPECT().Create(gomock.Any(), gomock.AssignableToTypeOf(&v1.ConfigMap{})),
+				clnt.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).Return(apierrors.NewNotFound(schema.GroupResource{}, "whatever")),
+				clnt.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).Return(apierrors.NewNotFound(schema.GroupResource{}, "whatever")),
+				mockDC.EXPECT().SetDevicePluginAsDesired(gomock.Any(), &ds, gomock.AssignableToTypeOf(&mod), mod.Spec.DevicePlugin.Container.Image, ""),
+				clnt.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil),
 				mockMetrics.EXPECT().SetCompletedStage(moduleName, namespace, "", metrics.DevicePluginStage, false),
-				mockDC.EXPECT().GarbageCollect(ctx, nil, sets.NewString()),
-				mockSU.EXPECT().ModuleUpdateStatus(ctx, &mod, []v1.Node{}, []v1.Node{}, nil).Return(nil),
+				mockDC.EXPECT().GarbageCollect(gomock.Any(), nil, sets.NewString()),
+				mockSU.EXPECT().ModuleUpdateStatus(gomock.Any(), &mod, []v1.Node{}, []v1.Node{}, nil, nil, nil).Return(nil),
+			)
+
+			res, err := mr.Reconcile(context.Background(), req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).To(Equal(reconcile.Result{}))
+			Expect(recorder.Events).To(Receive(Equal("Normal " + EventReasonDaemonSetCreated + " Created device plugin DaemonSet " + moduleName + "-device-plugin")))
+		})
+
+		It("should create a device plugin for each kernel and delete the shared one", func() {
+			const (
+				imageName     = "test-image"
+				kernelVersion = "1.2.3"
+			)
+
+			mappings := []kmmv1beta1.KernelMapping{
+				{
+					ContainerImage: imageName,
+					Literal:        kernelVersion,
+				},
+			}
+
+			mod := kmmv1beta1.Module{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      moduleName,
+					Namespace: namespace,
+				},
+				Spec: kmmv1beta1.ModuleSpec{
+					DevicePlugin: &kmmv1beta1.DevicePluginSpec{
+						Container: kmmv1beta1.DevicePluginContainerSpec{Image: "device-plugin:${KERNEL_XYZ}"},
+						PerKernel: true,
+					},
+					ModuleLoader: kmmv1beta1.ModuleLoaderSpec{
+						Container: kmmv1beta1.ModuleLoaderContainerSpec{
+							KernelMappings: mappings,
+						},
+					},
+					Selector: map[string]string{"key": "value"},
+				},
+			}
+
+			node := v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "node1",
+					Labels: map[string]string{"key": "value"},
+				},
+				Status: v1.NodeStatus{
+					NodeInfo: v1.NodeSystemInfo{KernelVersion: kernelVersion},
+				},
+			}
+
+			osConfig := module.NodeOSConfig{}
+
+			mappingWithDevicePlugin := mappings[0]
+			mappingWithDevicePlugin.DevicePluginImage = "device-plugin:${KERNEL_XYZ}"
+
+			preparedMapping := mappings[0]
+			preparedMapping.DevicePluginImage = "device-plugin:1.2.3"
+
+			sharedDS := appsv1.DaemonSet{
+				ObjectMeta: metav1.ObjectMeta{Name: moduleName + "-device-plugin", Namespace: namespace},
+			}
+
+			dsByKernelVersion := map[string]*appsv1.DaemonSet{"": &sharedDS}
+
+			mr := NewModuleReconciler(clnt, mockBM, mockCD, mockDC, mockKM, mockMetrics, allNamespaces, mockSU, mockNM, test.NoopTracer(), recorder)
+
+			devicePluginDS := appsv1.DaemonSet{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: moduleName + "-device-plugin-",
+					Namespace:    namespace,
+				},
+			}
+
+			gomock.InOrder(
+				clnt.EXPECT().Get(gomock.Any(), req.NamespacedName, gomock.Any()).DoAndReturn(
+					func(_ interface{}, _ interface{}, m *kmmv1beta1.Module) error {
+						m.ObjectMeta = mod.ObjectMeta
+						m.Spec = mod.Spec
+						return nil
+					},
+				),
+				clnt.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()),
+				mockMetrics.EXPECT().SetExistingKMMOModules(0),
+				clnt.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+					func(_ interface{}, list *v1.NodeList, _ ...interface{}) error {
+						list.Items = []v1.Node{node}
+						return nil
+					},
+				),
+				mockCD.EXPECT().FindConflictsOnNodes(gomock.Any(), &mod, gomock.Any()).Return(nil, sets.NewString(), nil),
+				mockKM.EXPECT().GetNodeOSConfig(gomock.Any()).Return(&osConfig),
+				mockKM.EXPECT().FindMappingForKernel(mappings, kernelVersion).Return(&mappings[0], nil),
+				mockKM.EXPECT().PrepareKernelMapping(&mappingWithDevicePlugin, &osConfig).Return(&preparedMapping, nil),
+				mockNM.EXPECT().SyncUnmappedNodes(gomock.Any(), moduleName, namespace, nil, sets.NewString()),
+				mockDC.EXPECT().ModuleDaemonSetsByKernelVersion(gomock.Any(), moduleName, namespace).Return(dsByKernelVersion, nil),
+				mockDC.EXPECT().DevicePluginDaemonSetsByKernelVersion(gomock.Any(), moduleName, namespace),
+				clnt.EXPECT().Get(gomock.Any(), parametersNSN, gomock.AssignableToTypeOf(&v1.ConfigMap{})).Return(apierrors.NewNotFound(schema.GroupResource{}, parametersNSN.Name)),
+				mockDC.EXPECT().SetModuleParametersAsDesired(gomock.AssignableToTypeOf(&v1.ConfigMap{}), gomock.Any()),
+				clnt.EXPECT().Create(gomock.Any(), gomock.AssignableToTypeOf(&v1.ConfigMap{})),
+				clnt.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).Return(apierrors.NewNotFound(schema.GroupResource{}, "whatever")),
+				mockDC.EXPECT().SetDriverContainerAsDesired(gomock.Any(), gomock.Any(), imageName, gomock.AssignableToTypeOf(mod), kernelVersion, sets.NewString()),
+				clnt.EXPECT().Create(gomock.Any(), gomock.Any()),
+				mockMetrics.EXPECT().SetCompletedStage(moduleName, namespace, kernelVersion, metrics.ModuleLoaderStage, false),
+				clnt.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).Return(apierrors.NewNotFound(schema.GroupResource{}, "whatever")),
+				mockDC.EXPECT().SetDevicePluginAsDesired(gomock.Any(), &devicePluginDS, gomock.AssignableToTypeOf(&mod), "device-plugin:1.2.3", kernelVersion),
+				clnt.EXPECT().Create(gomock.Any(), gomock.Any()),
+				mockMetrics.EXPECT().SetCompletedStage(moduleName, namespace, kernelVersion, metrics.DevicePluginStage, false),
+				clnt.EXPECT().Delete(gomock.Any(), &sharedDS),
+				mockDC.EXPECT().GarbageCollect(gomock.Any(), map[string]*appsv1.DaemonSet{}, sets.NewString(kernelVersion)),
+				mockSU.EXPECT().ModuleUpdateStatus(gomock.Any(), &mod, []v1.Node{node}, []v1.Node{node}, map[string]*appsv1.DaemonSet{}, nil, nil),
 			)
 
 			res, err := mr.Reconcile(context.Background(), req)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(res).To(Equal(reconcile.Result{}))
+			Expect(recorder.Events).To(Receive(HavePrefix("Normal " + EventReasonDaemonSetCreated + " Created module loader")))
+			Expect(recorder.Events).To(Receive(HavePrefix("Normal " + EventReasonDaemonSetCreated + " Created device plugin for kernel 1.2.3")))
+			Expect(recorder.Events).To(Receive(Equal("Normal " + EventReasonDaemonSetDeleted + " Deleted device plugin DaemonSet " + moduleName + "-device-plugin as the device plugin is deployed per kernel")))
+		})
+
+		It("should delete per-kernel device plugins that are not needed anymore", func() {
+			mod := kmmv1beta1.Module{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      moduleName,
+					Namespace: namespace,
+				},
+				Spec: kmmv1beta1.ModuleSpec{
+					DevicePlugin: &kmmv1beta1.DevicePluginSpec{
+						Container: kmmv1beta1.DevicePluginContainerSpec{Image: "device-plugin"},
+						PerKernel: true,
+					},
+					Selector: map[string]string{"key": "value"},
+				},
+			}
+
+			devicePluginDSByKernelVersion := map[string]*appsv1.DaemonSet{
+				"1.2.3": {ObjectMeta: metav1.ObjectMeta{Name: "old-device-plugin", Namespace: namespace}},
+			}
+
+			mr := NewModuleReconciler(clnt, mockBM, mockCD, mockDC, mockKM, mockMetrics, allNamespaces, mockSU, mockNM, test.NoopTracer(), recorder)
+
+			gomock.InOrder(
+				clnt.EXPECT().Get(gomock.Any(), req.NamespacedName, gomock.Any()).DoAndReturn(
+					func(_ interface{}, _ interface{}, m *kmmv1beta1.Module) error {
+						m.ObjectMeta = mod.ObjectMeta
+						m.Spec = mod.Spec
+						return nil
+					},
+				),
+				clnt.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()),
+				mockMetrics.EXPECT().SetExistingKMMOModules(0),
+				clnt.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+					func(_ interface{}, list *v1.NodeList, _ ...interface{}) error {
+						list.Items = []v1.Node{}
+						return nil
+					},
+				),
+				mockCD.EXPECT().FindConflictsOnNodes(gomock.Any(), &mod, gomock.Any()).Return(nil, sets.NewString(), nil),
+				mockNM.EXPECT().SyncUnmappedNodes(gomock.Any(), moduleName, namespace, nil, sets.NewString()),
+				mockDC.EXPECT().ModuleDaemonSetsByKernelVersion(gomock.Any(), moduleName, namespace),
+				mockDC.EXPECT().DevicePluginDaemonSetsByKernelVersion(gomock.Any(), moduleName, namespace).Return(devicePluginDSByKernelVersion, nil),
+				clnt.EXPECT().Get(gomock.Any(), parametersNSN, gomock.AssignableToTypeOf(&v1.ConfigMap{})).Return(apierrors.NewNotFound(schema.GroupResource{}, parametersNSN.Name)),
+				mockDC.EXPECT().SetModuleParametersAsDesired(gomock.AssignableToTypeOf(&v1.ConfigMap{}), gomock.Any()),
+				clnt.EXPECT().Create(gomock.Any(), gomock.AssignableToTypeOf(&v1.ConfigMap{})),
+				mockDC.EXPECT().GarbageCollect(gomock.Any(), nil, sets.NewString()),
+				mockDC.EXPECT().GarbageCollect(gomock.Any(), devicePluginDSByKernelVersion, sets.NewString()).Return([]string{"old-device-plugin"}, nil),
+				mockSU.EXPECT().ModuleUpdateStatus(gomock.Any(), &mod, []v1.Node{}, []v1.Node{}, nil, map[string]*appsv1.DaemonSet{}, nil),
+			)
+
+			res, err := mr.Reconcile(context.Background(), req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).To(Equal(reconcile.Result{}))
+			Expect(recorder.Events).To(Receive(Equal("Normal " + EventReasonDaemonSetDeleted + " Deleted per-kernel device plugin DaemonSet old-device-plugin")))
+		})
+
+		It("should report nodes without a mapping and deleted DaemonSets", func() {
+			const (
+				kernelVersion = "1.2.3"
+				oldDSName     = "old-daemonset"
+			)
+
+			mod := kmmv1beta1.Module{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      moduleName,
+					Namespace: namespace,
+				},
+				Spec: kmmv1beta1.ModuleSpec{
+					Selector:      map[string]string{"key": "value"},
+					UnmappedNodes: &kmmv1beta1.UnmappedNodesSpec{Label: true},
+				},
+			}
+
+			nodeList := v1.NodeList{
+				Items: []v1.Node{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+						Status: v1.NodeStatus{
+							NodeInfo: v1.NodeSystemInfo{KernelVersion: kernelVersion},
+						},
+					},
+				},
+			}
+
+			dsByKernelVersion := map[string]*appsv1.DaemonSet{
+				"4.5.6": {ObjectMeta: metav1.ObjectMeta{Name: oldDSName}},
+			}
+
+			mr := NewModuleReconciler(clnt, mockBM, mockCD, mockDC, mockKM, mockMetrics, allNamespaces, mockSU, mockNM, test.NoopTracer(), recorder)
+
+			gomock.InOrder(
+				clnt.EXPECT().Get(gomock.Any(), req.NamespacedName, gomock.Any()).DoAndReturn(
+					func(_ interface{}, _ interface{}, m *kmmv1beta1.Module) error {
+						m.ObjectMeta = mod.ObjectMeta
+						m.Spec = mod.Spec
+						return nil
+					},
+				),
+				clnt.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()),
+				mockMetrics.EXPECT().SetExistingKMMOModules(0),
+				clnt.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+					func(_ interface{}, list *v1.NodeList, _ ...interface{}) error {
+						list.Items = nodeList.Items
+						return nil
+					},
+				),
+				mockCD.EXPECT().FindConflictsOnNodes(gomock.Any(), &mod, gomock.Any()).Return(nil, sets.NewString(), nil),
+				mockKM.EXPECT().GetNodeOSConfig(&nodeList.Items[0]),
+				mockKM.EXPECT().FindMappingForKernel(gomock.Any(), kernelVersion).Return(nil, errors.New("no mapping")),
+				mockNM.EXPECT().SyncUnmappedNodes(gomock.Any(), moduleName, namespace, mod.Spec.UnmappedNodes, sets.NewString("node1")),
+				mockDC.EXPECT().ModuleDaemonSetsByKernelVersion(gomock.Any(), moduleName, namespace).Return(dsByKernelVersion, nil),
+				mockDC.EXPECT().DevicePluginDaemonSetsByKernelVersion(gomock.Any(), moduleName, namespace),
+				clnt.EXPECT().Get(gomock.Any(), parametersNSN, gomock.AssignableToTypeOf(&v1.ConfigMap{})).Return(apierrors.NewNotFound(schema.GroupResource{}, parametersNSN.Name)),
+				mockDC.EXPECT().SetModuleParametersAsDesired(gomock.AssignableToTypeOf(&v1.ConfigMap{}), gomock.Any()),
+				clnt.EXPECT().Create(gomock.Any(), gomock.AssignableToTypeOf(&v1.ConfigMap{})),
+				mockDC.EXPECT().GarbageCollect(gomock.Any(), dsByKernelVersion, sets.NewString()).Return([]string{oldDSName}, nil),
+				mockMetrics.EXPECT().DeleteKernelSeries(moduleName, namespace, "4.5.6"),
+				mockSU.EXPECT().ModuleUpdateStatus(gomock.Any(), &mod, []v1.Node{}, nodeList.Items, map[string]*appsv1.DaemonSet{}, nil, nil),
+			)
+
+			_, err := mr.Reconcile(context.Background(), req)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(recorder.Events).To(Receive(Equal("Warning " + EventReasonNoKernelMapping + " No kernel mapping found for 1 node(s) running kernel(s) 1.2.3")))
+			Expect(recorder.Events).To(Receive(HavePrefix("Normal " + EventReasonDaemonSetDeleted + " Deleted DaemonSet " + oldDSName)))
 		})
 	})
 })
+
+var _ = Describe("recordUnmappedNodes", func() {
+	node := func(name, kernelVersion string) v1.Node {
+		return v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status: v1.NodeStatus{
+				NodeInfo: v1.NodeSystemInfo{KernelVersion: kernelVersion},
+			},
+		}
+	}
+
+	targetedNodes := []v1.Node{
+		node("node1", "1.2.3"),
+		node("node2", "4.5.6"),
+		node("node3", "1.2.3"),
+		node("node4", "7.8.9"),
+	}
+
+	DescribeTable("should only report the unmapped nodes once",
+		func(inStatus []kmmv1beta1.UnmappedNode, unmapped sets.String, expectedEvent string) {
+			recorder := record.NewFakeRecorder(10)
+			r := ModuleReconciler{recorder: recorder}
+
+			mod := kmmv1beta1.Module{
+				Status: kmmv1beta1.ModuleStatus{UnmappedNodes: inStatus},
+			}
+
+			r.recordUnmappedNodes(&mod, targetedNodes, unmapped)
+
+			if expectedEvent == "" {
+				Expect(recorder.Events).NotTo(Receive())
+				return
+			}
+
+			Expect(recorder.Events).To(Receive(Equal("Warning " + EventReasonNoKernelMapping + " " + expectedEvent)))
+			Expect(recorder.Events).NotTo(Receive())
+		},
+		Entry("no unmapped nodes", nil, sets.NewString(), ""),
+		Entry(
+			"new unmapped nodes",
+			nil,
+			sets.NewString("node1", "node2", "node3"),
+			"No kernel mapping found for 3 node(s) running kernel(s) 1.2.3, 4.5.6",
+		),
+		Entry(
+			"already reported",
+			[]kmmv1beta1.UnmappedNode{{Name: "node1"}, {Name: "node2"}},
+			sets.NewString("node1", "node2"),
+			"",
+		),
+		Entry(
+			"one more unmapped node",
+			[]kmmv1beta1.UnmappedNode{{Name: "node1"}},
+			sets.NewString("node1", "node4"),
+			"No kernel mapping found for 2 node(s) running kernel(s) 1.2.3, 7.8.9",
+		),
+		Entry(
+			"one less unmapped node",
+			[]kmmv1beta1.UnmappedNode{{Name: "node1"}, {Name: "node2"}},
+			sets.NewString("node2"),
+			"No kernel mapping found for 1 node(s) running kernel(s) 4.5.6",
+		),
+	)
+})
@@ -0,0 +1,277 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	"github.com/qbarrand/oot-operator/internal/constants"
+	"github.com/qbarrand/oot-operator/internal/daemonset"
+	"github.com/qbarrand/oot-operator/internal/filter"
+	"github.com/qbarrand/oot-operator/internal/metrics"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/kubectl/pkg/util/podutils"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+//+kubebuilder:rbac:groups="core",resources=nodes,verbs=get;list;patch;watch
+//+kubebuilder:rbac:groups="core",resources=pods,verbs=list;watch
+//+kubebuilder:rbac:groups=kmm.sigs.k8s.io,resources=modules,verbs=list;watch
+//+kubebuilder:rbac:groups="core",resources=events,verbs=create;patch
+
+const (
+	EventReasonNodeLabelRepaired = "NodeLabelRepaired"
+
+	// podNodeNameField indexes pods by the node they are scheduled on.
+	podNodeNameField = "spec.nodeName"
+)
+
+// NodeLabelResyncReconciler periodically compares the labels set on nodes by PodNodeModuleReconciler with the ready
+// module loader and device plugin pods running on them, and repairs the labels that drifted.
+// Labels drift when they are changed by someone else, or when pods change while the operator is not running.
+type NodeLabelResyncReconciler struct {
+	client     client.Client
+	daemonAPI  daemonset.DaemonSetCreator
+	metricsAPI metrics.Metrics
+	filter     *filter.Filter
+	recorder   record.EventRecorder
+	period     time.Duration
+}
+
+func NewNodeLabelResyncReconciler(
+	client client.Client,
+	daemonAPI daemonset.DaemonSetCreator,
+	metricsAPI metrics.Metrics,
+	filter *filter.Filter,
+	recorder record.EventRecorder,
+	period time.Duration) *NodeLabelResyncReconciler {
+	return &NodeLabelResyncReconciler{
+		client:     client,
+		daemonAPI:  daemonAPI,
+		metricsAPI: metricsAPI,
+		filter:     filter,
+		recorder:   recorder,
+		period:     period,
+	}
+}
+
+type nodeLabelRepair struct {
+	label      string
+	moduleName string
+	repair     string
+}
+
+func (r *NodeLabelResyncReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := ctrl.LoggerFrom(ctx)
+
+	node := v1.Node{}
+
+	if err := r.client.Get(ctx, req.NamespacedName, &node); err != nil {
+		if k8serrors.IsNotFound(err) {
+			logger.Info("Node not found")
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, fmt.Errorf("could not get node %s: %v", req.Name, err)
+	}
+
+	podList := v1.PodList{}
+
+	opts := []client.ListOption{
+		client.HasLabels{constants.ModuleNameLabel},
+		client.MatchingFields{podNodeNameField: node.Name},
+	}
+
+	if err := r.client.List(ctx, &podList, opts...); err != nil {
+		return ctrl.Result{}, fmt.Errorf("could not list module pods on node %s: %v", node.Name, err)
+	}
+
+	modList := kmmv1beta1.ModuleList{}
+
+	if err := r.client.List(ctx, &modList); err != nil {
+		return ctrl.Result{}, fmt.Errorf("could not list Modules: %v", err)
+	}
+
+	managed := r.managedModuleNames(modList.Items, podList.Items)
+	namespaces := moduleNamespaces(modList.Items, podList.Items)
+
+	desired := r.desiredLabels(podList.Items)
+
+	nodeCopy := node.DeepCopy()
+
+	repairs := make([]nodeLabelRepair, 0)
+
+	for label := range node.Labels {
+		moduleName, ok := daemonset.ModuleNameFromNodeLabel(label)
+		if !ok || (managed != nil && !managed.Has(moduleName)) {
+			continue
+		}
+
+		if _, ok = desired[label]; !ok {
+			delete(node.Labels, label)
+			repairs = append(repairs, nodeLabelRepair{label: label, moduleName: moduleName, repair: metrics.NodeLabelRemoved})
+		}
+	}
+
+	for label, value := range desired {
+		moduleName, _ := daemonset.ModuleNameFromNodeLabel(label)
+
+		current, ok := node.Labels[label]
+
+		switch {
+		case !ok:
+			repairs = append(repairs, nodeLabelRepair{label: label, moduleName: moduleName, repair: metrics.NodeLabelAdded})
+		case current != value:
+			repairs = append(repairs, nodeLabelRepair{label: label, moduleName: moduleName, repair: metrics.NodeLabelUpdated})
+		default:
+			continue
+		}
+
+		if node.Labels == nil {
+			node.Labels = make(map[string]string, len(desired))
+		}
+
+		node.Labels[label] = value
+	}
+
+	if len(repairs) == 0 {
+		return ctrl.Result{RequeueAfter: r.period}, nil
+	}
+
+	if err := r.client.Patch(ctx, &node, client.MergeFrom(nodeCopy)); err != nil {
+		return ctrl.Result{}, fmt.Errorf("could not patch node %s: %v", node.Name, err)
+	}
+
+	sort.Slice(repairs, func(i, j int) bool {
+		return repairs[i].label < repairs[j].label
+	})
+
+	for _, rep := range repairs {
+		logger.Info("Repaired drifted node label", "label", rep.label, "repair", rep.repair)
+
+		// the series of a Module are deleted with it; do not create one for a Module that does not exist anymore
+		if namespace, ok := namespaces[rep.moduleName]; ok {
+			r.metricsAPI.IncNodeLabelRepairs(rep.moduleName, namespace, rep.repair)
+		}
+
+		switch rep.repair {
+		case metrics.NodeLabelAdded:
+			r.recorder.Eventf(&node, v1.EventTypeWarning, EventReasonNodeLabelRepaired, "Added missing label %s", rep.label)
+		case metrics.NodeLabelUpdated:
+			r.recorder.Eventf(&node, v1.EventTypeWarning, EventReasonNodeLabelRepaired, "Updated label %s", rep.label)
+		case metrics.NodeLabelRemoved:
+			r.recorder.Eventf(&node, v1.EventTypeWarning, EventReasonNodeLabelRepaired, "Removed stale label %s", rep.label)
+		}
+	}
+
+	return ctrl.Result{RequeueAfter: r.period}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *NodeLabelResyncReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &v1.Pod{}, podNodeNameField, func(o client.Object) []string {
+		return []string{o.(*v1.Pod).Spec.NodeName}
+	})
+	if err != nil {
+		return fmt.Errorf("could not index pods by node name: %v", err)
+	}
+
+	return ctrl.
+		NewControllerManagedBy(mgr).
+		Named("node-label-resync").
+		For(&v1.Node{}).
+		WithEventFilter(
+			r.filter.NodeLabelResyncReconcilerPredicate(),
+		).
+		Complete(r)
+}
+
+// managedModuleNames returns the names of the Modules whose node labels are managed by the operator, or nil if they
+// all are.
+// When only some namespaces are watched, labels of Modules in other namespaces may be managed by another instance of
+// the operator and must be left untouched.
+func (r *NodeLabelResyncReconciler) managedModuleNames(mods []kmmv1beta1.Module, pods []v1.Pod) sets.String {
+	if r.filter.AllNamespacesWatched() {
+		return nil
+	}
+
+	names := sets.NewString()
+
+	for _, mod := range mods {
+		names.Insert(mod.Name)
+	}
+
+	for _, pod := range pods {
+		names.Insert(pod.Labels[constants.ModuleNameLabel])
+	}
+
+	return names
+}
+
+// moduleNamespaces returns the namespace of the existing Modules by name.
+// If Modules with the same name exist in several namespaces, the namespace of their pods is preferred.
+func moduleNamespaces(mods []kmmv1beta1.Module, pods []v1.Pod) map[string]string {
+	existing := make(map[types.NamespacedName]bool, len(mods))
+	namespaces := make(map[string]string, len(mods))
+
+	for _, mod := range mods {
+		existing[types.NamespacedName{Name: mod.Name, Namespace: mod.Namespace}] = true
+		namespaces[mod.Name] = mod.Namespace
+	}
+
+	for _, pod := range pods {
+		name := pod.Labels[constants.ModuleNameLabel]
+
+		if existing[types.NamespacedName{Name: name, Namespace: pod.Namespace}] {
+			namespaces[name] = pod.Namespace
+		}
+	}
+
+	return namespaces
+}
+
+// desiredLabels returns the labels that PodNodeModuleReconciler sets on a node for the ready pods running on it.
+// If several module loader pods of the same Module are ready on the node, the information labels of the most recent
+// one are used.
+func (r *NodeLabelResyncReconciler) desiredLabels(pods []v1.Pod) map[string]string {
+	labels := make(map[string]string)
+	moduleLoaders := make(map[string]*v1.Pod)
+
+	for i := range pods {
+		pod := &pods[i]
+
+		if !podutils.IsPodReady(pod) {
+			continue
+		}
+
+		moduleName := pod.Labels[constants.ModuleNameLabel]
+		label := r.daemonAPI.GetNodeLabelFromPod(pod, moduleName)
+
+		labels[label] = ""
+
+		if label != daemonset.GetDriverContainerNodeLabel(moduleName) {
+			continue
+		}
+
+		if p, ok := moduleLoaders[moduleName]; !ok || p.CreationTimestamp.Before(&pod.CreationTimestamp) {
+			moduleLoaders[moduleName] = pod
+		}
+	}
+
+	for moduleName, pod := range moduleLoaders {
+		for k, v := range moduleLoaderInfoLabels(pod, moduleName) {
+			if v != "" {
+				labels[k] = v
+			}
+		}
+	}
+
+	return labels
+}
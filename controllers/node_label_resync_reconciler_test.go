@@ -0,0 +1,307 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	mock_client "github.com/qbarrand/oot-operator/internal/client"
+	"github.com/qbarrand/oot-operator/internal/constants"
+	"github.com/qbarrand/oot-operator/internal/daemonset"
+	"github.com/qbarrand/oot-operator/internal/filter"
+	"github.com/qbarrand/oot-operator/internal/metrics"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("NodeLabelResyncReconciler", func() {
+	Describe("Reconcile", func() {
+		const (
+			moduleName = "module-name"
+			nodeName   = "node-name"
+			period     = 10 * time.Minute
+		)
+
+		var (
+			kubeClient  *mock_client.MockClient
+			mockMetrics *metrics.MockMetrics
+			recorder    *record.FakeRecorder
+			r           *NodeLabelResyncReconciler
+		)
+
+		BeforeEach(func() {
+			ctrl := gomock.NewController(GinkgoT())
+			kubeClient = mock_client.NewMockClient(ctrl)
+			mockMetrics = metrics.NewMockMetrics(ctrl)
+			recorder = record.NewFakeRecorder(10)
+			r = NewNodeLabelResyncReconciler(
+				kubeClient,
+				daemonset.NewCreator(kubeClient, constants.KernelLabel, scheme),
+				mockMetrics,
+				allNamespaces,
+				recorder,
+				period,
+			)
+		})
+
+		ctx := context.Background()
+		nodeNSN := types.NamespacedName{Name: nodeName}
+		req := ctrl.Request{NamespacedName: nodeNSN}
+
+		readyPod := func(name, role, version string) v1.Pod {
+			return v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: "namespace",
+					Labels: map[string]string{
+						constants.ModuleNameLabel:    moduleName,
+						constants.ModuleVersionLabel: version,
+						constants.DaemonSetRole:      role,
+					},
+				},
+				Spec: v1.PodSpec{NodeName: nodeName},
+				Status: v1.PodStatus{
+					Conditions: []v1.PodCondition{
+						{Type: v1.PodReady, Status: v1.ConditionTrue},
+					},
+					ContainerStatuses: []v1.ContainerStatus{
+						{Name: "module-loader", ImageID: "example.com/driver@sha256:0123456789abcdef0123456789abcdef"},
+					},
+				},
+			}
+		}
+
+		expectNode := func(labels map[string]string) *gomock.Call {
+			return kubeClient.
+				EXPECT().
+				Get(ctx, nodeNSN, gomock.AssignableToTypeOf(&v1.Node{})).
+				Do(func(_ context.Context, _ types.NamespacedName, n *v1.Node) {
+					n.Name = nodeName
+					n.Labels = labels
+				})
+		}
+
+		module := func(name string) kmmv1beta1.Module {
+			return kmmv1beta1.Module{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "namespace"}}
+		}
+
+		expectModules := func(mods ...kmmv1beta1.Module) *gomock.Call {
+			return kubeClient.
+				EXPECT().
+				List(ctx, gomock.AssignableToTypeOf(&kmmv1beta1.ModuleList{})).
+				Do(func(_ context.Context, ml *kmmv1beta1.ModuleList, _ ...client.ListOption) {
+					ml.Items = mods
+				})
+		}
+
+		expectPods := func(pods ...v1.Pod) *gomock.Call {
+			return kubeClient.
+				EXPECT().
+				List(
+					ctx,
+					gomock.AssignableToTypeOf(&v1.PodList{}),
+					client.HasLabels{constants.ModuleNameLabel},
+					client.MatchingFields{"spec.nodeName": nodeName},
+				).
+				Do(func(_ context.Context, pl *v1.PodList, _ ...client.ListOption) {
+					pl.Items = pods
+				})
+		}
+
+		It("should do nothing if the node does not exist", func() {
+			kubeClient.
+				EXPECT().
+				Get(ctx, nodeNSN, gomock.Any()).
+				Return(k8serrors.NewNotFound(schema.GroupResource{Resource: "nodes"}, nodeName))
+
+			res, err := r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).To(Equal(ctrl.Result{}))
+		})
+
+		It("should not patch the node if its labels match the ready pods", func() {
+			gomock.InOrder(
+				expectNode(map[string]string{
+					"kmm.node.kubernetes.io/module-name.ready":               "",
+					"kmm.node.kubernetes.io/module-name.version":             "1.2.0",
+					"kmm.node.kubernetes.io/module-name.digest":              "0123456789ab",
+					"kmm.node.kubernetes.io/module-name.device-plugin-ready": "",
+					"kmm.node.kubernetes.io/module-name.unmapped":            "",
+				}),
+				expectPods(
+					readyPod("module-loader", "module-loader", "1.2.0"),
+					readyPod("device-plugin", "device-plugin", ""),
+				),
+				expectModules(module(moduleName)),
+			)
+
+			res, err := r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).To(Equal(ctrl.Result{RequeueAfter: period}))
+			Expect(recorder.Events).To(BeEmpty())
+		})
+
+		It("should add the labels of ready pods running on the node", func() {
+			notReadyPod := readyPod("not-ready", "device-plugin", "")
+			notReadyPod.Status.Conditions = nil
+
+			gomock.InOrder(
+				expectNode(nil),
+				expectPods(readyPod("module-loader", "module-loader", "1.2.0"), notReadyPod),
+				expectModules(module(moduleName)),
+				kubeClient.
+					EXPECT().
+					Patch(ctx, gomock.Any(), gomock.Any()).
+					Do(func(_ context.Context, n client.Object, p client.Patch, _ ...client.PatchOption) {
+						Expect(p.Type()).To(Equal(types.MergePatchType))
+						Expect(p.Data(n)).To(
+							MatchJSON(`{"metadata":{"labels":{
+								"kmm.node.kubernetes.io/module-name.digest":"0123456789ab",
+								"kmm.node.kubernetes.io/module-name.ready":"",
+								"kmm.node.kubernetes.io/module-name.version":"1.2.0"
+							}}}`),
+						)
+					}),
+			)
+
+			mockMetrics.EXPECT().IncNodeLabelRepairs(moduleName, "namespace", metrics.NodeLabelAdded).Times(3)
+
+			res, err := r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).To(Equal(ctrl.Result{RequeueAfter: period}))
+			Expect(recorder.Events).To(HaveLen(3))
+			Expect(recorder.Events).To(
+				Receive(Equal("Warning " + EventReasonNodeLabelRepaired + " Added missing label kmm.node.kubernetes.io/module-name.digest")),
+			)
+		})
+
+		It("should remove stale labels and update wrong values", func() {
+			gomock.InOrder(
+				expectNode(map[string]string{
+					"kmm.node.kubernetes.io/module-name.ready":               "",
+					"kmm.node.kubernetes.io/module-name.version":             "1.1.0",
+					"kmm.node.kubernetes.io/module-name.device-plugin-ready": "",
+					"kmm.node.kubernetes.io/other-module.ready":              "",
+					"kmm.node.kubernetes.io/other-module.unmapped":           "",
+					constants.KernelLabel:                                    "1.2.3",
+				}),
+				expectPods(readyPod("module-loader", "module-loader", "1.2.0")),
+				expectModules(module(moduleName), module("other-module")),
+				kubeClient.
+					EXPECT().
+					Patch(ctx, gomock.Any(), gomock.Any()).
+					Do(func(_ context.Context, n client.Object, p client.Patch, _ ...client.PatchOption) {
+						Expect(p.Data(n)).To(
+							MatchJSON(`{"metadata":{"labels":{
+								"kmm.node.kubernetes.io/module-name.device-plugin-ready":null,
+								"kmm.node.kubernetes.io/module-name.digest":"0123456789ab",
+								"kmm.node.kubernetes.io/module-name.version":"1.2.0",
+								"kmm.node.kubernetes.io/other-module.ready":null
+							}}}`),
+						)
+					}),
+			)
+
+			gomock.InOrder(
+				mockMetrics.EXPECT().IncNodeLabelRepairs(moduleName, "namespace", metrics.NodeLabelRemoved),
+				mockMetrics.EXPECT().IncNodeLabelRepairs(moduleName, "namespace", metrics.NodeLabelAdded),
+				mockMetrics.EXPECT().IncNodeLabelRepairs(moduleName, "namespace", metrics.NodeLabelUpdated),
+				mockMetrics.EXPECT().IncNodeLabelRepairs("other-module", "namespace", metrics.NodeLabelRemoved),
+			)
+
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.Events).To(HaveLen(4))
+			Expect(recorder.Events).To(
+				Receive(Equal("Warning " + EventReasonNodeLabelRepaired + " Removed stale label kmm.node.kubernetes.io/module-name.device-plugin-ready")),
+			)
+			Expect(recorder.Events).To(Receive())
+			Expect(recorder.Events).To(
+				Receive(Equal("Warning " + EventReasonNodeLabelRepaired + " Updated label kmm.node.kubernetes.io/module-name.version")),
+			)
+		})
+
+		It("should use the information of the most recent module loader pod", func() {
+			oldPod := readyPod("old", "module-loader", "1.1.0")
+			oldPod.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+
+			newPod := readyPod("new", "module-loader", "1.2.0")
+			newPod.CreationTimestamp = metav1.Now()
+
+			gomock.InOrder(
+				expectNode(map[string]string{
+					"kmm.node.kubernetes.io/module-name.ready":   "",
+					"kmm.node.kubernetes.io/module-name.version": "1.1.0",
+					"kmm.node.kubernetes.io/module-name.digest":  "0123456789ab",
+				}),
+				expectPods(newPod, oldPod),
+				expectModules(module(moduleName)),
+				kubeClient.
+					EXPECT().
+					Patch(ctx, gomock.Any(), gomock.Any()).
+					Do(func(_ context.Context, n client.Object, p client.Patch, _ ...client.PatchOption) {
+						Expect(p.Data(n)).To(
+							MatchJSON(`{"metadata":{"labels":{"kmm.node.kubernetes.io/module-name.version":"1.2.0"}}}`),
+						)
+					}),
+			)
+
+			mockMetrics.EXPECT().IncNodeLabelRepairs(moduleName, "namespace", metrics.NodeLabelUpdated)
+
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should leave the labels of Modules in namespaces that are not watched", func() {
+			r.filter = filter.New(nil, logr.Discard(), []string{"namespace"}, nil)
+
+			gomock.InOrder(
+				expectNode(map[string]string{
+					"kmm.node.kubernetes.io/module-name.ready":  "",
+					"kmm.node.kubernetes.io/other-module.ready": "",
+					"kmm.node.kubernetes.io/unknown.ready":      "",
+				}),
+				expectPods(),
+				expectModules(module(moduleName)),
+				kubeClient.
+					EXPECT().
+					Patch(ctx, gomock.Any(), gomock.Any()).
+					Do(func(_ context.Context, n client.Object, p client.Patch, _ ...client.PatchOption) {
+						Expect(p.Data(n)).To(
+							MatchJSON(`{"metadata":{"labels":{"kmm.node.kubernetes.io/module-name.ready":null}}}`),
+						)
+					}),
+			)
+
+			mockMetrics.EXPECT().IncNodeLabelRepairs(moduleName, "namespace", metrics.NodeLabelRemoved)
+
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should not count the repairs of labels of Modules that do not exist anymore", func() {
+			gomock.InOrder(
+				expectNode(map[string]string{"kmm.node.kubernetes.io/deleted.ready": ""}),
+				expectPods(),
+				expectModules(),
+				kubeClient.EXPECT().Patch(ctx, gomock.Any(), gomock.Any()),
+			)
+
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.Events).To(
+				Receive(Equal("Warning " + EventReasonNodeLabelRepaired + " Removed stale label kmm.node.kubernetes.io/deleted.ready")),
+			)
+		})
+	})
+})
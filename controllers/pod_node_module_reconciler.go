@@ -3,13 +3,18 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/qbarrand/oot-operator/internal/constants"
 	"github.com/qbarrand/oot-operator/internal/daemonset"
 	"github.com/qbarrand/oot-operator/internal/filter"
+	"github.com/qbarrand/oot-operator/internal/metrics"
 	v1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/kubectl/pkg/util/podutils"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -19,14 +24,42 @@ import (
 
 //+kubebuilder:rbac:groups="core",resources=pods,verbs=get;patch;list;watch
 //+kubebuilder:rbac:groups="core",resources=nodes,verbs=get;watch
+//+kubebuilder:rbac:groups="batch",resources=jobs,verbs=create
+
+const (
+	EventReasonNodeLabeled   = "NodeLabeled"
+	EventReasonNodeUnlabeled = "NodeUnlabeled"
+)
 
 type PodNodeModuleReconciler struct {
-	client    client.Client
-	daemonAPI daemonset.DaemonSetCreator
+	client      client.Client
+	daemonAPI   daemonset.DaemonSetCreator
+	metricsAPI  metrics.Metrics
+	kernelLabel string
+	recorder    record.EventRecorder
+
+	// startTime and restartCounts are used to count the restarts of module-loader containers, which happen when
+	// modprobe fails in the PostStart hook or when the health check finds the module unloaded.
+	startTime     time.Time
+	restartCounts map[types.NamespacedName]int32
+	restartsMutex sync.Mutex
 }
 
-func NewPodNodeModuleReconciler(client client.Client, daemonAPI daemonset.DaemonSetCreator) *PodNodeModuleReconciler {
-	return &PodNodeModuleReconciler{client: client, daemonAPI: daemonAPI}
+func NewPodNodeModuleReconciler(
+	client client.Client,
+	daemonAPI daemonset.DaemonSetCreator,
+	metricsAPI metrics.Metrics,
+	kernelLabel string,
+	recorder record.EventRecorder) *PodNodeModuleReconciler {
+	return &PodNodeModuleReconciler{
+		client:        client,
+		daemonAPI:     daemonAPI,
+		metricsAPI:    metricsAPI,
+		kernelLabel:   kernelLabel,
+		recorder:      recorder,
+		startTime:     time.Now(),
+		restartCounts: make(map[types.NamespacedName]int32),
+	}
 }
 
 func (pnmr *PodNodeModuleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -38,6 +71,7 @@ func (pnmr *PodNodeModuleReconciler) Reconcile(ctx context.Context, req ctrl.Req
 	if err := pnmr.client.Get(ctx, podNamespacedName, &pod); err != nil {
 		if k8serrors.IsNotFound(err) {
 			logger.Info("Pod not found")
+			pnmr.forgetRestarts(podNamespacedName)
 			return ctrl.Result{}, nil
 		}
 
@@ -51,7 +85,10 @@ func (pnmr *PodNodeModuleReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		return ctrl.Result{}, fmt.Errorf("pod %s has no %q label", podNamespacedName, constants.ModuleNameLabel)
 	}
 
+	pnmr.recordModprobeFailures(&pod, moduleName)
+
 	labelName := pnmr.daemonAPI.GetNodeLabelFromPod(&pod, moduleName)
+	infoLabels := pnmr.infoLabels(&pod, moduleName, labelName)
 
 	logger = logger.WithValues(
 		"node name", nodeName,
@@ -62,16 +99,26 @@ func (pnmr *PodNodeModuleReconciler) Reconcile(ctx context.Context, req ctrl.Req
 	if !podutils.IsPodReady(&pod) {
 		logger.Info("Unlabeling node")
 
-		if err := pnmr.deleteLabel(ctx, nodeName, labelName); err != nil {
+		if err := pnmr.deleteLabel(ctx, nodeName, labelName, infoLabels); err != nil {
 			return ctrl.Result{}, fmt.Errorf("could not unlabel node %s: %v", nodeName, err)
 		}
 
 		if !pod.DeletionTimestamp.IsZero() {
+			if job := daemonset.MakeBootConfigCleanupJob(&pod); job != nil {
+				logger.Info("Removing the modprobe configuration persisted on the node", "job", job.Name)
+
+				if err := pnmr.client.Create(ctx, job); err != nil && !k8serrors.IsAlreadyExists(err) {
+					return ctrl.Result{}, fmt.Errorf("could not create Job %s: %v", job.Name, err)
+				}
+			}
+
 			logger.Info("Pod deletion requested; removing finalizer")
 
 			if err := pnmr.deleteFinalizer(ctx, &pod); err != nil {
 				return ctrl.Result{}, fmt.Errorf("could not delete the pod finalizer: %v", err)
 			}
+
+			pnmr.forgetRestarts(podNamespacedName)
 		}
 
 		return ctrl.Result{}, nil
@@ -79,7 +126,7 @@ func (pnmr *PodNodeModuleReconciler) Reconcile(ctx context.Context, req ctrl.Req
 
 	logger.Info("Labeling node")
 
-	if err := pnmr.addLabel(ctx, nodeName, labelName); err != nil {
+	if err := pnmr.addLabel(ctx, nodeName, labelName, infoLabels); err != nil {
 		return ctrl.Result{}, fmt.Errorf("could not label node %s with %q: %v", nodeName, labelName, err)
 	}
 
@@ -94,6 +141,7 @@ func (pnmr *PodNodeModuleReconciler) SetupWithManager(mgr ctrl.Manager) error {
 				mgr.GetLogger().WithName("pod-readiness-changed"),
 			),
 			filter.DeletingPredicate(),
+			filter.PodRestartedPredicate(),
 		),
 		filter.HasLabel(constants.ModuleNameLabel),
 		filter.PodHasSpecNodeName(),
@@ -107,7 +155,79 @@ func (pnmr *PodNodeModuleReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Complete(pnmr)
 }
 
-func (pnmr *PodNodeModuleReconciler) addLabel(ctx context.Context, nodeName, labelName string) error {
+// recordModprobeFailures counts the restarts of module-loader pods since they were last seen, if the last one was caused
+// by the kernel module failing to load.
+// Restarts of pods that existed before the operator started and that were never seen are not counted.
+func (pnmr *PodNodeModuleReconciler) recordModprobeFailures(pod *v1.Pod, moduleName string) {
+	kernelVersion := pod.Labels[pnmr.kernelLabel]
+	if kernelVersion == "" || pod.Labels[constants.DaemonSetRole] == "device-plugin" {
+		// device plugin; per-kernel device plugin pods also carry the kernel label
+		return
+	}
+
+	nn := types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}
+	count := filter.PodRestartCount(pod)
+
+	pnmr.restartsMutex.Lock()
+
+	last, ok := pnmr.restartCounts[nn]
+	if !ok && pod.CreationTimestamp.Time.Before(pnmr.startTime) {
+		last = count
+	}
+
+	pnmr.restartCounts[nn] = count
+
+	pnmr.restartsMutex.Unlock()
+
+	if !lastTerminatedByModprobe(pod) {
+		return
+	}
+
+	for i := last; i < count; i++ {
+		pnmr.metricsAPI.IncModprobeFailures(moduleName, pod.Namespace, kernelVersion)
+	}
+}
+
+// lastTerminatedByModprobe returns whether the last termination of a container of pod was caused by its PostStart hook
+// failing to load the kernel module.
+func lastTerminatedByModprobe(pod *v1.Pod) bool {
+	for _, cs := range pod.Status.ContainerStatuses {
+		if t := cs.LastTerminationState.Terminated; t != nil && strings.TrimSpace(t.Message) == daemonset.ModprobeFailedMessage {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (pnmr *PodNodeModuleReconciler) forgetRestarts(nn types.NamespacedName) {
+	pnmr.restartsMutex.Lock()
+	defer pnmr.restartsMutex.Unlock()
+
+	delete(pnmr.restartCounts, nn)
+}
+
+// infoLabels returns the node labels describing the kernel module loaded by pod: its version and the short digest of
+// the module loader image.
+// Their value is empty if it is not known, and the map is empty for device plugin pods.
+func (pnmr *PodNodeModuleReconciler) infoLabels(pod *v1.Pod, moduleName, labelName string) map[string]string {
+	if labelName != daemonset.GetDriverContainerNodeLabel(moduleName) {
+		return map[string]string{}
+	}
+
+	return moduleLoaderInfoLabels(pod, moduleName)
+}
+
+// moduleLoaderInfoLabels returns the version and image digest node labels of the module loader pod of moduleName.
+func moduleLoaderInfoLabels(pod *v1.Pod, moduleName string) map[string]string {
+	return map[string]string{
+		daemonset.GetModuleVersionNodeLabel(moduleName): pod.Labels[constants.ModuleVersionLabel],
+		daemonset.GetImageDigestNodeLabel(moduleName):   daemonset.ShortImageDigest(pod),
+	}
+}
+
+// addLabel adds labelName and the non-empty infoLabels to the node, and removes the empty ones.
+func (pnmr *PodNodeModuleReconciler) addLabel(ctx context.Context, nodeName, labelName string, infoLabels map[string]string) error {
 	node := v1.Node{}
 
 	if err := pnmr.client.Get(ctx, types.NamespacedName{Name: nodeName}, &node); err != nil {
@@ -120,9 +240,27 @@ func (pnmr *PodNodeModuleReconciler) addLabel(ctx context.Context, nodeName, lab
 		node.Labels = make(map[string]string, 1)
 	}
 
+	_, alreadyLabeled := node.Labels[labelName]
+
 	node.Labels[labelName] = ""
 
-	return pnmr.client.Patch(ctx, &node, client.MergeFrom(nodeCopy))
+	for k, v := range infoLabels {
+		if v == "" {
+			delete(node.Labels, k)
+		} else {
+			node.Labels[k] = v
+		}
+	}
+
+	if err := pnmr.client.Patch(ctx, &node, client.MergeFrom(nodeCopy)); err != nil {
+		return err
+	}
+
+	if !alreadyLabeled {
+		pnmr.recorder.Eventf(&node, v1.EventTypeNormal, EventReasonNodeLabeled, "Added label %s", labelName)
+	}
+
+	return nil
 }
 
 func (pnmr *PodNodeModuleReconciler) deleteFinalizer(ctx context.Context, pod *v1.Pod) error {
@@ -133,16 +271,40 @@ func (pnmr *PodNodeModuleReconciler) deleteFinalizer(ctx context.Context, pod *v
 	return pnmr.client.Patch(ctx, pod, client.MergeFrom(podCopy))
 }
 
-func (pnmr *PodNodeModuleReconciler) deleteLabel(ctx context.Context, nodeName, labelName string) error {
+// deleteLabel removes labelName and infoLabels from the node.
+// It leaves the node untouched if infoLabels show that it was labeled by another pod, for instance the one replacing
+// pod during an upgrade.
+func (pnmr *PodNodeModuleReconciler) deleteLabel(ctx context.Context, nodeName, labelName string, infoLabels map[string]string) error {
 	node := v1.Node{}
 
 	if err := pnmr.client.Get(ctx, types.NamespacedName{Name: nodeName}, &node); err != nil {
 		return fmt.Errorf("could not get node %s: %v", nodeName, err)
 	}
 
+	for k, v := range infoLabels {
+		if nodeValue := node.Labels[k]; v != "" && nodeValue != "" && nodeValue != v {
+			ctrl.LoggerFrom(ctx).Info("Node labeled by another pod; not unlabeling", "label", k, "value", nodeValue)
+			return nil
+		}
+	}
+
 	nodeCopy := node.DeepCopy()
 
+	_, wasLabeled := node.Labels[labelName]
+
 	delete(node.Labels, labelName)
 
-	return pnmr.client.Patch(ctx, &node, client.MergeFrom(nodeCopy))
+	for k := range infoLabels {
+		delete(node.Labels, k)
+	}
+
+	if err := pnmr.client.Patch(ctx, &node, client.MergeFrom(nodeCopy)); err != nil {
+		return err
+	}
+
+	if wasLabeled {
+		pnmr.recorder.Eventf(&node, v1.EventTypeNormal, EventReasonNodeUnlabeled, "Removed label %s", labelName)
+	}
+
+	return nil
 }
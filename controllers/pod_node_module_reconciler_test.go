@@ -9,9 +9,14 @@ import (
 	mock_client "github.com/qbarrand/oot-operator/internal/client"
 	"github.com/qbarrand/oot-operator/internal/constants"
 	"github.com/qbarrand/oot-operator/internal/daemonset"
+	"github.com/qbarrand/oot-operator/internal/metrics"
+	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -27,16 +32,20 @@ var _ = Describe("PodNodeModuleReconciler", func() {
 		)
 
 		var (
-			kubeClient *mock_client.MockClient
-			r          *PodNodeModuleReconciler
-			mockDC     *daemonset.MockDaemonSetCreator
+			kubeClient  *mock_client.MockClient
+			r           *PodNodeModuleReconciler
+			mockDC      *daemonset.MockDaemonSetCreator
+			mockMetrics *metrics.MockMetrics
+			recorder    *record.FakeRecorder
 		)
 
 		BeforeEach(func() {
 			ctrl := gomock.NewController(GinkgoT())
 			kubeClient = mock_client.NewMockClient(ctrl)
 			mockDC = daemonset.NewMockDaemonSetCreator(ctrl)
-			r = NewPodNodeModuleReconciler(kubeClient, mockDC)
+			mockMetrics = metrics.NewMockMetrics(ctrl)
+			recorder = record.NewFakeRecorder(10)
+			r = NewPodNodeModuleReconciler(kubeClient, mockDC, mockMetrics, constants.KernelLabel, recorder)
 		})
 
 		ctx := context.Background()
@@ -96,6 +105,7 @@ var _ = Describe("PodNodeModuleReconciler", func() {
 
 			_, err := r.Reconcile(ctx, req)
 			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.Events).To(Receive(Equal("Normal " + EventReasonNodeUnlabeled + " Removed label " + nodeLabel)))
 		})
 
 		It("should label the node when a Pod is ready", func() {
@@ -146,6 +156,147 @@ var _ = Describe("PodNodeModuleReconciler", func() {
 					}),
 			)
 
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.Events).To(Receive(Equal("Normal " + EventReasonNodeLabeled + " Added label " + nodeLabel)))
+		})
+
+		It("should label the node with the version and image digest of module loader pods", func() {
+			readyLabel := daemonset.GetDriverContainerNodeLabel(moduleName)
+
+			gomock.InOrder(
+				kubeClient.EXPECT().Get(ctx, nn, gomock.Any()).Do(func(_ context.Context, _ types.NamespacedName, o client.Object) {
+					pod := o.(*v1.Pod)
+					pod.Labels = map[string]string{
+						constants.ModuleNameLabel:    moduleName,
+						constants.ModuleVersionLabel: "1.2.0",
+					}
+					pod.Spec.NodeName = nodeName
+					pod.Status.Conditions = []v1.PodCondition{
+						{
+							Type:   v1.PodReady,
+							Status: v1.ConditionTrue,
+						},
+					}
+					pod.Status.ContainerStatuses = []v1.ContainerStatus{
+						{Name: "module-loader", ImageID: "example.com/driver@sha256:0123456789abcdef0123456789abcdef"},
+					}
+				}),
+				mockDC.EXPECT().GetNodeLabelFromPod(gomock.Any(), moduleName).Return(readyLabel),
+				kubeClient.EXPECT().Get(ctx, types.NamespacedName{Name: nodeName}, gomock.Any()),
+				kubeClient.
+					EXPECT().
+					Patch(ctx, gomock.Any(), gomock.Any()).
+					Do(func(_ context.Context, n client.Object, p client.Patch, _ ...client.PatchOption) {
+						Expect(p.Data(n)).To(
+							MatchJSON(`{"metadata":{"labels":{
+								"kmm.node.kubernetes.io/module-name.digest":"0123456789ab",
+								"kmm.node.kubernetes.io/module-name.ready":"",
+								"kmm.node.kubernetes.io/module-name.version":"1.2.0"
+							}}}`),
+						)
+					}),
+			)
+
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.Events).To(Receive(Equal("Normal " + EventReasonNodeLabeled + " Added label " + readyLabel)))
+		})
+
+		It("should not unlabel a node that was labeled by another pod", func() {
+			readyLabel := daemonset.GetDriverContainerNodeLabel(moduleName)
+
+			gomock.InOrder(
+				kubeClient.EXPECT().Get(ctx, nn, gomock.Any()).Do(func(_ context.Context, _ types.NamespacedName, o client.Object) {
+					pod := o.(*v1.Pod)
+					pod.Labels = map[string]string{
+						constants.ModuleNameLabel:    moduleName,
+						constants.ModuleVersionLabel: "1.1.0",
+					}
+					pod.Spec.NodeName = nodeName
+				}),
+				mockDC.EXPECT().GetNodeLabelFromPod(gomock.Any(), moduleName).Return(readyLabel),
+				kubeClient.EXPECT().Get(ctx, types.NamespacedName{Name: nodeName}, gomock.Any()).Do(func(_ context.Context, _ types.NamespacedName, o client.Object) {
+					o.SetLabels(map[string]string{
+						readyLabel: "",
+						daemonset.GetModuleVersionNodeLabel(moduleName): "1.2.0",
+					})
+				}),
+			)
+
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.Events).NotTo(Receive())
+		})
+
+		It("should count the restarts of module-loader pods caused by modprobe as modprobe failures", func() {
+			const kernelVersion = "1.2.3"
+
+			setPod := func(restarts int32, message string) func(context.Context, types.NamespacedName, client.Object) {
+				return func(_ context.Context, _ types.NamespacedName, o client.Object) {
+					pod := o.(*v1.Pod)
+					pod.CreationTimestamp = metav1.Now()
+					pod.Labels = map[string]string{
+						constants.ModuleNameLabel: moduleName,
+						constants.KernelLabel:     kernelVersion,
+					}
+					pod.Name = podName
+					pod.Namespace = podNamespace
+					pod.Spec.NodeName = nodeName
+					pod.Status.ContainerStatuses = []v1.ContainerStatus{
+						{
+							RestartCount: restarts,
+							LastTerminationState: v1.ContainerState{
+								Terminated: &v1.ContainerStateTerminated{Message: message},
+							},
+						},
+					}
+				}
+			}
+
+			gomock.InOrder(
+				kubeClient.EXPECT().Get(ctx, nn, gomock.Any()).Do(setPod(2, daemonset.ModprobeFailedMessage+"\n")),
+				mockMetrics.EXPECT().IncModprobeFailures(moduleName, podNamespace, kernelVersion).Times(2),
+				mockDC.EXPECT().GetNodeLabelFromPod(gomock.Any(), moduleName).Return(nodeLabel),
+				kubeClient.EXPECT().Get(ctx, types.NamespacedName{Name: nodeName}, gomock.Any()),
+				kubeClient.EXPECT().Patch(ctx, gomock.Any(), gomock.Any()),
+				kubeClient.EXPECT().Get(ctx, nn, gomock.Any()).Do(setPod(3, daemonset.ModprobeFailedMessage)),
+				mockMetrics.EXPECT().IncModprobeFailures(moduleName, podNamespace, kernelVersion),
+				mockDC.EXPECT().GetNodeLabelFromPod(gomock.Any(), moduleName).Return(nodeLabel),
+				kubeClient.EXPECT().Get(ctx, types.NamespacedName{Name: nodeName}, gomock.Any()),
+				kubeClient.EXPECT().Patch(ctx, gomock.Any(), gomock.Any()),
+				kubeClient.EXPECT().Get(ctx, nn, gomock.Any()).Do(setPod(4, "")),
+				mockDC.EXPECT().GetNodeLabelFromPod(gomock.Any(), moduleName).Return(nodeLabel),
+				kubeClient.EXPECT().Get(ctx, types.NamespacedName{Name: nodeName}, gomock.Any()),
+				kubeClient.EXPECT().Patch(ctx, gomock.Any(), gomock.Any()),
+			)
+
+			for i := 0; i < 3; i++ {
+				_, err := r.Reconcile(ctx, req)
+				Expect(err).NotTo(HaveOccurred())
+			}
+		})
+
+		It("should not count the restarts of per-kernel device plugin pods", func() {
+			gomock.InOrder(
+				kubeClient.EXPECT().Get(ctx, nn, gomock.Any()).Do(func(_ context.Context, _ types.NamespacedName, o client.Object) {
+					pod := o.(*v1.Pod)
+					pod.CreationTimestamp = metav1.Now()
+					pod.Labels = map[string]string{
+						constants.DaemonSetRole:   "device-plugin",
+						constants.ModuleNameLabel: moduleName,
+						constants.KernelLabel:     "1.2.3",
+					}
+					pod.Name = podName
+					pod.Namespace = podNamespace
+					pod.Spec.NodeName = nodeName
+					pod.Status.ContainerStatuses = []v1.ContainerStatus{{RestartCount: 2}}
+				}),
+				mockDC.EXPECT().GetNodeLabelFromPod(gomock.Any(), moduleName).Return(nodeLabel),
+				kubeClient.EXPECT().Get(ctx, types.NamespacedName{Name: nodeName}, gomock.Any()),
+				kubeClient.EXPECT().Patch(ctx, gomock.Any(), gomock.Any()),
+			)
+
 			_, err := r.Reconcile(ctx, req)
 			Expect(err).NotTo(HaveOccurred())
 		})
@@ -217,6 +368,49 @@ var _ = Describe("PodNodeModuleReconciler", func() {
 					}),
 			)
 
+			_, err := r.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.Events).To(Receive(Equal("Normal " + EventReasonNodeUnlabeled + " Removed label " + nodeLabel)))
+		})
+
+		It("should remove the modprobe configuration persisted on the node when the pod is being deleted", func() {
+			now := metav1.Now()
+
+			gomock.InOrder(
+				kubeClient.
+					EXPECT().
+					Get(ctx, nn, gomock.Any()).
+					Do(func(_ context.Context, _ types.NamespacedName, o client.Object) {
+						pod := o.(*v1.Pod)
+						pod.Namespace = podNamespace
+						pod.UID = "some-uid"
+						pod.Labels = map[string]string{constants.ModuleNameLabel: moduleName}
+						pod.DeletionTimestamp = &now
+						pod.Finalizers = []string{constants.NodeLabelerFinalizer}
+						pod.Spec.NodeName = nodeName
+						pod.Spec.InitContainers = []v1.Container{
+							{
+								Name:    "persist-modprobe-config",
+								Command: []string{"sh", "-c", "some script", "some_kmod", ""},
+							},
+						}
+					}),
+				mockDC.EXPECT().GetNodeLabelFromPod(gomock.Any(), moduleName).Return(nodeLabel),
+				kubeClient.EXPECT().Get(ctx, types.NamespacedName{Name: nodeName}, gomock.Any()),
+				kubeClient.EXPECT().Patch(ctx, gomock.Any(), gomock.Any()),
+				kubeClient.
+					EXPECT().
+					Create(ctx, gomock.Any()).
+					Do(func(_ context.Context, o client.Object, _ ...client.CreateOption) {
+						job := o.(*batchv1.Job)
+						Expect(job.Name).To(Equal("boot-config-cleanup-some-uid"))
+						Expect(job.Namespace).To(Equal(podNamespace))
+						Expect(job.Spec.Template.Spec.NodeName).To(Equal(nodeName))
+					}).
+					Return(k8serrors.NewAlreadyExists(schema.GroupResource{Resource: "jobs"}, "boot-config-cleanup-some-uid")),
+				kubeClient.EXPECT().Patch(ctx, gomock.Any(), gomock.Any()),
+			)
+
 			_, err := r.Reconcile(ctx, req)
 			Expect(err).NotTo(HaveOccurred())
 		})
@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	"github.com/qbarrand/oot-operator/internal/filter"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	podSecurityLevelPrivileged = "privileged"
+
+	// PodSecurityOptOutAnnotation can be set to "false" on a namespace for the operator not to manage its Pod Security
+	// Admission labels.
+	PodSecurityOptOutAnnotation = "kmm.sigs.k8s.io/manage-pod-security-labels"
+
+	// podSecurityManagedAnnotation lists the Pod Security Admission labels set by the operator on a namespace, so that
+	// they can be removed once they are not needed anymore.
+	podSecurityManagedAnnotation = "kmm.sigs.k8s.io/pod-security-labels"
+
+	// podSecurityKeptAnnotation lists the label=level pairs that the operator did not change on a namespace, so that
+	// each of them is only reported once.
+	podSecurityKeptAnnotation = "kmm.sigs.k8s.io/pod-security-levels-kept"
+
+	EventReasonPodSecurityLevelKept = "PodSecurityLevelKept"
+)
+
+// podSecurityLabels are the Pod Security Admission labels that let module loader and device plugin pods run in a
+// namespace.
+var podSecurityLabels = []string{
+	"pod-security.kubernetes.io/enforce",
+	"pod-security.kubernetes.io/audit",
+	"pod-security.kubernetes.io/warn",
+}
+
+//+kubebuilder:rbac:groups="core",resources=namespaces,verbs=get;list;watch;patch
+//+kubebuilder:rbac:groups="core",resources=events,verbs=create;patch
+
+// PodSecurityLabelReconciler sets the privileged Pod Security Admission level on namespaces that contain Modules, so
+// that their module loader and device plugin pods are admitted.
+// Levels set by administrators are never changed, and the labels set by the operator are removed once the namespace
+// contains no Modules or opts out with PodSecurityOptOutAnnotation.
+type PodSecurityLabelReconciler struct {
+	client   client.Client
+	filter   *filter.Filter
+	recorder record.EventRecorder
+}
+
+func NewPodSecurityLabelReconciler(client client.Client, filter *filter.Filter, recorder record.EventRecorder) *PodSecurityLabelReconciler {
+	return &PodSecurityLabelReconciler{
+		client:   client,
+		filter:   filter,
+		recorder: recorder,
+	}
+}
+
+func (r *PodSecurityLabelReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	if !r.filter.ModuleNamespaceAllowed(req.Name) {
+		return ctrl.Result{}, nil
+	}
+
+	ns := v1.Namespace{}
+
+	if err := r.client.Get(ctx, client.ObjectKey{Name: req.Name}, &ns); err != nil {
+		if k8serrors.IsNotFound(err) {
+			logger.Info("Namespace not found")
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, fmt.Errorf("could not get namespace %s: %w", req.Name, err)
+	}
+
+	if ns.DeletionTimestamp != nil {
+		return ctrl.Result{}, nil
+	}
+
+	mods := kmmv1beta1.ModuleList{}
+
+	if err := r.client.List(ctx, &mods, client.InNamespace(req.Name)); err != nil {
+		return ctrl.Result{}, fmt.Errorf("could not list Modules in namespace %s: %w", req.Name, err)
+	}
+
+	patchFrom := client.MergeFrom(ns.DeepCopy())
+
+	managed := annotationSet(&ns, podSecurityManagedAnnotation)
+	reported := annotationSet(&ns, podSecurityKeptAnnotation)
+	kept := sets.NewString()
+
+	changed := false
+
+	if ns.Annotations[PodSecurityOptOutAnnotation] != "false" && len(mods.Items) > 0 {
+		for _, l := range podSecurityLabels {
+			level, ok := ns.Labels[l]
+
+			switch {
+			case !ok:
+				if ns.Labels == nil {
+					ns.Labels = make(map[string]string, len(podSecurityLabels))
+				}
+
+				ns.Labels[l] = podSecurityLevelPrivileged
+				managed.Insert(l)
+				changed = true
+			case level != podSecurityLevelPrivileged:
+				// The level was set or changed by an administrator after the operator set it.
+				if managed.Has(l) {
+					managed.Delete(l)
+					changed = true
+				}
+
+				entry := l + "=" + level
+
+				kept.Insert(entry)
+
+				if !reported.Has(entry) {
+					r.recorder.Eventf(
+						&ns,
+						v1.EventTypeWarning,
+						EventReasonPodSecurityLevelKept,
+						"Not changing label %s set to %s; module loader and device plugin pods may not be admitted",
+						l,
+						level,
+					)
+				}
+			}
+		}
+	} else if managed.Len() > 0 {
+		for _, l := range managed.List() {
+			if ns.Labels[l] == podSecurityLevelPrivileged {
+				delete(ns.Labels, l)
+			}
+		}
+
+		managed = sets.NewString()
+		changed = true
+	}
+
+	if !kept.Equal(reported) {
+		changed = true
+	}
+
+	if !changed {
+		return ctrl.Result{}, nil
+	}
+
+	setAnnotationSet(&ns, podSecurityManagedAnnotation, managed)
+	setAnnotationSet(&ns, podSecurityKeptAnnotation, kept)
+
+	logger.Info("Updating Pod Security Admission labels", "labels", managed.List(), "kept", kept.List())
+
+	if err := r.client.Patch(ctx, &ns, patchFrom); err != nil {
+		return ctrl.Result{}, fmt.Errorf("could not patch namespace %s: %w", req.Name, err)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// annotationSet returns the comma-separated values of the key annotation of ns.
+func annotationSet(ns *v1.Namespace, key string) sets.String {
+	s := sets.NewString()
+
+	if v := ns.Annotations[key]; v != "" {
+		s.Insert(strings.Split(v, ",")...)
+	}
+
+	return s
+}
+
+// setAnnotationSet sets the key annotation of ns to the comma-separated values of s, or removes it if s is empty.
+func setAnnotationSet(ns *v1.Namespace, key string, s sets.String) {
+	if s.Len() == 0 {
+		delete(ns.Annotations, key)
+		return
+	}
+
+	if ns.Annotations == nil {
+		ns.Annotations = make(map[string]string, 1)
+	}
+
+	ns.Annotations[key] = strings.Join(s.List(), ",")
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PodSecurityLabelReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1.Namespace{}).
+		Watches(
+			&source.Kind{Type: &kmmv1beta1.Module{}},
+			handler.EnqueueRequestsFromMapFunc(r.filter.FindNamespaceForModule),
+		).
+		Named("pod-security-label").
+		Complete(r)
+}
@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	"github.com/qbarrand/oot-operator/internal/client"
+	"github.com/qbarrand/oot-operator/internal/filter"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	runtimectrl "sigs.k8s.io/controller-runtime"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("PodSecurityLabelReconciler", func() {
+	Describe("Reconcile", func() {
+		const namespace = "some-namespace"
+
+		var (
+			ctx      context.Context
+			clnt     *client.MockClient
+			recorder *record.FakeRecorder
+			r        *PodSecurityLabelReconciler
+		)
+
+		BeforeEach(func() {
+			ctx = context.Background()
+			clnt = client.NewMockClient(gomock.NewController(GinkgoT()))
+			recorder = record.NewFakeRecorder(10)
+			r = NewPodSecurityLabelReconciler(clnt, allNamespaces, recorder)
+		})
+
+		req := runtimectrl.Request{NamespacedName: types.NamespacedName{Name: namespace}}
+
+		listModules := func(n int) *gomock.Call {
+			return clnt.EXPECT().List(ctx, &kmmv1beta1.ModuleList{}, runtimeclient.InNamespace(namespace)).DoAndReturn(
+				func(_ interface{}, list *kmmv1beta1.ModuleList, _ ...interface{}) error {
+					list.Items = make([]kmmv1beta1.Module, n)
+					return nil
+				},
+			)
+		}
+
+		getNamespace := func(labels, annotations map[string]string) *gomock.Call {
+			return clnt.EXPECT().Get(ctx, types.NamespacedName{Name: namespace}, gomock.Any()).DoAndReturn(
+				func(_ interface{}, _ interface{}, ns *v1.Namespace) error {
+					ns.ObjectMeta = metav1.ObjectMeta{Name: namespace, Labels: labels, Annotations: annotations}
+					return nil
+				},
+			)
+		}
+
+		It("should do nothing if Modules are not allowed in the namespace", func() {
+			r = NewPodSecurityLabelReconciler(clnt, filter.New(nil, logr.Discard(), nil, []string{"other-namespace"}), recorder)
+
+			Expect(r.Reconcile(ctx, req)).To(Equal(runtimectrl.Result{}))
+		})
+
+		It("should do nothing if the namespace opted out", func() {
+			gomock.InOrder(
+				getNamespace(nil, map[string]string{PodSecurityOptOutAnnotation: "false"}),
+				listModules(1),
+			)
+
+			Expect(r.Reconcile(ctx, req)).To(Equal(runtimectrl.Result{}))
+		})
+
+		It("should do nothing if there are no Modules in the namespace", func() {
+			gomock.InOrder(
+				getNamespace(nil, nil),
+				listModules(0),
+			)
+
+			Expect(r.Reconcile(ctx, req)).To(Equal(runtimectrl.Result{}))
+		})
+
+		It("should do nothing if the namespace already has the labels", func() {
+			gomock.InOrder(
+				getNamespace(
+					map[string]string{
+						"pod-security.kubernetes.io/enforce": "privileged",
+						"pod-security.kubernetes.io/audit":   "privileged",
+						"pod-security.kubernetes.io/warn":    "privileged",
+					},
+					nil,
+				),
+				listModules(1),
+			)
+
+			Expect(r.Reconcile(ctx, req)).To(Equal(runtimectrl.Result{}))
+		})
+
+		It("should set the privileged level on the namespace without changing the levels set by administrators", func() {
+			gomock.InOrder(
+				getNamespace(
+					map[string]string{
+						"pod-security.kubernetes.io/enforce": "restricted",
+						"other":                              "label",
+					},
+					nil,
+				),
+				listModules(1),
+				clnt.EXPECT().Patch(ctx, gomock.Any(), gomock.Any()).Do(
+					func(_ interface{}, ns *v1.Namespace, _ runtimeclient.Patch, _ ...runtimeclient.PatchOption) {
+						Expect(ns.Labels).To(Equal(map[string]string{
+							"pod-security.kubernetes.io/enforce": "restricted",
+							"pod-security.kubernetes.io/audit":   "privileged",
+							"pod-security.kubernetes.io/warn":    "privileged",
+							"other":                              "label",
+						}))
+						Expect(ns.Annotations).To(Equal(map[string]string{
+							"kmm.sigs.k8s.io/pod-security-labels":      "pod-security.kubernetes.io/audit,pod-security.kubernetes.io/warn",
+							"kmm.sigs.k8s.io/pod-security-levels-kept": "pod-security.kubernetes.io/enforce=restricted",
+						}))
+					},
+				),
+			)
+
+			Expect(r.Reconcile(ctx, req)).To(Equal(runtimectrl.Result{}))
+			Expect(recorder.Events).To(Receive(Equal(
+				"Warning " + EventReasonPodSecurityLevelKept +
+					" Not changing label pod-security.kubernetes.io/enforce set to restricted; module loader and device plugin pods may not be admitted",
+			)))
+		})
+
+		It("should not report a kept level again", func() {
+			gomock.InOrder(
+				getNamespace(
+					map[string]string{
+						"pod-security.kubernetes.io/enforce": "restricted",
+						"pod-security.kubernetes.io/audit":   "privileged",
+						"pod-security.kubernetes.io/warn":    "privileged",
+					},
+					map[string]string{
+						"kmm.sigs.k8s.io/pod-security-labels":      "pod-security.kubernetes.io/audit,pod-security.kubernetes.io/warn",
+						"kmm.sigs.k8s.io/pod-security-levels-kept": "pod-security.kubernetes.io/enforce=restricted",
+					},
+				),
+				listModules(1),
+			)
+
+			Expect(r.Reconcile(ctx, req)).To(Equal(runtimectrl.Result{}))
+			Expect(recorder.Events).NotTo(Receive())
+		})
+
+		It("should report a kept level again once it changed", func() {
+			gomock.InOrder(
+				getNamespace(
+					map[string]string{
+						"pod-security.kubernetes.io/enforce": "baseline",
+						"pod-security.kubernetes.io/audit":   "privileged",
+						"pod-security.kubernetes.io/warn":    "privileged",
+					},
+					map[string]string{
+						"kmm.sigs.k8s.io/pod-security-labels":      "pod-security.kubernetes.io/audit,pod-security.kubernetes.io/warn",
+						"kmm.sigs.k8s.io/pod-security-levels-kept": "pod-security.kubernetes.io/enforce=restricted",
+					},
+				),
+				listModules(1),
+				clnt.EXPECT().Patch(ctx, gomock.Any(), gomock.Any()).Do(
+					func(_ interface{}, ns *v1.Namespace, _ runtimeclient.Patch, _ ...runtimeclient.PatchOption) {
+						Expect(ns.Annotations).To(HaveKeyWithValue(
+							"kmm.sigs.k8s.io/pod-security-levels-kept",
+							"pod-security.kubernetes.io/enforce=baseline",
+						))
+					},
+				),
+			)
+
+			Expect(r.Reconcile(ctx, req)).To(Equal(runtimectrl.Result{}))
+			Expect(recorder.Events).To(Receive(Equal(
+				"Warning " + EventReasonPodSecurityLevelKept +
+					" Not changing label pod-security.kubernetes.io/enforce set to baseline; module loader and device plugin pods may not be admitted",
+			)))
+		})
+
+		It("should remove the labels it set once the namespace contains no Modules", func() {
+			gomock.InOrder(
+				getNamespace(
+					map[string]string{
+						"pod-security.kubernetes.io/enforce": "privileged",
+						"pod-security.kubernetes.io/audit":   "baseline",
+						"pod-security.kubernetes.io/warn":    "privileged",
+					},
+					map[string]string{
+						"kmm.sigs.k8s.io/pod-security-labels": "pod-security.kubernetes.io/audit,pod-security.kubernetes.io/enforce",
+					},
+				),
+				listModules(0),
+				clnt.EXPECT().Patch(ctx, gomock.Any(), gomock.Any()).Do(
+					func(_ interface{}, ns *v1.Namespace, _ runtimeclient.Patch, _ ...runtimeclient.PatchOption) {
+						Expect(ns.Labels).To(Equal(map[string]string{
+							"pod-security.kubernetes.io/audit": "baseline",
+							"pod-security.kubernetes.io/warn":  "privileged",
+						}))
+						Expect(ns.Annotations).To(BeEmpty())
+					},
+				),
+			)
+
+			Expect(r.Reconcile(ctx, req)).To(Equal(runtimectrl.Result{}))
+		})
+
+		It("should remove the labels it set once the namespace opts out", func() {
+			gomock.InOrder(
+				getNamespace(
+					map[string]string{"pod-security.kubernetes.io/enforce": "privileged"},
+					map[string]string{
+						PodSecurityOptOutAnnotation:           "false",
+						"kmm.sigs.k8s.io/pod-security-labels": "pod-security.kubernetes.io/enforce",
+					},
+				),
+				listModules(1),
+				clnt.EXPECT().Patch(ctx, gomock.Any(), gomock.Any()).Do(
+					func(_ interface{}, ns *v1.Namespace, _ runtimeclient.Patch, _ ...runtimeclient.PatchOption) {
+						Expect(ns.Labels).To(BeEmpty())
+						Expect(ns.Annotations).To(Equal(map[string]string{PodSecurityOptOutAnnotation: "false"}))
+					},
+				),
+			)
+
+			Expect(r.Reconcile(ctx, req)).To(Equal(runtimectrl.Result{}))
+		})
+	})
+})
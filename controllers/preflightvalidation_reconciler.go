@@ -18,27 +18,42 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
+	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	"github.com/qbarrand/oot-operator/internal/config"
 	"github.com/qbarrand/oot-operator/internal/filter"
+	"github.com/qbarrand/oot-operator/internal/metrics"
 	"github.com/qbarrand/oot-operator/internal/preflight"
 	"github.com/qbarrand/oot-operator/internal/statusupdater"
 	"github.com/qbarrand/oot-operator/internal/utils"
 )
 
-const reconcileRequeueInSeconds = 60
+const (
+	reconcileRequeueInSeconds = 60
+	reportConfigMapSuffix     = "-report"
+
+	EventReasonModuleVerified           = "ModuleVerified"
+	EventReasonModuleVerificationFailed = "ModuleVerificationFailed"
+)
 
 // ClusterPreflightReconciler reconciles a PreflightValidation object
 type PreflightValidationReconciler struct {
@@ -46,24 +61,43 @@ type PreflightValidationReconciler struct {
 	filter        *filter.Filter
 	statusUpdater statusupdater.PreflightStatusUpdater
 	preflight     preflight.PreflightAPI
+	metricsAPI    metrics.Metrics
+	scheme        *runtime.Scheme
+	cfgProvider   config.Provider
+	recorder      record.EventRecorder
 }
 
+// NewPreflightValidationReconciler returns a reconciler that checks up to the configured number of Modules at the same
+// time, each one for at most the configured module timeout.
 func NewPreflightValidationReconciler(
 	client client.Client,
 	filter *filter.Filter,
 	statusUpdater statusupdater.PreflightStatusUpdater,
-	preflight preflight.PreflightAPI) *PreflightValidationReconciler {
+	preflight preflight.PreflightAPI,
+	metricsAPI metrics.Metrics,
+	scheme *runtime.Scheme,
+	cfgProvider config.Provider,
+	recorder record.EventRecorder) *PreflightValidationReconciler {
 	return &PreflightValidationReconciler{
 		client:        client,
 		filter:        filter,
 		statusUpdater: statusUpdater,
-		preflight:     preflight}
+		preflight:     preflight,
+		metricsAPI:    metricsAPI,
+		scheme:        scheme,
+		cfgProvider:   cfgProvider,
+		recorder:      recorder,
+	}
 }
 
 func (r *PreflightValidationReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		Named("preflightvalidation").
-		For(&kmmv1beta1.PreflightValidation{}).
+		For(
+			&kmmv1beta1.PreflightValidation{},
+			// do not reconcile again on our own status updates
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+		).
 		Watches(
 			&source.Kind{Type: &kmmv1beta1.Module{}},
 			handler.EnqueueRequestsFromMapFunc(r.filter.EnqueueAllPreflightValidations),
@@ -78,6 +112,8 @@ func (r *PreflightValidationReconciler) SetupWithManager(mgr ctrl.Manager) error
 //+kubebuilder:rbac:groups=kmm.sigs.k8s.io,resources=modules,verbs=get;list;watch
 //+kubebuilder:rbac:groups=kmm.sigs.k8s.io,resources=preflightvalidations,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=kmm.sigs.k8s.io,resources=preflightvalidations/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="core",resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups="core",resources=configmaps,verbs=create;get;list;patch;update;watch
 
 // Reconcile Reconiliation entry point
 func (r *PreflightValidationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -89,13 +125,26 @@ func (r *PreflightValidationReconciler) Reconcile(ctx context.Context, req ctrl.
 	if err != nil {
 		if apierrors.IsNotFound(err) {
 			log.Info("Reconciliation object not found; not reconciling")
+			r.metricsAPI.DeletePreflightResults(req.Name, req.Namespace)
 			return ctrl.Result{}, nil
 		}
 		log.Error(err, "preflight validation reconcile failed to find object")
 		return ctrl.Result{}, err
 	}
 
-	reconCompleted, err := r.runPreflightValidation(ctx, &pv)
+	modules, err := r.getSelectedModules(ctx, &pv)
+	if err != nil {
+		log.Error(err, "failed to get the Modules selected by the preflight")
+		return ctrl.Result{}, err
+	}
+
+	// Completed is a terminal phase, until the PreflightValidation or the Modules it selects change.
+	if pv.Status.Summary.InProgress == 0 && pv.Status.ObservedGeneration == pv.Generation && statusesUpToDate(&pv, modules) {
+		log.Info("PreflightValidation already completed for these Modules; not reconciling")
+		return ctrl.Result{}, nil
+	}
+
+	reconCompleted, err := r.runPreflightValidation(ctx, &pv, modules)
 	if err != nil {
 		log.Error(err, "runPreflightValidation failed")
 		return ctrl.Result{}, err
@@ -109,64 +158,296 @@ func (r *PreflightValidationReconciler) Reconcile(ctx context.Context, req ctrl.
 	return ctrl.Result{RequeueAfter: time.Second * reconcileRequeueInSeconds}, nil
 }
 
-func (r *PreflightValidationReconciler) runPreflightValidation(ctx context.Context, pv *kmmv1beta1.PreflightValidation) (bool, error) {
+func (r *PreflightValidationReconciler) runPreflightValidation(ctx context.Context, pv *kmmv1beta1.PreflightValidation, modules []kmmv1beta1.Module) (bool, error) {
 	log := ctrl.LoggerFrom(ctx)
 
-	modulesToCheck, err := r.getModulesToCheck(ctx, pv)
+	kernels, err := r.preflight.GetKernelVersions(ctx, pv)
+	if err != nil {
+		return false, fmt.Errorf("failed to get the kernel versions to check for preflight: %w", err)
+	}
+
+	modulesToCheck, err := r.getModulesToCheck(ctx, pv, modules)
 	if err != nil {
 		return false, fmt.Errorf("failed to get list of modules to check for preflight: %w", err)
 	}
 
-	for _, module := range modulesToCheck {
-		log.Info("start module preflight validation", "name", module.Name)
+	summary := kmmv1beta1.PreflightSummary{}
+	inProgress := sets.NewString()
 
-		verified, message := r.preflight.PreflightUpgradeCheck(ctx, &module, pv.Spec.KernelVersion)
+	for res := range r.checkModules(ctx, pv.DeepCopy(), modulesToCheck, kernels) {
+		log.Info("module preflight validation result", "module", res.statusKey, "verified", res.Verified, "requeue", res.Requeue)
 
-		log.Info("module preflight validation result", "name", module.Name, "verified", verified)
+		r.updatePreflightStatus(ctx, pv, res.statusKey, res.Result)
 
-		r.updatePreflightStatus(ctx, pv, module.Name, message, verified)
+		switch {
+		case res.Requeue:
+			inProgress.Insert(res.statusKey)
+		case res.Verified:
+			r.recorder.Eventf(pv, v1.EventTypeNormal, EventReasonModuleVerified, "Module %s verified: %s", res.statusKey, res.Message)
+		default:
+			r.recorder.Eventf(pv, v1.EventTypeWarning, EventReasonModuleVerificationFailed, "Module %s failed verification: %s", res.statusKey, res.Message)
+		}
 	}
 
-	return r.checkPreflightCompletion(ctx, pv.Name, pv.Namespace)
+	summary.InProgress = int32(inProgress.Len())
+
+	for _, crStatus := range pv.Status.CRStatuses {
+		if crStatus.VerificationStatus == kmmv1beta1.VerificationTrue {
+			summary.Verified++
+		}
+	}
+
+	summary.Failed = int32(len(pv.Status.CRStatuses)) - summary.Verified - summary.InProgress
+
+	if err = r.statusUpdater.PreflightSetSummary(ctx, pv, summary); err != nil {
+		return false, fmt.Errorf("failed to update the preflight summary: %w", err)
+	}
+
+	r.metricsAPI.SetPreflightResults(pv.Name, pv.Namespace, int(summary.Verified), int(summary.Failed))
+
+	if err = r.writeReport(ctx, pv, inProgress); err != nil {
+		return false, fmt.Errorf("failed to write the preflight report: %w", err)
+	}
+
+	return summary.InProgress == 0, nil
 }
 
-func (r *PreflightValidationReconciler) getModulesToCheck(ctx context.Context, pv *kmmv1beta1.PreflightValidation) ([]kmmv1beta1.Module, error) {
-	log := ctrl.LoggerFrom(ctx)
+// writeReport writes the JSON and JUnit reports of pv into a ConfigMap owned by pv, and references that ConfigMap
+// from the status of pv.
+func (r *PreflightValidationReconciler) writeReport(ctx context.Context, pv *kmmv1beta1.PreflightValidation, inProgress sets.String) error {
+	report := preflight.NewValidationReport(pv, inProgress)
 
-	modulesList := kmmv1beta1.ModuleList{}
-	err := r.client.List(ctx, &modulesList)
+	jsonReport, err := report.JSON()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get list of all Modules: %w", err)
+		return fmt.Errorf("could not generate the JSON report: %w", err)
 	}
 
-	err = r.presetModulesStatuses(ctx, pv, modulesList.Items)
+	junitReport, err := report.JUnit()
+	if err != nil {
+		return fmt.Errorf("could not generate the JUnit report: %w", err)
+	}
+
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pv.Name + reportConfigMapSuffix,
+			Namespace: pv.Namespace,
+		},
+	}
+
+	opRes, err := controllerutil.CreateOrPatch(ctx, r.client, cm, func() error {
+		cm.Data = map[string]string{
+			preflight.ReportJSONKey:  string(jsonReport),
+			preflight.ReportJUnitKey: string(junitReport),
+		}
+
+		return controllerutil.SetControllerReference(pv, cm, r.scheme)
+	})
+	if err != nil {
+		return fmt.Errorf("could not create or patch ConfigMap %s: %w", cm.Name, err)
+	}
+
+	ctrl.LoggerFrom(ctx).Info("Reconciled preflight report", "name", cm.Name, "result", opRes)
+
+	if ref := pv.Status.ReportConfigMap; ref != nil && ref.Name == cm.Name {
+		return nil
+	}
+
+	return r.statusUpdater.PreflightSetReportConfigMap(ctx, pv, cm.Name)
+}
+
+type moduleResult struct {
+	preflight.Result
+
+	statusKey string
+}
+
+// checkModules checks modules using as many workers as the configured preflight concurrency and sends the results to the returned channel, which is
+// closed once all modules were checked.
+// pv is shared between workers and must not be modified until all results were received.
+func (r *PreflightValidationReconciler) checkModules(
+	ctx context.Context,
+	pv *kmmv1beta1.PreflightValidation,
+	modules []kmmv1beta1.Module,
+	kernels []preflight.Kernel) <-chan moduleResult {
+
+	modulesCh := make(chan *kmmv1beta1.Module)
+	results := make(chan moduleResult)
+
+	wg := sync.WaitGroup{}
+
+	concurrency := r.cfgProvider.Current().Preflight.Concurrency
+
+	for i := 0; i < concurrency && i < len(modules); i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for mod := range modulesCh {
+				results <- moduleResult{
+					Result:    r.checkModule(ctx, pv, mod, kernels),
+					statusKey: preflight.CRStatusKey(mod),
+				}
+			}
+		}()
+	}
+
+	go func() {
+		for i := range modules {
+			modulesCh <- &modules[i]
+		}
+
+		close(modulesCh)
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
+
+func (r *PreflightValidationReconciler) checkModule(
+	ctx context.Context,
+	pv *kmmv1beta1.PreflightValidation,
+	mod *kmmv1beta1.Module,
+	kernels []preflight.Kernel) preflight.Result {
+
+	ctrl.LoggerFrom(ctx).Info("start module preflight validation", "name", mod.Name, "namespace", mod.Namespace)
+
+	timeout := r.cfgProvider.Current().Preflight.ModuleTimeout.Duration
+
+	moduleCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	res := r.preflight.PreflightUpgradeCheck(moduleCtx, pv, mod, kernels)
+
+	if errors.Is(moduleCtx.Err(), context.DeadlineExceeded) {
+		return preflight.Result{
+			Message: fmt.Sprintf("Verification of module %s timed out after %v", mod.Name, timeout),
+			Stage:   res.Stage,
+		}
+	}
+
+	return res
+}
+
+func (r *PreflightValidationReconciler) getModulesToCheck(ctx context.Context, pv *kmmv1beta1.PreflightValidation, modules []kmmv1beta1.Module) ([]kmmv1beta1.Module, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	err := r.presetModulesStatuses(ctx, pv, modules)
 	if err != nil {
 		return nil, fmt.Errorf("failed to preset new modules' statuses: %w", err)
 	}
 
-	modulesToCheck := make([]kmmv1beta1.Module, 0, len(modulesList.Items))
-	for _, module := range modulesList.Items {
+	modulesToCheck := make([]kmmv1beta1.Module, 0, len(modules))
+	for _, module := range modules {
 		if module.GetDeletionTimestamp() != nil {
-			log.Info("Module is marked for deletion, skipping preflight validation", "name", module.Name)
+			log.Info("Module is marked for deletion, skipping preflight validation", "name", module.Name, "namespace", module.Namespace)
 			continue
 		}
-		if pv.Status.CRStatuses[module.Name].VerificationStatus != kmmv1beta1.VerificationTrue {
+		if pv.Status.CRStatuses[preflight.CRStatusKey(&module)].VerificationStatus != kmmv1beta1.VerificationTrue {
 			modulesToCheck = append(modulesToCheck, module)
 		}
 	}
 	return modulesToCheck, nil
 }
 
-func (r *PreflightValidationReconciler) updatePreflightStatus(ctx context.Context, pv *kmmv1beta1.PreflightValidation, moduleName, message string, verified bool) {
+// getSelectedModules returns the Modules matching the selector of pv, in the namespaces matching its namespace
+// selector and in which Modules are allowed.
+func (r *PreflightValidationReconciler) getSelectedModules(ctx context.Context, pv *kmmv1beta1.PreflightValidation) ([]kmmv1beta1.Module, error) {
+	opts := make([]client.ListOption, 0, 1)
+
+	if pv.Spec.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(pv.Spec.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector: %w", err)
+		}
+
+		opts = append(opts, client.MatchingLabelsSelector{Selector: selector})
+	}
+
+	modulesList := kmmv1beta1.ModuleList{}
+	if err := r.client.List(ctx, &modulesList, opts...); err != nil {
+		return nil, fmt.Errorf("failed to list Modules: %w", err)
+	}
+
+	// Modules that are not reconciled are not checked either
+	modules := make([]kmmv1beta1.Module, 0, len(modulesList.Items))
+	for _, mod := range modulesList.Items {
+		if r.filter.ModuleNamespaceAllowed(mod.Namespace) {
+			modules = append(modules, mod)
+		}
+	}
+
+	if pv.Spec.NamespaceSelector == nil {
+		return modules, nil
+	}
+
+	nsSelector, err := metav1.LabelSelectorAsSelector(pv.Spec.NamespaceSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid namespace selector: %w", err)
+	}
+
+	nsList := v1.NamespaceList{}
+	if err = r.client.List(ctx, &nsList, client.MatchingLabelsSelector{Selector: nsSelector}); err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+
+	namespaces := sets.NewString()
+	for _, ns := range nsList.Items {
+		namespaces.Insert(ns.Name)
+	}
+
+	selected := make([]kmmv1beta1.Module, 0, len(modules))
+	for _, mod := range modules {
+		if namespaces.Has(mod.Namespace) {
+			selected = append(selected, mod)
+		}
+	}
+
+	return selected, nil
+}
+
+func (r *PreflightValidationReconciler) updatePreflightStatus(ctx context.Context, pv *kmmv1beta1.PreflightValidation, moduleKey string, res preflight.Result) {
 	log := ctrl.LoggerFrom(ctx)
+
+	if crStatus, ok := pv.Status.CRStatuses[moduleKey]; ok && crStatus.VerificationStage != res.Stage {
+		if err := r.statusUpdater.PreflightSetVerificationStage(ctx, pv, moduleKey, res.Stage); err != nil {
+			log.Info(utils.WarnString("failed to update the stage of Module CR in preflight"), "module", moduleKey, "error", err)
+		}
+	}
+
 	verificationStatus := kmmv1beta1.VerificationFalse
-	if verified {
+	if res.Verified {
 		verificationStatus = kmmv1beta1.VerificationTrue
 	}
-	err := r.statusUpdater.PreflightSetVerificationStatus(ctx, pv, moduleName, verificationStatus, message)
+	err := r.statusUpdater.PreflightSetVerificationStatus(ctx, pv, moduleKey, verificationStatus, res.Message, res.CheckedImages)
 	if err != nil {
-		log.Info(utils.WarnString("failed to update the status of Module CR in preflight"), "module", moduleName, "error", err)
+		log.Info(utils.WarnString("failed to update the status of Module CR in preflight"), "module", moduleKey, "error", err)
+	}
+}
+
+// statusesUpToDate returns true if pv has a status for each of modules that are not being deleted, at their current
+// generation, and no other status.
+func statusesUpToDate(pv *kmmv1beta1.PreflightValidation, modules []kmmv1beta1.Module) bool {
+	keys := sets.NewString()
+
+	for i := range modules {
+		mod := &modules[i]
+
+		if mod.GetDeletionTimestamp() != nil {
+			continue
+		}
+
+		key := preflight.CRStatusKey(mod)
+
+		if status, ok := pv.Status.CRStatuses[key]; !ok || status.ModuleGeneration != mod.Generation {
+			return false
+		}
+
+		keys.Insert(key)
 	}
+
+	return keys.Equal(sets.StringKeySet(pv.Status.CRStatuses))
 }
 
 func (r *PreflightValidationReconciler) presetModulesStatuses(ctx context.Context, pv *kmmv1beta1.PreflightValidation, modules []kmmv1beta1.Module) error {
@@ -174,33 +455,18 @@ func (r *PreflightValidationReconciler) presetModulesStatuses(ctx context.Contex
 		pv.Status.CRStatuses = make(map[string]*kmmv1beta1.CRStatus, len(modules))
 	}
 	existingModulesName := sets.NewString()
-	newModulesNames := make([]string, 0, len(modules))
+	newModulesNames := make(map[string]int64)
 	for _, module := range modules {
 		if module.GetDeletionTimestamp() != nil {
 			continue
 		}
-		existingModulesName.Insert(module.Name)
-		if _, ok := pv.Status.CRStatuses[module.Name]; ok {
+		key := preflight.CRStatusKey(&module)
+		existingModulesName.Insert(key)
+		// Modules that changed since they were verified are verified again
+		if status, ok := pv.Status.CRStatuses[key]; ok && status.ModuleGeneration == module.Generation {
 			continue
 		}
-		newModulesNames = append(newModulesNames, module.Name)
+		newModulesNames[key] = module.Generation
 	}
 	return r.statusUpdater.PreflightPresetStatuses(ctx, pv, existingModulesName, newModulesNames)
 }
-
-func (r *PreflightValidationReconciler) checkPreflightCompletion(ctx context.Context, name, namespace string) (bool, error) {
-	pv := kmmv1beta1.PreflightValidation{}
-	err := r.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &pv)
-	if err != nil {
-		return false, fmt.Errorf("failed to get preflight validation object in checkPreflightCompletion: %w", err)
-	}
-
-	for modName, crStatus := range pv.Status.CRStatuses {
-		if crStatus.VerificationStatus != kmmv1beta1.VerificationTrue {
-			ctrl.LoggerFrom(ctx).Info("at least one Module is not verified yet", "module", modName, "status", crStatus.VerificationStatus)
-			return false, nil
-		}
-	}
-
-	return true, nil
-}
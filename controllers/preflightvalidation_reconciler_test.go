@@ -5,18 +5,25 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/go-logr/logr"
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
 	"github.com/qbarrand/oot-operator/internal/client"
+	"github.com/qbarrand/oot-operator/internal/config"
+	"github.com/qbarrand/oot-operator/internal/filter"
+	"github.com/qbarrand/oot-operator/internal/metrics"
 	"github.com/qbarrand/oot-operator/internal/preflight"
 	"github.com/qbarrand/oot-operator/internal/statusupdater"
+	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -24,12 +31,24 @@ const (
 	preflightName = "test-preflight"
 )
 
+func preflightConfig(concurrency int, moduleTimeout time.Duration) config.Provider {
+	cfg := config.NewDefault()
+	cfg.Preflight = config.Preflight{
+		Concurrency:   concurrency,
+		ModuleTimeout: metav1.Duration{Duration: moduleTimeout},
+	}
+
+	return config.NewStaticProvider(cfg)
+}
+
 var _ = Describe("Reconcile", func() {
 	var (
 		ctrl          *gomock.Controller
 		clnt          *client.MockClient
 		mockSU        *statusupdater.MockPreflightStatusUpdater
 		mockPreflight *preflight.MockPreflightAPI
+		mockMetrics   *metrics.MockMetrics
+		recorder      *record.FakeRecorder
 		req           reconcile.Request
 		ctx           context.Context
 		nsn           types.NamespacedName
@@ -41,17 +60,20 @@ var _ = Describe("Reconcile", func() {
 		clnt = client.NewMockClient(ctrl)
 		mockSU = statusupdater.NewMockPreflightStatusUpdater(ctrl)
 		mockPreflight = preflight.NewMockPreflightAPI(ctrl)
+		mockMetrics = metrics.NewMockMetrics(ctrl)
 		nsn = types.NamespacedName{
 			Name:      preflightName,
 			Namespace: namespace,
 		}
 		req = reconcile.Request{NamespacedName: nsn}
 		ctx = context.Background()
-		pr = NewPreflightValidationReconciler(clnt, nil, mockSU, mockPreflight)
+		recorder = record.NewFakeRecorder(10)
+		pr = NewPreflightValidationReconciler(clnt, allNamespaces, mockSU, mockPreflight, mockMetrics, scheme, preflightConfig(2, time.Minute), recorder)
 	})
 
 	It("should do nothing if the Preflight is not available anymore", func() {
 		clnt.EXPECT().Get(ctx, nsn, &kmmv1beta1.PreflightValidation{}).Return(apierrors.NewNotFound(schema.GroupResource{}, preflightName))
+		mockMetrics.EXPECT().DeletePreflightResults(preflightName, namespace)
 
 		res, err := pr.Reconcile(ctx, req)
 
@@ -68,22 +90,141 @@ var _ = Describe("Reconcile", func() {
 		Expect(res).To(Equal(reconcile.Result{}))
 	})
 
+	It("should do nothing if the Preflight is already completed for its generation and Modules", func() {
+		mod := kmmv1beta1.Module{
+			ObjectMeta: metav1.ObjectMeta{Name: "moduleName", Namespace: namespace, Generation: 3},
+		}
+
+		gomock.InOrder(
+			clnt.EXPECT().Get(ctx, nsn, &kmmv1beta1.PreflightValidation{}).DoAndReturn(
+				func(_ interface{}, _ interface{}, pv *kmmv1beta1.PreflightValidation) error {
+					pv.Generation = 2
+					pv.Status.CRStatuses = map[string]*kmmv1beta1.CRStatus{
+						preflight.CRStatusKey(&mod): {VerificationStatus: kmmv1beta1.VerificationTrue, ModuleGeneration: 3},
+					}
+					pv.Status.Summary = kmmv1beta1.PreflightSummary{Verified: 1}
+					pv.Status.ObservedGeneration = 2
+					return nil
+				},
+			),
+			clnt.EXPECT().List(ctx, gomock.Any()).DoAndReturn(
+				func(_ interface{}, list *kmmv1beta1.ModuleList, _ ...interface{}) error {
+					list.Items = []kmmv1beta1.Module{mod}
+					return nil
+				},
+			),
+		)
+
+		res, err := pr.Reconcile(ctx, req)
+
+		Expect(err).To(BeNil())
+		Expect(res).To(Equal(reconcile.Result{}))
+	})
+
+	It("should verify the Modules created after the Preflight completed", func() {
+		verified := kmmv1beta1.Module{
+			ObjectMeta: metav1.ObjectMeta{Name: "verified", Namespace: namespace, Generation: 1},
+		}
+		created := kmmv1beta1.Module{
+			ObjectMeta: metav1.ObjectMeta{Name: "created", Namespace: namespace, Generation: 1},
+		}
+		verifiedKey := preflight.CRStatusKey(&verified)
+		createdKey := preflight.CRStatusKey(&created)
+
+		gomock.InOrder(
+			clnt.EXPECT().Get(ctx, nsn, &kmmv1beta1.PreflightValidation{}).DoAndReturn(
+				func(_ interface{}, _ interface{}, pv *kmmv1beta1.PreflightValidation) error {
+					pv.Name = nsn.Name
+					pv.Namespace = nsn.Namespace
+					pv.Generation = 1
+					pv.Status.CRStatuses = map[string]*kmmv1beta1.CRStatus{
+						verifiedKey: {VerificationStatus: kmmv1beta1.VerificationTrue, ModuleGeneration: 1},
+					}
+					pv.Status.Summary = kmmv1beta1.PreflightSummary{Verified: 1}
+					pv.Status.ObservedGeneration = 1
+					pv.Status.ReportConfigMap = &v1.LocalObjectReference{Name: nsn.Name + "-report"}
+					return nil
+				},
+			),
+			clnt.EXPECT().List(ctx, gomock.Any()).DoAndReturn(
+				func(_ interface{}, list *kmmv1beta1.ModuleList, _ ...interface{}) error {
+					list.Items = []kmmv1beta1.Module{verified, created}
+					return nil
+				},
+			),
+			mockPreflight.EXPECT().GetKernelVersions(ctx, gomock.Any()).Return([]preflight.Kernel{{Version: "some kernel version"}}, nil),
+			mockSU.EXPECT().PreflightPresetStatuses(ctx, gomock.Any(), sets.NewString(verifiedKey, createdKey), map[string]int64{createdKey: 1}).DoAndReturn(
+				func(_ interface{}, pv *kmmv1beta1.PreflightValidation, _ sets.String, _ map[string]int64) error {
+					pv.Status.CRStatuses[createdKey] = &kmmv1beta1.CRStatus{VerificationStatus: kmmv1beta1.VerificationFalse, ModuleGeneration: 1}
+					return nil
+				},
+			),
+			mockPreflight.EXPECT().PreflightUpgradeCheck(gomock.Any(), gomock.Any(), &created, []preflight.Kernel{{Version: "some kernel version"}}).Return(
+				preflight.Result{Verified: true, Message: "some message"},
+			),
+			mockSU.EXPECT().PreflightSetVerificationStatus(ctx, gomock.Any(), createdKey, kmmv1beta1.VerificationTrue, "some message", nil).DoAndReturn(
+				func(_ interface{}, pv *kmmv1beta1.PreflightValidation, moduleName, status, _ string, _ []kmmv1beta1.CheckedImage) error {
+					pv.Status.CRStatuses[moduleName].VerificationStatus = status
+					return nil
+				},
+			),
+			mockSU.EXPECT().PreflightSetSummary(ctx, gomock.Any(), kmmv1beta1.PreflightSummary{Verified: 2}).Return(nil),
+			mockMetrics.EXPECT().SetPreflightResults(nsn.Name, nsn.Namespace, 2, 0),
+			clnt.EXPECT().Get(ctx, types.NamespacedName{Name: nsn.Name + "-report", Namespace: nsn.Namespace}, gomock.Any()).Return(apierrors.NewNotFound(schema.GroupResource{}, "whatever")),
+			clnt.EXPECT().Create(ctx, gomock.Any()),
+		)
+
+		res, err := pr.Reconcile(ctx, req)
+
+		Expect(err).To(BeNil())
+		Expect(res).To(Equal(reconcile.Result{}))
+		Expect(recorder.Events).To(Receive(Equal("Normal ModuleVerified Module " + createdKey + " verified: some message")))
+	})
+
+	It("should verify the Modules again once they changed", func() {
+		mod := kmmv1beta1.Module{
+			ObjectMeta: metav1.ObjectMeta{Name: "moduleName", Namespace: namespace, Generation: 2},
+		}
+		key := preflight.CRStatusKey(&mod)
+
+		pv := kmmv1beta1.PreflightValidation{
+			Status: kmmv1beta1.PreflightValidationStatus{
+				CRStatuses: map[string]*kmmv1beta1.CRStatus{
+					key: {VerificationStatus: kmmv1beta1.VerificationTrue, ModuleGeneration: 1},
+				},
+			},
+		}
+
+		Expect(statusesUpToDate(&pv, []kmmv1beta1.Module{mod})).To(BeFalse())
+
+		mockSU.EXPECT().PreflightPresetStatuses(ctx, &pv, sets.NewString(key), map[string]int64{key: 2}).DoAndReturn(
+			func(_ interface{}, pv *kmmv1beta1.PreflightValidation, _ sets.String, _ map[string]int64) error {
+				pv.Status.CRStatuses[key] = &kmmv1beta1.CRStatus{VerificationStatus: kmmv1beta1.VerificationFalse, ModuleGeneration: 2}
+				return nil
+			},
+		)
+
+		Expect(pr.getModulesToCheck(ctx, &pv, []kmmv1beta1.Module{mod})).To(Equal([]kmmv1beta1.Module{mod}))
+	})
+
 	It("good flow, all verified", func() {
 		mod := kmmv1beta1.Module{
 			ObjectMeta: metav1.ObjectMeta{
-				Name: "moduleName",
+				Name:      "moduleName",
+				Namespace: namespace,
 			},
 		}
 		pv := kmmv1beta1.PreflightValidation{
 			ObjectMeta: metav1.ObjectMeta{
-				Name:      nsn.Name,
-				Namespace: nsn.Namespace,
+				Name:       nsn.Name,
+				Namespace:  nsn.Namespace,
+				Generation: 1,
 			},
 			Spec: kmmv1beta1.PreflightValidationSpec{
 				KernelVersion: "some kernel version",
 			},
 			Status: kmmv1beta1.PreflightValidationStatus{
-				CRStatuses: map[string]*kmmv1beta1.CRStatus{mod.Name: &kmmv1beta1.CRStatus{}},
+				CRStatuses: map[string]*kmmv1beta1.CRStatus{preflight.CRStatusKey(&mod): &kmmv1beta1.CRStatus{}},
 			},
 		}
 		gomock.InOrder(
@@ -101,39 +242,57 @@ var _ = Describe("Reconcile", func() {
 					return nil
 				},
 			),
-			mockSU.EXPECT().PreflightPresetStatuses(ctx, &pv, sets.NewString(mod.Name), []string{}).Return(nil),
-			mockPreflight.EXPECT().PreflightUpgradeCheck(ctx, &mod, "some kernel version").Return(true, "some message"),
-			mockSU.EXPECT().PreflightSetVerificationStatus(ctx, &pv, mod.Name, kmmv1beta1.VerificationTrue, "some message").Return(nil),
-			clnt.EXPECT().Get(context.Background(), nsn, &kmmv1beta1.PreflightValidation{}).DoAndReturn(
-				func(_ interface{}, _ interface{}, m *kmmv1beta1.PreflightValidation) error {
-					m.Status.CRStatuses = map[string]*kmmv1beta1.CRStatus{mod.Name: &kmmv1beta1.CRStatus{VerificationStatus: "True"}}
+			mockPreflight.EXPECT().GetKernelVersions(ctx, &pv).Return([]preflight.Kernel{{Version: "some kernel version"}}, nil),
+			mockSU.EXPECT().PreflightPresetStatuses(ctx, &pv, sets.NewString(preflight.CRStatusKey(&mod)), map[string]int64{}).Return(nil),
+			mockPreflight.EXPECT().PreflightUpgradeCheck(gomock.Any(), &pv, &mod, []preflight.Kernel{{Version: "some kernel version"}}).Return(
+				preflight.Result{Verified: true, Message: "some message"},
+			),
+			mockSU.EXPECT().PreflightSetVerificationStatus(ctx, &pv, preflight.CRStatusKey(&mod), kmmv1beta1.VerificationTrue, "some message", nil).DoAndReturn(
+				func(_ interface{}, pv *kmmv1beta1.PreflightValidation, moduleName, status, _ string, _ []kmmv1beta1.CheckedImage) error {
+					pv.Status.CRStatuses[moduleName].VerificationStatus = status
 					return nil
 				},
 			),
+			mockSU.EXPECT().PreflightSetSummary(ctx, gomock.Any(), kmmv1beta1.PreflightSummary{Verified: 1}).Return(nil),
+			mockMetrics.EXPECT().SetPreflightResults(nsn.Name, nsn.Namespace, 1, 0),
+			clnt.EXPECT().Get(ctx, types.NamespacedName{Name: nsn.Name + "-report", Namespace: nsn.Namespace}, gomock.Any()).Return(apierrors.NewNotFound(schema.GroupResource{}, "whatever")),
+			clnt.EXPECT().Create(ctx, gomock.Any()).DoAndReturn(
+				func(_ interface{}, cm *v1.ConfigMap, _ ...ctrlclient.CreateOption) error {
+					Expect(cm.OwnerReferences).To(HaveLen(1))
+					Expect(cm.OwnerReferences[0].Name).To(Equal(nsn.Name))
+					Expect(cm.Data).To(HaveKey(preflight.ReportJSONKey))
+					Expect(cm.Data).To(HaveKey(preflight.ReportJUnitKey))
+					return nil
+				},
+			),
+			mockSU.EXPECT().PreflightSetReportConfigMap(ctx, &pv, nsn.Name+"-report").Return(nil),
 		)
 
 		res, err := pr.Reconcile(ctx, req)
 
 		Expect(err).To(BeNil())
 		Expect(res).To(Equal(reconcile.Result{}))
+		Expect(recorder.Events).To(Receive(Equal("Normal ModuleVerified Module " + namespace + "/moduleName verified: some message")))
 	})
 
 	It("good flow, some not verified", func() {
 		mod := kmmv1beta1.Module{
 			ObjectMeta: metav1.ObjectMeta{
-				Name: "moduleName",
+				Name:      "moduleName",
+				Namespace: namespace,
 			},
 		}
 		pv := kmmv1beta1.PreflightValidation{
 			ObjectMeta: metav1.ObjectMeta{
-				Name:      nsn.Name,
-				Namespace: nsn.Namespace,
+				Name:       nsn.Name,
+				Namespace:  nsn.Namespace,
+				Generation: 1,
 			},
 			Spec: kmmv1beta1.PreflightValidationSpec{
 				KernelVersion: "some kernel version",
 			},
 			Status: kmmv1beta1.PreflightValidationStatus{
-				CRStatuses: map[string]*kmmv1beta1.CRStatus{mod.Name: &kmmv1beta1.CRStatus{}},
+				CRStatuses: map[string]*kmmv1beta1.CRStatus{preflight.CRStatusKey(&mod): &kmmv1beta1.CRStatus{}},
 			},
 		}
 		gomock.InOrder(
@@ -151,15 +310,75 @@ var _ = Describe("Reconcile", func() {
 					return nil
 				},
 			),
-			mockSU.EXPECT().PreflightPresetStatuses(ctx, &pv, sets.NewString(mod.Name), []string{}).Return(nil),
-			mockPreflight.EXPECT().PreflightUpgradeCheck(ctx, &mod, "some kernel version").Return(true, "some message"),
-			mockSU.EXPECT().PreflightSetVerificationStatus(ctx, &pv, mod.Name, kmmv1beta1.VerificationTrue, "some message").Return(nil),
+			mockPreflight.EXPECT().GetKernelVersions(ctx, &pv).Return([]preflight.Kernel{{Version: "some kernel version"}}, nil),
+			mockSU.EXPECT().PreflightPresetStatuses(ctx, &pv, sets.NewString(preflight.CRStatusKey(&mod)), map[string]int64{}).Return(nil),
+			mockPreflight.EXPECT().PreflightUpgradeCheck(gomock.Any(), &pv, &mod, []preflight.Kernel{{Version: "some kernel version"}}).Return(
+				preflight.Result{Message: "some message"},
+			),
+			mockSU.EXPECT().PreflightSetVerificationStatus(ctx, &pv, preflight.CRStatusKey(&mod), kmmv1beta1.VerificationFalse, "some message", nil).Return(nil),
+			mockSU.EXPECT().PreflightSetSummary(ctx, gomock.Any(), kmmv1beta1.PreflightSummary{Failed: 1}).Return(nil),
+			mockMetrics.EXPECT().SetPreflightResults(nsn.Name, nsn.Namespace, 0, 1),
+			clnt.EXPECT().Get(ctx, types.NamespacedName{Name: nsn.Name + "-report", Namespace: nsn.Namespace}, gomock.Any()).Return(apierrors.NewNotFound(schema.GroupResource{}, "whatever")),
+			clnt.EXPECT().Create(ctx, gomock.Any()).Return(nil),
+			mockSU.EXPECT().PreflightSetReportConfigMap(ctx, &pv, nsn.Name+"-report").Return(nil),
+		)
+
+		res, err := pr.Reconcile(ctx, req)
+
+		Expect(err).To(BeNil())
+		Expect(res).To(Equal(reconcile.Result{}))
+		Expect(recorder.Events).To(Receive(Equal("Warning ModuleVerificationFailed Module " + namespace + "/moduleName failed verification: some message")))
+	})
+
+	It("build in progress, should requeue", func() {
+		mod := kmmv1beta1.Module{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "moduleName",
+				Namespace: namespace,
+			},
+		}
+		pv := kmmv1beta1.PreflightValidation{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       nsn.Name,
+				Namespace:  nsn.Namespace,
+				Generation: 1,
+			},
+			Spec: kmmv1beta1.PreflightValidationSpec{
+				KernelVersion: "some kernel version",
+			},
+			Status: kmmv1beta1.PreflightValidationStatus{
+				CRStatuses: map[string]*kmmv1beta1.CRStatus{
+					preflight.CRStatusKey(&mod): &kmmv1beta1.CRStatus{VerificationStage: kmmv1beta1.VerificationStageImage},
+				},
+			},
+		}
+		gomock.InOrder(
 			clnt.EXPECT().Get(context.Background(), nsn, &kmmv1beta1.PreflightValidation{}).DoAndReturn(
 				func(_ interface{}, _ interface{}, m *kmmv1beta1.PreflightValidation) error {
-					m.Status.CRStatuses = map[string]*kmmv1beta1.CRStatus{mod.Name: &kmmv1beta1.CRStatus{VerificationStatus: "False"}}
+					m.ObjectMeta = pv.ObjectMeta
+					m.Spec.KernelVersion = pv.Spec.KernelVersion
+					m.Status = pv.Status
 					return nil
 				},
 			),
+			clnt.EXPECT().List(ctx, gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ interface{}, list *kmmv1beta1.ModuleList, _ ...interface{}) error {
+					list.Items = []kmmv1beta1.Module{mod}
+					return nil
+				},
+			),
+			mockPreflight.EXPECT().GetKernelVersions(ctx, &pv).Return([]preflight.Kernel{{Version: "some kernel version"}}, nil),
+			mockSU.EXPECT().PreflightPresetStatuses(ctx, &pv, sets.NewString(preflight.CRStatusKey(&mod)), map[string]int64{}).Return(nil),
+			mockPreflight.EXPECT().PreflightUpgradeCheck(gomock.Any(), &pv, &mod, []preflight.Kernel{{Version: "some kernel version"}}).Return(
+				preflight.Result{Message: "build in progress", Stage: kmmv1beta1.VerificationStageBuild, Requeue: true},
+			),
+			mockSU.EXPECT().PreflightSetVerificationStage(ctx, &pv, preflight.CRStatusKey(&mod), kmmv1beta1.VerificationStageBuild).Return(nil),
+			mockSU.EXPECT().PreflightSetVerificationStatus(ctx, &pv, preflight.CRStatusKey(&mod), kmmv1beta1.VerificationFalse, "build in progress", nil).Return(nil),
+			mockSU.EXPECT().PreflightSetSummary(ctx, gomock.Any(), kmmv1beta1.PreflightSummary{InProgress: 1}).Return(nil),
+			mockMetrics.EXPECT().SetPreflightResults(nsn.Name, nsn.Namespace, 0, 0),
+			clnt.EXPECT().Get(ctx, types.NamespacedName{Name: nsn.Name + "-report", Namespace: nsn.Namespace}, gomock.Any()).Return(apierrors.NewNotFound(schema.GroupResource{}, "whatever")),
+			clnt.EXPECT().Create(ctx, gomock.Any()).Return(nil),
+			mockSU.EXPECT().PreflightSetReportConfigMap(ctx, &pv, nsn.Name+"-report").Return(nil),
 		)
 
 		res, err := pr.Reconcile(ctx, req)
@@ -170,6 +389,42 @@ var _ = Describe("Reconcile", func() {
 
 })
 
+var _ = Describe("checkModule", func() {
+	var (
+		ctrl          *gomock.Controller
+		mockPreflight *preflight.MockPreflightAPI
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockPreflight = preflight.NewMockPreflightAPI(ctrl)
+	})
+
+	It("should fail the module when the verification times out", func() {
+		const timeout = 10 * time.Millisecond
+
+		pr := NewPreflightValidationReconciler(nil, nil, nil, mockPreflight, nil, scheme, preflightConfig(1, timeout), nil)
+		pv := &kmmv1beta1.PreflightValidation{}
+		mod := &kmmv1beta1.Module{
+			ObjectMeta: metav1.ObjectMeta{Name: "moduleName"},
+		}
+
+		mockPreflight.EXPECT().PreflightUpgradeCheck(gomock.Any(), pv, mod, []preflight.Kernel{{Version: "kernel"}}).DoAndReturn(
+			func(ctx context.Context, _ *kmmv1beta1.PreflightValidation, _ *kmmv1beta1.Module, _ []preflight.Kernel) preflight.Result {
+				<-ctx.Done()
+				return preflight.Result{Verified: true, Stage: kmmv1beta1.VerificationStageImage}
+			},
+		)
+
+		res := pr.checkModule(context.Background(), pv, mod, []preflight.Kernel{{Version: "kernel"}})
+
+		Expect(res.Verified).To(BeFalse())
+		Expect(res.Requeue).To(BeFalse())
+		Expect(res.Stage).To(Equal(kmmv1beta1.VerificationStageImage))
+		Expect(res.Message).To(ContainSubstring("timed out"))
+	})
+})
+
 var _ = Describe("getModulesCheck", func() {
 	var (
 		ctrl          *gomock.Controller
@@ -186,7 +441,7 @@ var _ = Describe("getModulesCheck", func() {
 		mockSU = statusupdater.NewMockPreflightStatusUpdater(ctrl)
 		mockPreflight = preflight.NewMockPreflightAPI(ctrl)
 		ctx = context.Background()
-		pr = NewPreflightValidationReconciler(clnt, nil, mockSU, mockPreflight)
+		pr = NewPreflightValidationReconciler(clnt, allNamespaces, mockSU, mockPreflight, nil, scheme, preflightConfig(2, time.Minute), nil)
 	})
 
 	It("multiple modules, statuses exist, none deleted", func() {
@@ -200,31 +455,27 @@ var _ = Describe("getModulesCheck", func() {
 			},
 			Status: kmmv1beta1.PreflightValidationStatus{
 				CRStatuses: map[string]*kmmv1beta1.CRStatus{
-					"moduleName1": &kmmv1beta1.CRStatus{},
-					"moduleName2": &kmmv1beta1.CRStatus{}},
+					namespace + "/moduleName1": &kmmv1beta1.CRStatus{},
+					namespace + "/moduleName2": &kmmv1beta1.CRStatus{}},
 			},
 		}
 		mod1 := kmmv1beta1.Module{
 			ObjectMeta: metav1.ObjectMeta{
-				Name: "moduleName1",
+				Name:      "moduleName1",
+				Namespace: namespace,
 			},
 		}
 
 		mod2 := kmmv1beta1.Module{
 			ObjectMeta: metav1.ObjectMeta{
-				Name: "moduleName2",
+				Name:      "moduleName2",
+				Namespace: namespace,
 			},
 		}
 
-		clnt.EXPECT().List(ctx, gomock.Any(), gomock.Any()).DoAndReturn(
-			func(_ interface{}, list *kmmv1beta1.ModuleList, _ ...interface{}) error {
-				list.Items = []kmmv1beta1.Module{mod1, mod2}
-				return nil
-			},
-		)
-		mockSU.EXPECT().PreflightPresetStatuses(ctx, &pv, sets.NewString("moduleName1", "moduleName2"), []string{})
+		mockSU.EXPECT().PreflightPresetStatuses(ctx, &pv, sets.NewString(namespace+"/moduleName1", namespace+"/moduleName2"), map[string]int64{})
 
-		modulesToCheck, err := pr.getModulesToCheck(ctx, &pv)
+		modulesToCheck, err := pr.getModulesToCheck(ctx, &pv, []kmmv1beta1.Module{mod1, mod2})
 
 		Expect(err).To(BeNil())
 		Expect(modulesToCheck).To(Equal([]kmmv1beta1.Module{mod1, mod2}))
@@ -241,35 +492,30 @@ var _ = Describe("getModulesCheck", func() {
 				KernelVersion: "some kernel version",
 			},
 			Status: kmmv1beta1.PreflightValidationStatus{
-				CRStatuses: map[string]*kmmv1beta1.CRStatus{"moduleName1": &kmmv1beta1.CRStatus{}},
+				CRStatuses: map[string]*kmmv1beta1.CRStatus{namespace + "/moduleName1": &kmmv1beta1.CRStatus{}},
 			},
 		}
 		mod1 := kmmv1beta1.Module{
 			ObjectMeta: metav1.ObjectMeta{
-				Name: "moduleName1",
+				Name:      "moduleName1",
+				Namespace: namespace,
 			},
 		}
 
 		mod2 := kmmv1beta1.Module{
 			ObjectMeta: metav1.ObjectMeta{
-				Name: "moduleName2",
+				Name:      "moduleName2",
+				Namespace: namespace,
 			},
 		}
-		gomock.InOrder(
-			clnt.EXPECT().List(ctx, gomock.Any(), gomock.Any()).DoAndReturn(
-				func(_ interface{}, list *kmmv1beta1.ModuleList, _ ...interface{}) error {
-					list.Items = []kmmv1beta1.Module{mod1, mod2}
-					return nil
-				},
-			),
-			mockSU.EXPECT().PreflightPresetStatuses(ctx, &pv, sets.NewString("moduleName1", "moduleName2"), []string{"moduleName2"}).DoAndReturn(
-				func(_ interface{}, pv *kmmv1beta1.PreflightValidation, existingModules sets.String, newModules []string) error {
-					pv.Status.CRStatuses[newModules[0]] = &kmmv1beta1.CRStatus{}
-					return nil
-				}),
-		)
 
-		modulesToCheck, err := pr.getModulesToCheck(ctx, &pv)
+		mockSU.EXPECT().PreflightPresetStatuses(ctx, &pv, sets.NewString(namespace+"/moduleName1", namespace+"/moduleName2"), map[string]int64{namespace + "/moduleName2": 0}).DoAndReturn(
+			func(_ interface{}, pv *kmmv1beta1.PreflightValidation, existingModules sets.String, newModules map[string]int64) error {
+				pv.Status.CRStatuses[namespace+"/moduleName2"] = &kmmv1beta1.CRStatus{}
+				return nil
+			})
+
+		modulesToCheck, err := pr.getModulesToCheck(ctx, &pv, []kmmv1beta1.Module{mod1, mod2})
 
 		Expect(err).To(BeNil())
 		Expect(modulesToCheck).To(Equal([]kmmv1beta1.Module{mod1, mod2}))
@@ -285,46 +531,144 @@ var _ = Describe("getModulesCheck", func() {
 				KernelVersion: "some kernel version",
 			},
 			Status: kmmv1beta1.PreflightValidationStatus{
-				CRStatuses: map[string]*kmmv1beta1.CRStatus{"moduleName1": &kmmv1beta1.CRStatus{}},
+				CRStatuses: map[string]*kmmv1beta1.CRStatus{namespace + "/moduleName1": &kmmv1beta1.CRStatus{}},
 			},
 		}
 		mod1 := kmmv1beta1.Module{
 			ObjectMeta: metav1.ObjectMeta{
-				Name: "moduleName1",
+				Name:      "moduleName1",
+				Namespace: namespace,
 			},
 		}
 
 		mod2 := kmmv1beta1.Module{
 			ObjectMeta: metav1.ObjectMeta{
-				Name: "moduleName2",
+				Name:      "moduleName2",
+				Namespace: namespace,
 			},
 		}
 
 		mod3 := kmmv1beta1.Module{
 			ObjectMeta: metav1.ObjectMeta{
-				Name: "moduleName3",
+				Name:      "moduleName3",
+				Namespace: namespace,
 			},
 		}
 		timestamp := metav1.Now()
 		mod3.SetDeletionTimestamp(&timestamp)
 
+		mockSU.EXPECT().PreflightPresetStatuses(ctx, &pv, sets.NewString(namespace+"/moduleName1", namespace+"/moduleName2"), map[string]int64{namespace + "/moduleName2": 0}).DoAndReturn(
+			func(_ interface{}, pv *kmmv1beta1.PreflightValidation, existingModules sets.String, newModules map[string]int64) error {
+				pv.Status.CRStatuses[namespace+"/moduleName2"] = &kmmv1beta1.CRStatus{}
+				delete(pv.Status.CRStatuses, namespace+"/moduleName3")
+				return nil
+			})
+		modulesToCheck, err := pr.getModulesToCheck(ctx, &pv, []kmmv1beta1.Module{mod1, mod2, mod3})
+
+		Expect(err).To(BeNil())
+		Expect(modulesToCheck).To(Equal([]kmmv1beta1.Module{mod1, mod2}))
+	})
+
+	It("should only return the Modules selected by the preflight", func() {
+		pv := kmmv1beta1.PreflightValidation{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      preflightName,
+				Namespace: namespace,
+			},
+			Spec: kmmv1beta1.PreflightValidationSpec{
+				KernelVersion: "some kernel version",
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"team": "a"},
+				},
+				NamespaceSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"env": "test"},
+				},
+			},
+			Status: kmmv1beta1.PreflightValidationStatus{
+				CRStatuses: map[string]*kmmv1beta1.CRStatus{
+					"ns1/moduleName": &kmmv1beta1.CRStatus{},
+				},
+			},
+		}
+		mod1 := kmmv1beta1.Module{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "moduleName",
+				Namespace: "ns1",
+			},
+		}
+		mod2 := kmmv1beta1.Module{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "moduleName",
+				Namespace: "ns2",
+			},
+		}
+
 		gomock.InOrder(
 			clnt.EXPECT().List(ctx, gomock.Any(), gomock.Any()).DoAndReturn(
-				func(_ interface{}, list *kmmv1beta1.ModuleList, _ ...interface{}) error {
-					list.Items = []kmmv1beta1.Module{mod1, mod2, mod3}
+				func(_ interface{}, list *kmmv1beta1.ModuleList, opts ...ctrlclient.ListOption) error {
+					Expect(opts).To(HaveLen(1))
+					Expect(opts[0].(ctrlclient.MatchingLabelsSelector).String()).To(Equal("team=a"))
+					list.Items = []kmmv1beta1.Module{mod1, mod2}
 					return nil
 				},
 			),
-			mockSU.EXPECT().PreflightPresetStatuses(ctx, &pv, sets.NewString("moduleName1", "moduleName2"), []string{"moduleName2"}).DoAndReturn(
-				func(_ interface{}, pv *kmmv1beta1.PreflightValidation, existingModules sets.String, newModules []string) error {
-					pv.Status.CRStatuses[newModules[0]] = &kmmv1beta1.CRStatus{}
-					delete(pv.Status.CRStatuses, "moduleName3")
+			clnt.EXPECT().List(ctx, gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ interface{}, list *v1.NamespaceList, opts ...ctrlclient.ListOption) error {
+					Expect(opts).To(HaveLen(1))
+					Expect(opts[0].(ctrlclient.MatchingLabelsSelector).String()).To(Equal("env=test"))
+					list.Items = []v1.Namespace{
+						{ObjectMeta: metav1.ObjectMeta{Name: "ns1"}},
+					}
 					return nil
-				}),
+				},
+			),
 		)
-		modulesToCheck, err := pr.getModulesToCheck(ctx, &pv)
+
+		modules, err := pr.getSelectedModules(ctx, &pv)
 
 		Expect(err).To(BeNil())
-		Expect(modulesToCheck).To(Equal([]kmmv1beta1.Module{mod1, mod2}))
+		Expect(modules).To(Equal([]kmmv1beta1.Module{mod1}))
+	})
+
+	It("should not return Modules in namespaces that are not allowed", func() {
+		pv := kmmv1beta1.PreflightValidation{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      preflightName,
+				Namespace: namespace,
+			},
+			Status: kmmv1beta1.PreflightValidationStatus{
+				CRStatuses: map[string]*kmmv1beta1.CRStatus{
+					"ns1/moduleName": &kmmv1beta1.CRStatus{},
+				},
+			},
+		}
+		mod1 := kmmv1beta1.Module{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "moduleName",
+				Namespace: "ns1",
+			},
+		}
+		mod2 := kmmv1beta1.Module{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "moduleName",
+				Namespace: "ns2",
+			},
+		}
+
+		pr.filter = filter.New(nil, logr.Discard(), nil, []string{"ns1"})
+
+		gomock.InOrder(
+			clnt.EXPECT().List(ctx, gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ interface{}, list *kmmv1beta1.ModuleList, _ ...ctrlclient.ListOption) error {
+					list.Items = []kmmv1beta1.Module{mod1, mod2}
+					return nil
+				},
+			),
+		)
+
+		modules, err := pr.getSelectedModules(ctx, &pv)
+
+		Expect(err).To(BeNil())
+		Expect(modules).To(Equal([]kmmv1beta1.Module{mod1}))
 	})
 })
@@ -5,6 +5,8 @@ import (
 
 	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
 	"github.com/qbarrand/oot-operator/internal/build"
+	"github.com/qbarrand/oot-operator/internal/config"
+	"github.com/qbarrand/oot-operator/internal/module"
 	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -16,20 +18,27 @@ import (
 //go:generate mockgen -source=maker.go -package=job -destination=mock_maker.go
 
 type Maker interface {
-	MakeJob(mod kmmv1beta1.Module, buildConfig *kmmv1beta1.Build, targetKernel, containerImage string) (*batchv1.Job, error)
+	MakeJob(mod kmmv1beta1.Module, buildConfig *kmmv1beta1.Build, targetKernel, containerImage string, pushImage bool) (*batchv1.Job, error)
 }
 
 type maker struct {
-	helper build.Helper
-	scheme *runtime.Scheme
+	helper      build.Helper
+	cfgProvider config.Provider
+	scheme      *runtime.Scheme
 }
 
-func NewMaker(helper build.Helper, scheme *runtime.Scheme) Maker {
-	return &maker{helper: helper, scheme: scheme}
+func NewMaker(helper build.Helper, cfgProvider config.Provider, scheme *runtime.Scheme) Maker {
+	return &maker{helper: helper, cfgProvider: cfgProvider, scheme: scheme}
 }
 
-func (m *maker) MakeJob(mod kmmv1beta1.Module, buildConfig *kmmv1beta1.Build, targetKernel, containerImage string) (*batchv1.Job, error) {
-	args := []string{"--destination", containerImage}
+func (m *maker) MakeJob(mod kmmv1beta1.Module, buildConfig *kmmv1beta1.Build, targetKernel, containerImage string, pushImage bool) (*batchv1.Job, error) {
+	var args []string
+
+	if pushImage {
+		args = append(args, "--destination", containerImage)
+	} else {
+		args = append(args, "--no-push")
+	}
 
 	buildArgs := m.helper.ApplyBuildArgOverrides(
 		buildConfig.BuildArgs,
@@ -91,7 +100,7 @@ func (m *maker) MakeJob(mod kmmv1beta1.Module, buildConfig *kmmv1beta1.Build, ta
 		ObjectMeta: metav1.ObjectMeta{
 			GenerateName: mod.Name + "-build-",
 			Namespace:    mod.Namespace,
-			Labels:       labels(mod, targetKernel),
+			Labels:       labels(mod, targetKernel, pushImage),
 		},
 		Spec: batchv1.JobSpec{
 			Completions: pointer.Int32(1),
@@ -104,10 +113,11 @@ func (m *maker) MakeJob(mod kmmv1beta1.Module, buildConfig *kmmv1beta1.Build, ta
 						{
 							Args:         args,
 							Name:         "kaniko",
-							Image:        "gcr.io/kaniko-project/executor:latest",
+							Image:        m.cfgProvider.Current().DefaultBuilderImage,
 							VolumeMounts: volumeMounts,
 						},
 					},
+					Affinity:      module.NodeAffinity(nil, module.NodeSelectorRequirements(&mod.Spec), nil),
 					NodeSelector:  mod.Spec.Selector,
 					RestartPolicy: v1.RestartPolicyOnFailure,
 					Volumes:       volumes,
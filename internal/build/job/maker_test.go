@@ -1,12 +1,15 @@
 package job
 
 import (
+	"strconv"
+
 	"github.com/golang/mock/gomock"
 	"github.com/google/go-cmp/cmp"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
 	"github.com/qbarrand/oot-operator/internal/build"
+	"github.com/qbarrand/oot-operator/internal/config"
 	"github.com/qbarrand/oot-operator/internal/constants"
 	"golang.org/x/exp/slices"
 	batchv1 "k8s.io/api/batch/v1"
@@ -34,7 +37,7 @@ var _ = Describe("MakeJob", func() {
 	BeforeEach(func() {
 		ctrl = gomock.NewController(GinkgoT())
 		mh = build.NewMockHelper(ctrl)
-		m = NewMaker(mh, scheme)
+		m = NewMaker(mh, config.NewStaticProvider(config.NewDefault()), scheme)
 	})
 
 	AfterEach(func() {
@@ -70,6 +73,7 @@ var _ = Describe("MakeJob", func() {
 				Labels: map[string]string{
 					constants.ModuleNameLabel:    moduleName,
 					constants.TargetKernelTarget: kernelVersion,
+					constants.BuildPushLabel:     "true",
 				},
 				OwnerReferences: []metav1.OwnerReference{
 					{
@@ -193,7 +197,7 @@ var _ = Describe("MakeJob", func() {
 		override := kmmv1beta1.BuildArg{Name: "KERNEL_VERSION", Value: kernelVersion}
 		mh.EXPECT().ApplyBuildArgOverrides(buildArgs, override).Return(append(slices.Clone(buildArgs), override))
 
-		actual, err := m.MakeJob(*mod, km.Build, kernelVersion, km.ContainerImage)
+		actual, err := m.MakeJob(*mod, km.Build, kernelVersion, km.ContainerImage, true)
 		Expect(err).NotTo(HaveOccurred())
 
 		Expect(
@@ -224,6 +228,29 @@ var _ = Describe("MakeJob", func() {
 		),
 	)
 
+	It("should require the selector expressions of the Module", func() {
+		mod := mod.DeepCopy()
+		mod.Spec.SelectorExpressions = []metav1.LabelSelectorRequirement{
+			{Key: "gpu", Operator: metav1.LabelSelectorOpExists},
+		}
+
+		mh.EXPECT().ApplyBuildArgOverrides(nil, kmmv1beta1.BuildArg{Name: "KERNEL_VERSION", Value: kernelVersion})
+
+		actual, err := m.MakeJob(*mod, &kmmv1beta1.Build{}, kernelVersion, containerImage, true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(
+			actual.Spec.Template.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms,
+		).To(
+			Equal([]v1.NodeSelectorTerm{
+				{
+					MatchExpressions: []v1.NodeSelectorRequirement{
+						{Key: "gpu", Operator: v1.NodeSelectorOpExists},
+					},
+				},
+			}),
+		)
+	})
+
 	DescribeTable("should set correct kaniko flags", func(b kmmv1beta1.Build, flag string) {
 
 		km := kmmv1beta1.KernelMapping{
@@ -236,7 +263,7 @@ var _ = Describe("MakeJob", func() {
 
 		mh.EXPECT().ApplyBuildArgOverrides(nil, kmmv1beta1.BuildArg{Name: "KERNEL_VERSION", Value: kernelVersion})
 
-		actual, err := m.MakeJob(mod, &b, kernelVersion, km.ContainerImage)
+		actual, err := m.MakeJob(mod, &b, kernelVersion, km.ContainerImage, true)
 		Expect(err).NotTo(HaveOccurred())
 		Expect(actual.Spec.Template.Spec.Containers[0].Args).To(ContainElement(flag))
 
@@ -262,4 +289,21 @@ var _ = Describe("MakeJob", func() {
 			"--skip-tls-verify",
 		),
 	)
+
+	DescribeTable("should set the destination depending on pushImage", func(pushImage bool, expectedArgs []string, unexpectedArg string) {
+		b := kmmv1beta1.Build{Dockerfile: dockerfile}
+
+		mh.EXPECT().ApplyBuildArgOverrides(nil, kmmv1beta1.BuildArg{Name: "KERNEL_VERSION", Value: kernelVersion})
+
+		actual, err := m.MakeJob(mod, &b, kernelVersion, containerImage, pushImage)
+		Expect(err).NotTo(HaveOccurred())
+
+		args := actual.Spec.Template.Spec.Containers[0].Args
+		Expect(args).To(ContainElements(expectedArgs))
+		Expect(args).NotTo(ContainElement(unexpectedArg))
+		Expect(actual.Labels).To(HaveKeyWithValue(constants.BuildPushLabel, strconv.FormatBool(pushImage)))
+	},
+		Entry("push", true, []string{"--destination", containerImage}, "--no-push"),
+		Entry("no push", false, []string{"--no-push"}, "--destination"),
+	)
 })
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
 
 	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
 	"github.com/qbarrand/oot-operator/internal/auth"
@@ -11,11 +12,15 @@ import (
 	"github.com/qbarrand/oot-operator/internal/constants"
 	"github.com/qbarrand/oot-operator/internal/registry"
 	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
+const EventReasonBuildCreated = "BuildCreated"
+
 var errNoMatchingBuild = errors.New("no matching build")
 
 type jobManager struct {
@@ -23,29 +28,40 @@ type jobManager struct {
 	registry registry.Registry
 	maker    Maker
 	helper   build.Helper
+	recorder record.EventRecorder
 }
 
-func NewBuildManager(client client.Client, registry registry.Registry, maker Maker, helper build.Helper) *jobManager {
+func NewBuildManager(
+	client client.Client,
+	registry registry.Registry,
+	maker Maker,
+	helper build.Helper,
+	recorder record.EventRecorder) *jobManager {
 	return &jobManager{
 		client:   client,
 		registry: registry,
 		maker:    maker,
 		helper:   helper,
+		recorder: recorder,
 	}
 }
 
-func labels(mod kmmv1beta1.Module, targetKernel string) map[string]string {
+// labels returns the labels of the build Jobs of mod for targetKernel.
+// Jobs that do not push the image are labeled differently, so that they are never mistaken for a build that made the
+// image available in the registry.
+func labels(mod kmmv1beta1.Module, targetKernel string, pushImage bool) map[string]string {
 	return map[string]string{
 		constants.ModuleNameLabel:    mod.Name,
 		constants.TargetKernelTarget: targetKernel,
+		constants.BuildPushLabel:     strconv.FormatBool(pushImage),
 	}
 }
 
-func (jbm *jobManager) getJob(ctx context.Context, mod kmmv1beta1.Module, targetKernel string) (*batchv1.Job, error) {
+func (jbm *jobManager) getJob(ctx context.Context, mod kmmv1beta1.Module, targetKernel string, pushImage bool) (*batchv1.Job, error) {
 	jobList := batchv1.JobList{}
 
 	opts := []client.ListOption{
-		client.MatchingLabels(labels(mod, targetKernel)),
+		client.MatchingLabels(labels(mod, targetKernel, pushImage)),
 		client.InNamespace(mod.Namespace),
 	}
 
@@ -62,7 +78,7 @@ func (jbm *jobManager) getJob(ctx context.Context, mod kmmv1beta1.Module, target
 	return &jobList.Items[0], nil
 }
 
-func (jbm *jobManager) Sync(ctx context.Context, mod kmmv1beta1.Module, m kmmv1beta1.KernelMapping, targetKernel string) (build.Result, error) {
+func (jbm *jobManager) Sync(ctx context.Context, mod kmmv1beta1.Module, m kmmv1beta1.KernelMapping, targetKernel string, pushImage bool) (build.Result, error) {
 	logger := log.FromContext(ctx)
 
 	buildConfig := jbm.helper.GetRelevantBuild(mod, m)
@@ -76,18 +92,22 @@ func (jbm *jobManager) Sync(ctx context.Context, mod kmmv1beta1.Module, m kmmv1b
 		}
 		registryAuthGetter = auth.NewRegistryAuthGetter(jbm.client, namespacedName)
 	}
-	imageAvailable, err := jbm.registry.ImageExists(ctx, m.ContainerImage, buildConfig.Pull, registryAuthGetter)
-	if err != nil {
-		return build.Result{}, fmt.Errorf("could not check if the image is available: %v", err)
-	}
 
-	if imageAvailable {
-		return build.Result{Status: build.StatusCompleted, Requeue: false}, nil
-	}
+	// If the built image is not pushed, its existence in the registry says nothing about the build.
+	if pushImage {
+		imageAvailable, err := jbm.registry.ImageExists(ctx, m.ContainerImage, buildConfig.Pull, registryAuthGetter)
+		if err != nil {
+			return build.Result{}, fmt.Errorf("could not check if the image is available: %v", err)
+		}
 
-	logger.Info("Image not pull-able; building in-cluster")
+		if imageAvailable {
+			return build.Result{Status: build.StatusCompleted, Requeue: false}, nil
+		}
 
-	job, err := jbm.getJob(ctx, mod, targetKernel)
+		logger.Info("Image not pull-able; building in-cluster")
+	}
+
+	job, err := jbm.getJob(ctx, mod, targetKernel, pushImage)
 	if err != nil {
 		if !errors.Is(err, errNoMatchingBuild) {
 			return build.Result{}, fmt.Errorf("error getting the build: %v", err)
@@ -95,7 +115,7 @@ func (jbm *jobManager) Sync(ctx context.Context, mod kmmv1beta1.Module, m kmmv1b
 
 		logger.Info("Creating job")
 
-		job, err = jbm.maker.MakeJob(mod, buildConfig, targetKernel, m.ContainerImage)
+		job, err = jbm.maker.MakeJob(mod, buildConfig, targetKernel, m.ContainerImage, pushImage)
 		if err != nil {
 			return build.Result{}, fmt.Errorf("could not make Job: %v", err)
 		}
@@ -104,6 +124,16 @@ func (jbm *jobManager) Sync(ctx context.Context, mod kmmv1beta1.Module, m kmmv1b
 			return build.Result{}, fmt.Errorf("could not create Job: %v", err)
 		}
 
+		jbm.recorder.Eventf(
+			&mod,
+			v1.EventTypeNormal,
+			EventReasonBuildCreated,
+			"Created build Job %s for kernel %s and image %s",
+			job.Name,
+			targetKernel,
+			m.ContainerImage,
+		)
+
 		return build.Result{Status: build.StatusCreated, Requeue: true}, nil
 	}
 
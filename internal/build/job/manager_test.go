@@ -16,6 +16,8 @@ import (
 	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var _ = Describe("Labels", func() {
@@ -29,10 +31,19 @@ var _ = Describe("Labels", func() {
 			ObjectMeta: metav1.ObjectMeta{Name: moduleName},
 		}
 
-		labels := labels(mod, targetKernel)
+		labels := labels(mod, targetKernel, true)
 
 		Expect(labels).To(HaveKeyWithValue(constants.ModuleNameLabel, moduleName))
 		Expect(labels).To(HaveKeyWithValue(constants.TargetKernelTarget, targetKernel))
+		Expect(labels).To(HaveKeyWithValue(constants.BuildPushLabel, "true"))
+	})
+
+	It("should label Jobs that do not push the image differently", func() {
+		mod := kmmv1beta1.Module{
+			ObjectMeta: metav1.ObjectMeta{Name: "module-name"},
+		}
+
+		Expect(labels(mod, "1.2.3", false)).To(HaveKeyWithValue(constants.BuildPushLabel, "false"))
 	})
 })
 
@@ -45,6 +56,7 @@ var _ = Describe("JobManager", func() {
 			registry *registrypkg.MockRegistry
 			maker    *MockMaker
 			helper   *build.MockHelper
+			recorder *record.FakeRecorder
 		)
 
 		const (
@@ -58,6 +70,7 @@ var _ = Describe("JobManager", func() {
 			registry = registrypkg.NewMockRegistry(ctrl)
 			maker = NewMockMaker(ctrl)
 			helper = build.NewMockHelper(ctrl)
+			recorder = record.NewFakeRecorder(10)
 		})
 
 		po := kmmv1beta1.PullOptions{}
@@ -73,9 +86,9 @@ var _ = Describe("JobManager", func() {
 				helper.EXPECT().GetRelevantBuild(gomock.Any(), km).Return(km.Build),
 				registry.EXPECT().ImageExists(ctx, imageName, po, gomock.Any()).Return(false, errors.New("random error")),
 			)
-			mgr := NewBuildManager(nil, registry, maker, helper)
+			mgr := NewBuildManager(nil, registry, maker, helper, recorder)
 
-			_, err := mgr.Sync(ctx, kmmv1beta1.Module{}, km, "")
+			_, err := mgr.Sync(ctx, kmmv1beta1.Module{}, km, "", true)
 			Expect(err).To(HaveOccurred())
 		})
 
@@ -87,10 +100,10 @@ var _ = Describe("JobManager", func() {
 				registry.EXPECT().ImageExists(ctx, imageName, po, gomock.Any()).Return(true, nil),
 			)
 
-			mgr := NewBuildManager(nil, registry, maker, helper)
+			mgr := NewBuildManager(nil, registry, maker, helper, recorder)
 
 			Expect(
-				mgr.Sync(ctx, kmmv1beta1.Module{}, km, ""),
+				mgr.Sync(ctx, kmmv1beta1.Module{}, km, "", true),
 			).To(
 				Equal(build.Result{Status: build.StatusCompleted}),
 			)
@@ -110,7 +123,7 @@ var _ = Describe("JobManager", func() {
 			func(s batchv1.JobStatus, r build.Result, expectsErr bool) {
 				j := batchv1.Job{
 					ObjectMeta: metav1.ObjectMeta{
-						Labels:    labels(mod, kernelVersion),
+						Labels:    labels(mod, kernelVersion, true),
 						Namespace: namespace,
 					},
 					Status: s,
@@ -128,9 +141,11 @@ var _ = Describe("JobManager", func() {
 					registry.EXPECT().ImageExists(ctx, imageName, po, gomock.Any()).Return(false, nil),
 				)
 
-				mgr := NewBuildManager(clnt, registry, maker, helper)
+				mgr := NewBuildManager(clnt, registry, maker, helper, recorder)
+
+				res, err := mgr.Sync(ctx, mod, km, kernelVersion, true)
 
-				res, err := mgr.Sync(ctx, mod, km, kernelVersion)
+				Expect(recorder.Events).NotTo(Receive())
 
 				if expectsErr {
 					Expect(err).To(HaveOccurred())
@@ -150,14 +165,14 @@ var _ = Describe("JobManager", func() {
 			gomock.InOrder(
 				helper.EXPECT().GetRelevantBuild(mod, km).Return(km.Build),
 				registry.EXPECT().ImageExists(ctx, imageName, po, gomock.Any()),
-				maker.EXPECT().MakeJob(mod, km.Build, kernelVersion, km.ContainerImage).Return(nil, errors.New("random error")),
+				maker.EXPECT().MakeJob(mod, km.Build, kernelVersion, km.ContainerImage, true).Return(nil, errors.New("random error")),
 			)
 			clnt.EXPECT().List(ctx, gomock.Any(), gomock.Any(), gomock.Any())
 
-			mgr := NewBuildManager(clnt, registry, maker, helper)
+			mgr := NewBuildManager(clnt, registry, maker, helper, recorder)
 
 			Expect(
-				mgr.Sync(ctx, mod, km, kernelVersion),
+				mgr.Sync(ctx, mod, km, kernelVersion, true),
 			).Error().To(
 				HaveOccurred(),
 			)
@@ -180,7 +195,7 @@ var _ = Describe("JobManager", func() {
 			gomock.InOrder(
 				helper.EXPECT().GetRelevantBuild(mod, km).Return(km.Build),
 				registry.EXPECT().ImageExists(ctx, imageName, po, gomock.Any()),
-				maker.EXPECT().MakeJob(mod, km.Build, kernelVersion, km.ContainerImage).Return(&j, nil),
+				maker.EXPECT().MakeJob(mod, km.Build, kernelVersion, km.ContainerImage, true).Return(&j, nil),
 			)
 
 			gomock.InOrder(
@@ -188,10 +203,42 @@ var _ = Describe("JobManager", func() {
 				clnt.EXPECT().Create(ctx, &j),
 			)
 
-			mgr := NewBuildManager(clnt, registry, maker, helper)
+			mgr := NewBuildManager(clnt, registry, maker, helper, recorder)
+
+			Expect(
+				mgr.Sync(ctx, mod, km, kernelVersion, true),
+			).To(
+				Equal(build.Result{Requeue: true, Status: build.StatusCreated}),
+			)
+
+			Expect(recorder.Events).To(Receive(Equal("Normal BuildCreated Created build Job some-job for kernel 1.2.3 and image image-name")))
+		})
+
+		It("should not check the image existence if the image is not pushed", func() {
+			ctx := context.Background()
+
+			j := batchv1.Job{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "batch/v1",
+					Kind:       "Job",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      jobName,
+					Namespace: namespace,
+				},
+			}
+
+			gomock.InOrder(
+				helper.EXPECT().GetRelevantBuild(mod, km).Return(km.Build),
+				clnt.EXPECT().List(ctx, gomock.Any(), runtimeclient.MatchingLabels(labels(mod, kernelVersion, false)), gomock.Any()),
+				maker.EXPECT().MakeJob(mod, km.Build, kernelVersion, km.ContainerImage, false).Return(&j, nil),
+				clnt.EXPECT().Create(ctx, &j),
+			)
+
+			mgr := NewBuildManager(clnt, registry, maker, helper, recorder)
 
 			Expect(
-				mgr.Sync(ctx, mod, km, kernelVersion),
+				mgr.Sync(ctx, mod, km, kernelVersion, false),
 			).To(
 				Equal(build.Result{Requeue: true, Status: build.StatusCreated}),
 			)
@@ -223,14 +270,14 @@ var _ = Describe("JobManager", func() {
 					},
 				),
 				clnt.EXPECT().List(ctx, gomock.Any(), gomock.Any(), gomock.Any()),
-				maker.EXPECT().MakeJob(mod, km.Build, kernelVersion, km.ContainerImage).Return(&j, nil),
+				maker.EXPECT().MakeJob(mod, km.Build, kernelVersion, km.ContainerImage, true).Return(&j, nil),
 				clnt.EXPECT().Create(ctx, &j),
 			)
 
-			mgr := NewBuildManager(clnt, registry, maker, helper)
+			mgr := NewBuildManager(clnt, registry, maker, helper, recorder)
 
 			Expect(
-				mgr.Sync(ctx, mod, km, kernelVersion),
+				mgr.Sync(ctx, mod, km, kernelVersion, true),
 			).To(
 				Equal(build.Result{Requeue: true, Status: build.StatusCreated}),
 			)
@@ -0,0 +1,244 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	"github.com/qbarrand/oot-operator/internal/build"
+	"github.com/qbarrand/oot-operator/internal/constants"
+	"github.com/qbarrand/oot-operator/internal/daemonset"
+	"github.com/qbarrand/oot-operator/internal/metrics"
+	"github.com/qbarrand/oot-operator/internal/module"
+	"github.com/qbarrand/oot-operator/internal/registry"
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/trace"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type options struct {
+	kubeconfig    string
+	kubeContext   string
+	namespace     string
+	allNamespaces bool
+	checkImages   bool
+	kernelLabel   string
+	moduleFile    string
+	nodesFile     string
+}
+
+// NewRootCommand returns the kubectl-kmm command, writing its output to out.
+func NewRootCommand(out io.Writer) *cobra.Command {
+	opts := options{}
+
+	root := &cobra.Command{
+		Use:           "kubectl-kmm",
+		Short:         "Inspect kernel Modules and their state on nodes",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	root.SetOut(out)
+
+	pf := root.PersistentFlags()
+	pf.StringVar(&opts.kubeconfig, "kubeconfig", "", "Path to the kubeconfig file.")
+	pf.StringVar(&opts.kubeContext, "context", "", "The name of the kubeconfig context to use.")
+	pf.StringVarP(&opts.namespace, "namespace", "n", "", "The namespace of the Modules; defaults to the namespace of the current context.")
+	pf.BoolVarP(&opts.allNamespaces, "all-namespaces", "A", false, "Inspect Modules in all namespaces.")
+
+	status := &cobra.Command{
+		Use:   "status [MODULE]",
+		Short: "Show which nodes a Module targets and the state of the kernel module on each of them",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runStatus(cmd.Context(), cmd.OutOrStdout(), &opts, args)
+		},
+	}
+
+	status.Flags().BoolVar(&opts.checkImages, "check-images", false, "Check that the resolved images exist in their registry.")
+	status.Flags().StringVar(&opts.kernelLabel, "kernel-label", constants.KernelLabel, "The node label holding the full kernel version; must match the kernelLabel of the operator configuration.")
+
+	resolve := &cobra.Command{
+		Use:   "resolve -f MODULE_FILE [--nodes NODES_FILE]",
+		Short: "Show the image and build configuration a Module would resolve to for each kernel, without applying it",
+		Long: "Resolve the kernel mappings of a Module against a set of nodes, exactly like the operator would, " +
+			"and print the image and effective build configuration for each kernel, as well as the nodes for which " +
+			"no mapping matches.\n" +
+			"Nodes are read from --nodes if set, or listed from the cluster otherwise. Nothing is ever created or modified.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runResolve(cmd.Context(), cmd.OutOrStdout(), &opts)
+		},
+	}
+
+	resolve.Flags().StringVarP(&opts.moduleFile, "filename", "f", "", "The YAML or JSON file containing the Module.")
+	resolve.Flags().StringVar(&opts.nodesFile, "nodes", "", "A YAML or JSON file containing Nodes or lists of Nodes. If empty, nodes are listed from the cluster.")
+	utilruntime.Must(resolve.MarkFlagRequired("filename"))
+
+	root.AddCommand(status, resolve)
+
+	return root
+}
+
+func runStatus(ctx context.Context, out io.Writer, opts *options, args []string) error {
+	c, namespace, err := newClient(opts)
+	if err != nil {
+		return err
+	}
+
+	mods, err := getModules(ctx, c, namespace, args)
+	if err != nil {
+		return err
+	}
+
+	ins := NewInspector(
+		c,
+		daemonset.NewCreator(c, opts.kernelLabel, c.Scheme()),
+		module.NewKernelMapper(),
+		// Metrics and traces are not exported by the plugin.
+		registry.NewRegistry(metrics.New(), trace.NewNoopTracerProvider().Tracer("")),
+		opts.checkImages,
+	)
+
+	for i := range mods {
+		state, err := ins.InspectModule(ctx, &mods[i])
+		if err != nil {
+			return fmt.Errorf("could not inspect Module %s/%s: %w", mods[i].Namespace, mods[i].Name, err)
+		}
+
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+
+		if err = PrintModuleState(out, state); err != nil {
+			return fmt.Errorf("could not print the state of Module %s/%s: %w", mods[i].Namespace, mods[i].Name, err)
+		}
+	}
+
+	return nil
+}
+
+func runResolve(ctx context.Context, out io.Writer, opts *options) error {
+	mod, err := readModuleFile(opts.moduleFile)
+	if err != nil {
+		return err
+	}
+
+	var nodes []v1.Node
+
+	if opts.nodesFile != "" {
+		if nodes, err = readNodesFile(opts.nodesFile); err != nil {
+			return err
+		}
+	} else {
+		c, _, err := newClient(opts)
+		if err != nil {
+			return err
+		}
+
+		sel, err := module.NodeSelector(&mod.Spec)
+		if err != nil {
+			return err
+		}
+
+		nl := v1.NodeList{}
+
+		if err = c.List(ctx, &nl, client.MatchingLabelsSelector{Selector: sel}); err != nil {
+			return fmt.Errorf("could not list nodes: %w", err)
+		}
+
+		nodes = nl.Items
+	}
+
+	res, err := NewResolver(module.NewKernelMapper(), build.NewHelper()).Resolve(mod, nodes)
+	if err != nil {
+		return err
+	}
+
+	return PrintResolution(out, mod, res)
+}
+
+func readModuleFile(path string) (*kmmv1beta1.Module, error) {
+	fd, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %w", path, err)
+	}
+	defer fd.Close()
+
+	return ReadModule(fd)
+}
+
+func readNodesFile(path string) ([]v1.Node, error) {
+	fd, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %w", path, err)
+	}
+	defer fd.Close()
+
+	return ReadNodes(fd)
+}
+
+func getModules(ctx context.Context, c client.Client, namespace string, args []string) ([]kmmv1beta1.Module, error) {
+	if len(args) == 1 {
+		mod := kmmv1beta1.Module{}
+
+		if err := c.Get(ctx, types.NamespacedName{Name: args[0], Namespace: namespace}, &mod); err != nil {
+			return nil, fmt.Errorf("could not get Module %s: %w", args[0], err)
+		}
+
+		return []kmmv1beta1.Module{mod}, nil
+	}
+
+	mods := kmmv1beta1.ModuleList{}
+
+	if err := c.List(ctx, &mods, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("could not list Modules: %w", err)
+	}
+
+	return mods.Items, nil
+}
+
+// newClient returns a client for the cluster selected by opts, and the namespace Modules should be looked up in.
+func newClient(opts *options) (client.Client, string, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = opts.kubeconfig
+
+	overrides := clientcmd.ConfigOverrides{CurrentContext: opts.kubeContext}
+
+	cc := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &overrides)
+
+	restConfig, err := cc.ClientConfig()
+	if err != nil {
+		return nil, "", fmt.Errorf("could not load the kubeconfig: %w", err)
+	}
+
+	namespace := opts.namespace
+
+	switch {
+	case opts.allNamespaces:
+		namespace = ""
+	case namespace == "":
+		if namespace, _, err = cc.Namespace(); err != nil {
+			return nil, "", fmt.Errorf("could not get the namespace from the kubeconfig: %w", err)
+		}
+	}
+
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(kmmv1beta1.AddToScheme(scheme))
+
+	c, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, "", fmt.Errorf("could not create the client: %w", err)
+	}
+
+	return c, namespace, nil
+}
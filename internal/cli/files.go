@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+const decoderBufferSize = 4096
+
+// ReadModule decodes a single Module from a YAML or JSON document.
+func ReadModule(r io.Reader) (*kmmv1beta1.Module, error) {
+	mod := kmmv1beta1.Module{}
+
+	if err := yaml.NewYAMLOrJSONDecoder(r, decoderBufferSize).Decode(&mod); err != nil {
+		return nil, fmt.Errorf("could not decode the Module: %w", err)
+	}
+
+	if mod.Kind != "" && mod.Kind != "Module" {
+		return nil, fmt.Errorf("expected a Module, got a %s", mod.Kind)
+	}
+
+	return &mod, nil
+}
+
+// ReadNodes decodes nodes from a stream of YAML or JSON documents.
+// Each document may either be a Node or a list of nodes, as printed by kubectl get nodes -o yaml.
+func ReadNodes(r io.Reader) ([]v1.Node, error) {
+	decoder := yaml.NewYAMLOrJSONDecoder(r, decoderBufferSize)
+
+	nodes := make([]v1.Node, 0)
+
+	for {
+		raw := json.RawMessage{}
+
+		if err := decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nodes, nil
+			}
+
+			return nil, fmt.Errorf("could not decode document: %w", err)
+		}
+
+		// empty documents
+		if len(raw) == 0 || string(raw) == "null" {
+			continue
+		}
+
+		tm := metav1.TypeMeta{}
+
+		if err := json.Unmarshal(raw, &tm); err != nil {
+			return nil, fmt.Errorf("could not decode the kind of the document: %w", err)
+		}
+
+		switch tm.Kind {
+		case "Node":
+			node := v1.Node{}
+
+			if err := json.Unmarshal(raw, &node); err != nil {
+				return nil, fmt.Errorf("could not decode Node: %w", err)
+			}
+
+			nodes = append(nodes, node)
+		case "List", "NodeList":
+			nl := v1.NodeList{}
+
+			if err := json.Unmarshal(raw, &nl); err != nil {
+				return nil, fmt.Errorf("could not decode the list of nodes: %w", err)
+			}
+
+			nodes = append(nodes, nl.Items...)
+		default:
+			return nil, fmt.Errorf("unexpected kind %q; expected Node, NodeList or List", tm.Kind)
+		}
+	}
+}
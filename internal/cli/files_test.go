@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ReadModule", func() {
+	It("should decode a Module", func() {
+		const doc = `
+apiVersion: kmm.sigs.k8s.io/v1beta1
+kind: Module
+metadata:
+  name: name
+  namespace: namespace
+spec:
+  selector:
+    key: value
+`
+
+		mod, err := ReadModule(strings.NewReader(doc))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mod.Name).To(Equal("name"))
+		Expect(mod.Namespace).To(Equal("namespace"))
+		Expect(mod.Spec.Selector).To(Equal(map[string]string{"key": "value"}))
+	})
+
+	It("should return an error for other kinds", func() {
+		_, err := ReadModule(strings.NewReader("kind: Node"))
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("ReadNodes", func() {
+	It("should decode Nodes and lists of nodes", func() {
+		const doc = `
+apiVersion: v1
+kind: Node
+metadata:
+  name: node-a
+status:
+  nodeInfo:
+    kernelVersion: kernel-a
+---
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Node
+  metadata:
+    name: node-b
+- apiVersion: v1
+  kind: Node
+  metadata:
+    name: node-c
+`
+
+		nodes, err := ReadNodes(strings.NewReader(doc))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nodes).To(HaveLen(3))
+		Expect(nodes[0].Name).To(Equal("node-a"))
+		Expect(nodes[0].Status.NodeInfo.KernelVersion).To(Equal("kernel-a"))
+		Expect(nodes[1].Name).To(Equal("node-b"))
+		Expect(nodes[2].Name).To(Equal("node-c"))
+	})
+
+	It("should return an error for other kinds", func() {
+		_, err := ReadNodes(strings.NewReader("kind: Pod"))
+		Expect(err).To(HaveOccurred())
+	})
+})
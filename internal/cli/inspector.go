@@ -0,0 +1,286 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	"github.com/qbarrand/oot-operator/internal/auth"
+	"github.com/qbarrand/oot-operator/internal/build"
+	"github.com/qbarrand/oot-operator/internal/constants"
+	"github.com/qbarrand/oot-operator/internal/daemonset"
+	"github.com/qbarrand/oot-operator/internal/module"
+	"github.com/qbarrand/oot-operator/internal/registry"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	BuildStatusNone      = "None"
+	BuildStatusNotNeeded = "NotNeeded"
+	BuildStatusRunning   = "Running"
+	BuildStatusSucceeded = "Succeeded"
+	BuildStatusFailed    = "Failed"
+)
+
+// DaemonSetState describes a DaemonSet created for a Module.
+type DaemonSetState struct {
+	Name      string
+	Desired   int32
+	Available int32
+}
+
+// NodeState describes how a Module applies to a node that it targets.
+type NodeState struct {
+	Name          string
+	KernelVersion string
+
+	// Mapping is the literal or regexp of the kernel mapping that matches the kernel of the node.
+	Mapping string
+
+	// MappingError is set if no mapping could be resolved for the kernel of the node.
+	MappingError string
+
+	// Image is the container image that the kernel of the node resolves to.
+	Image string
+
+	// ImageExists is only set if images were checked.
+	ImageExists *bool
+
+	BuildStatus string
+
+	ModuleLoader *DaemonSetState
+
+	// DevicePlugin is the device plugin DaemonSet for the kernel of the node, if the device plugin runs per kernel.
+	DevicePlugin *DaemonSetState
+
+	// Ready is true if the node has the label set once the kernel module is loaded.
+	Ready bool
+
+	// DevicePluginReady is true if the node has the label set once the device plugin runs.
+	DevicePluginReady bool
+}
+
+// ModuleState is the state of a Module across the nodes it targets.
+type ModuleState struct {
+	Name      string
+	Namespace string
+	Nodes     []NodeState
+
+	// DevicePlugin is the device plugin DaemonSet shared by all kernels, if any.
+	DevicePlugin *DaemonSetState
+}
+
+//go:generate mockgen -source=inspector.go -package=cli -destination=mock_inspector.go
+
+type Inspector interface {
+	InspectModule(ctx context.Context, mod *kmmv1beta1.Module) (*ModuleState, error)
+}
+
+type inspector struct {
+	client      client.Client
+	daemonAPI   daemonset.DaemonSetCreator
+	kernelAPI   module.KernelMapper
+	registryAPI registry.Registry
+	checkImages bool
+}
+
+// NewInspector returns an Inspector that only looks images up in their registry if checkImages is true.
+func NewInspector(
+	client client.Client,
+	daemonAPI daemonset.DaemonSetCreator,
+	kernelAPI module.KernelMapper,
+	registryAPI registry.Registry,
+	checkImages bool) Inspector {
+	return &inspector{
+		client:      client,
+		daemonAPI:   daemonAPI,
+		kernelAPI:   kernelAPI,
+		registryAPI: registryAPI,
+		checkImages: checkImages,
+	}
+}
+
+func (i *inspector) InspectModule(ctx context.Context, mod *kmmv1beta1.Module) (*ModuleState, error) {
+	sel, err := module.NodeSelector(&mod.Spec)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := v1.NodeList{}
+	if err = i.client.List(ctx, &nodes, client.MatchingLabelsSelector{Selector: sel}); err != nil {
+		return nil, fmt.Errorf("could not list nodes: %w", err)
+	}
+
+	dsByKernelVersion, err := i.daemonAPI.ModuleDaemonSetsByKernelVersion(ctx, mod.Name, mod.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("could not get the DaemonSets of Module %s: %w", mod.Name, err)
+	}
+
+	devicePluginDSByKernelVersion, err := i.daemonAPI.DevicePluginDaemonSetsByKernelVersion(ctx, mod.Name, mod.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("could not get the device plugin DaemonSets of Module %s: %w", mod.Name, err)
+	}
+
+	state := ModuleState{
+		Name:      mod.Name,
+		Namespace: mod.Namespace,
+		Nodes:     make([]NodeState, 0, len(nodes.Items)),
+	}
+
+	if ds := dsByKernelVersion[daemonset.GetDevicePluginKernelVersion()]; ds != nil {
+		state.DevicePlugin = makeDaemonSetState(ds)
+	}
+
+	// nodes running the same kernel resolve to the same image and build
+	resolved := make(map[string]NodeState)
+
+	for _, node := range nodes.Items {
+		kernelVersion := node.Status.NodeInfo.KernelVersion
+
+		ns, ok := resolved[kernelVersion]
+		if !ok {
+			ns, err = i.resolveKernel(ctx, mod, &node)
+			if err != nil {
+				return nil, fmt.Errorf("could not resolve kernel %s: %w", kernelVersion, err)
+			}
+
+			if ds := dsByKernelVersion[kernelVersion]; ds != nil {
+				ns.ModuleLoader = makeDaemonSetState(ds)
+			}
+
+			if ds := devicePluginDSByKernelVersion[kernelVersion]; ds != nil {
+				ns.DevicePlugin = makeDaemonSetState(ds)
+			}
+
+			resolved[kernelVersion] = ns
+		}
+
+		ns.Name = node.Name
+		_, ns.Ready = node.Labels[daemonset.GetDriverContainerNodeLabel(mod.Name)]
+		_, ns.DevicePluginReady = node.Labels[daemonset.GetDevicePluginNodeLabel(mod.Name)]
+
+		state.Nodes = append(state.Nodes, ns)
+	}
+
+	sort.Slice(state.Nodes, func(a, b int) bool {
+		return state.Nodes[a].Name < state.Nodes[b].Name
+	})
+
+	return &state, nil
+}
+
+func (i *inspector) resolveKernel(ctx context.Context, mod *kmmv1beta1.Module, node *v1.Node) (NodeState, error) {
+	kernelVersion := node.Status.NodeInfo.KernelVersion
+
+	ns := NodeState{KernelVersion: kernelVersion}
+
+	m, err := i.kernelAPI.FindMappingForKernel(mod.Spec.ModuleLoader.Container.KernelMappings, kernelVersion)
+	if err != nil {
+		ns.MappingError = err.Error()
+		return ns, nil
+	}
+
+	ns.Mapping = m.Literal
+	if ns.Mapping == "" {
+		ns.Mapping = m.Regexp
+	}
+
+	osConfig, err := i.kernelAPI.GetOSConfigForKernel(kernelVersion)
+	if err != nil {
+		ns.MappingError = err.Error()
+		return ns, nil
+	}
+
+	m, err = i.kernelAPI.PrepareKernelMapping(m, osConfig)
+	if err != nil {
+		ns.MappingError = fmt.Sprintf("could not substitute the template variables: %v", err)
+		return ns, nil
+	}
+
+	ns.Image = m.ContainerImage
+
+	if i.checkImages {
+		exists, err := i.imageExists(ctx, mod, m)
+		if err != nil {
+			return ns, fmt.Errorf("could not check if image %s exists: %w", m.ContainerImage, err)
+		}
+
+		ns.ImageExists = &exists
+	}
+
+	if mod.Spec.ModuleLoader.Container.Build == nil && m.Build == nil {
+		ns.BuildStatus = BuildStatusNotNeeded
+		return ns, nil
+	}
+
+	if ns.BuildStatus, err = i.buildStatus(ctx, mod, kernelVersion); err != nil {
+		return ns, fmt.Errorf("could not get the build status: %w", err)
+	}
+
+	return ns, nil
+}
+
+func (i *inspector) imageExists(ctx context.Context, mod *kmmv1beta1.Module, m *kmmv1beta1.KernelMapping) (bool, error) {
+	var registryAuthGetter auth.RegistryAuthGetter
+
+	if irs := mod.Spec.ImageRepoSecret; irs != nil {
+		namespacedName := types.NamespacedName{
+			Name:      irs.Name,
+			Namespace: mod.Namespace,
+		}
+		registryAuthGetter = auth.NewRegistryAuthGetter(i.client, namespacedName)
+	}
+
+	var po kmmv1beta1.PullOptions
+
+	if b := build.NewHelper().GetRelevantBuild(*mod, *m); b != nil {
+		po = b.Pull
+	}
+
+	return i.registryAPI.ImageExists(ctx, m.ContainerImage, po, registryAuthGetter)
+}
+
+func (i *inspector) buildStatus(ctx context.Context, mod *kmmv1beta1.Module, kernelVersion string) (string, error) {
+	jobs := batchv1.JobList{}
+
+	opts := []client.ListOption{
+		client.MatchingLabels{
+			constants.ModuleNameLabel:    mod.Name,
+			constants.TargetKernelTarget: kernelVersion,
+			constants.BuildPushLabel:     "true",
+		},
+		client.InNamespace(mod.Namespace),
+	}
+
+	if err := i.client.List(ctx, &jobs, opts...); err != nil {
+		return "", fmt.Errorf("could not list build Jobs: %w", err)
+	}
+
+	if len(jobs.Items) == 0 {
+		return BuildStatusNone, nil
+	}
+
+	job := jobs.Items[0]
+
+	switch {
+	case job.Status.Succeeded > 0:
+		return BuildStatusSucceeded, nil
+	case job.Status.Failed > 0:
+		return BuildStatusFailed, nil
+	default:
+		return BuildStatusRunning, nil
+	}
+}
+
+func makeDaemonSetState(ds *appsv1.DaemonSet) *DaemonSetState {
+	return &DaemonSetState{
+		Name:      ds.Name,
+		Desired:   ds.Status.DesiredNumberScheduled,
+		Available: ds.Status.NumberAvailable,
+	}
+}
@@ -0,0 +1,290 @@
+package cli
+
+import (
+	"context"
+	"errors"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	"github.com/qbarrand/oot-operator/internal/client"
+	"github.com/qbarrand/oot-operator/internal/daemonset"
+	"github.com/qbarrand/oot-operator/internal/module"
+	"github.com/qbarrand/oot-operator/internal/registry"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("InspectModule", func() {
+	const (
+		moduleName      = "module-name"
+		moduleNamespace = "module-namespace"
+		kernelVersion   = "5.18.0"
+		otherKernel     = "4.18.0"
+		image           = "registry.example.com/module:5.18.0"
+	)
+
+	var (
+		ctrl         *gomock.Controller
+		clnt         *client.MockClient
+		mockDC       *daemonset.MockDaemonSetCreator
+		mockKM       *module.MockKernelMapper
+		mockRegistry *registry.MockRegistry
+		mod          *kmmv1beta1.Module
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		clnt = client.NewMockClient(ctrl)
+		mockDC = daemonset.NewMockDaemonSetCreator(ctrl)
+		mockKM = module.NewMockKernelMapper(ctrl)
+		mockRegistry = registry.NewMockRegistry(ctrl)
+		mod = &kmmv1beta1.Module{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      moduleName,
+				Namespace: moduleNamespace,
+			},
+			Spec: kmmv1beta1.ModuleSpec{
+				ModuleLoader: kmmv1beta1.ModuleLoaderSpec{
+					Container: kmmv1beta1.ModuleLoaderContainerSpec{
+						KernelMappings: []kmmv1beta1.KernelMapping{
+							{Regexp: "^5.+$", ContainerImage: "registry.example.com/module:${KERNEL_FULL_VERSION}"},
+						},
+					},
+				},
+				Selector: map[string]string{"key": "value"},
+			},
+		}
+	})
+
+	nodeWithKernel := func(name, kernel string, labels map[string]string) v1.Node {
+		return v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+			Status: v1.NodeStatus{
+				NodeInfo: v1.NodeSystemInfo{KernelVersion: kernel},
+			},
+		}
+	}
+
+	It("should return an error if the nodes cannot be listed", func() {
+		clnt.EXPECT().List(context.Background(), gomock.Any(), gomock.Any()).Return(errors.New("random error"))
+
+		_, err := NewInspector(clnt, mockDC, mockKM, mockRegistry, false).InspectModule(context.Background(), mod)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should report the mapping, image, DaemonSets and labels of each node", func() {
+		ctx := context.Background()
+
+		nodes := []v1.Node{
+			nodeWithKernel("node-b", kernelVersion, nil),
+			nodeWithKernel(
+				"node-a",
+				kernelVersion,
+				map[string]string{
+					daemonset.GetDriverContainerNodeLabel(moduleName): "",
+					daemonset.GetDevicePluginNodeLabel(moduleName):    "",
+				},
+			),
+			nodeWithKernel("node-c", otherKernel, nil),
+		}
+
+		dsByKernelVersion := map[string]*appsv1.DaemonSet{
+			kernelVersion: {
+				ObjectMeta: metav1.ObjectMeta{Name: "module-loader"},
+				Status:     appsv1.DaemonSetStatus{DesiredNumberScheduled: 2, NumberAvailable: 1},
+			},
+			daemonset.GetDevicePluginKernelVersion(): {
+				ObjectMeta: metav1.ObjectMeta{Name: "device-plugin"},
+				Status:     appsv1.DaemonSetStatus{DesiredNumberScheduled: 2, NumberAvailable: 2},
+			},
+		}
+
+		mapping := &mod.Spec.ModuleLoader.Container.KernelMappings[0]
+		osConfig := &module.NodeOSConfig{}
+		preparedMapping := kmmv1beta1.KernelMapping{Regexp: mapping.Regexp, ContainerImage: image}
+
+		gomock.InOrder(
+			clnt.EXPECT().List(ctx, gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ interface{}, list *v1.NodeList, _ ...interface{}) error {
+					list.Items = nodes
+					return nil
+				},
+			),
+			mockDC.EXPECT().ModuleDaemonSetsByKernelVersion(ctx, moduleName, moduleNamespace).Return(dsByKernelVersion, nil),
+			mockDC.EXPECT().DevicePluginDaemonSetsByKernelVersion(ctx, moduleName, moduleNamespace),
+			mockKM.EXPECT().FindMappingForKernel(mod.Spec.ModuleLoader.Container.KernelMappings, kernelVersion).Return(mapping, nil),
+			mockKM.EXPECT().GetOSConfigForKernel(kernelVersion).Return(osConfig, nil),
+			mockKM.EXPECT().PrepareKernelMapping(mapping, osConfig).Return(&preparedMapping, nil),
+			mockRegistry.EXPECT().ImageExists(ctx, image, kmmv1beta1.PullOptions{}, nil).Return(true, nil),
+			mockKM.EXPECT().FindMappingForKernel(mod.Spec.ModuleLoader.Container.KernelMappings, otherKernel).Return(nil, errors.New("no mapping")),
+		)
+
+		state, err := NewInspector(clnt, mockDC, mockKM, mockRegistry, true).InspectModule(ctx, mod)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(state.Name).To(Equal(moduleName))
+		Expect(state.Namespace).To(Equal(moduleNamespace))
+		Expect(state.DevicePlugin).To(Equal(&DaemonSetState{Name: "device-plugin", Desired: 2, Available: 2}))
+
+		exists := true
+		moduleLoader := &DaemonSetState{Name: "module-loader", Desired: 2, Available: 1}
+
+		Expect(state.Nodes).To(Equal([]NodeState{
+			{
+				Name:              "node-a",
+				KernelVersion:     kernelVersion,
+				Mapping:           mapping.Regexp,
+				Image:             image,
+				ImageExists:       &exists,
+				BuildStatus:       BuildStatusNotNeeded,
+				ModuleLoader:      moduleLoader,
+				Ready:             true,
+				DevicePluginReady: true,
+			},
+			{
+				Name:          "node-b",
+				KernelVersion: kernelVersion,
+				Mapping:       mapping.Regexp,
+				Image:         image,
+				ImageExists:   &exists,
+				BuildStatus:   BuildStatusNotNeeded,
+				ModuleLoader:  moduleLoader,
+			},
+			{
+				Name:          "node-c",
+				KernelVersion: otherKernel,
+				MappingError:  "no mapping",
+			},
+		}))
+	})
+
+	It("should report the device plugin DaemonSet of the kernel of each node", func() {
+		ctx := context.Background()
+
+		nodes := []v1.Node{
+			nodeWithKernel("node-a", kernelVersion, nil),
+			nodeWithKernel("node-b", otherKernel, nil),
+		}
+
+		devicePluginDSByKernelVersion := map[string]*appsv1.DaemonSet{
+			kernelVersion: {
+				ObjectMeta: metav1.ObjectMeta{Name: "device-plugin-" + kernelVersion},
+				Status:     appsv1.DaemonSetStatus{DesiredNumberScheduled: 1, NumberAvailable: 1},
+			},
+		}
+
+		mapping := &mod.Spec.ModuleLoader.Container.KernelMappings[0]
+		preparedMapping := kmmv1beta1.KernelMapping{Regexp: mapping.Regexp, ContainerImage: image}
+
+		gomock.InOrder(
+			clnt.EXPECT().List(ctx, gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ interface{}, list *v1.NodeList, _ ...interface{}) error {
+					list.Items = nodes
+					return nil
+				},
+			),
+			mockDC.EXPECT().ModuleDaemonSetsByKernelVersion(ctx, moduleName, moduleNamespace),
+			mockDC.
+				EXPECT().
+				DevicePluginDaemonSetsByKernelVersion(ctx, moduleName, moduleNamespace).
+				Return(devicePluginDSByKernelVersion, nil),
+			mockKM.EXPECT().FindMappingForKernel(gomock.Any(), kernelVersion).Return(mapping, nil),
+			mockKM.EXPECT().GetOSConfigForKernel(kernelVersion).Return(&module.NodeOSConfig{}, nil),
+			mockKM.EXPECT().PrepareKernelMapping(mapping, &module.NodeOSConfig{}).Return(&preparedMapping, nil),
+			mockKM.EXPECT().FindMappingForKernel(gomock.Any(), otherKernel).Return(nil, errors.New("no mapping")),
+		)
+
+		state, err := NewInspector(clnt, mockDC, mockKM, mockRegistry, false).InspectModule(ctx, mod)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(state.DevicePlugin).To(BeNil())
+		Expect(state.Nodes).To(HaveLen(2))
+		Expect(state.Nodes[0].DevicePlugin).To(Equal(
+			&DaemonSetState{Name: "device-plugin-" + kernelVersion, Desired: 1, Available: 1},
+		))
+		Expect(state.Nodes[1].DevicePlugin).To(BeNil())
+	})
+
+	It("should return an error if the device plugin DaemonSets cannot be listed", func() {
+		ctx := context.Background()
+
+		gomock.InOrder(
+			clnt.EXPECT().List(ctx, gomock.Any(), gomock.Any()),
+			mockDC.EXPECT().ModuleDaemonSetsByKernelVersion(ctx, moduleName, moduleNamespace),
+			mockDC.EXPECT().DevicePluginDaemonSetsByKernelVersion(ctx, moduleName, moduleNamespace).Return(nil, errors.New("random error")),
+		)
+
+		_, err := NewInspector(clnt, mockDC, mockKM, mockRegistry, false).InspectModule(ctx, mod)
+		Expect(err).To(HaveOccurred())
+	})
+
+	DescribeTable("should report the status of the build Job",
+		func(jobs []batchv1.Job, expected string) {
+			ctx := context.Background()
+
+			mod.Spec.ModuleLoader.Container.Build = &kmmv1beta1.Build{}
+
+			nodes := []v1.Node{nodeWithKernel("node", kernelVersion, nil)}
+			mapping := &mod.Spec.ModuleLoader.Container.KernelMappings[0]
+			preparedMapping := kmmv1beta1.KernelMapping{ContainerImage: image}
+
+			gomock.InOrder(
+				clnt.EXPECT().List(ctx, gomock.Any(), gomock.Any()).DoAndReturn(
+					func(_ interface{}, list *v1.NodeList, _ ...interface{}) error {
+						list.Items = nodes
+						return nil
+					},
+				),
+				mockDC.EXPECT().ModuleDaemonSetsByKernelVersion(ctx, moduleName, moduleNamespace),
+				mockDC.EXPECT().DevicePluginDaemonSetsByKernelVersion(ctx, moduleName, moduleNamespace),
+				mockKM.EXPECT().FindMappingForKernel(gomock.Any(), kernelVersion).Return(mapping, nil),
+				mockKM.EXPECT().GetOSConfigForKernel(kernelVersion).Return(&module.NodeOSConfig{}, nil),
+				mockKM.EXPECT().PrepareKernelMapping(mapping, &module.NodeOSConfig{}).Return(&preparedMapping, nil),
+				clnt.EXPECT().List(ctx, gomock.Any(), gomock.Any()).DoAndReturn(
+					func(_ interface{}, list *batchv1.JobList, _ ...interface{}) error {
+						list.Items = jobs
+						return nil
+					},
+				),
+			)
+
+			state, err := NewInspector(clnt, mockDC, mockKM, mockRegistry, false).InspectModule(ctx, mod)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(state.Nodes).To(HaveLen(1))
+			Expect(state.Nodes[0].BuildStatus).To(Equal(expected))
+			Expect(state.Nodes[0].ImageExists).To(BeNil())
+		},
+		Entry("no Job", nil, BuildStatusNone),
+		Entry("running", []batchv1.Job{{}}, BuildStatusRunning),
+		Entry("succeeded", []batchv1.Job{{Status: batchv1.JobStatus{Succeeded: 1}}}, BuildStatusSucceeded),
+		Entry("failed", []batchv1.Job{{Status: batchv1.JobStatus{Failed: 1}}}, BuildStatusFailed),
+	)
+
+	It("should report kernel versions that cannot be parsed", func() {
+		ctx := context.Background()
+
+		nodes := []v1.Node{nodeWithKernel("node", "5.18", nil)}
+
+		gomock.InOrder(
+			clnt.EXPECT().List(ctx, gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ interface{}, list *v1.NodeList, _ ...interface{}) error {
+					list.Items = nodes
+					return nil
+				},
+			),
+			mockDC.EXPECT().ModuleDaemonSetsByKernelVersion(ctx, moduleName, moduleNamespace),
+			mockDC.EXPECT().DevicePluginDaemonSetsByKernelVersion(ctx, moduleName, moduleNamespace),
+			mockKM.EXPECT().FindMappingForKernel(gomock.Any(), "5.18").Return(&mod.Spec.ModuleLoader.Container.KernelMappings[0], nil),
+			mockKM.EXPECT().GetOSConfigForKernel("5.18").Return(nil, errors.New("invalid kernel version")),
+		)
+
+		state, err := NewInspector(clnt, mockDC, mockKM, mockRegistry, true).InspectModule(ctx, mod)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(state.Nodes).To(HaveLen(1))
+		Expect(state.Nodes[0].MappingError).To(Equal("invalid kernel version"))
+		Expect(state.Nodes[0].Image).To(BeEmpty())
+	})
+})
@@ -0,0 +1,51 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: inspector.go
+
+// Package cli is a generated GoMock package.
+package cli
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	v1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+)
+
+// MockInspector is a mock of Inspector interface.
+type MockInspector struct {
+	ctrl     *gomock.Controller
+	recorder *MockInspectorMockRecorder
+}
+
+// MockInspectorMockRecorder is the mock recorder for MockInspector.
+type MockInspectorMockRecorder struct {
+	mock *MockInspector
+}
+
+// NewMockInspector creates a new mock instance.
+func NewMockInspector(ctrl *gomock.Controller) *MockInspector {
+	mock := &MockInspector{ctrl: ctrl}
+	mock.recorder = &MockInspectorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockInspector) EXPECT() *MockInspectorMockRecorder {
+	return m.recorder
+}
+
+// InspectModule mocks base method.
+func (m *MockInspector) InspectModule(ctx context.Context, mod *v1beta1.Module) (*ModuleState, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InspectModule", ctx, mod)
+	ret0, _ := ret[0].(*ModuleState)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InspectModule indicates an expected call of InspectModule.
+func (mr *MockInspectorMockRecorder) InspectModule(ctx, mod interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InspectModule", reflect.TypeOf((*MockInspector)(nil).InspectModule), ctx, mod)
+}
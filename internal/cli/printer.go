@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	"sigs.k8s.io/yaml"
+)
+
+const none = "<none>"
+
+// PrintModuleState writes a human-readable table of state to w.
+func PrintModuleState(w io.Writer, state *ModuleState) error {
+	if _, err := fmt.Fprintf(w, "Module:\t%s/%s\n", state.Namespace, state.Name); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "Device plugin:\t%s\n\n", daemonSetString(state.DevicePlugin)); err != nil {
+		return err
+	}
+
+	if len(state.Nodes) == 0 {
+		_, err := fmt.Fprintln(w, "No node targeted by this Module.")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "NODE\tKERNEL\tMAPPING\tIMAGE\tIMAGE EXISTS\tBUILD\tMODULE LOADER\tDEVICE PLUGIN\tREADY\tDEVICE PLUGIN READY")
+
+	for _, n := range state.Nodes {
+		mapping := n.Mapping
+		image := n.Image
+
+		if n.MappingError != "" {
+			mapping = "<error: " + n.MappingError + ">"
+			image = none
+		}
+
+		fmt.Fprintf(
+			tw,
+			"%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%t\t%t\n",
+			n.Name,
+			n.KernelVersion,
+			mapping,
+			image,
+			imageExistsString(n.ImageExists),
+			valueOrNone(n.BuildStatus),
+			daemonSetString(n.ModuleLoader),
+			daemonSetString(n.DevicePlugin),
+			n.Ready,
+			n.DevicePluginReady,
+		)
+	}
+
+	return tw.Flush()
+}
+
+func daemonSetString(ds *DaemonSetState) string {
+	if ds == nil {
+		return none
+	}
+
+	return fmt.Sprintf("%s (%d/%d available)", ds.Name, ds.Available, ds.Desired)
+}
+
+func imageExistsString(exists *bool) string {
+	if exists == nil {
+		return "-"
+	}
+
+	return strconv.FormatBool(*exists)
+}
+
+func valueOrNone(s string) string {
+	if s == "" {
+		return none
+	}
+
+	return s
+}
+
+// PrintResolution writes, for each kernel, the image and build configuration the operator would use for mod.
+func PrintResolution(w io.Writer, mod *kmmv1beta1.Module, res *Resolution) error {
+	fmt.Fprintf(w, "Module: %s/%s\n", mod.Namespace, mod.Name)
+
+	if len(res.Kernels) == 0 && len(res.UnmappedNodes) == 0 {
+		_, err := fmt.Fprintln(w, "No node targeted by this Module.")
+		return err
+	}
+
+	for _, kr := range res.Kernels {
+		fmt.Fprintf(w, "\nKernel: %s\n", kr.KernelVersion)
+		fmt.Fprintf(w, "  Nodes: %s\n", strings.Join(kr.Nodes, ", "))
+		fmt.Fprintf(w, "  Mapping: %s\n", kr.Mapping)
+
+		if kr.Error != "" {
+			fmt.Fprintf(w, "  Error: %s\n", kr.Error)
+			continue
+		}
+
+		fmt.Fprintf(w, "  Image: %s\n", kr.Image)
+
+		if kr.Build == nil {
+			fmt.Fprintln(w, "  Build: <none>")
+			continue
+		}
+
+		b, err := yaml.Marshal(kr.Build)
+		if err != nil {
+			return fmt.Errorf("could not marshal the build configuration for kernel %s: %w", kr.KernelVersion, err)
+		}
+
+		fmt.Fprintln(w, "  Build:")
+
+		for _, line := range strings.Split(strings.TrimRight(string(b), "\n"), "\n") {
+			fmt.Fprintf(w, "    %s\n", line)
+		}
+	}
+
+	if len(res.UnmappedNodes) > 0 {
+		fmt.Fprintln(w, "\nNodes with no matching kernel mapping:")
+
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+		fmt.Fprintln(tw, "  NODE\tKERNEL")
+
+		for _, n := range res.UnmappedNodes {
+			fmt.Fprintf(tw, "  %s\t%s\n", n.Name, n.KernelVersion)
+		}
+
+		return tw.Flush()
+	}
+
+	return nil
+}
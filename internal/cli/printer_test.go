@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("PrintModuleState", func() {
+	It("should print one line per node", func() {
+		exists := false
+
+		state := &ModuleState{
+			Name:         "module-name",
+			Namespace:    "module-namespace",
+			DevicePlugin: &DaemonSetState{Name: "device-plugin", Desired: 1, Available: 1},
+			Nodes: []NodeState{
+				{
+					Name:          "node-a",
+					KernelVersion: "5.18.0",
+					Mapping:       "^5.+$",
+					Image:         "some-image",
+					ImageExists:   &exists,
+					BuildStatus:   BuildStatusRunning,
+					DevicePlugin:  &DaemonSetState{Name: "device-plugin-5.18.0", Desired: 1, Available: 0},
+					Ready:         true,
+				},
+				{
+					Name:          "node-b",
+					KernelVersion: "4.18.0",
+					MappingError:  "no mapping",
+				},
+			},
+		}
+
+		buf := bytes.Buffer{}
+
+		Expect(PrintModuleState(&buf, state)).To(Succeed())
+
+		out := buf.String()
+		Expect(out).To(ContainSubstring("Module:\tmodule-namespace/module-name"))
+		Expect(out).To(ContainSubstring("device-plugin (1/1 available)"))
+		Expect(out).To(MatchRegexp(`node-a\s+5\.18\.0\s+\^5\.\+\$\s+some-image\s+false\s+Running\s+<none>\s+device-plugin-5\.18\.0 \(0/1 available\)\s+true\s+false`))
+		Expect(out).To(MatchRegexp(`node-b\s+4\.18\.0\s+<error: no mapping>\s+<none>\s+-\s+<none>\s+<none>\s+<none>\s+false\s+false`))
+	})
+
+	It("should say when no node is targeted", func() {
+		buf := bytes.Buffer{}
+
+		Expect(PrintModuleState(&buf, &ModuleState{Name: "name", Namespace: "namespace"})).To(Succeed())
+		Expect(buf.String()).To(ContainSubstring("No node targeted by this Module."))
+	})
+})
+
+var _ = Describe("PrintResolution", func() {
+	It("should print each kernel and the unmapped nodes", func() {
+		mod := &kmmv1beta1.Module{
+			ObjectMeta: metav1.ObjectMeta{Name: "module-name", Namespace: "module-namespace"},
+		}
+
+		res := &Resolution{
+			Kernels: []KernelResolution{
+				{
+					KernelVersion: "kernel-a",
+					Nodes:         []string{"node-a", "node-b"},
+					Mapping:       "kernel-a",
+					Image:         "image-a",
+					Build:         &kmmv1beta1.Build{Dockerfile: "FROM test"},
+				},
+				{
+					KernelVersion: "kernel-b",
+					Nodes:         []string{"node-c"},
+					Mapping:       ".*",
+					Image:         "image-b",
+				},
+			},
+			UnmappedNodes: []UnmappedNode{{Name: "node-d", KernelVersion: "kernel-d"}},
+		}
+
+		buf := bytes.Buffer{}
+
+		Expect(PrintResolution(&buf, mod, res)).To(Succeed())
+
+		out := buf.String()
+		Expect(out).To(ContainSubstring("Module: module-namespace/module-name"))
+		Expect(out).To(ContainSubstring("Kernel: kernel-a\n  Nodes: node-a, node-b\n  Mapping: kernel-a\n  Image: image-a\n  Build:\n"))
+		Expect(out).To(ContainSubstring("\n    dockerfile: FROM test\n"))
+		Expect(out).To(ContainSubstring("Kernel: kernel-b\n  Nodes: node-c\n  Mapping: .*\n  Image: image-b\n  Build: <none>\n"))
+		Expect(out).To(ContainSubstring("Nodes with no matching kernel mapping:"))
+		Expect(out).To(MatchRegexp(`node-d\s+kernel-d`))
+	})
+})
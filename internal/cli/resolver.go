@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"sort"
+
+	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	"github.com/qbarrand/oot-operator/internal/build"
+	"github.com/qbarrand/oot-operator/internal/module"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// KernelResolution is what the operator would do for all nodes running the same kernel.
+type KernelResolution struct {
+	KernelVersion string
+	Nodes         []string
+
+	// Mapping is the literal or regexp of the kernel mapping that matches the kernel.
+	Mapping string
+
+	// Image is the container image that the kernel resolves to.
+	Image string
+
+	// Build is the effective build configuration for that kernel, if the image needs to be built.
+	Build *kmmv1beta1.Build
+
+	// Error is set if the template variables in the mapping could not be substituted.
+	Error string
+}
+
+// UnmappedNode is a node targeted by a Module for which no kernel mapping matches.
+type UnmappedNode struct {
+	Name          string
+	KernelVersion string
+}
+
+// Resolution is the outcome of resolving the kernel mappings of a Module against a set of nodes.
+type Resolution struct {
+	Kernels       []KernelResolution
+	UnmappedNodes []UnmappedNode
+}
+
+//go:generate mockgen -source=resolver.go -package=cli -destination=mock_resolver.go
+
+type Resolver interface {
+	Resolve(mod *kmmv1beta1.Module, nodes []v1.Node) (*Resolution, error)
+}
+
+type resolver struct {
+	kernelAPI module.KernelMapper
+	helper    build.Helper
+}
+
+// NewResolver returns a Resolver that never contacts the cluster nor any registry.
+func NewResolver(kernelAPI module.KernelMapper, helper build.Helper) Resolver {
+	return &resolver{
+		kernelAPI: kernelAPI,
+		helper:    helper,
+	}
+}
+
+// Resolve resolves the kernel mappings of mod the way the Module reconciler would, for all nodes matching its selector.
+func (r *resolver) Resolve(mod *kmmv1beta1.Module, nodes []v1.Node) (*Resolution, error) {
+	selector, err := module.NodeSelector(&mod.Spec)
+	if err != nil {
+		return nil, err
+	}
+
+	res := Resolution{
+		Kernels:       make([]KernelResolution, 0),
+		UnmappedNodes: make([]UnmappedNode, 0),
+	}
+
+	// index of each kernel in res.Kernels
+	kernelIndex := make(map[string]int)
+	unmappedKernels := make(map[string]bool)
+
+	for _, node := range nodes {
+		if !selector.Matches(labels.Set(node.Labels)) {
+			continue
+		}
+
+		kernelVersion := node.Status.NodeInfo.KernelVersion
+
+		if unmappedKernels[kernelVersion] {
+			res.UnmappedNodes = append(res.UnmappedNodes, UnmappedNode{Name: node.Name, KernelVersion: kernelVersion})
+			continue
+		}
+
+		if i, ok := kernelIndex[kernelVersion]; ok {
+			res.Kernels[i].Nodes = append(res.Kernels[i].Nodes, node.Name)
+			continue
+		}
+
+		m, err := r.kernelAPI.FindMappingForKernel(mod.Spec.ModuleLoader.Container.KernelMappings, kernelVersion)
+		if err != nil {
+			unmappedKernels[kernelVersion] = true
+			res.UnmappedNodes = append(res.UnmappedNodes, UnmappedNode{Name: node.Name, KernelVersion: kernelVersion})
+			continue
+		}
+
+		kr := KernelResolution{
+			KernelVersion: kernelVersion,
+			Nodes:         []string{node.Name},
+			Mapping:       m.Literal,
+		}
+
+		if kr.Mapping == "" {
+			kr.Mapping = m.Regexp
+		}
+
+		if osConfig, err := r.kernelAPI.GetOSConfigForKernel(kernelVersion); err != nil {
+			kr.Error = err.Error()
+		} else if m, err = r.kernelAPI.PrepareKernelMapping(m, osConfig); err != nil {
+			kr.Error = err.Error()
+		} else {
+			kr.Image = m.ContainerImage
+			kr.Build = r.effectiveBuild(mod, m, kernelVersion)
+		}
+
+		kernelIndex[kernelVersion] = len(res.Kernels)
+		res.Kernels = append(res.Kernels, kr)
+	}
+
+	sort.Slice(res.Kernels, func(i, j int) bool {
+		return res.Kernels[i].KernelVersion < res.Kernels[j].KernelVersion
+	})
+
+	for _, kr := range res.Kernels {
+		sort.Strings(kr.Nodes)
+	}
+
+	sort.Slice(res.UnmappedNodes, func(i, j int) bool {
+		return res.UnmappedNodes[i].Name < res.UnmappedNodes[j].Name
+	})
+
+	return &res, nil
+}
+
+func (r *resolver) effectiveBuild(mod *kmmv1beta1.Module, m *kmmv1beta1.KernelMapping, kernelVersion string) *kmmv1beta1.Build {
+	if mod.Spec.ModuleLoader.Container.Build == nil && m.Build == nil {
+		return nil
+	}
+
+	b := r.helper.GetRelevantBuild(*mod, *m)
+
+	// the build Job always passes the target kernel to the build
+	b.BuildArgs = r.helper.ApplyBuildArgOverrides(
+		b.BuildArgs,
+		kmmv1beta1.BuildArg{Name: "KERNEL_VERSION", Value: kernelVersion},
+	)
+
+	return b
+}
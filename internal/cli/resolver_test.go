@@ -0,0 +1,172 @@
+package cli
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	"github.com/qbarrand/oot-operator/internal/build"
+	"github.com/qbarrand/oot-operator/internal/module"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Resolve", func() {
+	const (
+		literalKernel = "5.14.0-70.el9.x86_64"
+		regexpKernel  = "5.18.0-1.fc36.x86_64"
+		badKernel     = "4.18.0-1.x86_64"
+	)
+
+	node := func(name, kernel string, labels map[string]string) v1.Node {
+		return v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+			Status: v1.NodeStatus{
+				NodeInfo: v1.NodeSystemInfo{KernelVersion: kernel},
+			},
+		}
+	}
+
+	selector := map[string]string{"kmm": "true"}
+
+	mod := &kmmv1beta1.Module{
+		ObjectMeta: metav1.ObjectMeta{Name: "name", Namespace: "namespace"},
+		Spec: kmmv1beta1.ModuleSpec{
+			ModuleLoader: kmmv1beta1.ModuleLoaderSpec{
+				Container: kmmv1beta1.ModuleLoaderContainerSpec{
+					Build: &kmmv1beta1.Build{
+						Dockerfile: "module Dockerfile",
+						BuildArgs:  []kmmv1beta1.BuildArg{{Name: "arg", Value: "module"}},
+					},
+					KernelMappings: []kmmv1beta1.KernelMapping{
+						{
+							Literal:        literalKernel,
+							ContainerImage: "literal:${KERNEL_FULL_VERSION}",
+							Build: &kmmv1beta1.Build{
+								BuildArgs: []kmmv1beta1.BuildArg{{Name: "arg", Value: "mapping"}},
+							},
+						},
+						{
+							Regexp:         `^5\.18.+$`,
+							ContainerImage: "regexp:${KERNEL_FULL_VERSION}",
+						},
+					},
+				},
+			},
+			Selector: selector,
+		},
+	}
+
+	It("should resolve images and builds per kernel, and report unmapped nodes", func() {
+		nodes := []v1.Node{
+			node("node-regexp-b", regexpKernel, selector),
+			node("node-literal", literalKernel, selector),
+			node("node-regexp-a", regexpKernel, selector),
+			node("node-unmapped", badKernel, selector),
+			node("node-not-targeted", literalKernel, nil),
+		}
+
+		res, err := NewResolver(module.NewKernelMapper(), build.NewHelper()).Resolve(mod, nodes)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(res.Kernels).To(Equal([]KernelResolution{
+			{
+				KernelVersion: literalKernel,
+				Nodes:         []string{"node-literal"},
+				Mapping:       literalKernel,
+				Image:         "literal:" + literalKernel,
+				Build: &kmmv1beta1.Build{
+					Dockerfile: "module Dockerfile",
+					BuildArgs: []kmmv1beta1.BuildArg{
+						{Name: "arg", Value: "mapping"},
+						{Name: "KERNEL_VERSION", Value: literalKernel},
+					},
+				},
+			},
+			{
+				KernelVersion: regexpKernel,
+				Nodes:         []string{"node-regexp-a", "node-regexp-b"},
+				Mapping:       `^5\.18.+$`,
+				Image:         "regexp:" + regexpKernel,
+				Build: &kmmv1beta1.Build{
+					Dockerfile: "module Dockerfile",
+					BuildArgs: []kmmv1beta1.BuildArg{
+						{Name: "arg", Value: "module"},
+						{Name: "KERNEL_VERSION", Value: regexpKernel},
+					},
+				},
+			},
+		}))
+
+		Expect(res.UnmappedNodes).To(Equal([]UnmappedNode{
+			{Name: "node-unmapped", KernelVersion: badKernel},
+		}))
+	})
+
+	It("should not return a build if none is configured", func() {
+		noBuild := mod.DeepCopy()
+		noBuild.Spec.ModuleLoader.Container.Build = nil
+
+		res, err := NewResolver(module.NewKernelMapper(), build.NewHelper()).
+			Resolve(noBuild, []v1.Node{node("node", regexpKernel, selector)})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(res.Kernels).To(HaveLen(1))
+		Expect(res.Kernels[0].Image).To(Equal("regexp:" + regexpKernel))
+		Expect(res.Kernels[0].Build).To(BeNil())
+	})
+
+	It("should report an error for kernel versions without major, minor and patch numbers", func() {
+		anyKernel := mod.DeepCopy()
+		anyKernel.Spec.ModuleLoader.Container.KernelMappings = []kmmv1beta1.KernelMapping{
+			{Regexp: `^.+$`, ContainerImage: "image:${KERNEL_FULL_VERSION}"},
+		}
+
+		res, err := NewResolver(module.NewKernelMapper(), build.NewHelper()).
+			Resolve(anyKernel, []v1.Node{node("node", "5.18", selector)})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(res.Kernels).To(HaveLen(1))
+		Expect(res.Kernels[0].Error).To(ContainSubstring("does not contain major, minor and patch numbers"))
+		Expect(res.Kernels[0].Image).To(BeEmpty())
+	})
+
+	It("should not modify the Module", func() {
+		orig := mod.DeepCopy()
+
+		_, err := NewResolver(module.NewKernelMapper(), build.NewHelper()).
+			Resolve(mod, []v1.Node{node("node", literalKernel, selector)})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(mod).To(Equal(orig))
+	})
+
+	It("should only consider the nodes matching the selector expressions", func() {
+		withExpressions := mod.DeepCopy()
+		withExpressions.Spec.SelectorExpressions = []metav1.LabelSelectorRequirement{
+			{Key: "gpu", Operator: metav1.LabelSelectorOpDoesNotExist},
+		}
+
+		gpuNode := node("gpu-node", literalKernel, selector)
+		gpuNode.Labels = map[string]string{"gpu": ""}
+
+		for k, v := range selector {
+			gpuNode.Labels[k] = v
+		}
+
+		res, err := NewResolver(module.NewKernelMapper(), build.NewHelper()).
+			Resolve(withExpressions, []v1.Node{gpuNode, node("node", literalKernel, selector)})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.Kernels).To(HaveLen(1))
+		Expect(res.Kernels[0].Nodes).To(Equal([]string{"node"}))
+	})
+
+	It("should return an error for invalid selector expressions", func() {
+		invalid := mod.DeepCopy()
+		invalid.Spec.SelectorExpressions = []metav1.LabelSelectorRequirement{
+			{Key: "gpu", Operator: metav1.LabelSelectorOpIn},
+		}
+
+		_, err := NewResolver(module.NewKernelMapper(), build.NewHelper()).Resolve(invalid, nil)
+		Expect(err).To(HaveOccurred())
+	})
+})
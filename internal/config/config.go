@@ -0,0 +1,257 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/qbarrand/oot-operator/internal/constants"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	componentconfigv1alpha1 "k8s.io/component-base/config/v1alpha1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/config/v1alpha1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	APIVersion = "config.kmm.sigs.k8s.io/v1beta1"
+	Kind       = "OperatorConfig"
+)
+
+// Tracing configures the export of OpenTelemetry traces.
+// +kubebuilder:object:generate=true
+type Tracing struct {
+	// Endpoint is the host:port of the OTLP gRPC receiver that spans are sent to.
+	// Tracing is disabled if it is empty.
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Insecure disables TLS when connecting to Endpoint.
+	Insecure bool `json:"insecure,omitempty"`
+
+	// SampleRatio is the ratio of traces that are sampled, between 0 and 1.
+	// All traces are sampled if it is not set.
+	SampleRatio *float64 `json:"sampleRatio,omitempty"`
+}
+
+// Preflight configures how PreflightValidations are run.
+// +kubebuilder:object:generate=true
+type Preflight struct {
+	// Concurrency is the number of Modules checked in parallel by a PreflightValidation.
+	Concurrency int `json:"concurrency,omitempty"`
+
+	// ModuleTimeout is the maximum duration of a single Module check in a PreflightValidation.
+	ModuleTimeout metav1.Duration `json:"moduleTimeout,omitempty"`
+}
+
+// Config is the operator configuration read from the file passed with --config.
+// It embeds the controller-runtime manager settings, so that the same file can configure both.
+// +kubebuilder:object:generate=true
+// +kubebuilder:object:root=true
+type Config struct {
+	metav1.TypeMeta `json:",inline"`
+
+	v1alpha1.ControllerManagerConfigurationSpec `json:",inline"`
+
+	// KernelLabel is the node label holding the full kernel version.
+	KernelLabel string `json:"kernelLabel,omitempty"`
+
+	// DefaultBuilderImage is the image used by build Jobs.
+	// It can be changed without restarting the operator.
+	DefaultBuilderImage string `json:"defaultBuilderImage,omitempty"`
+
+	// RegistryCacheTTL is how long the results of registry layer scans are cached.
+	// It can be changed without restarting the operator.
+	RegistryCacheTTL metav1.Duration `json:"registryCacheTTL,omitempty"`
+
+	// Preflight can be changed without restarting the operator.
+	Preflight Preflight `json:"preflight,omitempty"`
+
+	// WatchedNamespaces restricts the namespaces watched by the operator.
+	// All namespaces are watched if it is empty.
+	WatchedNamespaces []string `json:"watchedNamespaces,omitempty"`
+
+	// AllowedModuleNamespaces restricts the namespaces in which Modules are reconciled.
+	// Modules in all watched namespaces are reconciled if it is empty.
+	AllowedModuleNamespaces []string `json:"allowedModuleNamespaces,omitempty"`
+
+	// EnableWebhooks serves the admission webhooks on the webhook port.
+	// The webhook configuration and serving certificate must be deployed separately.
+	EnableWebhooks bool `json:"enableWebhooks,omitempty"`
+
+	// ManagePodSecurityLabels sets the privileged Pod Security Admission level on namespaces that contain Modules,
+	// unless they opted out with the kmm.sigs.k8s.io/manage-pod-security-labels: "false" annotation.
+	ManagePodSecurityLabels bool `json:"managePodSecurityLabels,omitempty"`
+
+	// NodeLabelResyncPeriod is how often the labels set on nodes for Modules are compared with the pods running on
+	// them and repaired.
+	// The resync is disabled if it is 0.
+	NodeLabelResyncPeriod metav1.Duration `json:"nodeLabelResyncPeriod,omitempty"`
+
+	Tracing Tracing `json:"tracing,omitempty"`
+}
+
+// Complete returns the controller-runtime manager settings, so that Config can be passed to ctrl.Options.AndFrom.
+func (c *Config) Complete() (v1alpha1.ControllerManagerConfigurationSpec, error) {
+	return c.ControllerManagerConfigurationSpec, nil
+}
+
+// NewDefault returns the configuration used when no file is passed with --config.
+// Fields that are absent from a configuration file keep these values.
+func NewDefault() *Config {
+	return &Config{
+		TypeMeta: metav1.TypeMeta{APIVersion: APIVersion, Kind: Kind},
+		ControllerManagerConfigurationSpec: v1alpha1.ControllerManagerConfigurationSpec{
+			LeaderElection: &componentconfigv1alpha1.LeaderElectionConfiguration{
+				LeaderElect:  pointer.Bool(false),
+				ResourceName: "c5baf8af.sigs.k8s.io",
+			},
+			Metrics: v1alpha1.ControllerMetrics{BindAddress: ":8080"},
+			Health:  v1alpha1.ControllerHealth{HealthProbeBindAddress: ":8081"},
+			Webhook: v1alpha1.ControllerWebhook{Port: pointer.Int(9443)},
+		},
+		KernelLabel:           constants.KernelLabel,
+		DefaultBuilderImage:   "gcr.io/kaniko-project/executor:latest",
+		RegistryCacheTTL:      metav1.Duration{Duration: 24 * time.Hour},
+		NodeLabelResyncPeriod: metav1.Duration{Duration: 10 * time.Minute},
+		Preflight: Preflight{
+			Concurrency:   4,
+			ModuleTimeout: metav1.Duration{Duration: 5 * time.Minute},
+		},
+	}
+}
+
+// ParseFile reads and validates the configuration in path.
+// Unknown fields are rejected.
+func ParseFile(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", path, err)
+	}
+
+	cfg, err := decode(b)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode %s: %w", path, err)
+	}
+
+	if err = cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration in %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// decode returns the configuration in b, using the default values for absent fields other than apiVersion and kind.
+func decode(b []byte) (*Config, error) {
+	cfg := NewDefault()
+	cfg.TypeMeta = metav1.TypeMeta{}
+
+	if err := yaml.UnmarshalStrict(b, cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Validate returns an error if some fields of c have invalid values.
+func (c *Config) Validate() error {
+	var errs field.ErrorList
+
+	if c.APIVersion != APIVersion {
+		errs = append(errs, field.NotSupported(field.NewPath("apiVersion"), c.APIVersion, []string{APIVersion}))
+	}
+
+	if c.Kind != Kind {
+		errs = append(errs, field.NotSupported(field.NewPath("kind"), c.Kind, []string{Kind}))
+	}
+
+	if p := c.Webhook.Port; p != nil && (*p < 1 || *p > 65535) {
+		errs = append(errs, field.Invalid(field.NewPath("webhook", "port"), *p, "must be between 1 and 65535"))
+	}
+
+	for _, msg := range validation.IsQualifiedName(c.KernelLabel) {
+		errs = append(errs, field.Invalid(field.NewPath("kernelLabel"), c.KernelLabel, msg))
+	}
+
+	if c.DefaultBuilderImage == "" {
+		errs = append(errs, field.Required(field.NewPath("defaultBuilderImage"), ""))
+	}
+
+	if c.RegistryCacheTTL.Duration < 0 {
+		errs = append(errs, field.Invalid(field.NewPath("registryCacheTTL"), c.RegistryCacheTTL.Duration.String(), "must not be negative"))
+	}
+
+	if c.Preflight.Concurrency < 1 {
+		errs = append(errs, field.Invalid(field.NewPath("preflight", "concurrency"), c.Preflight.Concurrency, "must be at least 1"))
+	}
+
+	if c.Preflight.ModuleTimeout.Duration <= 0 {
+		errs = append(errs, field.Invalid(field.NewPath("preflight", "moduleTimeout"), c.Preflight.ModuleTimeout.Duration.String(), "must be positive"))
+	}
+
+	if c.NodeLabelResyncPeriod.Duration < 0 {
+		errs = append(errs, field.Invalid(field.NewPath("nodeLabelResyncPeriod"), c.NodeLabelResyncPeriod.Duration.String(), "must not be negative"))
+	}
+
+	nsPath := field.NewPath("watchedNamespaces")
+
+	if len(c.WatchedNamespaces) > 0 && c.CacheNamespace != "" {
+		errs = append(errs, field.Forbidden(nsPath, "cannot be used together with cacheNamespace"))
+	}
+
+	errs = append(errs, validateNamespaces(nsPath, c.WatchedNamespaces)...)
+
+	allowedPath := field.NewPath("allowedModuleNamespaces")
+
+	errs = append(errs, validateNamespaces(allowedPath, c.AllowedModuleNamespaces)...)
+
+	if len(c.WatchedNamespaces) > 0 {
+		watched := sets.NewString(c.WatchedNamespaces...)
+
+		for i, ns := range c.AllowedModuleNamespaces {
+			if !watched.Has(ns) {
+				errs = append(errs, field.Invalid(allowedPath.Index(i), ns, "must be one of the watched namespaces"))
+			}
+		}
+	}
+
+	if r := c.Tracing.SampleRatio; r != nil && (*r < 0 || *r > 1) {
+		errs = append(errs, field.Invalid(field.NewPath("tracing", "sampleRatio"), *r, "must be between 0 and 1"))
+	}
+
+	return errs.ToAggregate()
+}
+
+func validateNamespaces(path *field.Path, namespaces []string) field.ErrorList {
+	var errs field.ErrorList
+
+	seen := sets.NewString()
+
+	for i, ns := range namespaces {
+		for _, msg := range validation.IsDNS1123Label(ns) {
+			errs = append(errs, field.Invalid(path.Index(i), ns, msg))
+		}
+
+		if seen.Has(ns) {
+			errs = append(errs, field.Duplicate(path.Index(i), ns))
+		}
+
+		seen.Insert(ns)
+	}
+
+	return errs
+}
+
+// withReloadableFields returns a copy of c in which the fields that can be changed without restarting the operator
+// are taken from other.
+func (c *Config) withReloadableFields(other *Config) *Config {
+	next := *c
+
+	next.DefaultBuilderImage = other.DefaultBuilderImage
+	next.RegistryCacheTTL = other.RegistryCacheTTL
+	next.Preflight = other.Preflight
+
+	return &next
+}
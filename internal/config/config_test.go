@@ -0,0 +1,146 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+)
+
+const header = `
+apiVersion: config.kmm.sigs.k8s.io/v1beta1
+kind: OperatorConfig
+`
+
+func writeFile(contents string) string {
+	path := filepath.Join(GinkgoT().TempDir(), "config.yaml")
+	Expect(os.WriteFile(path, []byte(contents), 0600)).To(Succeed())
+	return path
+}
+
+var _ = Describe("ParseFile", func() {
+	It("should return an error if the file does not exist", func() {
+		_, err := ParseFile(filepath.Join(GinkgoT().TempDir(), "missing.yaml"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should return the default values for absent fields", func() {
+		cfg, err := ParseFile(writeFile(header))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg).To(Equal(NewDefault()))
+	})
+
+	It("should reject unknown fields", func() {
+		_, err := ParseFile(writeFile(header + "unknown: true"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	DescribeTable(
+		"should reject other API versions and kinds",
+		func(contents string) {
+			_, err := ParseFile(writeFile(contents))
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("no header", "kernelLabel: some-label"),
+		Entry(
+			"controller-runtime configuration",
+			"apiVersion: controller-runtime.sigs.k8s.io/v1alpha1\nkind: ControllerManagerConfig",
+		),
+	)
+
+	It("should read the manager and operator settings", func() {
+		path := writeFile(header + `
+health:
+  healthProbeBindAddress: :9081
+metrics:
+  bindAddress: 127.0.0.1:9080
+webhook:
+  port: 9444
+leaderElection:
+  leaderElect: true
+kernelLabel: example.com/kernel
+defaultBuilderImage: example.com/builder:v1
+registryCacheTTL: 1h
+preflight:
+  concurrency: 8
+  moduleTimeout: 1m
+watchedNamespaces: [ns1, ns2]
+allowedModuleNamespaces: [ns1]
+enableWebhooks: true
+managePodSecurityLabels: true
+nodeLabelResyncPeriod: 30m
+`)
+
+		cfg, err := ParseFile(path)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfg.Health.HealthProbeBindAddress).To(Equal(":9081"))
+		Expect(cfg.Metrics.BindAddress).To(Equal("127.0.0.1:9080"))
+		Expect(cfg.Webhook.Port).To(Equal(pointer.Int(9444)))
+		Expect(cfg.LeaderElection.LeaderElect).To(Equal(pointer.Bool(true)))
+		Expect(cfg.LeaderElection.ResourceName).To(Equal("c5baf8af.sigs.k8s.io"))
+		Expect(cfg.KernelLabel).To(Equal("example.com/kernel"))
+		Expect(cfg.DefaultBuilderImage).To(Equal("example.com/builder:v1"))
+		Expect(cfg.RegistryCacheTTL).To(Equal(metav1.Duration{Duration: time.Hour}))
+		Expect(cfg.Preflight).To(Equal(Preflight{Concurrency: 8, ModuleTimeout: metav1.Duration{Duration: time.Minute}}))
+		Expect(cfg.WatchedNamespaces).To(Equal([]string{"ns1", "ns2"}))
+		Expect(cfg.AllowedModuleNamespaces).To(Equal([]string{"ns1"}))
+		Expect(cfg.EnableWebhooks).To(BeTrue())
+		Expect(cfg.ManagePodSecurityLabels).To(BeTrue())
+		Expect(cfg.NodeLabelResyncPeriod).To(Equal(metav1.Duration{Duration: 30 * time.Minute}))
+	})
+
+	It("should read the tracing configuration", func() {
+		path := writeFile(header + `
+tracing:
+  endpoint: collector:4317
+  insecure: true
+  sampleRatio: 0.5
+`)
+
+		cfg, err := ParseFile(path)
+		Expect(err).NotTo(HaveOccurred())
+
+		ratio := 0.5
+
+		Expect(cfg.Tracing).To(Equal(Tracing{Endpoint: "collector:4317", Insecure: true, SampleRatio: &ratio}))
+	})
+})
+
+var _ = Describe("Validate", func() {
+	It("should accept the default configuration", func() {
+		Expect(NewDefault().Validate()).To(Succeed())
+	})
+
+	DescribeTable(
+		"should reject invalid values",
+		func(mutate func(*Config)) {
+			cfg := NewDefault()
+			mutate(cfg)
+			Expect(cfg.Validate()).NotTo(Succeed())
+		},
+		Entry("webhook port", func(c *Config) { c.Webhook.Port = pointer.Int(0) }),
+		Entry("kernel label", func(c *Config) { c.KernelLabel = "not a label" }),
+		Entry("builder image", func(c *Config) { c.DefaultBuilderImage = "" }),
+		Entry("registry cache TTL", func(c *Config) { c.RegistryCacheTTL.Duration = -time.Second }),
+		Entry("preflight concurrency", func(c *Config) { c.Preflight.Concurrency = 0 }),
+		Entry("preflight module timeout", func(c *Config) { c.Preflight.ModuleTimeout.Duration = 0 }),
+		Entry("node label resync period", func(c *Config) { c.NodeLabelResyncPeriod.Duration = -time.Second }),
+		Entry("watched namespace", func(c *Config) { c.WatchedNamespaces = []string{"Not_A_Namespace"} }),
+		Entry("duplicate watched namespaces", func(c *Config) { c.WatchedNamespaces = []string{"ns", "ns"} }),
+		Entry("watched namespaces and cache namespace", func(c *Config) {
+			c.WatchedNamespaces = []string{"ns"}
+			c.CacheNamespace = "other-ns"
+		}),
+		Entry("allowed module namespace", func(c *Config) { c.AllowedModuleNamespaces = []string{"Not_A_Namespace"} }),
+		Entry("allowed module namespace not watched", func(c *Config) {
+			c.WatchedNamespaces = []string{"ns"}
+			c.AllowedModuleNamespaces = []string{"other-ns"}
+		}),
+		Entry("sample ratio", func(c *Config) { c.Tracing.SampleRatio = pointer.Float64(2) }),
+	)
+})
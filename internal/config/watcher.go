@@ -0,0 +1,131 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// Provider returns the current operator configuration.
+// Callers should not keep the returned value, so that they see the changes made while the operator is running.
+type Provider interface {
+	Current() *Config
+}
+
+type staticProvider struct {
+	cfg *Config
+}
+
+// NewStaticProvider returns a Provider that always returns cfg.
+func NewStaticProvider(cfg *Config) Provider {
+	return &staticProvider{cfg: cfg}
+}
+
+func (p *staticProvider) Current() *Config {
+	return p.cfg
+}
+
+const defaultWatchInterval = 10 * time.Second
+
+// Watcher is a Provider that periodically reads the configuration file again.
+// Only the fields that can be changed without restarting the operator are updated; changes to other fields are
+// logged and ignored.
+type Watcher struct {
+	path     string
+	override func(*Config)
+	logger   logr.Logger
+	interval time.Duration
+
+	mu       sync.RWMutex
+	current  *Config
+	contents []byte
+}
+
+// NewWatcher returns a Watcher for the file in path, initially returning cfg.
+// If not nil, override is applied to each new version of the file before it is validated.
+func NewWatcher(path string, cfg *Config, override func(*Config), logger logr.Logger) *Watcher {
+	return &Watcher{
+		path:     path,
+		override: override,
+		logger:   logger,
+		interval: defaultWatchInterval,
+		current:  cfg,
+	}
+}
+
+func (w *Watcher) Current() *Config {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.current
+}
+
+// Start watches the file until ctx is done.
+func (w *Watcher) Start(ctx context.Context) error {
+	wait.UntilWithContext(ctx, w.reload, w.interval)
+	return nil
+}
+
+// NeedLeaderElection returns false, so that all replicas of the operator reload their configuration.
+func (w *Watcher) NeedLeaderElection() bool {
+	return false
+}
+
+func (w *Watcher) reload(_ context.Context) {
+	b, err := os.ReadFile(w.path)
+	if err != nil {
+		w.logger.Error(err, "Could not read the configuration file", "path", w.path)
+		return
+	}
+
+	if bytes.Equal(b, w.contents) {
+		return
+	}
+
+	w.contents = b
+
+	cfg, err := decode(b)
+	if err != nil {
+		w.logger.Error(err, "Could not decode the configuration file; keeping the current configuration", "path", w.path)
+		return
+	}
+
+	if w.override != nil {
+		w.override(cfg)
+	}
+
+	if err = cfg.Validate(); err != nil {
+		w.logger.Error(err, "Invalid configuration file; keeping the current configuration", "path", w.path)
+		return
+	}
+
+	current := w.Current()
+
+	next := current.withReloadableFields(cfg)
+
+	if !reflect.DeepEqual(next, cfg) {
+		w.logger.Info("Configuration file changed; changes to fields other than defaultBuilderImage, registryCacheTTL and preflight require a restart")
+	}
+
+	if reflect.DeepEqual(next, current) {
+		return
+	}
+
+	w.logger.Info(
+		"Reloaded configuration",
+		"defaultBuilderImage", next.DefaultBuilderImage,
+		"registryCacheTTL", next.RegistryCacheTTL.Duration,
+		"preflightConcurrency", next.Preflight.Concurrency,
+		"preflightModuleTimeout", next.Preflight.ModuleTimeout.Duration,
+	)
+
+	w.mu.Lock()
+	w.current = next
+	w.mu.Unlock()
+}
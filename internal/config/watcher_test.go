@@ -0,0 +1,95 @@
+package config
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Watcher", func() {
+	var (
+		path    string
+		initial *Config
+		w       *Watcher
+	)
+
+	BeforeEach(func() {
+		path = writeFile(header)
+
+		var err error
+
+		initial, err = ParseFile(path)
+		Expect(err).NotTo(HaveOccurred())
+
+		w = NewWatcher(path, initial, nil, logr.Discard())
+	})
+
+	ctx := context.Background()
+
+	It("should return the initial configuration if the file did not change", func() {
+		w.reload(ctx)
+		Expect(w.Current()).To(BeIdenticalTo(initial))
+	})
+
+	It("should only update the reloadable fields", func() {
+		const contents = header + `
+kernelLabel: example.com/kernel
+defaultBuilderImage: example.com/builder:v1
+preflight:
+  concurrency: 8
+`
+
+		Expect(os.WriteFile(path, []byte(contents), 0600)).To(Succeed())
+
+		w.reload(ctx)
+
+		expected := NewDefault()
+		expected.DefaultBuilderImage = "example.com/builder:v1"
+		expected.Preflight.Concurrency = 8
+
+		Expect(w.Current()).To(Equal(expected))
+	})
+
+	It("should apply the override before validating the file", func() {
+		w.override = func(c *Config) { c.Preflight.Concurrency = 16 }
+
+		Expect(os.WriteFile(path, []byte(header+"preflight: {concurrency: 0}"), 0600)).To(Succeed())
+
+		w.reload(ctx)
+
+		Expect(w.Current().Preflight.Concurrency).To(Equal(16))
+	})
+
+	It("should keep the current configuration if the file is invalid", func() {
+		Expect(os.WriteFile(path, []byte(header+"preflight: {concurrency: -1}"), 0600)).To(Succeed())
+
+		w.reload(ctx)
+
+		Expect(w.Current()).To(BeIdenticalTo(initial))
+	})
+
+	It("should reload the file until the context is done", func() {
+		w.interval = 10 * time.Millisecond
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+			Expect(w.Start(ctx)).To(Succeed())
+		}()
+
+		Expect(os.WriteFile(path, []byte(header+"registryCacheTTL: 1h"), 0600)).To(Succeed())
+
+		Eventually(func() time.Duration { return w.Current().RegistryCacheTTL.Duration }).Should(Equal(time.Hour))
+
+		cancel()
+		Eventually(done).Should(BeClosed())
+	})
+})
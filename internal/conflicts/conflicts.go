@@ -0,0 +1,144 @@
+package conflicts
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	"github.com/qbarrand/oot-operator/internal/module"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+//go:generate mockgen -source=conflicts.go -package=conflicts -destination=mock_conflicts.go
+
+type Detector interface {
+	FindConflicts(ctx context.Context, mod *kmmv1beta1.Module) ([]kmmv1beta1.ModuleConflict, error)
+	FindConflictsOnNodes(ctx context.Context, mod *kmmv1beta1.Module, nodes []v1.Node) ([]kmmv1beta1.ModuleConflict, sets.String, error)
+}
+
+type detector struct {
+	client client.Client
+}
+
+func NewDetector(client client.Client) Detector {
+	return &detector{client: client}
+}
+
+// FindConflicts returns the other Modules that load the same kernel module as mod on some of the nodes targeted by
+// mod, sorted by namespace and name.
+func (d *detector) FindConflicts(ctx context.Context, mod *kmmv1beta1.Module) ([]kmmv1beta1.ModuleConflict, error) {
+	if mod.Spec.ModuleLoader.Container.Modprobe.ModuleName == "" {
+		return nil, nil
+	}
+
+	sel, err := module.NodeSelector(&mod.Spec)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := v1.NodeList{}
+
+	if err = d.client.List(ctx, &nodes, client.MatchingLabelsSelector{Selector: sel}); err != nil {
+		return nil, fmt.Errorf("could not list nodes: %w", err)
+	}
+
+	conflicts, _, err := d.FindConflictsOnNodes(ctx, mod, nodes.Items)
+
+	return conflicts, err
+}
+
+// FindConflictsOnNodes returns the other Modules that load the same kernel module as mod on some of nodes, sorted by
+// namespace and name, and the names of the nodes on which mod must yield to a Module that precedes it.
+func (d *detector) FindConflictsOnNodes(ctx context.Context, mod *kmmv1beta1.Module, nodes []v1.Node) ([]kmmv1beta1.ModuleConflict, sets.String, error) {
+	moduleName := mod.Spec.ModuleLoader.Container.Modprobe.ModuleName
+	if moduleName == "" || len(nodes) == 0 {
+		return nil, sets.NewString(), nil
+	}
+
+	mods := kmmv1beta1.ModuleList{}
+
+	if err := d.client.List(ctx, &mods); err != nil {
+		return nil, nil, fmt.Errorf("could not list Modules: %w", err)
+	}
+
+	conflicts := make([]kmmv1beta1.ModuleConflict, 0)
+	yielded := sets.NewString()
+
+	for i := range mods.Items {
+		other := &mods.Items[i]
+
+		if other.Namespace == mod.Namespace && other.Name == mod.Name {
+			continue
+		}
+
+		if other.DeletionTimestamp != nil || other.Spec.ModuleLoader.Container.Modprobe.ModuleName != moduleName {
+			continue
+		}
+
+		sel, err := module.NodeSelector(&other.Spec)
+		if err != nil {
+			// the other Module cannot be reconciled and does not load the kernel module anywhere
+			continue
+		}
+
+		otherFirst := Precedes(other, mod)
+
+		var count int32
+
+		for _, n := range nodes {
+			if sel.Matches(labels.Set(n.Labels)) {
+				count++
+
+				if otherFirst {
+					yielded.Insert(n.Name)
+				}
+			}
+		}
+
+		if count > 0 {
+			conflicts = append(
+				conflicts,
+				kmmv1beta1.ModuleConflict{Name: other.Name, Namespace: other.Namespace, NodesNumber: count, Yielded: otherFirst},
+			)
+		}
+	}
+
+	if len(conflicts) == 0 {
+		return nil, yielded, nil
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].Namespace != conflicts[j].Namespace {
+			return conflicts[i].Namespace < conflicts[j].Namespace
+		}
+
+		return conflicts[i].Name < conflicts[j].Name
+	})
+
+	return conflicts, yielded, nil
+}
+
+// Precedes returns true if a keeps the nodes it shares with b when both load the same kernel module.
+// The Module created first wins; Modules that are not created yet come last.
+// Namespaces and names break ties, so that the order is the same in all reconciliations.
+func Precedes(a, b *kmmv1beta1.Module) bool {
+	ta, tb := a.CreationTimestamp, b.CreationTimestamp
+
+	if ta.IsZero() != tb.IsZero() {
+		return tb.IsZero()
+	}
+
+	if !ta.Equal(&tb) {
+		return ta.Before(&tb)
+	}
+
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+
+	return a.Name < b.Name
+}
@@ -0,0 +1,188 @@
+package conflicts
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	"github.com/qbarrand/oot-operator/internal/client"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type moduleOption func(*kmmv1beta1.Module)
+
+func withKernelModule(kernelModule string) moduleOption {
+	return func(mod *kmmv1beta1.Module) {
+		mod.Spec.ModuleLoader.Container.Modprobe.ModuleName = kernelModule
+	}
+}
+
+func withSelector(selector map[string]string) moduleOption {
+	return func(mod *kmmv1beta1.Module) {
+		mod.Spec.Selector = selector
+	}
+}
+
+func createdAt(created time.Time) moduleOption {
+	return func(mod *kmmv1beta1.Module) {
+		mod.CreationTimestamp = metav1.NewTime(created)
+	}
+}
+
+// makeModule returns a Module loading the kmod kernel module on all nodes, modified by opts.
+func makeModule(name, namespace string, opts ...moduleOption) kmmv1beta1.Module {
+	mod := kmmv1beta1.Module{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: kmmv1beta1.ModuleSpec{
+			ModuleLoader: kmmv1beta1.ModuleLoaderSpec{
+				Container: kmmv1beta1.ModuleLoaderContainerSpec{
+					Modprobe: kmmv1beta1.ModprobeSpec{ModuleName: "kmod"},
+				},
+			},
+		},
+	}
+
+	for _, opt := range opts {
+		opt(&mod)
+	}
+
+	return mod
+}
+
+var _ = Describe("FindConflicts", func() {
+	var (
+		ctx  context.Context
+		clnt *client.MockClient
+		d    Detector
+	)
+
+	BeforeEach(func() {
+		ctrl := gomock.NewController(GinkgoT())
+		clnt = client.NewMockClient(ctrl)
+		d = NewDetector(clnt)
+		ctx = context.Background()
+	})
+
+	It("should return nothing if the Module targets no nodes", func() {
+		mod := makeModule("mod", "ns", withSelector(map[string]string{"a": "b"}))
+
+		clnt.EXPECT().List(ctx, &v1.NodeList{}, ctrlclient.MatchingLabelsSelector{Selector: labels.SelectorFromSet(labels.Set{"a": "b"})})
+
+		Expect(d.FindConflicts(ctx, &mod)).To(BeNil())
+	})
+
+	It("should only return the Modules loading the same kernel module on the same nodes", func() {
+		mod := makeModule("mod", "ns", withSelector(map[string]string{"worker": "true"}))
+
+		nodes := []v1.Node{
+			{ObjectMeta: metav1.ObjectMeta{Name: "node1", Labels: map[string]string{"worker": "true", "gpu": "true"}}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "node2", Labels: map[string]string{"worker": "true"}}},
+		}
+
+		now := metav1.Now()
+
+		deleted := makeModule("deleted", "ns")
+		deleted.DeletionTimestamp = &now
+
+		noGPU := makeModule("no-gpu", "ns")
+		noGPU.Spec.SelectorExpressions = []metav1.LabelSelectorRequirement{
+			{Key: "gpu", Operator: metav1.LabelSelectorOpDoesNotExist},
+		}
+
+		mods := []kmmv1beta1.Module{
+			mod,
+			makeModule("all-nodes", "z-ns"),
+			makeModule("gpu-nodes", "a-ns", withSelector(map[string]string{"gpu": "true"})),
+			makeModule("other-nodes", "ns", withSelector(map[string]string{"worker": "false"})),
+			makeModule("other-kmod", "ns", withKernelModule("other-kmod")),
+			noGPU,
+			deleted,
+		}
+
+		gomock.InOrder(
+			clnt.EXPECT().List(ctx, gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ interface{}, list *v1.NodeList, _ ...ctrlclient.ListOption) error {
+					list.Items = nodes
+					return nil
+				},
+			),
+			clnt.EXPECT().List(ctx, gomock.Any()).DoAndReturn(
+				func(_ interface{}, list *kmmv1beta1.ModuleList, _ ...ctrlclient.ListOption) error {
+					list.Items = mods
+					return nil
+				},
+			),
+		)
+
+		Expect(
+			d.FindConflicts(ctx, &mod),
+		).To(
+			Equal([]kmmv1beta1.ModuleConflict{
+				{Name: "gpu-nodes", Namespace: "a-ns", NodesNumber: 1, Yielded: true},
+				{Name: "no-gpu", Namespace: "ns", NodesNumber: 1},
+				{Name: "all-nodes", Namespace: "z-ns", NodesNumber: 2},
+			}),
+		)
+	})
+})
+
+var _ = Describe("FindConflictsOnNodes", func() {
+	It("should return the nodes shared with Modules created first", func() {
+		ctrl := gomock.NewController(GinkgoT())
+		clnt := client.NewMockClient(ctrl)
+		ctx := context.Background()
+
+		now := time.Now()
+
+		mod := makeModule("mod", "ns", createdAt(now))
+
+		nodes := []v1.Node{
+			{ObjectMeta: metav1.ObjectMeta{Name: "node1", Labels: map[string]string{"a": "true"}}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "node2", Labels: map[string]string{"b": "true"}}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "node3"}},
+		}
+
+		clnt.EXPECT().List(ctx, gomock.Any()).DoAndReturn(
+			func(_ interface{}, list *kmmv1beta1.ModuleList, _ ...ctrlclient.ListOption) error {
+				list.Items = []kmmv1beta1.Module{
+					mod,
+					makeModule("earlier", "ns", createdAt(now.Add(-time.Hour)), withSelector(map[string]string{"a": "true"})),
+					makeModule("later", "ns", createdAt(now.Add(time.Hour)), withSelector(map[string]string{"b": "true"})),
+				}
+				return nil
+			},
+		)
+
+		conflicts, yielded, err := NewDetector(clnt).FindConflictsOnNodes(ctx, &mod, nodes)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conflicts).To(Equal([]kmmv1beta1.ModuleConflict{
+			{Name: "earlier", Namespace: "ns", NodesNumber: 1, Yielded: true},
+			{Name: "later", Namespace: "ns", NodesNumber: 1},
+		}))
+		Expect(yielded).To(Equal(sets.NewString("node1")))
+	})
+})
+
+var _ = Describe("Precedes", func() {
+	now := time.Now()
+
+	DescribeTable(
+		"should order Modules",
+		func(a, b kmmv1beta1.Module, expected bool) {
+			Expect(Precedes(&a, &b)).To(Equal(expected))
+		},
+		Entry("created first", makeModule("b", "ns", createdAt(now)), makeModule("a", "ns", createdAt(now.Add(time.Second))), true),
+		Entry("created last", makeModule("a", "ns", createdAt(now.Add(time.Second))), makeModule("b", "ns", createdAt(now)), false),
+		Entry("not created yet", makeModule("a", "ns"), makeModule("b", "ns", createdAt(now)), false),
+		Entry("existing", makeModule("b", "ns", createdAt(now)), makeModule("a", "ns"), true),
+		Entry("same time, namespace first", makeModule("b", "a", createdAt(now)), makeModule("a", "b", createdAt(now)), true),
+		Entry("same time and namespace, name last", makeModule("b", "ns", createdAt(now)), makeModule("a", "ns", createdAt(now)), false),
+	)
+})
@@ -0,0 +1,69 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: conflicts.go
+
+// Package conflicts is a generated GoMock package.
+package conflicts
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	v1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	sets "k8s.io/apimachinery/pkg/util/sets"
+)
+
+// MockDetector is a mock of Detector interface.
+type MockDetector struct {
+	ctrl     *gomock.Controller
+	recorder *MockDetectorMockRecorder
+}
+
+// MockDetectorMockRecorder is the mock recorder for MockDetector.
+type MockDetectorMockRecorder struct {
+	mock *MockDetector
+}
+
+// NewMockDetector creates a new mock instance.
+func NewMockDetector(ctrl *gomock.Controller) *MockDetector {
+	mock := &MockDetector{ctrl: ctrl}
+	mock.recorder = &MockDetectorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDetector) EXPECT() *MockDetectorMockRecorder {
+	return m.recorder
+}
+
+// FindConflicts mocks base method.
+func (m *MockDetector) FindConflicts(ctx context.Context, mod *v1beta1.Module) ([]v1beta1.ModuleConflict, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindConflicts", ctx, mod)
+	ret0, _ := ret[0].([]v1beta1.ModuleConflict)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindConflicts indicates an expected call of FindConflicts.
+func (mr *MockDetectorMockRecorder) FindConflicts(ctx, mod interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindConflicts", reflect.TypeOf((*MockDetector)(nil).FindConflicts), ctx, mod)
+}
+
+// FindConflictsOnNodes mocks base method.
+func (m *MockDetector) FindConflictsOnNodes(ctx context.Context, mod *v1beta1.Module, nodes []v1.Node) ([]v1beta1.ModuleConflict, sets.String, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindConflictsOnNodes", ctx, mod, nodes)
+	ret0, _ := ret[0].([]v1beta1.ModuleConflict)
+	ret1, _ := ret[1].(sets.String)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// FindConflictsOnNodes indicates an expected call of FindConflictsOnNodes.
+func (mr *MockDetectorMockRecorder) FindConflictsOnNodes(ctx, mod, nodes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindConflictsOnNodes", reflect.TypeOf((*MockDetector)(nil).FindConflictsOnNodes), ctx, mod, nodes)
+}
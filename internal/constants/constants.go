@@ -2,7 +2,11 @@ package constants
 
 const (
 	ModuleNameLabel      = "kmm.node.kubernetes.io/module.name"
+	ModuleVersionLabel   = "kmm.node.kubernetes.io/module.version"
 	NodeLabelerFinalizer = "kmm.node.kubernetes.io/node-labeler"
 	TargetKernelTarget   = "kmm.node.kubernetes.io/target-kernel"
+	BuildPushLabel       = "kmm.node.kubernetes.io/build.push"
 	DaemonSetRole        = "kmm.node.kubernetes.io/role"
+	KernelLabel          = "kmm.node.kubernetes.io/kernel-version.full"
+	OSVersionLabel       = "feature.node.kubernetes.io/system-os_release.RHEL_VERSION"
 )
@@ -4,10 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"path"
+	"strings"
 
 	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
 	"github.com/qbarrand/oot-operator/internal/constants"
+	"github.com/qbarrand/oot-operator/internal/module"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -24,16 +28,46 @@ const (
 	nodeLibModulesVolumeName       = "node-lib-modules"
 	nodeUsrLibModulesPath          = "/usr/lib/modules"
 	nodeUsrLibModulesVolumeName    = "node-usr-lib-modules"
+	nodeSysModulePath              = "/sys/module"
+	nodeSysModuleMountPath         = "/host/sys/module"
+	nodeSysModuleVolumeName        = "node-sys-module"
+	moduleParametersPath           = "/etc/kmm/parameters"
+	moduleParametersKey            = "parameters"
+	moduleParametersVolumeName     = "module-parameters"
+	nodeModulesLoadDPath           = "/etc/modules-load.d"
+	nodeModulesLoadDMountPath      = "/host/etc/modules-load.d"
+	nodeModulesLoadDVolumeName     = "node-modules-load-d"
+	nodeModprobeDPath              = "/etc/modprobe.d"
+	nodeModprobeDMountPath         = "/host/etc/modprobe.d"
+	nodeModprobeDVolumeName        = "node-modprobe-d"
+	nodeVarLibKMMPath              = "/var/lib/kmm"
+	nodeVarLibKMMMountPath         = "/host/var/lib/kmm"
+	nodeVarLibKMMVolumeName        = "node-var-lib-kmm"
+	persistConfigContainerName     = "persist-modprobe-config"
+	cleanupConfigContainerName     = "cleanup-modprobe-config"
+	bootConfigCleanupJobPrefix     = "boot-config-cleanup-"
+	podUIDEnvVar                   = "POD_UID"
+	shortDigestLength              = 12
 	devicePluginKernelVersion      = ""
+	moduleLoaderContainerName      = "module-loader"
+	moduleLoaderRole               = "module-loader"
+	devicePluginRole               = "device-plugin"
+	defaultSELinuxType             = "spc_t"
+	defaultPriorityClassName       = "system-node-critical"
+
+	defaultHealthCheckPeriodSeconds    = 10
+	defaultHealthCheckFailureThreshold = 3
 )
 
 //go:generate mockgen -source=daemonset.go -package=daemonset -destination=mock_daemonset.go
 
 type DaemonSetCreator interface {
 	GarbageCollect(ctx context.Context, existingDS map[string]*appsv1.DaemonSet, validKernels sets.String) ([]string, error)
+	DevicePluginDaemonSetsByKernelVersion(ctx context.Context, name, namespace string) (map[string]*appsv1.DaemonSet, error)
 	ModuleDaemonSetsByKernelVersion(ctx context.Context, name, namespace string) (map[string]*appsv1.DaemonSet, error)
-	SetDriverContainerAsDesired(ctx context.Context, ds *appsv1.DaemonSet, image string, mod kmmv1beta1.Module, kernelVersion string) error
-	SetDevicePluginAsDesired(ctx context.Context, ds *appsv1.DaemonSet, mod *kmmv1beta1.Module) error
+	SetDriverContainerAsDesired(ctx context.Context, ds *appsv1.DaemonSet, image string, mod kmmv1beta1.Module, kernelVersion string, excludedNodes sets.String) error
+	SetDevicePluginAsDesired(ctx context.Context, ds *appsv1.DaemonSet, mod *kmmv1beta1.Module, image, kernelVersion string) error
+	SetModuleParametersAsDesired(cm *v1.ConfigMap, mod *kmmv1beta1.Module) error
 	GetNodeLabelFromPod(pod *v1.Pod, moduleName string) string
 }
 
@@ -67,7 +101,18 @@ func (dc *daemonSetGenerator) GarbageCollect(ctx context.Context, existingDS map
 	return deleted, nil
 }
 
+// ModuleDaemonSetsByKernelVersion returns the module loader DaemonSets of a Module by kernel version.
+// The device plugin DaemonSet shared by all kernels, if any, is returned for GetDevicePluginKernelVersion().
 func (dc *daemonSetGenerator) ModuleDaemonSetsByKernelVersion(ctx context.Context, name, namespace string) (map[string]*appsv1.DaemonSet, error) {
+	return dc.daemonSetsByKernelVersion(ctx, name, namespace, false)
+}
+
+// DevicePluginDaemonSetsByKernelVersion returns the per-kernel device plugin DaemonSets of a Module by kernel version.
+func (dc *daemonSetGenerator) DevicePluginDaemonSetsByKernelVersion(ctx context.Context, name, namespace string) (map[string]*appsv1.DaemonSet, error) {
+	return dc.daemonSetsByKernelVersion(ctx, name, namespace, true)
+}
+
+func (dc *daemonSetGenerator) daemonSetsByKernelVersion(ctx context.Context, name, namespace string, perKernelDevicePlugins bool) (map[string]*appsv1.DaemonSet, error) {
 	dsList, err := dc.moduleDaemonSets(ctx, name, namespace)
 	if err != nil {
 		return nil, fmt.Errorf("could not get all DaemonSets: %w", err)
@@ -78,6 +123,10 @@ func (dc *daemonSetGenerator) ModuleDaemonSetsByKernelVersion(ctx context.Contex
 	for i := 0; i < len(dsList); i++ {
 		ds := dsList[i]
 
+		if dc.isPerKernelDevicePluginDaemonSet(&ds) != perKernelDevicePlugins {
+			continue
+		}
+
 		kernelVersion := ds.Labels[dc.kernelLabel]
 		if dsByKernelVersion[kernelVersion] != nil {
 			return nil, fmt.Errorf("multiple DaemonSets found for kernel %q", kernelVersion)
@@ -89,7 +138,9 @@ func (dc *daemonSetGenerator) ModuleDaemonSetsByKernelVersion(ctx context.Contex
 	return dsByKernelVersion, nil
 }
 
-func (dc *daemonSetGenerator) SetDriverContainerAsDesired(ctx context.Context, ds *appsv1.DaemonSet, image string, mod kmmv1beta1.Module, kernelVersion string) error {
+// SetDriverContainerAsDesired sets the spec of the module loader DaemonSet for kernelVersion.
+// Its pods run on the nodes targeted by mod with kernelVersion, except excludedNodes.
+func (dc *daemonSetGenerator) SetDriverContainerAsDesired(ctx context.Context, ds *appsv1.DaemonSet, image string, mod kmmv1beta1.Module, kernelVersion string, excludedNodes sets.String) error {
 	if ds == nil {
 		return errors.New("ds cannot be nil")
 	}
@@ -102,10 +153,16 @@ func (dc *daemonSetGenerator) SetDriverContainerAsDesired(ctx context.Context, d
 		return errors.New("kernelVersion cannot be empty")
 	}
 
+	containerSpec := mod.Spec.ModuleLoader.Container
+
+	if err := ValidateModuleLoaderContainer(containerSpec); err != nil {
+		return fmt.Errorf("invalid module loader container: %w", err)
+	}
+
 	standardLabels := map[string]string{
 		constants.ModuleNameLabel: mod.Name,
 		dc.kernelLabel:            kernelVersion,
-		constants.DaemonSetRole:   "module-loader",
+		constants.DaemonSetRole:   moduleLoaderRole,
 	}
 
 	ds.SetLabels(
@@ -117,79 +174,170 @@ func (dc *daemonSetGenerator) SetDriverContainerAsDesired(ctx context.Context, d
 
 	hostPathDirectory := v1.HostPathDirectory
 
+	podOptions := mod.Spec.ModuleLoader.PodOptions
+
+	readinessProbe, livenessProbe := makeHealthProbes(containerSpec)
+
+	// The version is not part of the selector, so that it can change without recreating the DaemonSet.
+	templateLabels := podLabels(podOptions, standardLabels)
+	if mod.Spec.Version != "" {
+		templateLabels[constants.ModuleVersionLabel] = mod.Spec.Version
+	}
+
+	command := []string{"sleep", "infinity"}
+	initContainers := containerSpec.InitContainers
+
+	volumes := []v1.Volume{
+		{
+			Name: nodeLibModulesVolumeName,
+			VolumeSource: v1.VolumeSource{
+				HostPath: &v1.HostPathVolumeSource{
+					Path: nodeLibModulesPath,
+					Type: &hostPathDirectory,
+				},
+			},
+		},
+		{
+			Name: nodeUsrLibModulesVolumeName,
+			VolumeSource: v1.VolumeSource{
+				HostPath: &v1.HostPathVolumeSource{
+					Path: nodeUsrLibModulesPath,
+					Type: &hostPathDirectory,
+				},
+			},
+		},
+	}
+
+	volumeMounts := []v1.VolumeMount{
+		{
+			Name:      nodeLibModulesVolumeName,
+			ReadOnly:  true,
+			MountPath: nodeLibModulesPath,
+		},
+		{
+			Name:      nodeUsrLibModulesVolumeName,
+			ReadOnly:  true,
+			MountPath: nodeUsrLibModulesPath,
+		},
+	}
+
+	// The parameters are not part of the pod template: the pods read them from a ConfigMap and apply their changes
+	// without being recreated.
+	if containerSpec.Modprobe.RawArgs == nil {
+		command = MakeParametersSyncCommand(containerSpec.Modprobe)
+
+		volumes = append(
+			volumes,
+			v1.Volume{
+				Name: moduleParametersVolumeName,
+				VolumeSource: v1.VolumeSource{
+					ConfigMap: &v1.ConfigMapVolumeSource{
+						LocalObjectReference: v1.LocalObjectReference{Name: ModuleParametersConfigMapName(mod.Name)},
+						DefaultMode:          pointer.Int32(v1.ConfigMapVolumeSourceDefaultMode),
+						Optional:             pointer.Bool(true),
+					},
+				},
+			},
+			v1.Volume{
+				Name: nodeSysModuleVolumeName,
+				VolumeSource: v1.VolumeSource{
+					HostPath: &v1.HostPathVolumeSource{
+						Path: nodeSysModulePath,
+						Type: &hostPathDirectory,
+					},
+				},
+			},
+		)
+
+		volumeMounts = append(
+			volumeMounts,
+			v1.VolumeMount{
+				Name:      moduleParametersVolumeName,
+				ReadOnly:  true,
+				MountPath: moduleParametersPath,
+			},
+			v1.VolumeMount{
+				Name:      nodeSysModuleVolumeName,
+				MountPath: nodeSysModuleMountPath,
+			},
+		)
+
+		if containerSpec.LoadAtBoot {
+			persistVolumes, persistVolumeMounts := persistConfigVolumes()
+
+			volumes = append(volumes, persistVolumes...)
+			volumeMounts = append(volumeMounts, persistVolumeMounts...)
+
+			persistContainer := v1.Container{
+				Command:         MakePersistCommand(containerSpec.Modprobe),
+				Name:            persistConfigContainerName,
+				Image:           image,
+				ImagePullPolicy: containerSpec.ImagePullPolicy,
+				SecurityContext: rootSecurityContext(nil, mod.Spec.ModuleLoader.SecurityOptions),
+				Env: []v1.EnvVar{
+					{
+						Name:      podUIDEnvVar,
+						ValueFrom: &v1.EnvVarSource{FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.uid"}},
+					},
+				},
+				VolumeMounts: append(
+					[]v1.VolumeMount{
+						{
+							Name:      moduleParametersVolumeName,
+							ReadOnly:  true,
+							MountPath: moduleParametersPath,
+						},
+					},
+					persistVolumeMounts...,
+				),
+			}
+
+			initContainers = append([]v1.Container{persistContainer}, containerSpec.InitContainers...)
+		}
+	}
+
 	ds.Spec = appsv1.DaemonSetSpec{
 		Template: v1.PodTemplateSpec{
 			ObjectMeta: metav1.ObjectMeta{
-				Labels:     standardLabels,
-				Finalizers: []string{constants.NodeLabelerFinalizer},
+				Annotations: podOptions.PodAnnotations,
+				Labels:      templateLabels,
+				Finalizers:  []string{constants.NodeLabelerFinalizer},
 			},
 			Spec: v1.PodSpec{
+				Affinity: module.NodeAffinity(mod.Spec.ModuleLoader.Affinity, module.NodeSelectorRequirements(&mod.Spec), excludeNodesRequirements(excludedNodes)),
 				Containers: []v1.Container{
 					{
-						Command:         []string{"sleep", "infinity"},
-						Name:            "module-loader",
+						Command:         command,
+						Env:             containerSpec.Env,
+						Name:            moduleLoaderContainerName,
 						Image:           image,
 						ImagePullPolicy: mod.Spec.ModuleLoader.Container.ImagePullPolicy,
+						Resources:       mod.Spec.ModuleLoader.Container.Resources,
+						LivenessProbe:   livenessProbe,
+						ReadinessProbe:  readinessProbe,
 						Lifecycle: &v1.Lifecycle{
 							PostStart: &v1.LifecycleHandler{
 								Exec: &v1.ExecAction{
-									Command: MakeLoadCommand(mod.Spec.ModuleLoader.Container.Modprobe),
+									Command: MakePostStartCommand(mod.Spec.ModuleLoader.Container.Modprobe),
 								},
 							},
 							PreStop: &v1.LifecycleHandler{
 								Exec: &v1.ExecAction{
-									Command: MakeUnloadCommand(mod.Spec.ModuleLoader.Container.Modprobe),
+									Command: MakeUnloadCommand(containerSpec.Modprobe),
 								},
 							},
 						},
-						SecurityContext: &v1.SecurityContext{
-							AllowPrivilegeEscalation: pointer.Bool(false),
-							Capabilities: &v1.Capabilities{
-								Add: []v1.Capability{"SYS_MODULE"},
-							},
-							RunAsUser: pointer.Int64(0),
-							SELinuxOptions: &v1.SELinuxOptions{
-								Type: "spc_t",
-							},
-						},
-						VolumeMounts: []v1.VolumeMount{
-							{
-								Name:      nodeLibModulesVolumeName,
-								ReadOnly:  true,
-								MountPath: nodeLibModulesPath,
-							},
-							{
-								Name:      nodeUsrLibModulesVolumeName,
-								ReadOnly:  true,
-								MountPath: nodeUsrLibModulesPath,
-							},
-						},
+						SecurityContext: rootSecurityContext([]v1.Capability{"SYS_MODULE"}, mod.Spec.ModuleLoader.SecurityOptions),
+						VolumeMounts:    append(volumeMounts, containerSpec.VolumeMounts...),
 					},
 				},
+				InitContainers:     initContainers,
 				ImagePullSecrets:   GetPodPullSecrets(mod.Spec.ImageRepoSecret),
 				NodeSelector:       nodeSelector,
-				PriorityClassName:  "system-node-critical",
+				PriorityClassName:  priorityClassName(podOptions),
 				ServiceAccountName: mod.Spec.ModuleLoader.ServiceAccountName,
-				Volumes: []v1.Volume{
-					{
-						Name: nodeLibModulesVolumeName,
-						VolumeSource: v1.VolumeSource{
-							HostPath: &v1.HostPathVolumeSource{
-								Path: nodeLibModulesPath,
-								Type: &hostPathDirectory,
-							},
-						},
-					},
-					{
-						Name: nodeUsrLibModulesVolumeName,
-						VolumeSource: v1.VolumeSource{
-							HostPath: &v1.HostPathVolumeSource{
-								Path: nodeUsrLibModulesPath,
-								Type: &hostPathDirectory,
-							},
-						},
-					},
-				},
+				Tolerations:        podOptions.Tolerations,
+				Volumes:            append(volumes, containerSpec.Volumes...),
 			},
 		},
 		Selector: &metav1.LabelSelector{MatchLabels: standardLabels},
@@ -198,7 +346,24 @@ func (dc *daemonSetGenerator) SetDriverContainerAsDesired(ctx context.Context, d
 	return controllerutil.SetControllerReference(&mod, ds, dc.scheme)
 }
 
-func (dc *daemonSetGenerator) SetDevicePluginAsDesired(ctx context.Context, ds *appsv1.DaemonSet, mod *kmmv1beta1.Module) error {
+// SetModuleParametersAsDesired sets the data of the ConfigMap holding the kernel module parameters of mod, one per
+// line, which is mounted in the module loader pods.
+func (dc *daemonSetGenerator) SetModuleParametersAsDesired(cm *v1.ConfigMap, mod *kmmv1beta1.Module) error {
+	if cm == nil {
+		return errors.New("cm cannot be nil")
+	}
+
+	cm.Data = map[string]string{
+		moduleParametersKey: strings.Join(mod.Spec.ModuleLoader.Container.Modprobe.Parameters, "\n"),
+	}
+
+	return controllerutil.SetControllerReference(mod, cm, dc.scheme)
+}
+
+// SetDevicePluginAsDesired sets the spec of a device plugin DaemonSet running image.
+// Its pods run on the nodes where the kernel module of mod is loaded.
+// If kernelVersion is not empty, they only run on nodes with that kernel.
+func (dc *daemonSetGenerator) SetDevicePluginAsDesired(ctx context.Context, ds *appsv1.DaemonSet, mod *kmmv1beta1.Module, image, kernelVersion string) error {
 	if ds == nil {
 		return errors.New("ds cannot be nil")
 	}
@@ -207,6 +372,10 @@ func (dc *daemonSetGenerator) SetDevicePluginAsDesired(ctx context.Context, ds *
 		return errors.New("device plugin in module should not be nil")
 	}
 
+	if image == "" {
+		return errors.New("image cannot be empty")
+	}
+
 	containerVolumeMounts := []v1.VolumeMount{
 		{
 			Name:      kubeletDevicePluginsVolumeName,
@@ -228,38 +397,50 @@ func (dc *daemonSetGenerator) SetDevicePluginAsDesired(ctx context.Context, ds *
 
 	standardLabels := map[string]string{
 		constants.ModuleNameLabel: mod.Name,
-		constants.DaemonSetRole:   "device-plugin",
+		constants.DaemonSetRole:   devicePluginRole,
+	}
+
+	nodeSelector := map[string]string{GetDriverContainerNodeLabel(mod.Name): ""}
+
+	if kernelVersion != devicePluginKernelVersion {
+		standardLabels[dc.kernelLabel] = kernelVersion
+		nodeSelector[dc.kernelLabel] = kernelVersion
 	}
 
 	ds.SetLabels(
 		OverrideLabels(ds.GetLabels(), standardLabels),
 	)
 
+	podOptions := mod.Spec.DevicePlugin.PodOptions
+
 	ds.Spec = appsv1.DaemonSetSpec{
 		Selector: &metav1.LabelSelector{MatchLabels: standardLabels},
 		Template: v1.PodTemplateSpec{
 			ObjectMeta: metav1.ObjectMeta{
-				Labels:     standardLabels,
-				Finalizers: []string{constants.NodeLabelerFinalizer},
+				Annotations: podOptions.PodAnnotations,
+				Labels:      podLabels(podOptions, standardLabels),
+				Finalizers:  []string{constants.NodeLabelerFinalizer},
 			},
 			Spec: v1.PodSpec{
+				Affinity: podOptions.Affinity.DeepCopy(),
 				Containers: []v1.Container{
 					{
 						Args:            mod.Spec.DevicePlugin.Container.Args,
 						Command:         mod.Spec.DevicePlugin.Container.Command,
 						Env:             mod.Spec.DevicePlugin.Container.Env,
 						Name:            "device-plugin",
-						Image:           mod.Spec.DevicePlugin.Container.Image,
+						Image:           image,
 						ImagePullPolicy: mod.Spec.DevicePlugin.Container.ImagePullPolicy,
 						Resources:       mod.Spec.DevicePlugin.Container.Resources,
-						SecurityContext: &v1.SecurityContext{Privileged: pointer.Bool(true)},
+						SecurityContext: devicePluginSecurityContext(mod.Spec.DevicePlugin.SecurityOptions),
 						VolumeMounts:    append(mod.Spec.DevicePlugin.Container.VolumeMounts, containerVolumeMounts...),
 					},
 				},
-				PriorityClassName:  "system-node-critical",
+				PriorityClassName:  priorityClassName(podOptions),
 				ImagePullSecrets:   GetPodPullSecrets(mod.Spec.ImageRepoSecret),
-				NodeSelector:       map[string]string{getDriverContainerNodeLabel(mod.Name): ""},
+				NodeSelector:       nodeSelector,
 				ServiceAccountName: mod.Spec.DevicePlugin.ServiceAccountName,
+				Tolerations:        podOptions.Tolerations,
 				Volumes:            append([]v1.Volume{devicePluginVolume}, mod.Spec.DevicePlugin.Volumes...),
 			},
 		},
@@ -269,11 +450,21 @@ func (dc *daemonSetGenerator) SetDevicePluginAsDesired(ctx context.Context, ds *
 }
 
 func (dc *daemonSetGenerator) GetNodeLabelFromPod(pod *v1.Pod, moduleName string) string {
-	kernelVersion := pod.Labels[dc.kernelLabel]
-	if kernelVersion == devicePluginKernelVersion {
-		return getDevicePluginNodeLabel(moduleName)
+	role, ok := pod.Labels[constants.DaemonSetRole]
+	if !ok {
+		// pods created before roles were added only have a kernel label if they load the kernel module
+		role = moduleLoaderRole
+
+		if pod.Labels[dc.kernelLabel] == devicePluginKernelVersion {
+			role = devicePluginRole
+		}
 	}
-	return getDriverContainerNodeLabel(moduleName)
+
+	if role == devicePluginRole {
+		return GetDevicePluginNodeLabel(moduleName)
+	}
+
+	return GetDriverContainerNodeLabel(moduleName)
 }
 
 func (dc *daemonSetGenerator) moduleDaemonSets(ctx context.Context, name, namespace string) ([]appsv1.DaemonSet, error) {
@@ -292,6 +483,170 @@ func (dc *daemonSetGenerator) isDevicePluginDaemonSet(ds *appsv1.DaemonSet) bool
 	return ds.Labels[dc.kernelLabel] == ""
 }
 
+func (dc *daemonSetGenerator) isPerKernelDevicePluginDaemonSet(ds *appsv1.DaemonSet) bool {
+	return ds.Labels[constants.DaemonSetRole] == devicePluginRole && !dc.isDevicePluginDaemonSet(ds)
+}
+
+// persistConfigVolumes returns the volumes and volume mounts of the host directories in which the modprobe
+// configuration of the kernel module is persisted.
+func persistConfigVolumes() ([]v1.Volume, []v1.VolumeMount) {
+	hostPathDirectoryOrCreate := v1.HostPathDirectoryOrCreate
+
+	dirs := []struct {
+		name      string
+		path      string
+		mountPath string
+	}{
+		{name: nodeModulesLoadDVolumeName, path: nodeModulesLoadDPath, mountPath: nodeModulesLoadDMountPath},
+		{name: nodeModprobeDVolumeName, path: nodeModprobeDPath, mountPath: nodeModprobeDMountPath},
+		{name: nodeVarLibKMMVolumeName, path: nodeVarLibKMMPath, mountPath: nodeVarLibKMMMountPath},
+	}
+
+	volumes := make([]v1.Volume, 0, len(dirs))
+	volumeMounts := make([]v1.VolumeMount, 0, len(dirs))
+
+	for _, d := range dirs {
+		volumes = append(volumes, v1.Volume{
+			Name: d.name,
+			VolumeSource: v1.VolumeSource{
+				HostPath: &v1.HostPathVolumeSource{
+					Path: d.path,
+					Type: &hostPathDirectoryOrCreate,
+				},
+			},
+		})
+
+		volumeMounts = append(volumeMounts, v1.VolumeMount{Name: d.name, MountPath: d.mountPath})
+	}
+
+	return volumes, volumeMounts
+}
+
+// ValidateModuleLoaderContainer returns an error if the extra volumes, volume mounts or init containers in spec would
+// replace or shadow those managed by the operator in the module loader pod.
+func ValidateModuleLoaderContainer(spec kmmv1beta1.ModuleLoaderContainerSpec) error {
+	for _, vol := range spec.Volumes {
+		switch vol.Name {
+		case nodeLibModulesVolumeName, nodeUsrLibModulesVolumeName, nodeSysModuleVolumeName, moduleParametersVolumeName,
+			nodeModulesLoadDVolumeName, nodeModprobeDVolumeName, nodeVarLibKMMVolumeName:
+			return fmt.Errorf("volume name %q is reserved", vol.Name)
+		}
+	}
+
+	for _, vm := range spec.VolumeMounts {
+		mountPath := path.Clean(vm.MountPath)
+
+		reservedPaths := []string{
+			nodeLibModulesPath,
+			nodeUsrLibModulesPath,
+			nodeSysModuleMountPath,
+			moduleParametersPath,
+			nodeModulesLoadDMountPath,
+			nodeModprobeDMountPath,
+			nodeVarLibKMMMountPath,
+		}
+
+		for _, p := range reservedPaths {
+			if mountPath == p || strings.HasPrefix(mountPath, p+"/") {
+				return fmt.Errorf("volume mount %q: mount path %s would shadow %s", vm.Name, vm.MountPath, p)
+			}
+		}
+	}
+
+	for _, c := range spec.InitContainers {
+		if c.Name == moduleLoaderContainerName || c.Name == persistConfigContainerName {
+			return fmt.Errorf("init container name %q is reserved", c.Name)
+		}
+	}
+
+	if spec.LoadAtBoot && spec.Modprobe.RawArgs != nil {
+		return errors.New("loadAtBoot cannot be used with modprobe.rawArgs")
+	}
+
+	return nil
+}
+
+// excludeNodesRequirements returns the node field requirements excluding nodes, or nil if nodes is empty.
+func excludeNodesRequirements(nodes sets.String) []v1.NodeSelectorRequirement {
+	if nodes.Len() == 0 {
+		return nil
+	}
+
+	return []v1.NodeSelectorRequirement{
+		{
+			Key:      "metadata.name",
+			Operator: v1.NodeSelectorOpNotIn,
+			Values:   nodes.List(),
+		},
+	}
+}
+
+// rootSecurityContext returns an unprivileged security context running as root with the capabilities in defaultCaps
+// and opts.
+func rootSecurityContext(defaultCaps []v1.Capability, opts *kmmv1beta1.SecurityOptions) *v1.SecurityContext {
+	caps := append(make([]v1.Capability, 0, len(defaultCaps)), defaultCaps...)
+	seLinuxType := defaultSELinuxType
+
+	if opts != nil {
+		for _, c := range opts.Capabilities {
+			if !capabilityIn(v1.Capability(c), caps) {
+				caps = append(caps, v1.Capability(c))
+			}
+		}
+
+		if opts.SELinuxType != "" {
+			seLinuxType = string(opts.SELinuxType)
+		}
+	}
+
+	sc := &v1.SecurityContext{
+		AllowPrivilegeEscalation: pointer.Bool(false),
+		RunAsUser:                pointer.Int64(0),
+		SELinuxOptions: &v1.SELinuxOptions{
+			Type: seLinuxType,
+		},
+	}
+
+	if len(caps) > 0 {
+		sc.Capabilities = &v1.Capabilities{Add: caps}
+	}
+
+	return sc
+}
+
+// devicePluginSecurityContext returns a privileged security context if opts is nil, and rootSecurityContext otherwise.
+func devicePluginSecurityContext(opts *kmmv1beta1.SecurityOptions) *v1.SecurityContext {
+	if opts == nil {
+		return &v1.SecurityContext{Privileged: pointer.Bool(true)}
+	}
+
+	return rootSecurityContext(nil, opts)
+}
+
+func capabilityIn(c v1.Capability, caps []v1.Capability) bool {
+	for _, cc := range caps {
+		if cc == c {
+			return true
+		}
+	}
+
+	return false
+}
+
+// podLabels returns the labels requested in opts, overridden by the operator's own labels so that the DaemonSet's
+// selector keeps matching its pods.
+func podLabels(opts kmmv1beta1.PodOptions, standardLabels map[string]string) map[string]string {
+	return OverrideLabels(CopyMapStringString(opts.PodLabels), standardLabels)
+}
+
+func priorityClassName(opts kmmv1beta1.PodOptions) string {
+	if opts.PriorityClassName == "" {
+		return defaultPriorityClassName
+	}
+
+	return opts.PriorityClassName
+}
+
 // CopyMapStringString returns a deep copy of m.
 func CopyMapStringString(m map[string]string) map[string]string {
 	n := make(map[string]string, len(m))
@@ -303,14 +658,69 @@ func CopyMapStringString(m map[string]string) map[string]string {
 	return n
 }
 
-func getDriverContainerNodeLabel(moduleName string) string {
+// GetDriverContainerNodeLabel returns the label set on nodes where the kernel module of moduleName is loaded.
+func GetDriverContainerNodeLabel(moduleName string) string {
 	return fmt.Sprintf("kmm.node.kubernetes.io/%s.ready", moduleName)
 }
 
-func getDevicePluginNodeLabel(moduleName string) string {
+// GetModuleVersionNodeLabel returns the label holding the version of moduleName on nodes where it is loaded.
+func GetModuleVersionNodeLabel(moduleName string) string {
+	return fmt.Sprintf("kmm.node.kubernetes.io/%s.version", moduleName)
+}
+
+// GetImageDigestNodeLabel returns the label holding the short digest of the module loader image of moduleName on nodes
+// where it is loaded.
+func GetImageDigestNodeLabel(moduleName string) string {
+	return fmt.Sprintf("kmm.node.kubernetes.io/%s.digest", moduleName)
+}
+
+// ShortImageDigest returns the first 12 hexadecimal characters of the digest of the image run by the module loader
+// container of pod, or an empty string if it is not known yet.
+func ShortImageDigest(pod *v1.Pod) string {
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.Name != moduleLoaderContainerName {
+			continue
+		}
+
+		// The image ID is in the form [docker-pullable://][repository@]sha256:<hex>.
+		i := strings.LastIndex(cs.ImageID, "sha256:")
+		if i == -1 {
+			return ""
+		}
+
+		digest := cs.ImageID[i+len("sha256:"):]
+		if len(digest) > shortDigestLength {
+			digest = digest[:shortDigestLength]
+		}
+
+		return digest
+	}
+
+	return ""
+}
+
+// GetDevicePluginNodeLabel returns the label set on nodes where the device plugin of moduleName is running.
+func GetDevicePluginNodeLabel(moduleName string) string {
 	return fmt.Sprintf("kmm.node.kubernetes.io/%s.device-plugin-ready", moduleName)
 }
 
+// ModuleNameFromNodeLabel returns the Module name in label, and false if label was not built by
+// GetDriverContainerNodeLabel, GetModuleVersionNodeLabel, GetImageDigestNodeLabel or GetDevicePluginNodeLabel.
+func ModuleNameFromNodeLabel(label string) (string, bool) {
+	name := strings.TrimPrefix(label, "kmm.node.kubernetes.io/")
+	if name == label {
+		return "", false
+	}
+
+	for _, suffix := range []string{".ready", ".version", ".digest", ".device-plugin-ready"} {
+		if moduleName := strings.TrimSuffix(name, suffix); moduleName != name && moduleName != "" {
+			return moduleName, true
+		}
+	}
+
+	return "", false
+}
+
 func IsDevicePluginKernelVersion(kernelVersion string) bool {
 	return kernelVersion == devicePluginKernelVersion
 }
@@ -339,10 +749,276 @@ func OverrideLabels(labels, overrides map[string]string) map[string]string {
 	return labels
 }
 
+// ModuleParametersConfigMapName returns the name of the ConfigMap holding the kernel module parameters of the Module
+// moduleName.
+func ModuleParametersConfigMapName(moduleName string) string {
+	return moduleName + "-module-parameters"
+}
+
+// MakeHealthCheckCommand returns a command that succeeds if moduleName is loaded and command, if not empty, succeeds.
+func MakeHealthCheckCommand(moduleName string, command []string) []string {
+	sysfsPath := path.Join(nodeSysModulePath, sysfsName(moduleName))
+
+	if len(command) == 0 {
+		return []string{"test", "-d", sysfsPath}
+	}
+
+	return append([]string{"sh", "-c", `test -d "$0" && exec "$@"`, sysfsPath}, command...)
+}
+
+// makeHealthProbes returns the readiness and liveness probes of the module loader container, or nil if its health
+// check is not configured or disabled.
+// With RawArgs, the name of the kernel module in sysfs is not known, so that only the health check command is run; no
+// probes are added if there is none.
+// Both probes use the same failure threshold, so that a single transient failure neither removes the node label nor
+// restarts the container.
+func makeHealthProbes(spec kmmv1beta1.ModuleLoaderContainerSpec) (*v1.Probe, *v1.Probe) {
+	hc := spec.HealthCheck
+	if hc == nil || hc.Disabled {
+		return nil, nil
+	}
+
+	command := hc.Command
+
+	if spec.Modprobe.RawArgs == nil {
+		command = MakeHealthCheckCommand(spec.Modprobe.ModuleName, hc.Command)
+	} else if len(command) == 0 {
+		return nil, nil
+	}
+
+	periodSeconds := hc.PeriodSeconds
+	if periodSeconds == 0 {
+		periodSeconds = defaultHealthCheckPeriodSeconds
+	}
+
+	failureThreshold := hc.FailureThreshold
+	if failureThreshold == 0 {
+		failureThreshold = defaultHealthCheckFailureThreshold
+	}
+
+	handler := v1.ProbeHandler{
+		Exec: &v1.ExecAction{
+			Command: command,
+		},
+	}
+
+	readiness := &v1.Probe{
+		ProbeHandler:     handler,
+		TimeoutSeconds:   1,
+		PeriodSeconds:    periodSeconds,
+		SuccessThreshold: 1,
+		FailureThreshold: failureThreshold,
+	}
+
+	liveness := &v1.Probe{
+		ProbeHandler:     *handler.DeepCopy(),
+		TimeoutSeconds:   1,
+		PeriodSeconds:    periodSeconds,
+		SuccessThreshold: 1,
+		FailureThreshold: failureThreshold,
+	}
+
+	return readiness, liveness
+}
+
+// sysfsName returns name as it appears in sysfs, which uses underscores in module and parameter names while modprobe
+// accepts dashes as well.
+func sysfsName(name string) string {
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+// syncParametersFunction defines sync_parameters, which writes the parameters of $desired that are not in $applied to
+// the kernel module parameters in the $parameters directory.
+// Both lists have one name=value parameter per line, so that values may contain spaces.
+// It fails if a parameter was removed, if a parameter without value was added, or if a parameter cannot be written;
+// the kernel module must then be reloaded.
+const syncParametersFunction = `has_parameter() {
+	n="$1" awk -F= '$1 == ENVIRON["n"] { found = 1 } END { exit !found }'
+}
+sync_parameters() {
+	while IFS= read -r p; do
+		case "$p" in
+		'') ;;
+		*=*) printf '%s\n' "$desired" | has_parameter "${p%%=*}" || return 1 ;;
+		*) printf '%s\n' "$desired" | grep -qxF -e "$p" || return 1 ;;
+		esac
+	done <<EOF
+$applied
+EOF
+	while IFS= read -r p; do
+		case "$p" in
+		''|*=*) ;;
+		*) printf '%s\n' "$applied" | grep -qxF -e "$p" || return 1 ;;
+		esac
+	done <<EOF
+$desired
+EOF
+	while IFS= read -r p; do
+		[ -z "$p" ] && continue
+		printf '%s\n' "$applied" | grep -qxF -e "$p" && continue
+		printf '%s' "${p#*=}" > "$parameters/$(printf '%s' "${p%%=*}" | tr - _)" || return 1
+		echo "Set parameter $p"
+	done <<EOF
+$desired
+EOF
+}`
+
+// parametersSyncScript writes the changes made to the parameters file to the kernel module parameters in sysfs, and
+// to the persisted parameters if the modprobe configuration is persisted on the host.
+// If a parameter cannot be changed at runtime, it unloads the kernel module with the command passed as arguments and
+// exits, so that the container is restarted and loads the kernel module with the new parameters.
+// $0 is the name of the kernel module in sysfs.
+const parametersSyncScript = syncParametersFunction + `
+file=` + moduleParametersPath + "/" + moduleParametersKey + `
+parameters=` + nodeSysModuleMountPath + `/$0/parameters
+persisted=` + nodeVarLibKMMMountPath + `/$0
+applied=$(cat "$file" 2>/dev/null)
+while sleep 10; do
+	desired=$(cat "$file" 2>/dev/null)
+	[ "$desired" = "$applied" ] && continue
+	[ -d "$persisted" ] && printf '%s\n' "$desired" > "$persisted/parameters"
+	if ! sync_parameters; then
+		echo "Some parameters cannot be changed at runtime; reloading the kernel module"
+		"$@"
+		exit 0
+	fi
+	applied=$desired
+done`
+
+// MakeParametersSyncCommand returns the command of the module loader container, which applies the changes to the
+// kernel module parameters while the kernel module is loaded.
+func MakeParametersSyncCommand(spec kmmv1beta1.ModprobeSpec) []string {
+	return append([]string{"sh", "-c", parametersSyncScript, sysfsName(spec.ModuleName)}, MakeUnloadCommand(spec)...)
+}
+
+// persistScript copies the kernel module files for the running kernel and its parameters to /var/lib/kmm/$0 on the
+// host, and configures the host to load the kernel module from there at boot.
+// The UID of the pod is recorded with them, so that they are only removed by the cleanup of the last pod that
+// persisted them.
+// $0 is the name of the kernel module in sysfs and $1 the root directory of the kernel module files, if any.
+const persistScript = `set -e
+dir=` + nodeVarLibKMMMountPath + `/$0
+rm -rf "$dir"
+mkdir -p "$dir"
+printf '%s\n' "$` + podUIDEnvVar + `" > "$dir/pod-uid"
+cat ` + moduleParametersPath + "/" + moduleParametersKey + ` > "$dir/parameters" 2>/dev/null || true
+opts=
+if [ -n "$1" ]; then
+	mkdir -p "$dir/lib/modules"
+	cp -R "$1/lib/modules/$(uname -r)" "$dir/lib/modules/"
+	opts="-d ` + nodeVarLibKMMPath + `/$0"
+fi
+echo "install $0 /sbin/modprobe --ignore-install $opts $0 \$(cat ` + nodeVarLibKMMPath + `/$0/parameters) \$CMDLINE_OPTS" > ` + nodeModprobeDMountPath + `/kmm-$0.conf
+echo "$0" > ` + nodeModulesLoadDMountPath + `/kmm-$0.conf
+echo "Persisted the modprobe configuration of $0"`
+
+// cleanupScript removes the modprobe configuration persisted by persistScript, unless it was persisted again by
+// another pod since.
+// $0 is the name of the kernel module in sysfs and $1 the UID of the pod whose configuration is removed.
+const cleanupScript = `set -e
+dir=` + nodeVarLibKMMMountPath + `/$0
+if [ "$(cat "$dir/pod-uid" 2>/dev/null)" != "$1" ]; then
+	echo "The modprobe configuration of $0 was not persisted by pod $1; leaving it"
+	exit 0
+fi
+rm -f ` + nodeModulesLoadDMountPath + `/kmm-$0.conf ` + nodeModprobeDMountPath + `/kmm-$0.conf
+rm -rf "$dir"
+echo "Removed the persisted modprobe configuration of $0"`
+
+// MakePersistCommand returns the command persisting the modprobe configuration of the kernel module on the host, so
+// that it is loaded at boot.
+func MakePersistCommand(spec kmmv1beta1.ModprobeSpec) []string {
+	return []string{"sh", "-c", persistScript, sysfsName(spec.ModuleName), spec.DirName}
+}
+
+// MakeBootConfigCleanupJob returns a Job removing the modprobe configuration persisted on its node by pod, or nil if
+// pod does not load its kernel module at boot.
+// It must be run once pod is deleted, and not in a PreStop hook: those also run when the container is restarted and
+// when the node shuts down, which would remove the configuration before the reboot it is persisted for.
+func MakeBootConfigCleanupJob(pod *v1.Pod) *batchv1.Job {
+	var persistContainer *v1.Container
+
+	for i, c := range pod.Spec.InitContainers {
+		if c.Name == persistConfigContainerName {
+			persistContainer = &pod.Spec.InitContainers[i]
+			break
+		}
+	}
+
+	// The sysfs module name is the first argument of the command built by MakePersistCommand.
+	if persistContainer == nil || len(persistContainer.Command) < 4 {
+		return nil
+	}
+
+	volumes, volumeMounts := persistConfigVolumes()
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      bootConfigCleanupJobPrefix + string(pod.UID),
+			Namespace: pod.Namespace,
+		},
+		Spec: batchv1.JobSpec{
+			ActiveDeadlineSeconds:   pointer.Int64(600),
+			BackoffLimit:            pointer.Int32(3),
+			TTLSecondsAfterFinished: pointer.Int32(600),
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{
+						{
+							Command:         []string{"sh", "-c", cleanupScript, persistContainer.Command[3], string(pod.UID)},
+							Name:            cleanupConfigContainerName,
+							Image:           persistContainer.Image,
+							ImagePullPolicy: persistContainer.ImagePullPolicy,
+							SecurityContext: persistContainer.SecurityContext,
+							VolumeMounts:    volumeMounts,
+						},
+					},
+					ImagePullSecrets:   pod.Spec.ImagePullSecrets,
+					NodeName:           pod.Spec.NodeName,
+					PriorityClassName:  pod.Spec.PriorityClassName,
+					RestartPolicy:      v1.RestartPolicyNever,
+					ServiceAccountName: pod.Spec.ServiceAccountName,
+					Tolerations:        pod.Spec.Tolerations,
+					Volumes:            volumes,
+				},
+			},
+		},
+	}
+}
+
+// ModprobeFailedMessage is the termination message of module loader containers restarted because the kernel module
+// could not be loaded.
+const ModprobeFailedMessage = "modprobe failed"
+
+// MakePostStartCommand returns the PostStart hook of the module loader container.
+// It loads the kernel module, and writes ModprobeFailedMessage to the termination log of the container if that fails,
+// so that those restarts can be told apart from the ones caused by health checks or parameter changes.
+func MakePostStartCommand(spec kmmv1beta1.ModprobeSpec) []string {
+	script := `"$0" "$@" || { echo "` + ModprobeFailedMessage + `" > ` + v1.TerminationMessagePathDefault + `; exit 1; }`
+
+	return append([]string{"sh", "-c", script}, MakeLoadCommand(spec)...)
+}
+
+// loadScript runs the command passed as arguments with one more argument per line of the parameters file, so that
+// parameters are neither split on spaces nor expanded by the shell.
+const loadScript = `file=` + moduleParametersPath + "/" + moduleParametersKey + `
+if [ -f "$file" ]; then
+	while IFS= read -r p || [ -n "$p" ]; do
+		[ -n "$p" ] && set -- "$@" "$p"
+	done < "$file"
+fi
+exec "$0" "$@"`
+
+// MakeLoadCommand returns the command loading the kernel module.
+// If RawArgs.Load is set, it is used as is.
+// If RawArgs is set without Load, the ConfigMap is not mounted and the parameters are passed on the command line.
+// Otherwise, the parameters are read from the module parameters ConfigMap mounted in the container.
 func MakeLoadCommand(spec kmmv1beta1.ModprobeSpec) []string {
 	loadCommand := []string{"modprobe"}
 
-	if ra := spec.RawArgs; ra != nil && len(ra.Load) > 0 {
+	ra := spec.RawArgs
+
+	if ra != nil && len(ra.Load) > 0 {
 		return append(loadCommand, ra.Load...)
 	}
 
@@ -357,7 +1033,12 @@ func MakeLoadCommand(spec kmmv1beta1.ModprobeSpec) []string {
 	}
 
 	loadCommand = append(loadCommand, spec.ModuleName)
-	return append(loadCommand, spec.Parameters...)
+
+	if ra != nil {
+		return append(loadCommand, spec.Parameters...)
+	}
+
+	return append([]string{"sh", "-c", loadScript}, loadCommand...)
 }
 
 func MakeUnloadCommand(spec kmmv1beta1.ModprobeSpec) []string {
@@ -3,6 +3,9 @@ package daemonset
 import (
 	"context"
 	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
 
 	"github.com/golang/mock/gomock"
 	"github.com/google/go-cmp/cmp"
@@ -42,7 +45,7 @@ var _ = Describe("SetDriverContainerAsDesired", func() {
 
 	It("should return an error if the DaemonSet is nil", func() {
 		Expect(
-			dg.SetDriverContainerAsDesired(context.Background(), nil, "", kmmv1beta1.Module{}, ""),
+			dg.SetDriverContainerAsDesired(context.Background(), nil, "", kmmv1beta1.Module{}, "", nil),
 		).To(
 			HaveOccurred(),
 		)
@@ -50,7 +53,7 @@ var _ = Describe("SetDriverContainerAsDesired", func() {
 
 	It("should return an error if the image is empty", func() {
 		Expect(
-			dg.SetDriverContainerAsDesired(context.Background(), &appsv1.DaemonSet{}, "", kmmv1beta1.Module{}, ""),
+			dg.SetDriverContainerAsDesired(context.Background(), &appsv1.DaemonSet{}, "", kmmv1beta1.Module{}, "", nil),
 		).To(
 			HaveOccurred(),
 		)
@@ -58,7 +61,7 @@ var _ = Describe("SetDriverContainerAsDesired", func() {
 
 	It("should return an error if the kernel version is empty", func() {
 		Expect(
-			dg.SetDriverContainerAsDesired(context.Background(), &appsv1.DaemonSet{}, "", kmmv1beta1.Module{}, ""),
+			dg.SetDriverContainerAsDesired(context.Background(), &appsv1.DaemonSet{}, "", kmmv1beta1.Module{}, "", nil),
 		).To(
 			HaveOccurred(),
 		)
@@ -73,12 +76,471 @@ var _ = Describe("SetDriverContainerAsDesired", func() {
 
 		ds := appsv1.DaemonSet{}
 
-		err := dg.SetDriverContainerAsDesired(context.Background(), &ds, "test-image", mod, kernelVersion)
+		err := dg.SetDriverContainerAsDesired(context.Background(), &ds, "test-image", mod, kernelVersion, nil)
 		Expect(err).NotTo(HaveOccurred())
 		Expect(ds.Spec.Template.Spec.Containers).To(HaveLen(1))
+		Expect(ds.Spec.Template.Spec.Volumes).To(HaveLen(4))
+	})
+
+	It("should require the selector expressions of the Module", func() {
+		mod := kmmv1beta1.Module{
+			Spec: kmmv1beta1.ModuleSpec{
+				Selector: map[string]string{"node-role.kubernetes.io/worker": ""},
+				SelectorExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "gpu", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"none"}},
+				},
+			},
+		}
+
+		ds := appsv1.DaemonSet{}
+
+		err := dg.SetDriverContainerAsDesired(context.Background(), &ds, "test-image", mod, kernelVersion, sets.NewString("node1"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ds.Spec.Template.Spec.NodeSelector).To(Equal(map[string]string{"node-role.kubernetes.io/worker": "", kernelLabel: kernelVersion}))
+		Expect(
+			ds.Spec.Template.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms,
+		).To(
+			Equal([]v1.NodeSelectorTerm{
+				{
+					MatchExpressions: []v1.NodeSelectorRequirement{
+						{Key: "gpu", Operator: v1.NodeSelectorOpNotIn, Values: []string{"none"}},
+					},
+					MatchFields: []v1.NodeSelectorRequirement{
+						{Key: "metadata.name", Operator: v1.NodeSelectorOpNotIn, Values: []string{"node1"}},
+					},
+				},
+			}),
+		)
+	})
+
+	It("should not schedule pods on excluded nodes", func() {
+		ds := appsv1.DaemonSet{}
+
+		err := dg.SetDriverContainerAsDesired(context.Background(), &ds, "test-image", kmmv1beta1.Module{}, kernelVersion, sets.NewString("node2", "node1"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(
+			ds.Spec.Template.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms,
+		).To(
+			Equal([]v1.NodeSelectorTerm{
+				{
+					MatchFields: []v1.NodeSelectorRequirement{
+						{Key: "metadata.name", Operator: v1.NodeSelectorOpNotIn, Values: []string{"node1", "node2"}},
+					},
+				},
+			}),
+		)
+	})
+
+	It("should return an error if the module loader container is invalid", func() {
+		mod := kmmv1beta1.Module{
+			Spec: kmmv1beta1.ModuleSpec{
+				ModuleLoader: kmmv1beta1.ModuleLoaderSpec{
+					Container: kmmv1beta1.ModuleLoaderContainerSpec{
+						Volumes: []v1.Volume{{Name: "node-lib-modules"}},
+					},
+				},
+			},
+		}
+
+		Expect(
+			dg.SetDriverContainerAsDesired(context.Background(), &appsv1.DaemonSet{}, "test-image", mod, kernelVersion, nil),
+		).To(
+			HaveOccurred(),
+		)
+	})
+
+	It("should not sync the parameters if raw arguments are used", func() {
+		mod := kmmv1beta1.Module{
+			Spec: kmmv1beta1.ModuleSpec{
+				ModuleLoader: kmmv1beta1.ModuleLoaderSpec{
+					Container: kmmv1beta1.ModuleLoaderContainerSpec{
+						Modprobe: kmmv1beta1.ModprobeSpec{
+							ModuleName: "some-kmod",
+							RawArgs:    &kmmv1beta1.ModprobeArgs{Load: []string{"some-kmod"}, Unload: []string{"-r", "some-kmod"}},
+						},
+					},
+				},
+			},
+		}
+
+		ds := appsv1.DaemonSet{}
+
+		err := dg.SetDriverContainerAsDesired(context.Background(), &ds, "test-image", mod, kernelVersion, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ds.Spec.Template.Spec.Containers[0].Command).To(Equal([]string{"sleep", "infinity"}))
+		Expect(ds.Spec.Template.Spec.Containers[0].VolumeMounts).To(HaveLen(2))
 		Expect(ds.Spec.Template.Spec.Volumes).To(HaveLen(2))
 	})
 
+	It("should persist the modprobe configuration on the host if the kernel module is loaded at boot", func() {
+		initContainer := v1.Container{Name: "prepare", Image: "some-image"}
+
+		mod := kmmv1beta1.Module{
+			Spec: kmmv1beta1.ModuleSpec{
+				ModuleLoader: kmmv1beta1.ModuleLoaderSpec{
+					Container: kmmv1beta1.ModuleLoaderContainerSpec{
+						InitContainers: []v1.Container{initContainer},
+						LoadAtBoot:     true,
+						Modprobe:       kmmv1beta1.ModprobeSpec{ModuleName: "some-kmod", DirName: "/opt"},
+					},
+				},
+			},
+		}
+
+		ds := appsv1.DaemonSet{}
+
+		err := dg.SetDriverContainerAsDesired(context.Background(), &ds, "test-image", mod, kernelVersion, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		podSpec := ds.Spec.Template.Spec
+		Expect(podSpec.InitContainers).To(HaveLen(2))
+		Expect(podSpec.InitContainers[0].Name).To(Equal("persist-modprobe-config"))
+		Expect(podSpec.InitContainers[0].Image).To(Equal("test-image"))
+		Expect(podSpec.InitContainers[0].Command).To(Equal(MakePersistCommand(mod.Spec.ModuleLoader.Container.Modprobe)))
+		Expect(podSpec.InitContainers[0].Env).To(Equal([]v1.EnvVar{
+			{
+				Name:      "POD_UID",
+				ValueFrom: &v1.EnvVarSource{FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.uid"}},
+			},
+		}))
+		Expect(podSpec.InitContainers[0].VolumeMounts).To(HaveLen(4))
+		Expect(podSpec.InitContainers[1]).To(Equal(initContainer))
+		Expect(podSpec.Containers[0].Lifecycle.PreStop.Exec.Command).To(Equal(MakeUnloadCommand(mod.Spec.ModuleLoader.Container.Modprobe)))
+		Expect(podSpec.Containers[0].VolumeMounts).To(HaveLen(7))
+
+		directoryOrCreate := v1.HostPathDirectoryOrCreate
+
+		Expect(podSpec.Volumes).To(HaveLen(7))
+		Expect(podSpec.Volumes[4:]).To(Equal([]v1.Volume{
+			{
+				Name: "node-modules-load-d",
+				VolumeSource: v1.VolumeSource{
+					HostPath: &v1.HostPathVolumeSource{Path: "/etc/modules-load.d", Type: &directoryOrCreate},
+				},
+			},
+			{
+				Name: "node-modprobe-d",
+				VolumeSource: v1.VolumeSource{
+					HostPath: &v1.HostPathVolumeSource{Path: "/etc/modprobe.d", Type: &directoryOrCreate},
+				},
+			},
+			{
+				Name: "node-var-lib-kmm",
+				VolumeSource: v1.VolumeSource{
+					HostPath: &v1.HostPathVolumeSource{Path: "/var/lib/kmm", Type: &directoryOrCreate},
+				},
+			},
+		}))
+	})
+
+	It("should add the extra volumes, volume mounts, env and init containers", func() {
+		vol := v1.Volume{Name: "dev"}
+		vm := v1.VolumeMount{Name: "dev", MountPath: "/dev"}
+		env := []v1.EnvVar{{Name: "KEY", Value: "value"}}
+		initContainers := []v1.Container{{Name: "prepare", Image: "some-image"}}
+
+		mod := kmmv1beta1.Module{
+			Spec: kmmv1beta1.ModuleSpec{
+				ModuleLoader: kmmv1beta1.ModuleLoaderSpec{
+					Container: kmmv1beta1.ModuleLoaderContainerSpec{
+						Env:            env,
+						InitContainers: initContainers,
+						VolumeMounts:   []v1.VolumeMount{vm},
+						Volumes:        []v1.Volume{vol},
+					},
+				},
+			},
+		}
+
+		ds := appsv1.DaemonSet{}
+
+		err := dg.SetDriverContainerAsDesired(context.Background(), &ds, "test-image", mod, kernelVersion, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		podSpec := ds.Spec.Template.Spec
+		Expect(podSpec.Volumes).To(HaveLen(5))
+		Expect(podSpec.Volumes[4]).To(Equal(vol))
+		Expect(podSpec.Containers[0].VolumeMounts).To(HaveLen(5))
+		Expect(podSpec.Containers[0].VolumeMounts[4]).To(Equal(vm))
+		Expect(podSpec.Containers[0].Env).To(Equal(env))
+		Expect(podSpec.InitContainers).To(Equal(initContainers))
+	})
+
+	It("should add the capabilities and SELinux type of the security options", func() {
+		mod := kmmv1beta1.Module{
+			Spec: kmmv1beta1.ModuleSpec{
+				ModuleLoader: kmmv1beta1.ModuleLoaderSpec{
+					SecurityOptions: &kmmv1beta1.SecurityOptions{
+						Capabilities: []kmmv1beta1.Capability{"SYS_RAWIO", "SYS_MODULE"},
+						SELinuxType:  "container_device_t",
+					},
+				},
+			},
+		}
+
+		ds := appsv1.DaemonSet{}
+
+		err := dg.SetDriverContainerAsDesired(context.Background(), &ds, "test-image", mod, kernelVersion, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(
+			ds.Spec.Template.Spec.Containers[0].SecurityContext,
+		).To(
+			Equal(&v1.SecurityContext{
+				AllowPrivilegeEscalation: pointer.Bool(false),
+				Capabilities: &v1.Capabilities{
+					Add: []v1.Capability{"SYS_MODULE", "SYS_RAWIO"},
+				},
+				RunAsUser:      pointer.Int64(0),
+				SELinuxOptions: &v1.SELinuxOptions{Type: "container_device_t"},
+			}),
+		)
+	})
+
+	It("should not add probes if the health check is disabled", func() {
+		mod := kmmv1beta1.Module{
+			Spec: kmmv1beta1.ModuleSpec{
+				ModuleLoader: kmmv1beta1.ModuleLoaderSpec{
+					Container: kmmv1beta1.ModuleLoaderContainerSpec{
+						HealthCheck: &kmmv1beta1.HealthCheck{Disabled: true},
+					},
+				},
+			},
+		}
+
+		ds := appsv1.DaemonSet{}
+
+		err := dg.SetDriverContainerAsDesired(context.Background(), &ds, "test-image", mod, kernelVersion, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ds.Spec.Template.Spec.Containers[0].LivenessProbe).To(BeNil())
+		Expect(ds.Spec.Template.Spec.Containers[0].ReadinessProbe).To(BeNil())
+	})
+
+	It("should not add probes if there is no health check", func() {
+		mod := kmmv1beta1.Module{
+			Spec: kmmv1beta1.ModuleSpec{
+				ModuleLoader: kmmv1beta1.ModuleLoaderSpec{
+					Container: kmmv1beta1.ModuleLoaderContainerSpec{
+						Modprobe: kmmv1beta1.ModprobeSpec{ModuleName: "kmod"},
+					},
+				},
+			},
+		}
+
+		ds := appsv1.DaemonSet{}
+
+		err := dg.SetDriverContainerAsDesired(context.Background(), &ds, "test-image", mod, kernelVersion, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ds.Spec.Template.Spec.Containers[0].LivenessProbe).To(BeNil())
+		Expect(ds.Spec.Template.Spec.Containers[0].ReadinessProbe).To(BeNil())
+	})
+
+	It("should check that the kernel module is loaded if the health check is empty", func() {
+		mod := kmmv1beta1.Module{
+			Spec: kmmv1beta1.ModuleSpec{
+				ModuleLoader: kmmv1beta1.ModuleLoaderSpec{
+					Container: kmmv1beta1.ModuleLoaderContainerSpec{
+						HealthCheck: &kmmv1beta1.HealthCheck{},
+						Modprobe:    kmmv1beta1.ModprobeSpec{ModuleName: "some-kmod"},
+					},
+				},
+			},
+		}
+
+		ds := appsv1.DaemonSet{}
+
+		err := dg.SetDriverContainerAsDesired(context.Background(), &ds, "test-image", mod, kernelVersion, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		expected := &v1.Probe{
+			ProbeHandler: v1.ProbeHandler{
+				Exec: &v1.ExecAction{
+					Command: []string{"test", "-d", "/sys/module/some_kmod"},
+				},
+			},
+			TimeoutSeconds:   1,
+			PeriodSeconds:    10,
+			SuccessThreshold: 1,
+			FailureThreshold: 3,
+		}
+
+		Expect(ds.Spec.Template.Spec.Containers[0].LivenessProbe).To(Equal(expected))
+		Expect(ds.Spec.Template.Spec.Containers[0].ReadinessProbe).To(Equal(expected))
+	})
+
+	It("should not add probes for raw modprobe arguments without a health check command", func() {
+		mod := kmmv1beta1.Module{
+			Spec: kmmv1beta1.ModuleSpec{
+				ModuleLoader: kmmv1beta1.ModuleLoaderSpec{
+					Container: kmmv1beta1.ModuleLoaderContainerSpec{
+						HealthCheck: &kmmv1beta1.HealthCheck{},
+						Modprobe: kmmv1beta1.ModprobeSpec{
+							ModuleName: "kmod",
+							RawArgs:    &kmmv1beta1.ModprobeArgs{Load: []string{"kmod-alias"}},
+						},
+					},
+				},
+			},
+		}
+
+		ds := appsv1.DaemonSet{}
+
+		err := dg.SetDriverContainerAsDesired(context.Background(), &ds, "test-image", mod, kernelVersion, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ds.Spec.Template.Spec.Containers[0].LivenessProbe).To(BeNil())
+		Expect(ds.Spec.Template.Spec.Containers[0].ReadinessProbe).To(BeNil())
+	})
+
+	It("should only run the health check command for raw modprobe arguments", func() {
+		mod := kmmv1beta1.Module{
+			Spec: kmmv1beta1.ModuleSpec{
+				ModuleLoader: kmmv1beta1.ModuleLoaderSpec{
+					Container: kmmv1beta1.ModuleLoaderContainerSpec{
+						HealthCheck: &kmmv1beta1.HealthCheck{Command: []string{"/check.sh"}},
+						Modprobe: kmmv1beta1.ModprobeSpec{
+							ModuleName: "kmod",
+							RawArgs:    &kmmv1beta1.ModprobeArgs{Load: []string{"kmod-alias"}},
+						},
+					},
+				},
+			},
+		}
+
+		ds := appsv1.DaemonSet{}
+
+		err := dg.SetDriverContainerAsDesired(context.Background(), &ds, "test-image", mod, kernelVersion, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		container := ds.Spec.Template.Spec.Containers[0]
+		Expect(container.LivenessProbe.Exec.Command).To(Equal([]string{"/check.sh"}))
+		Expect(container.ReadinessProbe.Exec.Command).To(Equal([]string{"/check.sh"}))
+	})
+
+	It("should use the health check settings", func() {
+		mod := kmmv1beta1.Module{
+			Spec: kmmv1beta1.ModuleSpec{
+				ModuleLoader: kmmv1beta1.ModuleLoaderSpec{
+					Container: kmmv1beta1.ModuleLoaderContainerSpec{
+						HealthCheck: &kmmv1beta1.HealthCheck{
+							Command:          []string{"/check.sh"},
+							PeriodSeconds:    30,
+							FailureThreshold: 5,
+						},
+						Modprobe: kmmv1beta1.ModprobeSpec{ModuleName: "kmod"},
+					},
+				},
+			},
+		}
+
+		ds := appsv1.DaemonSet{}
+
+		err := dg.SetDriverContainerAsDesired(context.Background(), &ds, "test-image", mod, kernelVersion, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		container := ds.Spec.Template.Spec.Containers[0]
+		Expect(container.LivenessProbe.Exec.Command).To(Equal(MakeHealthCheckCommand("kmod", []string{"/check.sh"})))
+		Expect(container.LivenessProbe.PeriodSeconds).To(BeEquivalentTo(30))
+		Expect(container.LivenessProbe.FailureThreshold).To(BeEquivalentTo(5))
+		Expect(container.ReadinessProbe.Exec.Command).To(Equal(MakeHealthCheckCommand("kmod", []string{"/check.sh"})))
+		Expect(container.ReadinessProbe.PeriodSeconds).To(BeEquivalentTo(30))
+		Expect(container.ReadinessProbe.FailureThreshold).To(BeEquivalentTo(5))
+	})
+
+	It("should label the pods with the version of the Module", func() {
+		mod := kmmv1beta1.Module{
+			ObjectMeta: metav1.ObjectMeta{Name: moduleName},
+			Spec:       kmmv1beta1.ModuleSpec{Version: "1.2.0"},
+		}
+
+		ds := appsv1.DaemonSet{}
+
+		err := dg.SetDriverContainerAsDesired(context.Background(), &ds, "test-image", mod, kernelVersion, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ds.Spec.Template.Labels).To(HaveKeyWithValue(constants.ModuleVersionLabel, "1.2.0"))
+		Expect(ds.Spec.Selector.MatchLabels).NotTo(HaveKey(constants.ModuleVersionLabel))
+		Expect(ds.Labels).NotTo(HaveKey(constants.ModuleVersionLabel))
+	})
+
+	It("should apply the pod options of the module loader", func() {
+		tolerations := []v1.Toleration{
+			{Key: "nvidia.com/gpu", Operator: v1.TolerationOpExists, Effect: v1.TaintEffectNoSchedule},
+		}
+
+		resources := v1.ResourceRequirements{
+			Limits: v1.ResourceList{v1.ResourceMemory: resource.MustParse("64Mi")},
+		}
+
+		mod := kmmv1beta1.Module{
+			ObjectMeta: metav1.ObjectMeta{Name: moduleName},
+			Spec: kmmv1beta1.ModuleSpec{
+				ModuleLoader: kmmv1beta1.ModuleLoaderSpec{
+					Container: kmmv1beta1.ModuleLoaderContainerSpec{Resources: resources},
+					PodOptions: kmmv1beta1.PodOptions{
+						Tolerations:       tolerations,
+						PriorityClassName: "high-priority",
+						PodAnnotations:    map[string]string{"some": "annotation"},
+						PodLabels: map[string]string{
+							"team":                    "accelerators",
+							constants.ModuleNameLabel: "overridden",
+						},
+					},
+				},
+			},
+		}
+
+		ds := appsv1.DaemonSet{}
+
+		err := dg.SetDriverContainerAsDesired(context.Background(), &ds, "test-image", mod, kernelVersion, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		podSpec := ds.Spec.Template.Spec
+		Expect(podSpec.Tolerations).To(Equal(tolerations))
+		Expect(podSpec.PriorityClassName).To(Equal("high-priority"))
+		Expect(podSpec.Containers[0].Resources).To(Equal(resources))
+		Expect(ds.Spec.Template.Annotations).To(Equal(map[string]string{"some": "annotation"}))
+		Expect(ds.Spec.Template.Labels).To(HaveKeyWithValue("team", "accelerators"))
+		Expect(ds.Spec.Template.Labels).To(HaveKeyWithValue(constants.ModuleNameLabel, moduleName))
+		Expect(ds.Spec.Selector.MatchLabels).NotTo(HaveKey("team"))
+	})
+
+	It("should merge the module loader affinity with the node selector requirements", func() {
+		zone := v1.NodeSelectorRequirement{Key: "zone", Operator: v1.NodeSelectorOpIn, Values: []string{"a"}}
+
+		mod := kmmv1beta1.Module{
+			Spec: kmmv1beta1.ModuleSpec{
+				ModuleLoader: kmmv1beta1.ModuleLoaderSpec{
+					PodOptions: kmmv1beta1.PodOptions{
+						Affinity: &v1.Affinity{
+							NodeAffinity: &v1.NodeAffinity{
+								RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{
+									NodeSelectorTerms: []v1.NodeSelectorTerm{
+										{MatchExpressions: []v1.NodeSelectorRequirement{zone}},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+
+		ds := appsv1.DaemonSet{}
+
+		err := dg.SetDriverContainerAsDesired(context.Background(), &ds, "test-image", mod, kernelVersion, sets.NewString("node1"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(
+			ds.Spec.Template.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms,
+		).To(
+			Equal([]v1.NodeSelectorTerm{
+				{
+					MatchExpressions: []v1.NodeSelectorRequirement{zone},
+					MatchFields: []v1.NodeSelectorRequirement{
+						{Key: "metadata.name", Operator: v1.NodeSelectorOpNotIn, Values: []string{"node1"}},
+					},
+				},
+			}),
+		)
+	})
+
 	It("should work as expected", func() {
 		const (
 			moduleLoaderImage   = "driver-image"
@@ -115,7 +577,7 @@ var _ = Describe("SetDriverContainerAsDesired", func() {
 			},
 		}
 
-		err := dg.SetDriverContainerAsDesired(context.Background(), &ds, moduleLoaderImage, mod, kernelVersion)
+		err := dg.SetDriverContainerAsDesired(context.Background(), &ds, moduleLoaderImage, mod, kernelVersion, nil)
 		Expect(err).NotTo(HaveOccurred())
 
 		podLabels := map[string]string{
@@ -157,7 +619,7 @@ var _ = Describe("SetDriverContainerAsDesired", func() {
 								Lifecycle: &v1.Lifecycle{
 									PostStart: &v1.LifecycleHandler{
 										Exec: &v1.ExecAction{
-											Command: MakeLoadCommand(mod.Spec.ModuleLoader.Container.Modprobe),
+											Command: MakePostStartCommand(mod.Spec.ModuleLoader.Container.Modprobe),
 										},
 									},
 									PreStop: &v1.LifecycleHandler{
@@ -166,7 +628,7 @@ var _ = Describe("SetDriverContainerAsDesired", func() {
 										},
 									},
 								},
-								Command: []string{"sleep", "infinity"},
+								Command: MakeParametersSyncCommand(mod.Spec.ModuleLoader.Container.Modprobe),
 								VolumeMounts: []v1.VolumeMount{
 									{
 										Name:      "node-lib-modules",
@@ -178,6 +640,15 @@ var _ = Describe("SetDriverContainerAsDesired", func() {
 										ReadOnly:  true,
 										MountPath: "/usr/lib/modules",
 									},
+									{
+										Name:      "module-parameters",
+										ReadOnly:  true,
+										MountPath: "/etc/kmm/parameters",
+									},
+									{
+										Name:      "node-sys-module",
+										MountPath: "/host/sys/module",
+									},
 								},
 								SecurityContext: &v1.SecurityContext{
 									AllowPrivilegeEscalation: pointer.Bool(false),
@@ -219,6 +690,25 @@ var _ = Describe("SetDriverContainerAsDesired", func() {
 									},
 								},
 							},
+							{
+								Name: "module-parameters",
+								VolumeSource: v1.VolumeSource{
+									ConfigMap: &v1.ConfigMapVolumeSource{
+										LocalObjectReference: v1.LocalObjectReference{Name: moduleName + "-module-parameters"},
+										DefaultMode:          pointer.Int32(0644),
+										Optional:             pointer.Bool(true),
+									},
+								},
+							},
+							{
+								Name: "node-sys-module",
+								VolumeSource: v1.VolumeSource{
+									HostPath: &v1.HostPathVolumeSource{
+										Path: "/sys/module",
+										Type: &directory,
+									},
+								},
+							},
 						},
 					},
 				},
@@ -296,22 +786,69 @@ var _ = Describe("SetDriverContainerAsDesired", func() {
 					list.Items = append(list.Items, ds2)
 					return nil
 				},
-			)
+			)
+
+			dc := NewCreator(clnt, kernelLabel, scheme)
+			mod := kmmv1beta1.Module{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      moduleName,
+					Namespace: namespace,
+				},
+			}
+
+			m, err := dc.ModuleDaemonSetsByKernelVersion(context.Background(), mod.Name, mod.Namespace)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(m).To(HaveLen(2))
+			Expect(m).To(HaveKeyWithValue(kernelVersion, &ds1))
+			Expect(m).To(HaveKeyWithValue(otherKernelVersion, &ds2))
+		})
+	})
+})
+
+var _ = Describe("SetModuleParametersAsDesired", func() {
+	dg := NewCreator(nil, kernelLabel, scheme)
+
+	It("should return an error if the ConfigMap is nil", func() {
+		Expect(
+			dg.SetModuleParametersAsDesired(nil, &kmmv1beta1.Module{}),
+		).To(
+			HaveOccurred(),
+		)
+	})
+
+	It("should write one parameter per line and own the ConfigMap", func() {
+		mod := kmmv1beta1.Module{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      moduleName,
+				Namespace: namespace,
+			},
+			Spec: kmmv1beta1.ModuleSpec{
+				ModuleLoader: kmmv1beta1.ModuleLoaderSpec{
+					Container: kmmv1beta1.ModuleLoaderContainerSpec{
+						Modprobe: kmmv1beta1.ModprobeSpec{
+							ModuleName: "some-kmod",
+							Parameters: []string{"a=1", "b=2"},
+						},
+					},
+				},
+			},
+		}
 
-			dc := NewCreator(clnt, kernelLabel, scheme)
-			mod := kmmv1beta1.Module{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      moduleName,
-					Namespace: namespace,
-				},
-			}
+		cm := v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      ModuleParametersConfigMapName(moduleName),
+				Namespace: namespace,
+			},
+		}
 
-			m, err := dc.ModuleDaemonSetsByKernelVersion(context.Background(), mod.Name, mod.Namespace)
-			Expect(err).NotTo(HaveOccurred())
-			Expect(m).To(HaveLen(2))
-			Expect(m).To(HaveKeyWithValue(kernelVersion, &ds1))
-			Expect(m).To(HaveKeyWithValue(otherKernelVersion, &ds2))
-		})
+		Expect(
+			dg.SetModuleParametersAsDesired(&cm, &mod),
+		).NotTo(
+			HaveOccurred(),
+		)
+		Expect(cm.Data).To(Equal(map[string]string{"parameters": "a=1\nb=2"}))
+		Expect(cm.OwnerReferences).To(HaveLen(1))
+		Expect(cm.OwnerReferences[0].Name).To(Equal(moduleName))
 	})
 })
 
@@ -320,7 +857,7 @@ var _ = Describe("SetDevicePluginAsDesired", func() {
 
 	It("should return an error if the DaemonSet is nil", func() {
 		Expect(
-			dg.SetDevicePluginAsDesired(context.Background(), nil, &kmmv1beta1.Module{}),
+			dg.SetDevicePluginAsDesired(context.Background(), nil, &kmmv1beta1.Module{}, devicePluginImage, ""),
 		).To(
 			HaveOccurred(),
 		)
@@ -329,7 +866,7 @@ var _ = Describe("SetDevicePluginAsDesired", func() {
 	It("should return an error if DevicePlugin not set in the Spec", func() {
 		ds := appsv1.DaemonSet{}
 		Expect(
-			dg.SetDevicePluginAsDesired(context.Background(), &ds, &kmmv1beta1.Module{}),
+			dg.SetDevicePluginAsDesired(context.Background(), &ds, &kmmv1beta1.Module{}, devicePluginImage, ""),
 		).To(
 			HaveOccurred(),
 		)
@@ -349,12 +886,123 @@ var _ = Describe("SetDevicePluginAsDesired", func() {
 
 		ds := appsv1.DaemonSet{}
 
-		err := dg.SetDevicePluginAsDesired(context.Background(), &ds, &mod)
+		err := dg.SetDevicePluginAsDesired(context.Background(), &ds, &mod, devicePluginImage, "")
 		Expect(err).NotTo(HaveOccurred())
 		Expect(ds.Spec.Template.Spec.Volumes).To(HaveLen(2))
 		Expect(ds.Spec.Template.Spec.Volumes[1]).To(Equal(vol))
 	})
 
+	It("should run an unprivileged container if security options are set", func() {
+		mod := kmmv1beta1.Module{
+			Spec: kmmv1beta1.ModuleSpec{
+				DevicePlugin: &kmmv1beta1.DevicePluginSpec{
+					Container:       kmmv1beta1.DevicePluginContainerSpec{Image: devicePluginImage},
+					SecurityOptions: &kmmv1beta1.SecurityOptions{},
+				},
+			},
+		}
+
+		ds := appsv1.DaemonSet{}
+
+		err := dg.SetDevicePluginAsDesired(context.Background(), &ds, &mod, devicePluginImage, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(
+			ds.Spec.Template.Spec.Containers[0].SecurityContext,
+		).To(
+			Equal(&v1.SecurityContext{
+				AllowPrivilegeEscalation: pointer.Bool(false),
+				RunAsUser:                pointer.Int64(0),
+				SELinuxOptions:           &v1.SELinuxOptions{Type: "spc_t"},
+			}),
+		)
+	})
+
+	It("should return an error if the image is empty", func() {
+		mod := kmmv1beta1.Module{
+			Spec: kmmv1beta1.ModuleSpec{
+				DevicePlugin: &kmmv1beta1.DevicePluginSpec{},
+			},
+		}
+
+		Expect(
+			dg.SetDevicePluginAsDesired(context.Background(), &appsv1.DaemonSet{}, &mod, "", ""),
+		).To(
+			HaveOccurred(),
+		)
+	})
+
+	It("should only run the device plugin on nodes with the kernel if it is set", func() {
+		mod := kmmv1beta1.Module{
+			ObjectMeta: metav1.ObjectMeta{Name: moduleName},
+			Spec: kmmv1beta1.ModuleSpec{
+				DevicePlugin: &kmmv1beta1.DevicePluginSpec{
+					Container: kmmv1beta1.DevicePluginContainerSpec{Image: "not-used"},
+					PerKernel: true,
+				},
+			},
+		}
+
+		ds := appsv1.DaemonSet{}
+
+		err := dg.SetDevicePluginAsDesired(context.Background(), &ds, &mod, "device-plugin:"+kernelVersion, kernelVersion)
+		Expect(err).NotTo(HaveOccurred())
+
+		expectedLabels := map[string]string{
+			constants.ModuleNameLabel: moduleName,
+			constants.DaemonSetRole:   "device-plugin",
+			kernelLabel:               kernelVersion,
+		}
+
+		Expect(ds.Labels).To(Equal(expectedLabels))
+		Expect(ds.Spec.Selector.MatchLabels).To(Equal(expectedLabels))
+		Expect(ds.Spec.Template.Spec.Containers[0].Image).To(Equal("device-plugin:" + kernelVersion))
+		Expect(
+			ds.Spec.Template.Spec.NodeSelector,
+		).To(
+			Equal(map[string]string{
+				GetDriverContainerNodeLabel(moduleName): "",
+				kernelLabel:                             kernelVersion,
+			}),
+		)
+	})
+
+	It("should apply the pod options of the device plugin", func() {
+		tolerations := []v1.Toleration{
+			{Key: "nvidia.com/gpu", Operator: v1.TolerationOpExists, Effect: v1.TaintEffectNoSchedule},
+		}
+
+		affinity := &v1.Affinity{
+			PodAntiAffinity: &v1.PodAntiAffinity{},
+		}
+
+		mod := kmmv1beta1.Module{
+			ObjectMeta: metav1.ObjectMeta{Name: moduleName},
+			Spec: kmmv1beta1.ModuleSpec{
+				DevicePlugin: &kmmv1beta1.DevicePluginSpec{
+					Container: kmmv1beta1.DevicePluginContainerSpec{Image: devicePluginImage},
+					PodOptions: kmmv1beta1.PodOptions{
+						Tolerations:    tolerations,
+						Affinity:       affinity,
+						PodAnnotations: map[string]string{"some": "annotation"},
+						PodLabels:      map[string]string{constants.DaemonSetRole: "overridden"},
+					},
+				},
+			},
+		}
+
+		ds := appsv1.DaemonSet{}
+
+		err := dg.SetDevicePluginAsDesired(context.Background(), &ds, &mod, devicePluginImage, "")
+		Expect(err).NotTo(HaveOccurred())
+
+		podSpec := ds.Spec.Template.Spec
+		Expect(podSpec.Tolerations).To(Equal(tolerations))
+		Expect(podSpec.Affinity).To(Equal(affinity))
+		Expect(podSpec.PriorityClassName).To(Equal("system-node-critical"))
+		Expect(ds.Spec.Template.Annotations).To(Equal(map[string]string{"some": "annotation"}))
+		Expect(ds.Spec.Template.Labels).To(HaveKeyWithValue(constants.DaemonSetRole, "device-plugin"))
+	})
+
 	It("should work as expected", func() {
 		const (
 			dsName             = "ds-name"
@@ -431,7 +1079,7 @@ var _ = Describe("SetDevicePluginAsDesired", func() {
 			},
 		}
 
-		err := dg.SetDevicePluginAsDesired(context.Background(), &ds, &mod)
+		err := dg.SetDevicePluginAsDesired(context.Background(), &ds, &mod, devicePluginImage, "")
 		Expect(err).NotTo(HaveOccurred())
 
 		podLabels := map[string]string{
@@ -488,7 +1136,7 @@ var _ = Describe("SetDevicePluginAsDesired", func() {
 						},
 						ImagePullSecrets: []v1.LocalObjectReference{repoSecret},
 						NodeSelector: map[string]string{
-							getDriverContainerNodeLabel(mod.Name): "",
+							GetDriverContainerNodeLabel(mod.Name): "",
 						},
 						PriorityClassName:  "system-node-critical",
 						ServiceAccountName: serviceAccountName,
@@ -710,6 +1358,131 @@ var _ = Describe("ModuleDaemonSetsByKernelVersion", func() {
 	})
 })
 
+var _ = Describe("DevicePluginDaemonSetsByKernelVersion", func() {
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		clnt = client.NewMockClient(ctrl)
+	})
+
+	It("should only return per-kernel device plugin DaemonSets", func() {
+		moduleLoader := appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "module-loader",
+				Labels: map[string]string{
+					constants.ModuleNameLabel: moduleName,
+					constants.DaemonSetRole:   "module-loader",
+					kernelLabel:               kernelVersion,
+				},
+			},
+		}
+
+		sharedDevicePlugin := appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "shared-device-plugin",
+				Labels: map[string]string{
+					constants.ModuleNameLabel: moduleName,
+					constants.DaemonSetRole:   "device-plugin",
+				},
+			},
+		}
+
+		kernelDevicePlugin := appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "kernel-device-plugin",
+				Labels: map[string]string{
+					constants.ModuleNameLabel: moduleName,
+					constants.DaemonSetRole:   "device-plugin",
+					kernelLabel:               kernelVersion,
+				},
+			},
+		}
+
+		ctx := context.Background()
+
+		clnt.EXPECT().List(ctx, gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ interface{}, list *appsv1.DaemonSetList, _ ...interface{}) error {
+				list.Items = []appsv1.DaemonSet{moduleLoader, sharedDevicePlugin, kernelDevicePlugin}
+				return nil
+			},
+		).Times(2)
+
+		dc := NewCreator(clnt, kernelLabel, scheme)
+
+		m, err := dc.DevicePluginDaemonSetsByKernelVersion(ctx, moduleName, namespace)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m).To(Equal(map[string]*appsv1.DaemonSet{kernelVersion: &kernelDevicePlugin}))
+
+		m, err = dc.ModuleDaemonSetsByKernelVersion(ctx, moduleName, namespace)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m).To(Equal(map[string]*appsv1.DaemonSet{
+			kernelVersion:             &moduleLoader,
+			devicePluginKernelVersion: &sharedDevicePlugin,
+		}))
+	})
+})
+
+var _ = Describe("ValidateModuleLoaderContainer", func() {
+	It("should accept extra volumes and mounts", func() {
+		spec := kmmv1beta1.ModuleLoaderContainerSpec{
+			InitContainers: []v1.Container{{Name: "prepare"}},
+			VolumeMounts: []v1.VolumeMount{
+				{Name: "lib", MountPath: "/lib"},
+				{Name: "modprobe-d", MountPath: "/etc/modprobe.d"},
+				{Name: "lib-modules-extra", MountPath: "/lib/modules-extra"},
+			},
+			Volumes: []v1.Volume{{Name: "lib"}, {Name: "modprobe-d"}, {Name: "lib-modules-extra"}},
+		}
+
+		Expect(ValidateModuleLoaderContainer(spec)).To(Succeed())
+	})
+
+	DescribeTable(
+		"should reject",
+		func(spec kmmv1beta1.ModuleLoaderContainerSpec) {
+			Expect(ValidateModuleLoaderContainer(spec)).NotTo(Succeed())
+		},
+		Entry(
+			"a reserved volume name",
+			kmmv1beta1.ModuleLoaderContainerSpec{Volumes: []v1.Volume{{Name: "node-usr-lib-modules"}}},
+		),
+		Entry(
+			"the module parameters volume name",
+			kmmv1beta1.ModuleLoaderContainerSpec{Volumes: []v1.Volume{{Name: "module-parameters"}}},
+		),
+		Entry(
+			"a mount under /host/sys/module",
+			kmmv1beta1.ModuleLoaderContainerSpec{VolumeMounts: []v1.VolumeMount{{Name: "a", MountPath: "/host/sys/module/kmod"}}},
+		),
+		Entry(
+			"a mount on /host/etc/modprobe.d",
+			kmmv1beta1.ModuleLoaderContainerSpec{VolumeMounts: []v1.VolumeMount{{Name: "a", MountPath: "/host/etc/modprobe.d"}}},
+		),
+		Entry(
+			"an init container named like the persist container",
+			kmmv1beta1.ModuleLoaderContainerSpec{InitContainers: []v1.Container{{Name: "persist-modprobe-config"}}},
+		),
+		Entry(
+			"loadAtBoot with raw arguments",
+			kmmv1beta1.ModuleLoaderContainerSpec{
+				LoadAtBoot: true,
+				Modprobe:   kmmv1beta1.ModprobeSpec{RawArgs: &kmmv1beta1.ModprobeArgs{Load: []string{"kmod"}}},
+			},
+		),
+		Entry(
+			"a mount on /lib/modules",
+			kmmv1beta1.ModuleLoaderContainerSpec{VolumeMounts: []v1.VolumeMount{{Name: "a", MountPath: "/lib/modules/"}}},
+		),
+		Entry(
+			"a mount under /usr/lib/modules",
+			kmmv1beta1.ModuleLoaderContainerSpec{VolumeMounts: []v1.VolumeMount{{Name: "a", MountPath: "/usr/lib/modules/5.14"}}},
+		),
+		Entry(
+			"an init container named like the module loader",
+			kmmv1beta1.ModuleLoaderContainerSpec{InitContainers: []v1.Container{{Name: "module-loader"}}},
+		),
+	)
+})
+
 var _ = Describe("GetPodPullSecrets", func() {
 	It("should return nil if the secret is nil", func() {
 		Expect(
@@ -753,6 +1526,57 @@ var _ = Describe("OverrideLabels", func() {
 	})
 })
 
+var _ = Describe("ShortImageDigest", func() {
+	const digest = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+	DescribeTable("should return the short digest of the module loader image",
+		func(statuses []v1.ContainerStatus, expected string) {
+			pod := v1.Pod{
+				Status: v1.PodStatus{ContainerStatuses: statuses},
+			}
+
+			Expect(ShortImageDigest(&pod)).To(Equal(expected))
+		},
+		Entry("no container status", nil, ""),
+		Entry(
+			"an image ID without digest",
+			[]v1.ContainerStatus{{Name: "module-loader", ImageID: "some-image"}},
+			"",
+		),
+		Entry(
+			"a digest only",
+			[]v1.ContainerStatus{{Name: "module-loader", ImageID: "sha256:" + digest}},
+			"0123456789ab",
+		),
+		Entry(
+			"a pullable image ID",
+			[]v1.ContainerStatus{
+				{Name: "other", ImageID: "sha256:ffffffffffff"},
+				{Name: "module-loader", ImageID: "docker-pullable://example.com/driver@sha256:" + digest},
+			},
+			"0123456789ab",
+		),
+	)
+})
+
+var _ = Describe("ModuleNameFromNodeLabel", func() {
+	DescribeTable("should return the Module name of node labels set by the operator",
+		func(label, expectedName string, expectedOK bool) {
+			name, ok := ModuleNameFromNodeLabel(label)
+			Expect(ok).To(Equal(expectedOK))
+			Expect(name).To(Equal(expectedName))
+		},
+		Entry("driver container", GetDriverContainerNodeLabel("some.module"), "some.module", true),
+		Entry("version", GetModuleVersionNodeLabel("some-module"), "some-module", true),
+		Entry("digest", GetImageDigestNodeLabel("some-module"), "some-module", true),
+		Entry("device plugin", GetDevicePluginNodeLabel("some-module"), "some-module", true),
+		Entry("unmapped marker", "kmm.node.kubernetes.io/some-module.unmapped", "", false),
+		Entry("kernel label", constants.KernelLabel, "", false),
+		Entry("no Module name", "kmm.node.kubernetes.io/.ready", "", false),
+		Entry("other prefix", "example.com/some-module.ready", "", false),
+	)
+})
+
 var _ = Describe("GetNodeLabelFromPod", func() {
 	var dc DaemonSetCreator
 
@@ -770,7 +1594,7 @@ var _ = Describe("GetNodeLabelFromPod", func() {
 			},
 		}
 		res := dc.GetNodeLabelFromPod(&pod, "module-name")
-		Expect(res).To(Equal(getDriverContainerNodeLabel("module-name")))
+		Expect(res).To(Equal(GetDriverContainerNodeLabel("module-name")))
 	})
 
 	It("should return a device plugin label", func() {
@@ -782,7 +1606,220 @@ var _ = Describe("GetNodeLabelFromPod", func() {
 			},
 		}
 		res := dc.GetNodeLabelFromPod(&pod, "module-name")
-		Expect(res).To(Equal(getDevicePluginNodeLabel("module-name")))
+		Expect(res).To(Equal(GetDevicePluginNodeLabel("module-name")))
+	})
+
+	It("should return a device plugin label for per-kernel device plugins", func() {
+		pod := v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{
+					constants.ModuleNameLabel: moduleName,
+					constants.DaemonSetRole:   "device-plugin",
+					kernelLabel:               "some kernel",
+				},
+			},
+		}
+		res := dc.GetNodeLabelFromPod(&pod, "module-name")
+		Expect(res).To(Equal(GetDevicePluginNodeLabel("module-name")))
+	})
+})
+
+var _ = Describe("MakeHealthCheckCommand", func() {
+	It("should only check sysfs without a command", func() {
+		Expect(
+			MakeHealthCheckCommand("some-kmod", nil),
+		).To(
+			Equal([]string{"test", "-d", "/sys/module/some_kmod"}),
+		)
+	})
+
+	It("should run the command if the module is loaded", func() {
+		Expect(
+			MakeHealthCheckCommand("some-kmod", []string{"/check.sh", "arg"}),
+		).To(
+			Equal([]string{"sh", "-c", `test -d "$0" && exec "$@"`, "/sys/module/some_kmod", "/check.sh", "arg"}),
+		)
+	})
+})
+
+var _ = Describe("MakeParametersSyncCommand", func() {
+	It("should sync the parameters of the module and unload it if they cannot be changed", func() {
+		spec := kmmv1beta1.ModprobeSpec{ModuleName: "some-kmod"}
+
+		Expect(
+			MakeParametersSyncCommand(spec),
+		).To(
+			Equal([]string{"sh", "-c", parametersSyncScript, "some_kmod", "modprobe", "-rv", "some-kmod"}),
+		)
+	})
+})
+
+var _ = Describe("sync_parameters", func() {
+	var parameters string
+
+	BeforeEach(func() {
+		parameters = GinkgoT().TempDir()
+	})
+
+	syncParameters := func(applied, desired string) error {
+		cmd := exec.Command("sh", "-c", syncParametersFunction+"\nsync_parameters")
+		cmd.Env = append(os.Environ(), "applied="+applied, "desired="+desired, "parameters="+parameters)
+		return cmd.Run()
+	}
+
+	readParameter := func(name string) string {
+		b, err := os.ReadFile(filepath.Join(parameters, name))
+		Expect(err).NotTo(HaveOccurred())
+		return string(b)
+	}
+
+	It("should only write the parameters that changed", func() {
+		Expect(syncParameters("a=1\nb=2", "a=1\nb=3")).To(Succeed())
+		Expect(filepath.Join(parameters, "a")).NotTo(BeAnExistingFile())
+		Expect(readParameter("b")).To(Equal("3"))
+	})
+
+	It("should write values containing spaces as a single parameter", func() {
+		Expect(syncParameters("a=1", "a=1\nnames=first second\nb=2")).To(Succeed())
+		Expect(readParameter("names")).To(Equal("first second"))
+		Expect(readParameter("b")).To(Equal("2"))
+		Expect(filepath.Join(parameters, "second")).NotTo(BeAnExistingFile())
+	})
+
+	It("should write values containing the separator", func() {
+		Expect(syncParameters("", "opts=a=b")).To(Succeed())
+		Expect(readParameter("opts")).To(Equal("a=b"))
+	})
+
+	It("should replace dashes in parameter names", func() {
+		Expect(syncParameters("", "max-queues=8")).To(Succeed())
+		Expect(readParameter("max_queues")).To(Equal("8"))
+	})
+
+	DescribeTable("should fail without writing the new parameters",
+		func(applied, desired string) {
+			Expect(syncParameters(applied, desired)).NotTo(Succeed())
+
+			entries, err := os.ReadDir(parameters)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+		},
+		Entry("when a parameter was removed", "a=1\nb=2", "a=3"),
+		Entry("when a parameter without value was removed", "debug\na=1", "a=2"),
+		Entry("when a parameter without value was added", "a=1", "a=2\ndebug"),
+		Entry("when a removed parameter name only matches as a regular expression", "a.b=1", "axb=1"),
+		Entry("when a removed parameter name is a prefix of another", "a=1", "ab=1"),
+	)
+
+	It("should keep parameters without value that did not change", func() {
+		Expect(syncParameters("debug\na=1", "debug\na=2")).To(Succeed())
+		Expect(readParameter("a")).To(Equal("2"))
+	})
+
+	It("should fail if a parameter cannot be written", func() {
+		Expect(os.Mkdir(filepath.Join(parameters, "ro"), 0755)).To(Succeed())
+		Expect(syncParameters("", "ro=1")).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("MakePersistCommand", func() {
+	It("should persist the kernel module files from its directory", func() {
+		spec := kmmv1beta1.ModprobeSpec{ModuleName: "some-kmod", DirName: "/opt"}
+
+		Expect(
+			MakePersistCommand(spec),
+		).To(
+			Equal([]string{"sh", "-c", persistScript, "some_kmod", "/opt"}),
+		)
+	})
+})
+
+var _ = Describe("MakeBootConfigCleanupJob", func() {
+	It("should return nil if the pod does not load its kernel module at boot", func() {
+		pod := v1.Pod{
+			Spec: v1.PodSpec{
+				InitContainers: []v1.Container{{Name: "prepare"}},
+			},
+		}
+
+		Expect(MakeBootConfigCleanupJob(&pod)).To(BeNil())
+	})
+
+	It("should remove the configuration persisted by the pod on its node", func() {
+		securityContext := &v1.SecurityContext{Privileged: pointer.Bool(true)}
+		tolerations := []v1.Toleration{{Key: "some-key", Operator: v1.TolerationOpExists}}
+		pullSecrets := []v1.LocalObjectReference{{Name: "pull-secret"}}
+
+		pod := v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "some-pod",
+				Namespace: namespace,
+				UID:       "some-uid",
+			},
+			Spec: v1.PodSpec{
+				ImagePullSecrets: pullSecrets,
+				InitContainers: []v1.Container{
+					{
+						Name:            "persist-modprobe-config",
+						Image:           "some-image",
+						ImagePullPolicy: v1.PullAlways,
+						Command:         MakePersistCommand(kmmv1beta1.ModprobeSpec{ModuleName: "some-kmod"}),
+						SecurityContext: securityContext,
+					},
+				},
+				NodeName:           "some-node",
+				PriorityClassName:  "some-priority-class",
+				ServiceAccountName: "some-sa",
+				Tolerations:        tolerations,
+			},
+		}
+
+		job := MakeBootConfigCleanupJob(&pod)
+		Expect(job).NotTo(BeNil())
+		Expect(job.Name).To(Equal("boot-config-cleanup-some-uid"))
+		Expect(job.Namespace).To(Equal(namespace))
+		Expect(job.OwnerReferences).To(BeEmpty())
+		Expect(job.Spec.TTLSecondsAfterFinished).NotTo(BeNil())
+
+		podSpec := job.Spec.Template.Spec
+		Expect(podSpec.NodeName).To(Equal("some-node"))
+		Expect(podSpec.RestartPolicy).To(Equal(v1.RestartPolicyNever))
+		Expect(podSpec.ImagePullSecrets).To(Equal(pullSecrets))
+		Expect(podSpec.PriorityClassName).To(Equal("some-priority-class"))
+		Expect(podSpec.ServiceAccountName).To(Equal("some-sa"))
+		Expect(podSpec.Tolerations).To(Equal(tolerations))
+		Expect(podSpec.Volumes).To(HaveLen(3))
+		Expect(podSpec.Containers).To(HaveLen(1))
+
+		c := podSpec.Containers[0]
+		Expect(c.Image).To(Equal("some-image"))
+		Expect(c.ImagePullPolicy).To(Equal(v1.PullAlways))
+		Expect(c.SecurityContext).To(Equal(securityContext))
+		Expect(c.Command).To(Equal([]string{"sh", "-c", cleanupScript, "some_kmod", "some-uid"}))
+		Expect(c.VolumeMounts).To(HaveLen(3))
+	})
+})
+
+var _ = Describe("MakePostStartCommand", func() {
+	It("should record a termination message if the kernel module cannot be loaded", func() {
+		spec := kmmv1beta1.ModprobeSpec{
+			ModuleName: "some-kmod",
+			RawArgs: &kmmv1beta1.ModprobeArgs{
+				Load: []string{"some-kmod"},
+			},
+		}
+
+		Expect(
+			MakePostStartCommand(spec),
+		).To(
+			Equal([]string{
+				"sh",
+				"-c",
+				`"$0" "$@" || { echo "modprobe failed" > /dev/termination-log; exit 1; }`,
+				"modprobe",
+				"some-kmod",
+			}),
+		)
 	})
 })
 
@@ -804,7 +1841,23 @@ var _ = Describe("MakeLoadCommand", func() {
 		)
 	})
 
-	It("should build the command from the spec as expected", func() {
+	It("should pass the parameters on the command line if raw arguments are set without load arguments", func() {
+		spec := kmmv1beta1.ModprobeSpec{
+			ModuleName: moduleName,
+			Parameters: []string{"a=1", "b=some value"},
+			RawArgs: &kmmv1beta1.ModprobeArgs{
+				Unload: []string{"-r", moduleName},
+			},
+		}
+
+		Expect(
+			MakeLoadCommand(spec),
+		).To(
+			Equal([]string{"modprobe", "-v", moduleName, "a=1", "b=some value"}),
+		)
+	})
+
+	It("should build the command from the spec and read the parameters from the mounted ConfigMap", func() {
 		const (
 			arg1 = "arg1"
 			arg2 = "arg2"
@@ -820,7 +1873,7 @@ var _ = Describe("MakeLoadCommand", func() {
 		Expect(
 			MakeLoadCommand(spec),
 		).To(
-			Equal([]string{"modprobe", "-v", "-d", dir, moduleName, arg1, arg2}),
+			Equal([]string{"sh", "-c", loadScript, "modprobe", "-v", "-d", dir, moduleName}),
 		)
 	})
 
@@ -835,7 +1888,7 @@ var _ = Describe("MakeLoadCommand", func() {
 		Expect(
 			MakeLoadCommand(spec),
 		).To(
-			Equal([]string{"modprobe", "-z", "-k", moduleName}),
+			Equal([]string{"sh", "-c", loadScript, "modprobe", "-z", "-k", moduleName}),
 		)
 	})
 })
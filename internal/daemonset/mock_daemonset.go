@@ -38,6 +38,21 @@ func (m *MockDaemonSetCreator) EXPECT() *MockDaemonSetCreatorMockRecorder {
 	return m.recorder
 }
 
+// DevicePluginDaemonSetsByKernelVersion mocks base method.
+func (m *MockDaemonSetCreator) DevicePluginDaemonSetsByKernelVersion(ctx context.Context, name, namespace string) (map[string]*v1.DaemonSet, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DevicePluginDaemonSetsByKernelVersion", ctx, name, namespace)
+	ret0, _ := ret[0].(map[string]*v1.DaemonSet)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DevicePluginDaemonSetsByKernelVersion indicates an expected call of DevicePluginDaemonSetsByKernelVersion.
+func (mr *MockDaemonSetCreatorMockRecorder) DevicePluginDaemonSetsByKernelVersion(ctx, name, namespace interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DevicePluginDaemonSetsByKernelVersion", reflect.TypeOf((*MockDaemonSetCreator)(nil).DevicePluginDaemonSetsByKernelVersion), ctx, name, namespace)
+}
+
 // GarbageCollect mocks base method.
 func (m *MockDaemonSetCreator) GarbageCollect(ctx context.Context, existingDS map[string]*v1.DaemonSet, validKernels sets.String) ([]string, error) {
 	m.ctrl.T.Helper()
@@ -83,29 +98,43 @@ func (mr *MockDaemonSetCreatorMockRecorder) ModuleDaemonSetsByKernelVersion(ctx,
 }
 
 // SetDevicePluginAsDesired mocks base method.
-func (m *MockDaemonSetCreator) SetDevicePluginAsDesired(ctx context.Context, ds *v1.DaemonSet, mod *v1beta1.Module) error {
+func (m *MockDaemonSetCreator) SetDevicePluginAsDesired(ctx context.Context, ds *v1.DaemonSet, mod *v1beta1.Module, image, kernelVersion string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SetDevicePluginAsDesired", ctx, ds, mod)
+	ret := m.ctrl.Call(m, "SetDevicePluginAsDesired", ctx, ds, mod, image, kernelVersion)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // SetDevicePluginAsDesired indicates an expected call of SetDevicePluginAsDesired.
-func (mr *MockDaemonSetCreatorMockRecorder) SetDevicePluginAsDesired(ctx, ds, mod interface{}) *gomock.Call {
+func (mr *MockDaemonSetCreatorMockRecorder) SetDevicePluginAsDesired(ctx, ds, mod, image, kernelVersion interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDevicePluginAsDesired", reflect.TypeOf((*MockDaemonSetCreator)(nil).SetDevicePluginAsDesired), ctx, ds, mod)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDevicePluginAsDesired", reflect.TypeOf((*MockDaemonSetCreator)(nil).SetDevicePluginAsDesired), ctx, ds, mod, image, kernelVersion)
 }
 
 // SetDriverContainerAsDesired mocks base method.
-func (m *MockDaemonSetCreator) SetDriverContainerAsDesired(ctx context.Context, ds *v1.DaemonSet, image string, mod v1beta1.Module, kernelVersion string) error {
+func (m *MockDaemonSetCreator) SetDriverContainerAsDesired(ctx context.Context, ds *v1.DaemonSet, image string, mod v1beta1.Module, kernelVersion string, excludedNodes sets.String) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SetDriverContainerAsDesired", ctx, ds, image, mod, kernelVersion)
+	ret := m.ctrl.Call(m, "SetDriverContainerAsDesired", ctx, ds, image, mod, kernelVersion, excludedNodes)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // SetDriverContainerAsDesired indicates an expected call of SetDriverContainerAsDesired.
-func (mr *MockDaemonSetCreatorMockRecorder) SetDriverContainerAsDesired(ctx, ds, image, mod, kernelVersion interface{}) *gomock.Call {
+func (mr *MockDaemonSetCreatorMockRecorder) SetDriverContainerAsDesired(ctx, ds, image, mod, kernelVersion, excludedNodes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDriverContainerAsDesired", reflect.TypeOf((*MockDaemonSetCreator)(nil).SetDriverContainerAsDesired), ctx, ds, image, mod, kernelVersion, excludedNodes)
+}
+
+// SetModuleParametersAsDesired mocks base method.
+func (m *MockDaemonSetCreator) SetModuleParametersAsDesired(cm *v10.ConfigMap, mod *v1beta1.Module) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetModuleParametersAsDesired", cm, mod)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetModuleParametersAsDesired indicates an expected call of SetModuleParametersAsDesired.
+func (mr *MockDaemonSetCreatorMockRecorder) SetModuleParametersAsDesired(cm, mod interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetDriverContainerAsDesired", reflect.TypeOf((*MockDaemonSetCreator)(nil).SetDriverContainerAsDesired), ctx, ds, image, mod, kernelVersion)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetModuleParametersAsDesired", reflect.TypeOf((*MockDaemonSetCreator)(nil).SetModuleParametersAsDesired), cm, mod)
 }
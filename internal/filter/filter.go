@@ -2,13 +2,17 @@ package filter
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/go-logr/logr"
 	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	"github.com/qbarrand/oot-operator/internal/module"
+	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/kubectl/pkg/util/podutils"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
@@ -29,15 +33,37 @@ var skipDeletions predicate.Predicate = predicate.Funcs{
 type Filter struct {
 	client client.Client
 	logger logr.Logger
+
+	watchedNamespaces       sets.String
+	allowedModuleNamespaces sets.String
 }
 
-func New(client client.Client, logger logr.Logger) *Filter {
+// New returns a Filter that only considers objects in watchedNamespaces and Modules in allowedModuleNamespaces.
+// An empty list means all namespaces.
+func New(client client.Client, logger logr.Logger, watchedNamespaces, allowedModuleNamespaces []string) *Filter {
 	return &Filter{
-		client: client,
-		logger: logger,
+		client:                  client,
+		logger:                  logger,
+		watchedNamespaces:       sets.NewString(watchedNamespaces...),
+		allowedModuleNamespaces: sets.NewString(allowedModuleNamespaces...),
 	}
 }
 
+// AllNamespacesWatched returns true if the operator watches objects in all namespaces.
+func (f *Filter) AllNamespacesWatched() bool {
+	return f.watchedNamespaces.Len() == 0
+}
+
+// NamespaceWatched returns true if the operator watches objects in ns.
+func (f *Filter) NamespaceWatched(ns string) bool {
+	return f.AllNamespacesWatched() || f.watchedNamespaces.Has(ns)
+}
+
+// ModuleNamespaceAllowed returns true if Modules in ns can be reconciled.
+func (f *Filter) ModuleNamespaceAllowed(ns string) bool {
+	return f.NamespaceWatched(ns) && (f.allowedModuleNamespaces.Len() == 0 || f.allowedModuleNamespaces.Has(ns))
+}
+
 func (f *Filter) ModuleReconcilerNodePredicate(kernelLabel string) predicate.Predicate {
 	return predicate.And(
 		skipDeletions,
@@ -54,6 +80,13 @@ func (f *Filter) NodeKernelReconcilerPredicate(labelName string) predicate.Predi
 	return predicate.And(skipDeletions, labelMismatch)
 }
 
+// NodeLabelResyncReconcilerPredicate returns true for node creations and label changes, so that the labels of all
+// nodes are checked when the operator starts and labels removed by mistake are repaired without waiting for the next
+// resync.
+func (f *Filter) NodeLabelResyncReconcilerPredicate() predicate.Predicate {
+	return predicate.And(skipDeletions, predicate.LabelChangedPredicate{})
+}
+
 func (f *Filter) FindModulesForNode(node client.Object) []reconcile.Request {
 	logger := f.logger.WithValues("node", node.GetName())
 
@@ -75,20 +108,17 @@ func (f *Filter) FindModulesForNode(node client.Object) []reconcile.Request {
 	for _, mod := range mods.Items {
 		logger := logger.WithValues("module name", mod.Name)
 
-		logger.V(1).Info("Processing module")
-
-		sel := labels.NewSelector()
-
-		for k, v := range mod.Spec.Selector {
-			logger.V(1).Info("Processing selector item", "key", k, "value", v)
+		if !f.ModuleNamespaceAllowed(mod.Namespace) {
+			logger.V(1).Info("Modules are not allowed in this namespace; skipping", "namespace", mod.Namespace)
+			continue
+		}
 
-			requirement, err := labels.NewRequirement(k, selection.Equals, []string{v})
-			if err != nil {
-				logger.Error(err, "could not generate requirement: %v", err)
-				return reqs
-			}
+		logger.V(1).Info("Processing module")
 
-			sel = sel.Add(*requirement)
+		sel, err := module.NodeSelector(&mod.Spec)
+		if err != nil {
+			logger.Error(err, "could not build the node selector; skipping")
+			continue
 		}
 
 		if !sel.Matches(nodeLabelsSet) {
@@ -107,10 +137,97 @@ func (f *Filter) FindModulesForNode(node client.Object) []reconcile.Request {
 	return reqs
 }
 
+// FindClusterModulesForModule returns a request for each ClusterModule loading the same kernel module as mod.
+func (f *Filter) FindClusterModulesForModule(mod client.Object) []reconcile.Request {
+	reqs := make([]reconcile.Request, 0)
+
+	m, ok := mod.(*kmmv1beta1.Module)
+	if !ok {
+		return reqs
+	}
+
+	logger := f.logger.WithValues("module", m.Name, "namespace", m.Namespace)
+
+	cms := kmmv1beta1.ClusterModuleList{}
+
+	if err := f.client.List(context.Background(), &cms); err != nil {
+		logger.Error(err, "could not list ClusterModules")
+		return reqs
+	}
+
+	kernelModule := m.Spec.ModuleLoader.Container.Modprobe.ModuleName
+
+	for _, cm := range cms.Items {
+		if cm.Spec.ModuleSpec.ModuleLoader.Container.Modprobe.ModuleName == kernelModule {
+			reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Name: cm.Name}})
+		}
+	}
+
+	return reqs
+}
+
+// FindModulesLoadingSameKernelModule returns a request for each other Module loading the same kernel module as mod,
+// so that they take back the nodes that mod yielded to them or release the nodes it now takes.
+func (f *Filter) FindModulesLoadingSameKernelModule(mod client.Object) []reconcile.Request {
+	reqs := make([]reconcile.Request, 0)
+
+	m, ok := mod.(*kmmv1beta1.Module)
+	if !ok {
+		return reqs
+	}
+
+	kernelModule := m.Spec.ModuleLoader.Container.Modprobe.ModuleName
+	if kernelModule == "" {
+		return reqs
+	}
+
+	logger := f.logger.WithValues("module", m.Name, "namespace", m.Namespace)
+
+	mods := kmmv1beta1.ModuleList{}
+
+	if err := f.client.List(context.Background(), &mods); err != nil {
+		logger.Error(err, "could not list Modules")
+		return reqs
+	}
+
+	for _, other := range mods.Items {
+		if other.Namespace == m.Namespace && other.Name == m.Name {
+			continue
+		}
+
+		if !f.ModuleNamespaceAllowed(other.Namespace) {
+			continue
+		}
+
+		if other.Spec.ModuleLoader.Container.Modprobe.ModuleName == kernelModule {
+			reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Name: other.Name, Namespace: other.Namespace}})
+		}
+	}
+
+	return reqs
+}
+
+// FindNamespaceForModule returns a request for the namespace of mod if Modules are allowed in it.
+func (f *Filter) FindNamespaceForModule(mod client.Object) []reconcile.Request {
+	if !f.ModuleNamespaceAllowed(mod.GetNamespace()) {
+		return []reconcile.Request{}
+	}
+
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: mod.GetNamespace()}},
+	}
+}
+
 func (f *Filter) EnqueueAllPreflightValidations(mod client.Object) []reconcile.Request {
 	reqs := make([]reconcile.Request, 0)
 
 	logger := f.logger.WithValues("module", mod.GetName())
+
+	if !f.ModuleNamespaceAllowed(mod.GetNamespace()) {
+		logger.V(1).Info("Modules are not allowed in this namespace; not enqueuing preflights", "namespace", mod.GetNamespace())
+		return reqs
+	}
+
 	logger.Info("Listing all preflights")
 	preflights := kmmv1beta1.PreflightValidationList{}
 	if err := f.client.List(context.Background(), &preflights); err != nil {
@@ -118,17 +235,76 @@ func (f *Filter) EnqueueAllPreflightValidations(mod client.Object) []reconcile.R
 		return reqs
 	}
 
+	// fetched once, and only if a preflight has a namespace selector
+	var nsLabels labels.Set
+
 	for _, preflight := range preflights.Items {
 		// skip the preflight being deleted
 		if preflight.GetDeletionTimestamp() != nil {
 			continue
 		}
+
+		if !f.NamespaceWatched(preflight.Namespace) {
+			continue
+		}
+
 		nsn := types.NamespacedName{Name: preflight.Name, Namespace: preflight.Namespace}
+
+		if preflight.Spec.NamespaceSelector != nil && nsLabels == nil {
+			ns := v1.Namespace{}
+
+			if err := f.client.Get(context.Background(), types.NamespacedName{Name: mod.GetNamespace()}, &ns); err != nil {
+				// let the preflight reconciler sort it out
+				logger.Info("could not get the Module's namespace; enqueuing the preflight", "preflight", preflight.Name, "error", err)
+				reqs = append(reqs, reconcile.Request{NamespacedName: nsn})
+				continue
+			}
+
+			// never nil, even if the namespace has no labels
+			nsLabels = labels.Merge(ns.Labels, nil)
+		}
+
+		selected, err := preflightSelectsModule(&preflight, mod, nsLabels)
+		if err != nil {
+			logger.Error(err, "could not check if the preflight selects the Module", "preflight", preflight.Name)
+			continue
+		}
+
+		if !selected {
+			continue
+		}
+
 		reqs = append(reqs, reconcile.Request{NamespacedName: nsn})
 	}
 	return reqs
 }
 
+func preflightSelectsModule(pv *kmmv1beta1.PreflightValidation, mod client.Object, nsLabels labels.Set) (bool, error) {
+	if pv.Spec.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(pv.Spec.Selector)
+		if err != nil {
+			return false, fmt.Errorf("invalid selector: %w", err)
+		}
+
+		if !selector.Matches(labels.Set(mod.GetLabels())) {
+			return false, nil
+		}
+	}
+
+	if pv.Spec.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(pv.Spec.NamespaceSelector)
+		if err != nil {
+			return false, fmt.Errorf("invalid namespace selector: %w", err)
+		}
+
+		if !selector.Matches(nsLabels) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
 // DeletingPredicate returns a predicate that returns true if the object is being deleted.
 func DeletingPredicate() predicate.Predicate {
 	return predicate.NewPredicateFuncs(func(object client.Object) bool {
@@ -167,6 +343,74 @@ func PodReadinessChangedPredicate(logger logr.Logger) predicate.Predicate {
 	}
 }
 
+// PodRestartedPredicate returns a predicate for Update events that only returns true if one of the containers of the
+// pod was restarted.
+func PodRestartedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldPod, ok := e.ObjectOld.(*v1.Pod)
+			if !ok {
+				return false
+			}
+
+			newPod, ok := e.ObjectNew.(*v1.Pod)
+			if !ok {
+				return false
+			}
+
+			return PodRestartCount(newPod) > PodRestartCount(oldPod)
+		},
+	}
+}
+
+// PodRestartCount returns the sum of the restart counts of all containers in pod.
+func PodRestartCount(pod *v1.Pod) int32 {
+	var count int32
+
+	for _, cs := range pod.Status.ContainerStatuses {
+		count += cs.RestartCount
+	}
+
+	return count
+}
+
+// JobFinishedPredicate returns a predicate that only returns true for Update events where the Job just completed or
+// failed.
+func JobFinishedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc:  func(_ event.CreateEvent) bool { return false },
+		DeleteFunc:  func(_ event.DeleteEvent) bool { return false },
+		GenericFunc: func(_ event.GenericEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldJob, ok := e.ObjectOld.(*batchv1.Job)
+			if !ok {
+				return false
+			}
+
+			newJob, ok := e.ObjectNew.(*batchv1.Job)
+			if !ok {
+				return false
+			}
+
+			return !jobFinished(oldJob) && jobFinished(newJob)
+		},
+	}
+}
+
+func jobFinished(job *batchv1.Job) bool {
+	for _, c := range job.Status.Conditions {
+		if (c.Type == batchv1.JobComplete || c.Type == batchv1.JobFailed) && c.Status == v1.ConditionTrue {
+			return true
+		}
+	}
+
+	return false
+}
+
 func PreflightReconcilerModulePredicate() predicate.Predicate {
-	return predicate.GenerationChangedPredicate{}
+	// Label changes may change which preflights select the Module.
+	return predicate.Or(
+		predicate.GenerationChangedPredicate{},
+		predicate.LabelChangedPredicate{},
+	)
 }
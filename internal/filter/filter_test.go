@@ -10,6 +10,7 @@ import (
 	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
 	mockClient "github.com/qbarrand/oot-operator/internal/client"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -69,7 +70,7 @@ var _ = Describe("ModuleReconcilerNodePredicate", func() {
 	var p predicate.Predicate
 
 	BeforeEach(func() {
-		p = New(nil, logr.Discard()).ModuleReconcilerNodePredicate(kernelLabel)
+		p = New(nil, logr.Discard(), nil, nil).ModuleReconcilerNodePredicate(kernelLabel)
 	})
 
 	It("should return true for creations", func() {
@@ -152,7 +153,7 @@ var _ = Describe("NodeKernelReconcilerPredicate", func() {
 
 	BeforeEach(func() {
 		ctrl = gomock.NewController(GinkgoT())
-		p = New(nil, logr.Discard()).NodeKernelReconcilerPredicate(labelName)
+		p = New(nil, logr.Discard(), nil, nil).NodeKernelReconcilerPredicate(labelName)
 	})
 
 	It("should return true if the node has no labels", func() {
@@ -229,6 +230,62 @@ var _ = Describe("NodeKernelReconcilerPredicate", func() {
 	})
 })
 
+var _ = Describe("NodeLabelResyncReconcilerPredicate", func() {
+	var p predicate.Predicate
+
+	BeforeEach(func() {
+		p = New(nil, logr.Discard(), nil, nil).NodeLabelResyncReconcilerPredicate()
+	})
+
+	It("should return true for creations", func() {
+		Expect(
+			p.Create(event.CreateEvent{Object: &v1.Node{}}),
+		).To(
+			BeTrue(),
+		)
+	})
+
+	It("should return true for label updates", func() {
+		ev := event.UpdateEvent{
+			ObjectOld: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"kmm.node.kubernetes.io/module.ready": ""},
+				},
+			},
+			ObjectNew: &v1.Node{},
+		}
+
+		Expect(
+			p.Update(ev),
+		).To(
+			BeTrue(),
+		)
+	})
+
+	It("should return false for updates that do not change labels", func() {
+		ev := event.UpdateEvent{
+			ObjectOld: &v1.Node{},
+			ObjectNew: &v1.Node{
+				Status: v1.NodeStatus{NodeInfo: v1.NodeSystemInfo{KernelVersion: "1.2.3"}},
+			},
+		}
+
+		Expect(
+			p.Update(ev),
+		).To(
+			BeFalse(),
+		)
+	})
+
+	It("should return false for deletions", func() {
+		Expect(
+			p.Delete(event.DeleteEvent{Object: &v1.Node{}}),
+		).To(
+			BeFalse(),
+		)
+	})
+})
+
 var _ = Describe("FindModulesForNode", func() {
 	BeforeEach(func() {
 		ctrl = gomock.NewController(GinkgoT())
@@ -238,7 +295,7 @@ var _ = Describe("FindModulesForNode", func() {
 	It("should return nothing if there are no modules", func() {
 		clnt.EXPECT().List(context.Background(), gomock.Any(), gomock.Any())
 
-		p := New(clnt, logr.Discard())
+		p := New(clnt, logr.Discard(), nil, nil)
 		Expect(
 			p.FindModulesForNode(&v1.Node{}),
 		).To(
@@ -260,7 +317,7 @@ var _ = Describe("FindModulesForNode", func() {
 			},
 		)
 
-		p := New(clnt, logr.Discard())
+		p := New(clnt, logr.Discard(), nil, nil)
 
 		Expect(
 			p.FindModulesForNode(&v1.Node{}),
@@ -296,7 +353,7 @@ var _ = Describe("FindModulesForNode", func() {
 			},
 		)
 
-		p := New(clnt, logr.Discard())
+		p := New(clnt, logr.Discard(), nil, nil)
 
 		expectedReq := reconcile.Request{
 			NamespacedName: types.NamespacedName{Name: mod1Name},
@@ -305,6 +362,195 @@ var _ = Describe("FindModulesForNode", func() {
 		reqs := p.FindModulesForNode(&node)
 		Expect(reqs).To(Equal([]reconcile.Request{expectedReq}))
 	})
+
+	It("should match the selector expressions of modules", func() {
+		node := v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"worker": "", "gpu": "none"}},
+		}
+
+		makeModule := func(name string, expressions ...metav1.LabelSelectorRequirement) kmmv1beta1.Module {
+			return kmmv1beta1.Module{
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				Spec: kmmv1beta1.ModuleSpec{
+					Selector:            map[string]string{"worker": ""},
+					SelectorExpressions: expressions,
+				},
+			}
+		}
+
+		mods := []kmmv1beta1.Module{
+			makeModule("gpu-exists", metav1.LabelSelectorRequirement{Key: "gpu", Operator: metav1.LabelSelectorOpExists}),
+			makeModule("gpu-not-none", metav1.LabelSelectorRequirement{Key: "gpu", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"none"}}),
+			makeModule("invalid", metav1.LabelSelectorRequirement{Key: "gpu", Operator: metav1.LabelSelectorOpIn}),
+		}
+
+		clnt.EXPECT().List(context.Background(), gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ interface{}, list *kmmv1beta1.ModuleList, _ ...interface{}) error {
+				list.Items = mods
+				return nil
+			},
+		)
+
+		Expect(
+			New(clnt, logr.Discard(), nil, nil).FindModulesForNode(&node),
+		).To(
+			Equal([]reconcile.Request{{NamespacedName: types.NamespacedName{Name: "gpu-exists"}}}),
+		)
+	})
+
+	It("should skip modules in namespaces that are not allowed", func() {
+		mod1 := kmmv1beta1.Module{
+			ObjectMeta: metav1.ObjectMeta{Name: "mod1", Namespace: "allowed"},
+		}
+
+		mod2 := kmmv1beta1.Module{
+			ObjectMeta: metav1.ObjectMeta{Name: "mod2", Namespace: "not-allowed"},
+		}
+
+		clnt.EXPECT().List(context.Background(), gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ interface{}, list *kmmv1beta1.ModuleList, _ ...interface{}) error {
+				list.Items = []kmmv1beta1.Module{mod1, mod2}
+				return nil
+			},
+		)
+
+		p := New(clnt, logr.Discard(), nil, []string{"allowed"})
+
+		expectedReq := reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: "mod1", Namespace: "allowed"},
+		}
+
+		Expect(p.FindModulesForNode(&v1.Node{})).To(Equal([]reconcile.Request{expectedReq}))
+	})
+})
+
+var _ = Describe("FindClusterModulesForModule", func() {
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		clnt = mockClient.NewMockClient(ctrl)
+	})
+
+	It("should only return the ClusterModules loading the same kernel module", func() {
+		modprobe := func(name string) kmmv1beta1.ModuleLoaderSpec {
+			return kmmv1beta1.ModuleLoaderSpec{
+				Container: kmmv1beta1.ModuleLoaderContainerSpec{
+					Modprobe: kmmv1beta1.ModprobeSpec{ModuleName: name},
+				},
+			}
+		}
+
+		mod := kmmv1beta1.Module{
+			ObjectMeta: metav1.ObjectMeta{Name: "mod", Namespace: "ns"},
+			Spec:       kmmv1beta1.ModuleSpec{ModuleLoader: modprobe("kmod")},
+		}
+
+		cms := []kmmv1beta1.ClusterModule{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "same"},
+				Spec: kmmv1beta1.ClusterModuleSpec{
+					ModuleSpec: kmmv1beta1.ModuleSpec{ModuleLoader: modprobe("kmod")},
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "other"},
+				Spec: kmmv1beta1.ClusterModuleSpec{
+					ModuleSpec: kmmv1beta1.ModuleSpec{ModuleLoader: modprobe("other-kmod")},
+				},
+			},
+		}
+
+		clnt.EXPECT().List(context.Background(), gomock.Any()).DoAndReturn(
+			func(_ interface{}, list *kmmv1beta1.ClusterModuleList, _ ...interface{}) error {
+				list.Items = cms
+				return nil
+			},
+		)
+
+		Expect(
+			New(clnt, logr.Discard(), nil, nil).FindClusterModulesForModule(&mod),
+		).To(
+			Equal([]reconcile.Request{{NamespacedName: types.NamespacedName{Name: "same"}}}),
+		)
+	})
+})
+
+var _ = Describe("FindModulesLoadingSameKernelModule", func() {
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		clnt = mockClient.NewMockClient(ctrl)
+	})
+
+	makeModule := func(namespace, name, kernelModule string) kmmv1beta1.Module {
+		return kmmv1beta1.Module{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec: kmmv1beta1.ModuleSpec{
+				ModuleLoader: kmmv1beta1.ModuleLoaderSpec{
+					Container: kmmv1beta1.ModuleLoaderContainerSpec{
+						Modprobe: kmmv1beta1.ModprobeSpec{ModuleName: kernelModule},
+					},
+				},
+			},
+		}
+	}
+
+	It("should return nothing for Modules without a kernel module name", func() {
+		mod := makeModule("ns", "mod", "")
+
+		Expect(
+			New(clnt, logr.Discard(), nil, nil).FindModulesLoadingSameKernelModule(&mod),
+		).To(
+			BeEmpty(),
+		)
+	})
+
+	It("should only return the other allowed Modules loading the same kernel module", func() {
+		mod := makeModule("ns", "mod", "kmod")
+
+		clnt.EXPECT().List(context.Background(), gomock.Any()).DoAndReturn(
+			func(_ interface{}, list *kmmv1beta1.ModuleList, _ ...interface{}) error {
+				list.Items = []kmmv1beta1.Module{
+					mod,
+					makeModule("ns", "same", "kmod"),
+					makeModule("other-ns", "same", "kmod"),
+					makeModule("ns", "other", "other-kmod"),
+				}
+				return nil
+			},
+		)
+
+		Expect(
+			New(clnt, logr.Discard(), nil, []string{"ns"}).FindModulesLoadingSameKernelModule(&mod),
+		).To(
+			Equal([]reconcile.Request{{NamespacedName: types.NamespacedName{Name: "same", Namespace: "ns"}}}),
+		)
+	})
+})
+
+var _ = Describe("AllNamespacesWatched", func() {
+	It("should return true if no namespace is listed", func() {
+		Expect(New(nil, logr.Discard(), nil, nil).AllNamespacesWatched()).To(BeTrue())
+	})
+
+	It("should return false if some namespaces are listed", func() {
+		Expect(New(nil, logr.Discard(), []string{"ns"}, nil).AllNamespacesWatched()).To(BeFalse())
+	})
+})
+
+var _ = Describe("ModuleNamespaceAllowed", func() {
+	DescribeTable("should return the expected value",
+		func(watched, allowed []string, expected bool) {
+			Expect(
+				New(nil, logr.Discard(), watched, allowed).ModuleNamespaceAllowed("ns"),
+			).To(
+				Equal(expected),
+			)
+		},
+		Entry("all namespaces", nil, nil, true),
+		Entry("watched", []string{"ns"}, nil, true),
+		Entry("not watched", []string{"other-ns"}, nil, false),
+		Entry("allowed", []string{"ns", "other-ns"}, []string{"ns"}, true),
+		Entry("not allowed", []string{"ns", "other-ns"}, []string{"other-ns"}, false),
+	)
 })
 
 var _ = Describe("DeletingPredicate", func() {
@@ -387,6 +633,96 @@ var _ = Describe("PodReadinessChangedPredicate", func() {
 	)
 })
 
+var _ = Describe("PodRestartedPredicate", func() {
+	p := PodRestartedPredicate()
+
+	podWithRestarts := func(restarts ...int32) *v1.Pod {
+		pod := v1.Pod{}
+
+		for _, r := range restarts {
+			pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, v1.ContainerStatus{RestartCount: r})
+		}
+
+		return &pod
+	}
+
+	DescribeTable(
+		"should return the expected value",
+		func(e event.UpdateEvent, expected bool) {
+			Expect(p.Update(e)).To(Equal(expected))
+		},
+		Entry("objects are nil", event.UpdateEvent{}, false),
+		Entry("new object is not a Pod", event.UpdateEvent{ObjectOld: &v1.Pod{}, ObjectNew: &v1.Node{}}, false),
+		Entry(
+			"no restart",
+			event.UpdateEvent{ObjectOld: podWithRestarts(1, 2), ObjectNew: podWithRestarts(1, 2)},
+			false,
+		),
+		Entry(
+			"one container restarted",
+			event.UpdateEvent{ObjectOld: podWithRestarts(1, 2), ObjectNew: podWithRestarts(1, 3)},
+			true,
+		),
+	)
+
+	It("should accept creations", func() {
+		Expect(p.Create(event.CreateEvent{Object: &v1.Pod{}})).To(BeTrue())
+	})
+})
+
+var _ = Describe("JobFinishedPredicate", func() {
+	p := JobFinishedPredicate()
+
+	jobWithCondition := func(t batchv1.JobConditionType, status v1.ConditionStatus) *batchv1.Job {
+		return &batchv1.Job{
+			Status: batchv1.JobStatus{
+				Conditions: []batchv1.JobCondition{{Type: t, Status: status}},
+			},
+		}
+	}
+
+	DescribeTable(
+		"should return the expected value",
+		func(e event.UpdateEvent, expected bool) {
+			Expect(p.Update(e)).To(Equal(expected))
+		},
+		Entry("objects are nil", event.UpdateEvent{}, false),
+		Entry(
+			"Job is still running",
+			event.UpdateEvent{ObjectOld: &batchv1.Job{}, ObjectNew: &batchv1.Job{}},
+			false,
+		),
+		Entry(
+			"Job just completed",
+			event.UpdateEvent{ObjectOld: &batchv1.Job{}, ObjectNew: jobWithCondition(batchv1.JobComplete, v1.ConditionTrue)},
+			true,
+		),
+		Entry(
+			"Job just failed",
+			event.UpdateEvent{ObjectOld: &batchv1.Job{}, ObjectNew: jobWithCondition(batchv1.JobFailed, v1.ConditionTrue)},
+			true,
+		),
+		Entry(
+			"Job condition is not true",
+			event.UpdateEvent{ObjectOld: &batchv1.Job{}, ObjectNew: jobWithCondition(batchv1.JobFailed, v1.ConditionFalse)},
+			false,
+		),
+		Entry(
+			"Job had already completed",
+			event.UpdateEvent{
+				ObjectOld: jobWithCondition(batchv1.JobComplete, v1.ConditionTrue),
+				ObjectNew: jobWithCondition(batchv1.JobComplete, v1.ConditionTrue),
+			},
+			false,
+		),
+	)
+
+	It("should ignore creations and deletions", func() {
+		Expect(p.Create(event.CreateEvent{Object: &batchv1.Job{}})).To(BeFalse())
+		Expect(p.Delete(event.DeleteEvent{Object: &batchv1.Job{}})).To(BeFalse())
+	})
+})
+
 var _ = Describe("FindPreflightsForModule", func() {
 
 	BeforeEach(func() {
@@ -397,7 +733,7 @@ var _ = Describe("FindPreflightsForModule", func() {
 	It("no preflight exists", func() {
 		clnt.EXPECT().List(context.Background(), gomock.Any(), gomock.Any())
 
-		p := New(clnt, logr.Discard())
+		p := New(clnt, logr.Discard(), nil, nil)
 
 		res := p.EnqueueAllPreflightValidations(&kmmv1beta1.Module{})
 		Expect(res).To(BeEmpty())
@@ -424,9 +760,135 @@ var _ = Describe("FindPreflightsForModule", func() {
 			},
 		}
 
-		p := New(clnt, logr.Discard())
+		p := New(clnt, logr.Discard(), nil, nil)
 		res := p.EnqueueAllPreflightValidations(&kmmv1beta1.Module{})
 		Expect(res).To(Equal(expectedRes))
 	})
 
+	It("should only enqueue the preflights selecting the Module", func() {
+		mod := kmmv1beta1.Module{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "module",
+				Namespace: "moduleNamespace",
+				Labels:    map[string]string{"team": "a"},
+			},
+		}
+
+		preflights := []kmmv1beta1.PreflightValidation{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "no-selector"},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "matching-selector"},
+				Spec: kmmv1beta1.PreflightValidationSpec{
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "other-selector"},
+				Spec: kmmv1beta1.PreflightValidationSpec{
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "b"}},
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "matching-namespace-selector"},
+				Spec: kmmv1beta1.PreflightValidationSpec{
+					NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "test"}},
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "other-namespace-selector"},
+				Spec: kmmv1beta1.PreflightValidationSpec{
+					NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}},
+				},
+			},
+		}
+
+		gomock.InOrder(
+			clnt.EXPECT().List(context.Background(), gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ interface{}, list *kmmv1beta1.PreflightValidationList, _ ...interface{}) error {
+					list.Items = preflights
+					return nil
+				},
+			),
+			clnt.EXPECT().Get(context.Background(), types.NamespacedName{Name: mod.Namespace}, &v1.Namespace{}).DoAndReturn(
+				func(_ interface{}, _ interface{}, ns *v1.Namespace) error {
+					ns.Labels = map[string]string{"env": "test"}
+					return nil
+				},
+			),
+		)
+
+		expectedRes := []reconcile.Request{
+			{NamespacedName: types.NamespacedName{Name: "no-selector"}},
+			{NamespacedName: types.NamespacedName{Name: "matching-selector"}},
+			{NamespacedName: types.NamespacedName{Name: "matching-namespace-selector"}},
+		}
+
+		p := New(clnt, logr.Discard(), nil, nil)
+		res := p.EnqueueAllPreflightValidations(&mod)
+		Expect(res).To(Equal(expectedRes))
+	})
+
+	It("should only enqueue the preflights in watched namespaces", func() {
+		preflights := []kmmv1beta1.PreflightValidation{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "watched", Namespace: "ns"},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "not-watched", Namespace: "other-ns"},
+			},
+		}
+
+		clnt.EXPECT().List(context.Background(), gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ interface{}, list *kmmv1beta1.PreflightValidationList, _ ...interface{}) error {
+				list.Items = preflights
+				return nil
+			},
+		)
+
+		p := New(clnt, logr.Discard(), []string{"ns"}, nil)
+
+		mod := kmmv1beta1.Module{
+			ObjectMeta: metav1.ObjectMeta{Name: "module", Namespace: "ns"},
+		}
+
+		Expect(
+			p.EnqueueAllPreflightValidations(&mod),
+		).To(
+			Equal([]reconcile.Request{{NamespacedName: types.NamespacedName{Name: "watched", Namespace: "ns"}}}),
+		)
+	})
+
+	It("should not enqueue anything for Modules in namespaces that are not allowed", func() {
+		p := New(clnt, logr.Discard(), nil, []string{"ns"})
+
+		mod := kmmv1beta1.Module{
+			ObjectMeta: metav1.ObjectMeta{Name: "module", Namespace: "other-ns"},
+		}
+
+		Expect(p.EnqueueAllPreflightValidations(&mod)).To(BeEmpty())
+	})
+})
+
+var _ = Describe("FindNamespaceForModule", func() {
+	mod := &kmmv1beta1.Module{
+		ObjectMeta: metav1.ObjectMeta{Name: "mod", Namespace: "ns"},
+	}
+
+	It("should return the namespace of the Module", func() {
+		Expect(
+			New(nil, logr.Discard(), nil, nil).FindNamespaceForModule(mod),
+		).To(
+			Equal([]reconcile.Request{{NamespacedName: types.NamespacedName{Name: "ns"}}}),
+		)
+	})
+
+	It("should return nothing if Modules are not allowed in the namespace", func() {
+		Expect(
+			New(nil, logr.Discard(), nil, []string{"other-ns"}).FindNamespaceForModule(mod),
+		).To(
+			BeEmpty(),
+		)
+	})
 })
@@ -1,6 +1,8 @@
 package metrics
 
 import (
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 	runtimemetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 )
@@ -9,9 +11,28 @@ import (
 const (
 	existingKMMOModulesQuery = "kmmo_module_total"
 	completedKMMOStageQuery  = "kmmo_completed_stage"
+	preflightVerifiedQuery   = "kmmo_preflight_verified_modules"
+	preflightFailedQuery     = "kmmo_preflight_failed_modules"
+	unmappedNodesQuery       = "kmmo_unmapped_nodes"
+	nodesLoadedQuery         = "kmmo_nodes_loaded"
+	buildDurationQuery       = "kmmo_build_duration_seconds"
+	buildFailuresQuery       = "kmmo_build_failures_total"
+	modprobeFailuresQuery    = "kmmo_modprobe_failures_total"
+	registryDurationQuery    = "kmmo_registry_request_duration_seconds"
+	nodeLabelRepairsQuery    = "kmmo_node_label_repairs_total"
 	BuildStage               = "build"
 	ModuleLoaderStage        = "module-loader"
 	DevicePluginStage        = "device-plugin"
+
+	BuildResultSucceeded = "succeeded"
+	BuildResultFailed    = "failed"
+
+	RegistryOperationManifest = "manifest"
+	RegistryOperationLayer    = "layer"
+
+	NodeLabelAdded   = "added"
+	NodeLabelUpdated = "updated"
+	NodeLabelRemoved = "removed"
 )
 
 //go:generate mockgen -source=metrics.go -package=metrics -destination=mock_metrics_api.go
@@ -21,11 +42,31 @@ type Metrics interface {
 	Register()
 	SetExistingKMMOModules(value int)
 	SetCompletedStage(kmmoName, kmmoNamespace, kernelVersion, stage string, completed bool)
+	SetPreflightResults(preflightName, preflightNamespace string, verified, failed int)
+	DeletePreflightResults(preflightName, preflightNamespace string)
+	SetUnmappedNodes(kmmoName, kmmoNamespace string, value int)
+	SetNodesLoaded(kmmoName, kmmoNamespace string, value int)
+	ObserveBuildDuration(kmmoName, kmmoNamespace, result string, duration time.Duration)
+	IncBuildFailures(kmmoName, kmmoNamespace, kernelVersion string)
+	IncModprobeFailures(kmmoName, kmmoNamespace, kernelVersion string)
+	ObserveRegistryRequestDuration(operation string, duration time.Duration)
+	IncNodeLabelRepairs(kmmoName, kmmoNamespace, repair string)
+	DeleteKernelSeries(kmmoName, kmmoNamespace, kernelVersion string)
+	DeleteModuleSeries(kmmoName, kmmoNamespace string)
 }
 
 type metrics struct {
 	kmmoResourcesNum   prometheus.Gauge
 	kmmoCompletedStage *prometheus.GaugeVec
+	preflightVerified  *prometheus.GaugeVec
+	preflightFailed    *prometheus.GaugeVec
+	unmappedNodes      *prometheus.GaugeVec
+	nodesLoaded        *prometheus.GaugeVec
+	buildDuration      *prometheus.HistogramVec
+	buildFailures      *prometheus.CounterVec
+	modprobeFailures   *prometheus.CounterVec
+	registryDuration   *prometheus.HistogramVec
+	nodeLabelRepairs   *prometheus.CounterVec
 }
 
 func New() Metrics {
@@ -44,9 +85,92 @@ func New() Metrics {
 		[]string{"kmmo", "namespace", "kernel", "stage"},
 	)
 
+	preflightVerified := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: preflightVerifiedQuery,
+			Help: "For a given preflight and namespace, the number of Modules that were verified.",
+		},
+		[]string{"preflight", "namespace"},
+	)
+	preflightFailed := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: preflightFailedQuery,
+			Help: "For a given preflight and namespace, the number of Modules that failed verification.",
+		},
+		[]string{"preflight", "namespace"},
+	)
+
+	unmappedNodes := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: unmappedNodesQuery,
+			Help: "For a given kmmo and namespace, the number of targeted nodes whose kernel matches no kernel mapping.",
+		},
+		[]string{"kmmo", "namespace"},
+	)
+
+	nodesLoaded := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: nodesLoadedQuery,
+			Help: "For a given kmmo and namespace, the number of nodes on which the kernel module is loaded.",
+		},
+		[]string{"kmmo", "namespace"},
+	)
+
+	buildDuration := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: buildDurationQuery,
+			Help: "For a given kmmo, namespace and result (succeeded, failed), the duration of the build Jobs.",
+			// 30s to ~1h
+			Buckets: prometheus.ExponentialBuckets(30, 2, 8),
+		},
+		[]string{"kmmo", "namespace", "result"},
+	)
+
+	buildFailures := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: buildFailuresQuery,
+			Help: "For a given kmmo, namespace and kernel version, the number of failed build Jobs.",
+		},
+		[]string{"kmmo", "namespace", "kernel"},
+	)
+
+	modprobeFailures := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: modprobeFailuresQuery,
+			Help: "For a given kmmo, namespace and kernel version, the number of module-loader container restarts caused by modprobe failures.",
+		},
+		[]string{"kmmo", "namespace", "kernel"},
+	)
+
+	registryDuration := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    registryDurationQuery,
+			Help:    "For a given operation (manifest, layer), the duration of the requests made to container registries.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"operation"},
+	)
+
+	nodeLabelRepairs := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: nodeLabelRepairsQuery,
+			Help: "For a given kmmo, namespace and repair (added, updated, removed), the number of node labels that had drifted from the pods running on the nodes and were repaired.",
+		},
+		[]string{"kmmo", "namespace", "repair"},
+	)
+
 	return &metrics{
 		kmmoResourcesNum:   kmmoResourcesNum,
 		kmmoCompletedStage: completedStages,
+		preflightVerified:  preflightVerified,
+		preflightFailed:    preflightFailed,
+		unmappedNodes:      unmappedNodes,
+		nodesLoaded:        nodesLoaded,
+		buildDuration:      buildDuration,
+		buildFailures:      buildFailures,
+		modprobeFailures:   modprobeFailures,
+		registryDuration:   registryDuration,
+		nodeLabelRepairs:   nodeLabelRepairs,
 	}
 }
 
@@ -54,6 +178,15 @@ func (m *metrics) Register() {
 	runtimemetrics.Registry.MustRegister(
 		m.kmmoResourcesNum,
 		m.kmmoCompletedStage,
+		m.preflightVerified,
+		m.preflightFailed,
+		m.unmappedNodes,
+		m.nodesLoaded,
+		m.buildDuration,
+		m.buildFailures,
+		m.modprobeFailures,
+		m.registryDuration,
+		m.nodeLabelRepairs,
 	)
 }
 
@@ -68,3 +201,63 @@ func (m *metrics) SetCompletedStage(kmmoName, kmmoNamespace, kernelVersion, stag
 	}
 	m.kmmoCompletedStage.WithLabelValues(kmmoName, kmmoNamespace, kernelVersion, stage).Set(value)
 }
+
+func (m *metrics) SetPreflightResults(preflightName, preflightNamespace string, verified, failed int) {
+	m.preflightVerified.WithLabelValues(preflightName, preflightNamespace).Set(float64(verified))
+	m.preflightFailed.WithLabelValues(preflightName, preflightNamespace).Set(float64(failed))
+}
+
+func (m *metrics) DeletePreflightResults(preflightName, preflightNamespace string) {
+	m.preflightVerified.DeleteLabelValues(preflightName, preflightNamespace)
+	m.preflightFailed.DeleteLabelValues(preflightName, preflightNamespace)
+}
+
+func (m *metrics) SetUnmappedNodes(kmmoName, kmmoNamespace string, value int) {
+	m.unmappedNodes.WithLabelValues(kmmoName, kmmoNamespace).Set(float64(value))
+}
+
+func (m *metrics) SetNodesLoaded(kmmoName, kmmoNamespace string, value int) {
+	m.nodesLoaded.WithLabelValues(kmmoName, kmmoNamespace).Set(float64(value))
+}
+
+func (m *metrics) ObserveBuildDuration(kmmoName, kmmoNamespace, result string, duration time.Duration) {
+	m.buildDuration.WithLabelValues(kmmoName, kmmoNamespace, result).Observe(duration.Seconds())
+}
+
+func (m *metrics) IncBuildFailures(kmmoName, kmmoNamespace, kernelVersion string) {
+	m.buildFailures.WithLabelValues(kmmoName, kmmoNamespace, kernelVersion).Inc()
+}
+
+func (m *metrics) IncModprobeFailures(kmmoName, kmmoNamespace, kernelVersion string) {
+	m.modprobeFailures.WithLabelValues(kmmoName, kmmoNamespace, kernelVersion).Inc()
+}
+
+func (m *metrics) ObserveRegistryRequestDuration(operation string, duration time.Duration) {
+	m.registryDuration.WithLabelValues(operation).Observe(duration.Seconds())
+}
+
+func (m *metrics) IncNodeLabelRepairs(kmmoName, kmmoNamespace, repair string) {
+	m.nodeLabelRepairs.WithLabelValues(kmmoName, kmmoNamespace, repair).Inc()
+}
+
+// DeleteKernelSeries removes all series of kmmoName that are specific to kernelVersion.
+func (m *metrics) DeleteKernelSeries(kmmoName, kmmoNamespace, kernelVersion string) {
+	labels := prometheus.Labels{"kmmo": kmmoName, "namespace": kmmoNamespace, "kernel": kernelVersion}
+
+	m.kmmoCompletedStage.DeletePartialMatch(labels)
+	m.buildFailures.Delete(labels)
+	m.modprobeFailures.Delete(labels)
+}
+
+// DeleteModuleSeries removes all series of kmmoName.
+func (m *metrics) DeleteModuleSeries(kmmoName, kmmoNamespace string) {
+	labels := prometheus.Labels{"kmmo": kmmoName, "namespace": kmmoNamespace}
+
+	m.kmmoCompletedStage.DeletePartialMatch(labels)
+	m.unmappedNodes.Delete(labels)
+	m.nodesLoaded.Delete(labels)
+	m.buildDuration.DeletePartialMatch(labels)
+	m.buildFailures.DeletePartialMatch(labels)
+	m.modprobeFailures.DeletePartialMatch(labels)
+	m.nodeLabelRepairs.DeletePartialMatch(labels)
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("stale series", func() {
+	const (
+		name      = "name"
+		namespace = "namespace"
+		kernel    = "kernel"
+	)
+
+	var m *metrics
+
+	BeforeEach(func() {
+		m = New().(*metrics)
+
+		for _, mod := range []string{name, "other"} {
+			m.SetCompletedStage(mod, namespace, kernel, ModuleLoaderStage, true)
+			m.SetCompletedStage(mod, namespace, "other-kernel", ModuleLoaderStage, true)
+			m.SetCompletedStage(mod, namespace, "", DevicePluginStage, true)
+			m.SetUnmappedNodes(mod, namespace, 1)
+			m.SetNodesLoaded(mod, namespace, 2)
+			m.ObserveBuildDuration(mod, namespace, BuildResultSucceeded, time.Minute)
+			m.IncBuildFailures(mod, namespace, kernel)
+			m.IncModprobeFailures(mod, namespace, kernel)
+			m.IncNodeLabelRepairs(mod, namespace, NodeLabelAdded)
+		}
+	})
+
+	It("should only delete the series of a kernel", func() {
+		m.DeleteKernelSeries(name, namespace, kernel)
+
+		Expect(testutil.CollectAndCount(m.kmmoCompletedStage)).To(Equal(5))
+		Expect(testutil.CollectAndCount(m.buildFailures)).To(Equal(1))
+		Expect(testutil.CollectAndCount(m.modprobeFailures)).To(Equal(1))
+		Expect(testutil.CollectAndCount(m.nodesLoaded)).To(Equal(2))
+	})
+
+	It("should only delete the series of a Module", func() {
+		m.DeleteModuleSeries(name, namespace)
+
+		Expect(testutil.CollectAndCount(m.kmmoCompletedStage)).To(Equal(3))
+		Expect(testutil.CollectAndCount(m.unmappedNodes)).To(Equal(1))
+		Expect(testutil.CollectAndCount(m.nodesLoaded)).To(Equal(1))
+		Expect(testutil.CollectAndCount(m.buildDuration)).To(Equal(1))
+		Expect(testutil.CollectAndCount(m.buildFailures)).To(Equal(1))
+		Expect(testutil.CollectAndCount(m.modprobeFailures)).To(Equal(1))
+		Expect(testutil.CollectAndCount(m.nodeLabelRepairs)).To(Equal(1))
+	})
+})
@@ -6,6 +6,7 @@ package metrics
 
 import (
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -33,6 +34,102 @@ func (m *MockMetrics) EXPECT() *MockMetricsMockRecorder {
 	return m.recorder
 }
 
+// DeleteKernelSeries mocks base method.
+func (m *MockMetrics) DeleteKernelSeries(kmmoName, kmmoNamespace, kernelVersion string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "DeleteKernelSeries", kmmoName, kmmoNamespace, kernelVersion)
+}
+
+// DeleteKernelSeries indicates an expected call of DeleteKernelSeries.
+func (mr *MockMetricsMockRecorder) DeleteKernelSeries(kmmoName, kmmoNamespace, kernelVersion interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteKernelSeries", reflect.TypeOf((*MockMetrics)(nil).DeleteKernelSeries), kmmoName, kmmoNamespace, kernelVersion)
+}
+
+// DeleteModuleSeries mocks base method.
+func (m *MockMetrics) DeleteModuleSeries(kmmoName, kmmoNamespace string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "DeleteModuleSeries", kmmoName, kmmoNamespace)
+}
+
+// DeleteModuleSeries indicates an expected call of DeleteModuleSeries.
+func (mr *MockMetricsMockRecorder) DeleteModuleSeries(kmmoName, kmmoNamespace interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteModuleSeries", reflect.TypeOf((*MockMetrics)(nil).DeleteModuleSeries), kmmoName, kmmoNamespace)
+}
+
+// DeletePreflightResults mocks base method.
+func (m *MockMetrics) DeletePreflightResults(preflightName, preflightNamespace string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "DeletePreflightResults", preflightName, preflightNamespace)
+}
+
+// DeletePreflightResults indicates an expected call of DeletePreflightResults.
+func (mr *MockMetricsMockRecorder) DeletePreflightResults(preflightName, preflightNamespace interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePreflightResults", reflect.TypeOf((*MockMetrics)(nil).DeletePreflightResults), preflightName, preflightNamespace)
+}
+
+// IncBuildFailures mocks base method.
+func (m *MockMetrics) IncBuildFailures(kmmoName, kmmoNamespace, kernelVersion string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "IncBuildFailures", kmmoName, kmmoNamespace, kernelVersion)
+}
+
+// IncBuildFailures indicates an expected call of IncBuildFailures.
+func (mr *MockMetricsMockRecorder) IncBuildFailures(kmmoName, kmmoNamespace, kernelVersion interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncBuildFailures", reflect.TypeOf((*MockMetrics)(nil).IncBuildFailures), kmmoName, kmmoNamespace, kernelVersion)
+}
+
+// IncModprobeFailures mocks base method.
+func (m *MockMetrics) IncModprobeFailures(kmmoName, kmmoNamespace, kernelVersion string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "IncModprobeFailures", kmmoName, kmmoNamespace, kernelVersion)
+}
+
+// IncModprobeFailures indicates an expected call of IncModprobeFailures.
+func (mr *MockMetricsMockRecorder) IncModprobeFailures(kmmoName, kmmoNamespace, kernelVersion interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncModprobeFailures", reflect.TypeOf((*MockMetrics)(nil).IncModprobeFailures), kmmoName, kmmoNamespace, kernelVersion)
+}
+
+// IncNodeLabelRepairs mocks base method.
+func (m *MockMetrics) IncNodeLabelRepairs(kmmoName, kmmoNamespace, repair string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "IncNodeLabelRepairs", kmmoName, kmmoNamespace, repair)
+}
+
+// IncNodeLabelRepairs indicates an expected call of IncNodeLabelRepairs.
+func (mr *MockMetricsMockRecorder) IncNodeLabelRepairs(kmmoName, kmmoNamespace, repair interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncNodeLabelRepairs", reflect.TypeOf((*MockMetrics)(nil).IncNodeLabelRepairs), kmmoName, kmmoNamespace, repair)
+}
+
+// ObserveBuildDuration mocks base method.
+func (m *MockMetrics) ObserveBuildDuration(kmmoName, kmmoNamespace, result string, duration time.Duration) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ObserveBuildDuration", kmmoName, kmmoNamespace, result, duration)
+}
+
+// ObserveBuildDuration indicates an expected call of ObserveBuildDuration.
+func (mr *MockMetricsMockRecorder) ObserveBuildDuration(kmmoName, kmmoNamespace, result, duration interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ObserveBuildDuration", reflect.TypeOf((*MockMetrics)(nil).ObserveBuildDuration), kmmoName, kmmoNamespace, result, duration)
+}
+
+// ObserveRegistryRequestDuration mocks base method.
+func (m *MockMetrics) ObserveRegistryRequestDuration(operation string, duration time.Duration) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ObserveRegistryRequestDuration", operation, duration)
+}
+
+// ObserveRegistryRequestDuration indicates an expected call of ObserveRegistryRequestDuration.
+func (mr *MockMetricsMockRecorder) ObserveRegistryRequestDuration(operation, duration interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ObserveRegistryRequestDuration", reflect.TypeOf((*MockMetrics)(nil).ObserveRegistryRequestDuration), operation, duration)
+}
+
 // Register mocks base method.
 func (m *MockMetrics) Register() {
 	m.ctrl.T.Helper()
@@ -68,3 +165,39 @@ func (mr *MockMetricsMockRecorder) SetExistingKMMOModules(value interface{}) *go
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetExistingKMMOModules", reflect.TypeOf((*MockMetrics)(nil).SetExistingKMMOModules), value)
 }
+
+// SetNodesLoaded mocks base method.
+func (m *MockMetrics) SetNodesLoaded(kmmoName, kmmoNamespace string, value int) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetNodesLoaded", kmmoName, kmmoNamespace, value)
+}
+
+// SetNodesLoaded indicates an expected call of SetNodesLoaded.
+func (mr *MockMetricsMockRecorder) SetNodesLoaded(kmmoName, kmmoNamespace, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetNodesLoaded", reflect.TypeOf((*MockMetrics)(nil).SetNodesLoaded), kmmoName, kmmoNamespace, value)
+}
+
+// SetPreflightResults mocks base method.
+func (m *MockMetrics) SetPreflightResults(preflightName, preflightNamespace string, verified, failed int) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetPreflightResults", preflightName, preflightNamespace, verified, failed)
+}
+
+// SetPreflightResults indicates an expected call of SetPreflightResults.
+func (mr *MockMetricsMockRecorder) SetPreflightResults(preflightName, preflightNamespace, verified, failed interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPreflightResults", reflect.TypeOf((*MockMetrics)(nil).SetPreflightResults), preflightName, preflightNamespace, verified, failed)
+}
+
+// SetUnmappedNodes mocks base method.
+func (m *MockMetrics) SetUnmappedNodes(kmmoName, kmmoNamespace string, value int) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetUnmappedNodes", kmmoName, kmmoNamespace, value)
+}
+
+// SetUnmappedNodes indicates an expected call of SetUnmappedNodes.
+func (mr *MockMetricsMockRecorder) SetUnmappedNodes(kmmoName, kmmoNamespace, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUnmappedNodes", reflect.TypeOf((*MockMetrics)(nil).SetUnmappedNodes), kmmoName, kmmoNamespace, value)
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/a8m/envsubst/parse"
 	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	"github.com/qbarrand/oot-operator/internal/constants"
 	v1 "k8s.io/api/core/v1"
 )
 
@@ -18,12 +19,15 @@ const (
 	kernelVersionPatchIdx = 2
 )
 
+var kernelVersionFieldsRegexp = regexp.MustCompile("[.,-]")
+
 type NodeOSConfig struct {
 	KernelFullVersion  string `subst:"KERNEL_FULL_VERSION"`
 	KernelVersionMMP   string `subst:"KERNEL_XYZ"`
 	KernelVersionMajor string `subst:"KERNEL_X"`
 	KernelVersionMinor string `subst:"KERNEL_Y"`
 	KernelVersionPatch string `subst:"KERNEL_Z"`
+	OSVersion          string `subst:"OS_VERSION"`
 }
 
 //go:generate mockgen -source=kernelmapper.go -package=module -destination=mock_kernelmapper.go
@@ -31,6 +35,7 @@ type NodeOSConfig struct {
 type KernelMapper interface {
 	FindMappingForKernel(mappings []kmmv1beta1.KernelMapping, kernelVersion string) (*kmmv1beta1.KernelMapping, error)
 	GetNodeOSConfig(node *v1.Node) *NodeOSConfig
+	GetOSConfigForKernel(kernelVersion string) (*NodeOSConfig, error)
 	PrepareKernelMapping(mapping *kmmv1beta1.KernelMapping, osConfig *NodeOSConfig) (*kmmv1beta1.KernelMapping, error)
 }
 
@@ -62,18 +67,29 @@ func (k *kernelMapper) FindMappingForKernel(mappings []kmmv1beta1.KernelMapping,
 	return nil, errors.New("no suitable mapping found")
 }
 
+// GetNodeOSConfig returns the NodeOSConfig of node. Its OS version is read from the label set by Node Feature
+// Discovery, if any.
 func (k *kernelMapper) GetNodeOSConfig(node *v1.Node) *NodeOSConfig {
-	osConfig := NodeOSConfig{}
+	osConfig := makeOSConfig(
+		node.Status.NodeInfo.KernelVersion,
+		kernelVersionFieldsRegexp.Split(node.Status.NodeInfo.KernelVersion, -1),
+	)
+
+	osConfig.OSVersion = node.Labels[constants.OSVersionLabel]
+
+	return osConfig
+}
 
-	osConfigFieldsList := regexp.MustCompile("[.,-]").Split(node.Status.NodeInfo.KernelVersion, -1)
+// GetOSConfigForKernel returns the NodeOSConfig that a node running kernelVersion would have.
+// It returns an error if kernelVersion does not start with major, minor and patch numbers.
+func (k *kernelMapper) GetOSConfigForKernel(kernelVersion string) (*NodeOSConfig, error) {
+	osConfigFieldsList := kernelVersionFieldsRegexp.Split(kernelVersion, -1)
 
-	osConfig.KernelFullVersion = node.Status.NodeInfo.KernelVersion
-	osConfig.KernelVersionMMP = strings.Join(osConfigFieldsList[:kernelVersionPatchIdx+1], ".")
-	osConfig.KernelVersionMajor = osConfigFieldsList[kernelVersionMajorIdx]
-	osConfig.KernelVersionMinor = osConfigFieldsList[kernelVersionMinorIdx]
-	osConfig.KernelVersionPatch = osConfigFieldsList[kernelVersionPatchIdx]
+	if len(osConfigFieldsList) <= kernelVersionPatchIdx {
+		return nil, fmt.Errorf("kernel version %q does not contain major, minor and patch numbers", kernelVersion)
+	}
 
-	return &osConfig
+	return makeOSConfig(kernelVersion, osConfigFieldsList), nil
 }
 
 func (k *kernelMapper) PrepareKernelMapping(mapping *kmmv1beta1.KernelMapping, osConfig *NodeOSConfig) (*kmmv1beta1.KernelMapping, error) {
@@ -86,12 +102,28 @@ func (k *kernelMapper) PrepareKernelMapping(mapping *kmmv1beta1.KernelMapping, o
 		return nil, fmt.Errorf("failed to substitute the os config into ContainerImage field: %w", err)
 	}
 
+	substDevicePluginImage, err := parser.Parse(mapping.DevicePluginImage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to substitute the os config into DevicePluginImage field: %w", err)
+	}
+
 	substMapping := mapping.DeepCopy()
 	substMapping.ContainerImage = substContainerImage
+	substMapping.DevicePluginImage = substDevicePluginImage
 
 	return substMapping, nil
 }
 
+func makeOSConfig(kernelVersion string, osConfigFieldsList []string) *NodeOSConfig {
+	return &NodeOSConfig{
+		KernelFullVersion:  kernelVersion,
+		KernelVersionMMP:   strings.Join(osConfigFieldsList[:kernelVersionPatchIdx+1], "."),
+		KernelVersionMajor: osConfigFieldsList[kernelVersionMajorIdx],
+		KernelVersionMinor: osConfigFieldsList[kernelVersionMinorIdx],
+		KernelVersionPatch: osConfigFieldsList[kernelVersionPatchIdx],
+	}
+}
+
 func (k *kernelMapper) prepareOSConfigList(osConfig NodeOSConfig) []string {
 	t := reflect.TypeOf(osConfig)
 	v := reflect.ValueOf(osConfig)
@@ -4,7 +4,9 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	"github.com/qbarrand/oot-operator/internal/constants"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 var _ = Describe("FindMappingForKernel", func() {
@@ -84,7 +86,7 @@ var _ = Describe("PrepareKernelMapping", func() {
 		Expect(err).To(HaveOccurred())
 	})
 
-	It("should only substitute the ContainerImage field", func() {
+	It("should only substitute the ContainerImage and DevicePluginImage fields", func() {
 		const (
 			dockerfile = "RUN echo $MYVAR"
 			literal    = "some literal:${KERNEL_XYZ"
@@ -92,9 +94,10 @@ var _ = Describe("PrepareKernelMapping", func() {
 		)
 
 		mapping := kmmv1beta1.KernelMapping{
-			ContainerImage: "some image:${KERNEL_XYZ}",
-			Literal:        literal,
-			Regexp:         regexp,
+			ContainerImage:    "some image:${KERNEL_XYZ}",
+			DevicePluginImage: "some device plugin:${KERNEL_X}",
+			Literal:           literal,
+			Regexp:            regexp,
 			Build: &kmmv1beta1.Build{
 				BuildArgs: []kmmv1beta1.BuildArg{
 					{Name: "name1", Value: "value1"},
@@ -104,9 +107,10 @@ var _ = Describe("PrepareKernelMapping", func() {
 			},
 		}
 		expectMapping := kmmv1beta1.KernelMapping{
-			ContainerImage: "some image:kernelMMP",
-			Literal:        literal,
-			Regexp:         regexp,
+			ContainerImage:    "some image:kernelMMP",
+			DevicePluginImage: "some device plugin:kernelMajor",
+			Literal:           literal,
+			Regexp:            regexp,
 			Build: &kmmv1beta1.Build{
 				BuildArgs: []kmmv1beta1.BuildArg{
 					{Name: "name1", Value: "value1"},
@@ -120,6 +124,14 @@ var _ = Describe("PrepareKernelMapping", func() {
 		Expect(err).NotTo(HaveOccurred())
 		Expect(*res).To(Equal(expectMapping))
 	})
+
+	It("should substitute the OS version", func() {
+		mapping := kmmv1beta1.KernelMapping{ContainerImage: "some image:${OS_VERSION}-${KERNEL_FULL_VERSION}"}
+
+		res, err := km.PrepareKernelMapping(&mapping, &NodeOSConfig{KernelFullVersion: "kernelFullVersion", OSVersion: "8.6"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.ContainerImage).To(Equal("some image:8.6-kernelFullVersion"))
+	})
 })
 
 var _ = Describe("GetNodeOSConfig", func() {
@@ -127,6 +139,9 @@ var _ = Describe("GetNodeOSConfig", func() {
 
 	It("parsing the node data", func() {
 		node := v1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{constants.OSVersionLabel: "8.4"},
+			},
 			Status: v1.NodeStatus{
 				NodeInfo: v1.NodeSystemInfo{
 					KernelVersion: "4.18.0-305.45.1.el8_4.x86_64",
@@ -141,9 +156,33 @@ var _ = Describe("GetNodeOSConfig", func() {
 			KernelVersionMajor: "4",
 			KernelVersionMinor: "18",
 			KernelVersionPatch: "0",
+			OSVersion:          "8.4",
 		}
 
 		res := km.GetNodeOSConfig(&node)
 		Expect(*res).To(Equal(expectedOSConfig))
 	})
 })
+
+var _ = Describe("GetOSConfigForKernel", func() {
+	km := NewKernelMapper()
+
+	It("parsing the kernel version", func() {
+		expectedOSConfig := NodeOSConfig{
+			KernelFullVersion:  "4.18.0-305.45.1.el8_4.x86_64",
+			KernelVersionMMP:   "4.18.0",
+			KernelVersionMajor: "4",
+			KernelVersionMinor: "18",
+			KernelVersionPatch: "0",
+		}
+
+		res, err := km.GetOSConfigForKernel("4.18.0-305.45.1.el8_4.x86_64")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*res).To(Equal(expectedOSConfig))
+	})
+
+	It("should return an error if the kernel version is too short", func() {
+		_, err := km.GetOSConfigForKernel("4.18")
+		Expect(err).To(HaveOccurred())
+	})
+})
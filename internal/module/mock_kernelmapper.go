@@ -64,6 +64,21 @@ func (mr *MockKernelMapperMockRecorder) GetNodeOSConfig(node interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNodeOSConfig", reflect.TypeOf((*MockKernelMapper)(nil).GetNodeOSConfig), node)
 }
 
+// GetOSConfigForKernel mocks base method.
+func (m *MockKernelMapper) GetOSConfigForKernel(kernelVersion string) (*NodeOSConfig, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOSConfigForKernel", kernelVersion)
+	ret0, _ := ret[0].(*NodeOSConfig)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOSConfigForKernel indicates an expected call of GetOSConfigForKernel.
+func (mr *MockKernelMapperMockRecorder) GetOSConfigForKernel(kernelVersion interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOSConfigForKernel", reflect.TypeOf((*MockKernelMapper)(nil).GetOSConfigForKernel), kernelVersion)
+}
+
 // PrepareKernelMapping mocks base method.
 func (m *MockKernelMapper) PrepareKernelMapping(mapping *v1beta1.KernelMapping, osConfig *NodeOSConfig) (*v1beta1.KernelMapping, error) {
 	m.ctrl.T.Helper()
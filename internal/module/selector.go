@@ -0,0 +1,85 @@
+package module
+
+import (
+	"fmt"
+
+	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// NodeSelector returns the selector matching the nodes targeted by spec.
+// An empty selector matches all nodes.
+func NodeSelector(spec *kmmv1beta1.ModuleSpec) (labels.Selector, error) {
+	ls := metav1.LabelSelector{
+		MatchLabels:      spec.Selector,
+		MatchExpressions: spec.SelectorExpressions,
+	}
+
+	sel, err := metav1.LabelSelectorAsSelector(&ls)
+	if err != nil {
+		return nil, fmt.Errorf("invalid node selector: %w", err)
+	}
+
+	return sel, nil
+}
+
+// NodeSelectorRequirements returns the node affinity requirements equivalent to the selector expressions of spec.
+// Pods must use them together with spec.Selector as their node selector.
+func NodeSelectorRequirements(spec *kmmv1beta1.ModuleSpec) []v1.NodeSelectorRequirement {
+	if len(spec.SelectorExpressions) == 0 {
+		return nil
+	}
+
+	reqs := make([]v1.NodeSelectorRequirement, 0, len(spec.SelectorExpressions))
+
+	for _, e := range spec.SelectorExpressions {
+		reqs = append(reqs, v1.NodeSelectorRequirement{
+			Key:      e.Key,
+			Operator: v1.NodeSelectorOperator(e.Operator),
+			Values:   e.Values,
+		})
+	}
+
+	return reqs
+}
+
+// NodeAffinity returns a copy of base in which all required node affinity terms also require expressions and fields.
+// A single term is created if base has none.
+func NodeAffinity(base *v1.Affinity, expressions, fields []v1.NodeSelectorRequirement) *v1.Affinity {
+	affinity := base.DeepCopy()
+
+	if len(expressions) == 0 && len(fields) == 0 {
+		return affinity
+	}
+
+	if affinity == nil {
+		affinity = &v1.Affinity{}
+	}
+
+	if affinity.NodeAffinity == nil {
+		affinity.NodeAffinity = &v1.NodeAffinity{}
+	}
+
+	na := affinity.NodeAffinity
+
+	if na.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		na.RequiredDuringSchedulingIgnoredDuringExecution = &v1.NodeSelector{}
+	}
+
+	required := na.RequiredDuringSchedulingIgnoredDuringExecution
+
+	if len(required.NodeSelectorTerms) == 0 {
+		required.NodeSelectorTerms = []v1.NodeSelectorTerm{{}}
+	}
+
+	// terms are ORed; requirements within a term are ANDed
+	for i := range required.NodeSelectorTerms {
+		t := &required.NodeSelectorTerms[i]
+		t.MatchExpressions = append(t.MatchExpressions, expressions...)
+		t.MatchFields = append(t.MatchFields, fields...)
+	}
+
+	return affinity
+}
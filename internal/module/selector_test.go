@@ -0,0 +1,152 @@
+package module
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+var _ = Describe("NodeSelector", func() {
+	spec := kmmv1beta1.ModuleSpec{
+		Selector: map[string]string{"node-role.kubernetes.io/worker": ""},
+		SelectorExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "gpu", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"none"}},
+		},
+	}
+
+	DescribeTable(
+		"should match labels and expressions",
+		func(nodeLabels map[string]string, expected bool) {
+			sel, err := NodeSelector(&spec)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sel.Matches(labels.Set(nodeLabels))).To(Equal(expected))
+		},
+		Entry("worker without gpu label", map[string]string{"node-role.kubernetes.io/worker": ""}, true),
+		Entry("worker with a gpu", map[string]string{"node-role.kubernetes.io/worker": "", "gpu": "a100"}, true),
+		Entry("worker without a gpu", map[string]string{"node-role.kubernetes.io/worker": "", "gpu": "none"}, false),
+		Entry("not a worker", map[string]string{"gpu": "a100"}, false),
+	)
+
+	It("should match all nodes if the selector is empty", func() {
+		sel, err := NodeSelector(&kmmv1beta1.ModuleSpec{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sel.Empty()).To(BeTrue())
+	})
+
+	It("should return an error for invalid expressions", func() {
+		invalid := kmmv1beta1.ModuleSpec{
+			SelectorExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "gpu", Operator: "Equals", Values: []string{"none"}},
+			},
+		}
+
+		_, err := NodeSelector(&invalid)
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("NodeSelectorRequirements", func() {
+	It("should return nil without expressions", func() {
+		Expect(NodeSelectorRequirements(&kmmv1beta1.ModuleSpec{})).To(BeNil())
+	})
+
+	It("should convert the expressions", func() {
+		spec := kmmv1beta1.ModuleSpec{
+			SelectorExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "a", Operator: metav1.LabelSelectorOpIn, Values: []string{"1", "2"}},
+				{Key: "b", Operator: metav1.LabelSelectorOpDoesNotExist},
+			},
+		}
+
+		Expect(NodeSelectorRequirements(&spec)).To(Equal([]v1.NodeSelectorRequirement{
+			{Key: "a", Operator: v1.NodeSelectorOpIn, Values: []string{"1", "2"}},
+			{Key: "b", Operator: v1.NodeSelectorOpDoesNotExist},
+		}))
+	})
+})
+
+var _ = Describe("NodeAffinity", func() {
+	expressions := []v1.NodeSelectorRequirement{
+		{Key: "gpu", Operator: v1.NodeSelectorOpExists},
+	}
+
+	fields := []v1.NodeSelectorRequirement{
+		{Key: "metadata.name", Operator: v1.NodeSelectorOpNotIn, Values: []string{"node1"}},
+	}
+
+	It("should return nil without a base affinity or requirements", func() {
+		Expect(NodeAffinity(nil, nil, nil)).To(BeNil())
+	})
+
+	It("should return a copy of the base affinity without requirements", func() {
+		base := &v1.Affinity{
+			PodAntiAffinity: &v1.PodAntiAffinity{},
+		}
+
+		res := NodeAffinity(base, nil, nil)
+		Expect(res).To(Equal(base))
+		Expect(res).NotTo(BeIdenticalTo(base))
+	})
+
+	It("should create a single term without a base affinity", func() {
+		Expect(
+			NodeAffinity(nil, expressions, fields),
+		).To(
+			Equal(&v1.Affinity{
+				NodeAffinity: &v1.NodeAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{
+						NodeSelectorTerms: []v1.NodeSelectorTerm{
+							{MatchExpressions: expressions, MatchFields: fields},
+						},
+					},
+				},
+			}),
+		)
+	})
+
+	It("should add the requirements to all required terms and keep the rest of the base affinity", func() {
+		zoneA := v1.NodeSelectorRequirement{Key: "zone", Operator: v1.NodeSelectorOpIn, Values: []string{"a"}}
+		zoneB := v1.NodeSelectorRequirement{Key: "zone", Operator: v1.NodeSelectorOpIn, Values: []string{"b"}}
+
+		preferred := []v1.PreferredSchedulingTerm{
+			{
+				Weight:     1,
+				Preference: v1.NodeSelectorTerm{MatchExpressions: []v1.NodeSelectorRequirement{zoneA}},
+			},
+		}
+
+		base := &v1.Affinity{
+			NodeAffinity: &v1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{
+					NodeSelectorTerms: []v1.NodeSelectorTerm{
+						{MatchExpressions: []v1.NodeSelectorRequirement{zoneA}},
+						{MatchExpressions: []v1.NodeSelectorRequirement{zoneB}},
+					},
+				},
+				PreferredDuringSchedulingIgnoredDuringExecution: preferred,
+			},
+		}
+
+		Expect(
+			NodeAffinity(base, expressions, fields),
+		).To(
+			Equal(&v1.Affinity{
+				NodeAffinity: &v1.NodeAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{
+						NodeSelectorTerms: []v1.NodeSelectorTerm{
+							{MatchExpressions: append([]v1.NodeSelectorRequirement{zoneA}, expressions...), MatchFields: fields},
+							{MatchExpressions: append([]v1.NodeSelectorRequirement{zoneB}, expressions...), MatchFields: fields},
+						},
+					},
+					PreferredDuringSchedulingIgnoredDuringExecution: preferred,
+				},
+			}),
+		)
+
+		// the base affinity must not be modified
+		Expect(base.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchExpressions).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,51 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: nodemarker.go
+
+// Package nodemarker is a generated GoMock package.
+package nodemarker
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	v1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	sets "k8s.io/apimachinery/pkg/util/sets"
+)
+
+// MockNodeMarker is a mock of NodeMarker interface.
+type MockNodeMarker struct {
+	ctrl     *gomock.Controller
+	recorder *MockNodeMarkerMockRecorder
+}
+
+// MockNodeMarkerMockRecorder is the mock recorder for MockNodeMarker.
+type MockNodeMarkerMockRecorder struct {
+	mock *MockNodeMarker
+}
+
+// NewMockNodeMarker creates a new mock instance.
+func NewMockNodeMarker(ctrl *gomock.Controller) *MockNodeMarker {
+	mock := &MockNodeMarker{ctrl: ctrl}
+	mock.recorder = &MockNodeMarkerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockNodeMarker) EXPECT() *MockNodeMarkerMockRecorder {
+	return m.recorder
+}
+
+// SyncUnmappedNodes mocks base method.
+func (m *MockNodeMarker) SyncUnmappedNodes(ctx context.Context, moduleName, namespace string, spec *v1beta1.UnmappedNodesSpec, unmapped sets.String) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SyncUnmappedNodes", ctx, moduleName, namespace, spec, unmapped)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SyncUnmappedNodes indicates an expected call of SyncUnmappedNodes.
+func (mr *MockNodeMarkerMockRecorder) SyncUnmappedNodes(ctx, moduleName, namespace, spec, unmapped interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SyncUnmappedNodes", reflect.TypeOf((*MockNodeMarker)(nil).SyncUnmappedNodes), ctx, moduleName, namespace, spec, unmapped)
+}
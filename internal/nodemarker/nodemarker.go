@@ -0,0 +1,158 @@
+package nodemarker
+
+import (
+	"context"
+	"fmt"
+
+	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+//go:generate mockgen -source=nodemarker.go -package=nodemarker -destination=mock_nodemarker.go
+
+type NodeMarker interface {
+	SyncUnmappedNodes(ctx context.Context, moduleName, namespace string, spec *kmmv1beta1.UnmappedNodesSpec, unmapped sets.String) error
+}
+
+type nodeMarker struct {
+	client client.Client
+}
+
+func NewNodeMarker(client client.Client) NodeMarker {
+	return &nodeMarker{client: client}
+}
+
+// GetUnmappedNodeKey returns the key of the label and taint set on nodes whose kernel matches none of the kernel
+// mappings of moduleName.
+func GetUnmappedNodeKey(moduleName string) string {
+	return fmt.Sprintf("kmm.node.kubernetes.io/%s.unmapped", moduleName)
+}
+
+// SyncUnmappedNodes makes sure that the nodes in unmapped, and only them, carry the markers requested by spec for the
+// Module moduleName in namespace.
+// Markers are removed from all nodes if spec is nil, for example once the Module was deleted.
+// Markers use the same key for all Modules with the same name: they are only added, and never removed, while such a
+// Module exists in another namespace.
+func (nm *nodeMarker) SyncUnmappedNodes(ctx context.Context, moduleName, namespace string, spec *kmmv1beta1.UnmappedNodesSpec, unmapped sets.String) error {
+	if spec == nil {
+		spec = &kmmv1beta1.UnmappedNodesSpec{}
+	}
+
+	keep, err := nm.sameNameModuleExists(ctx, moduleName, namespace)
+	if err != nil {
+		return fmt.Errorf("could not look for Modules named %s in other namespaces: %w", moduleName, err)
+	}
+
+	nodes := v1.NodeList{}
+
+	if err := nm.client.List(ctx, &nodes); err != nil {
+		return fmt.Errorf("could not list nodes: %w", err)
+	}
+
+	key := GetUnmappedNodeKey(moduleName)
+
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		isUnmapped := unmapped.Has(node.Name)
+
+		nodeCopy := node.DeepCopy()
+
+		changed := false
+
+		if wantLabel := isUnmapped && spec.Label; wantLabel || !keep {
+			changed = setLabel(node, key, wantLabel)
+		}
+
+		if wantTaint := isUnmapped && spec.TaintEffect != ""; (wantTaint || !keep) && setTaint(node, key, isUnmapped, spec.TaintEffect) {
+			changed = true
+		}
+
+		if !changed {
+			continue
+		}
+
+		log.FromContext(ctx).Info("Updating unmapped node markers", "node", node.Name, "unmapped", isUnmapped)
+
+		if err := nm.client.Patch(ctx, node, client.MergeFrom(nodeCopy)); err != nil {
+			return fmt.Errorf("could not patch node %s: %w", node.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// sameNameModuleExists returns true if a Module named moduleName exists in another namespace than namespace.
+func (nm *nodeMarker) sameNameModuleExists(ctx context.Context, moduleName, namespace string) (bool, error) {
+	mods := kmmv1beta1.ModuleList{}
+
+	if err := nm.client.List(ctx, &mods); err != nil {
+		return false, fmt.Errorf("could not list Modules: %w", err)
+	}
+
+	for _, mod := range mods.Items {
+		if mod.Name == moduleName && mod.Namespace != namespace {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// setLabel adds or removes the key label and returns true if the labels of node were modified.
+func setLabel(node *v1.Node, key string, present bool) bool {
+	_, ok := node.Labels[key]
+
+	switch {
+	case present && !ok:
+		if node.Labels == nil {
+			node.Labels = make(map[string]string, 1)
+		}
+
+		node.Labels[key] = ""
+	case !present && ok:
+		delete(node.Labels, key)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// setTaint makes sure that node only has a key taint with effect if unmapped is true and effect is not empty, and
+// returns true if the taints of node were modified.
+func setTaint(node *v1.Node, key string, unmapped bool, effect v1.TaintEffect) bool {
+	wanted := unmapped && effect != ""
+
+	taints := make([]v1.Taint, 0, len(node.Spec.Taints)+1)
+	found := false
+	changed := false
+
+	for _, t := range node.Spec.Taints {
+		if t.Key != key {
+			taints = append(taints, t)
+			continue
+		}
+
+		if wanted && !found && t.Effect == effect {
+			found = true
+			taints = append(taints, t)
+			continue
+		}
+
+		changed = true
+	}
+
+	if wanted && !found {
+		taints = append(taints, v1.Taint{Key: key, Effect: effect})
+		changed = true
+	}
+
+	if changed {
+		node.Spec.Taints = taints
+	}
+
+	return changed
+}
@@ -0,0 +1,227 @@
+package nodemarker
+
+import (
+	"context"
+	"errors"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	"github.com/qbarrand/oot-operator/internal/client"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("GetUnmappedNodeKey", func() {
+	It("should work as expected", func() {
+		Expect(GetUnmappedNodeKey("module-name")).To(Equal("kmm.node.kubernetes.io/module-name.unmapped"))
+	})
+})
+
+var _ = Describe("SyncUnmappedNodes", func() {
+	const (
+		moduleName = "module-name"
+		namespace  = "namespace"
+	)
+
+	var (
+		ctx  context.Context
+		clnt *client.MockClient
+		nm   NodeMarker
+	)
+
+	key := GetUnmappedNodeKey(moduleName)
+
+	BeforeEach(func() {
+		ctrl := gomock.NewController(GinkgoT())
+		clnt = client.NewMockClient(ctrl)
+		nm = NewNodeMarker(clnt)
+		ctx = context.Background()
+	})
+
+	listModules := func(mods ...kmmv1beta1.Module) *gomock.Call {
+		return clnt.EXPECT().List(ctx, gomock.AssignableToTypeOf(&kmmv1beta1.ModuleList{})).DoAndReturn(
+			func(_ interface{}, list *kmmv1beta1.ModuleList, _ ...interface{}) error {
+				list.Items = mods
+				return nil
+			},
+		)
+	}
+
+	listNodes := func(nodes ...v1.Node) *gomock.Call {
+		return clnt.EXPECT().List(ctx, gomock.AssignableToTypeOf(&v1.NodeList{})).DoAndReturn(
+			func(_ interface{}, list *v1.NodeList, _ ...interface{}) error {
+				list.Items = nodes
+				return nil
+			},
+		)
+	}
+
+	expectPatch := func(nodeName, data string) *gomock.Call {
+		return clnt.EXPECT().Patch(ctx, gomock.Any(), gomock.Any()).Do(
+			func(_ context.Context, n ctrlclient.Object, p ctrlclient.Patch, _ ...ctrlclient.PatchOption) {
+				Expect(n.GetName()).To(Equal(nodeName))
+				Expect(p.Type()).To(Equal(types.MergePatchType))
+				Expect(p.Data(n)).To(MatchJSON(data))
+			},
+		)
+	}
+
+	It("should return an error if the Modules cannot be listed", func() {
+		listModules().Return(errors.New("random error"))
+
+		Expect(
+			nm.SyncUnmappedNodes(ctx, moduleName, namespace, nil, sets.NewString()),
+		).To(
+			HaveOccurred(),
+		)
+	})
+
+	It("should return an error if the nodes cannot be listed", func() {
+		gomock.InOrder(
+			listModules(),
+			listNodes().Return(errors.New("random error")),
+		)
+
+		Expect(
+			nm.SyncUnmappedNodes(ctx, moduleName, namespace, nil, sets.NewString()),
+		).To(
+			HaveOccurred(),
+		)
+	})
+
+	It("should label and taint unmapped nodes only", func() {
+		spec := &kmmv1beta1.UnmappedNodesSpec{Label: true, TaintEffect: v1.TaintEffectNoSchedule}
+
+		gomock.InOrder(
+			listModules(),
+			listNodes(
+				v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "unmapped"}},
+				v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "mapped"}},
+			),
+			expectPatch(
+				"unmapped",
+				`{"metadata":{"labels":{"`+key+`":""}},"spec":{"taints":[{"effect":"NoSchedule","key":"`+key+`"}]}}`,
+			),
+		)
+
+		Expect(
+			nm.SyncUnmappedNodes(ctx, moduleName, namespace, spec, sets.NewString("unmapped")),
+		).NotTo(
+			HaveOccurred(),
+		)
+	})
+
+	It("should not patch nodes that are already marked", func() {
+		spec := &kmmv1beta1.UnmappedNodesSpec{Label: true, TaintEffect: v1.TaintEffectNoSchedule}
+
+		gomock.InOrder(
+			listModules(),
+			listNodes(
+				v1.Node{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:   "unmapped",
+						Labels: map[string]string{key: ""},
+					},
+					Spec: v1.NodeSpec{
+						Taints: []v1.Taint{{Key: key, Effect: v1.TaintEffectNoSchedule}},
+					},
+				},
+			),
+		)
+
+		Expect(
+			nm.SyncUnmappedNodes(ctx, moduleName, namespace, spec, sets.NewString("unmapped")),
+		).NotTo(
+			HaveOccurred(),
+		)
+	})
+
+	It("should replace the taint if its effect changed", func() {
+		spec := &kmmv1beta1.UnmappedNodesSpec{TaintEffect: v1.TaintEffectNoExecute}
+
+		gomock.InOrder(
+			listModules(),
+			listNodes(
+				v1.Node{
+					ObjectMeta: metav1.ObjectMeta{Name: "unmapped"},
+					Spec: v1.NodeSpec{
+						Taints: []v1.Taint{
+							{Key: "other", Effect: v1.TaintEffectNoSchedule},
+							{Key: key, Effect: v1.TaintEffectNoSchedule},
+						},
+					},
+				},
+			),
+			expectPatch(
+				"unmapped",
+				`{"spec":{"taints":[{"effect":"NoSchedule","key":"other"},{"effect":"NoExecute","key":"`+key+`"}]}}`,
+			),
+		)
+
+		Expect(
+			nm.SyncUnmappedNodes(ctx, moduleName, namespace, spec, sets.NewString("unmapped")),
+		).NotTo(
+			HaveOccurred(),
+		)
+	})
+
+	It("should remove markers from all nodes if spec is nil", func() {
+		gomock.InOrder(
+			listModules(),
+			listNodes(
+				v1.Node{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:   "marked",
+						Labels: map[string]string{key: "", "other": ""},
+					},
+					Spec: v1.NodeSpec{
+						Taints: []v1.Taint{{Key: key, Effect: v1.TaintEffectNoSchedule}},
+					},
+				},
+				v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "not-marked"}},
+			),
+			expectPatch(
+				"marked",
+				`{"metadata":{"labels":{"`+key+`":null}},"spec":{"taints":null}}`,
+			),
+		)
+
+		Expect(
+			nm.SyncUnmappedNodes(ctx, moduleName, namespace, nil, sets.NewString("marked")),
+		).NotTo(
+			HaveOccurred(),
+		)
+	})
+
+	It("should only add markers while a Module with the same name exists in another namespace", func() {
+		spec := &kmmv1beta1.UnmappedNodesSpec{Label: true}
+
+		gomock.InOrder(
+			listModules(
+				kmmv1beta1.Module{ObjectMeta: metav1.ObjectMeta{Name: moduleName, Namespace: namespace}},
+				kmmv1beta1.Module{ObjectMeta: metav1.ObjectMeta{Name: moduleName, Namespace: "other-namespace"}},
+			),
+			listNodes(
+				v1.Node{
+					ObjectMeta: metav1.ObjectMeta{Name: "marked-by-other", Labels: map[string]string{key: ""}},
+					Spec: v1.NodeSpec{
+						Taints: []v1.Taint{{Key: key, Effect: v1.TaintEffectNoSchedule}},
+					},
+				},
+				v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "unmapped"}},
+			),
+			expectPatch("unmapped", `{"metadata":{"labels":{"`+key+`":""}}}`),
+		)
+
+		Expect(
+			nm.SyncUnmappedNodes(ctx, moduleName, namespace, spec, sets.NewString("unmapped")),
+		).NotTo(
+			HaveOccurred(),
+		)
+	})
+})
@@ -35,17 +35,31 @@ func (m *MockPreflightAPI) EXPECT() *MockPreflightAPIMockRecorder {
 	return m.recorder
 }
 
-// PreflightUpgradeCheck mocks base method.
-func (m *MockPreflightAPI) PreflightUpgradeCheck(ctx context.Context, mod *v1beta1.Module, kernelVersion string) (bool, string) {
+// GetKernelVersions mocks base method.
+func (m *MockPreflightAPI) GetKernelVersions(ctx context.Context, pv *v1beta1.PreflightValidation) ([]Kernel, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "PreflightUpgradeCheck", ctx, mod, kernelVersion)
-	ret0, _ := ret[0].(bool)
-	ret1, _ := ret[1].(string)
+	ret := m.ctrl.Call(m, "GetKernelVersions", ctx, pv)
+	ret0, _ := ret[0].([]Kernel)
+	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
+// GetKernelVersions indicates an expected call of GetKernelVersions.
+func (mr *MockPreflightAPIMockRecorder) GetKernelVersions(ctx, pv interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetKernelVersions", reflect.TypeOf((*MockPreflightAPI)(nil).GetKernelVersions), ctx, pv)
+}
+
+// PreflightUpgradeCheck mocks base method.
+func (m *MockPreflightAPI) PreflightUpgradeCheck(ctx context.Context, pv *v1beta1.PreflightValidation, mod *v1beta1.Module, kernels []Kernel) Result {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PreflightUpgradeCheck", ctx, pv, mod, kernels)
+	ret0, _ := ret[0].(Result)
+	return ret0
+}
+
 // PreflightUpgradeCheck indicates an expected call of PreflightUpgradeCheck.
-func (mr *MockPreflightAPIMockRecorder) PreflightUpgradeCheck(ctx, mod, kernelVersion interface{}) *gomock.Call {
+func (mr *MockPreflightAPIMockRecorder) PreflightUpgradeCheck(ctx, pv, mod, kernels interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PreflightUpgradeCheck", reflect.TypeOf((*MockPreflightAPI)(nil).PreflightUpgradeCheck), ctx, mod, kernelVersion)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PreflightUpgradeCheck", reflect.TypeOf((*MockPreflightAPI)(nil).PreflightUpgradeCheck), ctx, pv, mod, kernels)
 }
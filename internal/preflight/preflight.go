@@ -2,14 +2,23 @@ package preflight
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 
 	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
 	"github.com/qbarrand/oot-operator/internal/auth"
+	"github.com/qbarrand/oot-operator/internal/build"
+	"github.com/qbarrand/oot-operator/internal/config"
 	"github.com/qbarrand/oot-operator/internal/module"
 	"github.com/qbarrand/oot-operator/internal/registry"
+	"github.com/qbarrand/oot-operator/internal/tracing"
 
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/cache"
 	ctrlruntime "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -19,47 +28,286 @@ const (
 	VerificationStatusReasonNoDaemonSet        = "Verification successful, no driver-container present in the recipe"
 	VerificationStatusReasonUnknown            = "Verification has not started yet"
 	VerificationStatusReasonVerified           = "Verification successful, this Module would be verified again in this Preflight CR"
+	VerificationStatusReasonBuildVerified      = "Verification successful, the kernel module compiles for this kernel"
+	VerificationStatusReasonBuildPushed        = "Verification successful, the kernel module compiles for this kernel and the image was pushed"
+	VerificationStatusReasonBuildInProgress    = "Waiting for build verification"
+
+	// Layers are immutable and identified by their digest, so results of their scans can be kept for a long time.
+	scannedLayersCacheSize = 4096
+
+	// Each release image ships a single driver-toolkit image; few release images are checked at the same time.
+	driverToolkitsCacheSize = 64
 )
 
 //go:generate mockgen -source=preflight.go -package=preflight -destination=mock_preflight_api.go
 
 type PreflightAPI interface {
-	PreflightUpgradeCheck(ctx context.Context, mod *kmmv1beta1.Module, kernelVersion string) (bool, string)
+	GetKernelVersions(ctx context.Context, pv *kmmv1beta1.PreflightValidation) ([]Kernel, error)
+	PreflightUpgradeCheck(ctx context.Context, pv *kmmv1beta1.PreflightValidation, mod *kmmv1beta1.Module, kernels []Kernel) Result
+}
+
+// Kernel is a kernel that Modules are checked against.
+type Kernel struct {
+	// Version is the full kernel version.
+	Version string
+
+	// OSVersion is the version of the OS shipping the kernel, if known.
+	OSVersion string
+}
+
+// Result is the outcome of the preflight verification of a Module.
+type Result struct {
+	// Verified is true if the Module was verified.
+	Verified bool
+
+	// Message describes the verification result.
+	Message string
+
+	// Stage is the verification stage that the Module reached.
+	Stage string
+
+	// Requeue is true if the verification is not over yet and should be run again later.
+	Requeue bool
+
+	// CheckedImages lists the images that were looked up during the image stage.
+	CheckedImages []kmmv1beta1.CheckedImage
 }
 
 func NewPreflightAPI(
 	client client.Client,
+	buildAPI build.Manager,
 	registryAPI registry.Registry,
-	kernelAPI module.KernelMapper) PreflightAPI {
+	kernelAPI module.KernelMapper,
+	cfgProvider config.Provider,
+	tracer trace.Tracer) PreflightAPI {
 	return &preflight{
-		registryAPI: registryAPI,
-		kernelAPI:   kernelAPI,
-		client:      client,
+		buildAPI:       buildAPI,
+		registryAPI:    registryAPI,
+		kernelAPI:      kernelAPI,
+		client:         client,
+		cfgProvider:    cfgProvider,
+		tracer:         tracer,
+		scannedLayers:  cache.NewLRUExpireCache(scannedLayersCacheSize),
+		driverToolkits: cache.NewLRUExpireCache(driverToolkitsCacheSize),
 	}
 }
 
 type preflight struct {
 	client      client.Client
+	buildAPI    build.Manager
 	registryAPI registry.Registry
 	kernelAPI   module.KernelMapper
+	cfgProvider config.Provider
+	tracer      trace.Tracer
+
+	// scannedLayers caches whether a file was found in a layer, so that layers are not pulled again for the same
+	// file.
+	scannedLayers *cache.LRUExpireCache
+
+	// driverToolkits caches the driver-toolkit entries of release images by release image digest, so that the
+	// release and driver-toolkit images are not pulled again on each verification.
+	driverToolkits *cache.LRUExpireCache
+}
+
+// GetKernelVersions returns the kernel versions that Modules need to be checked against: either the kernel version
+// of the PreflightValidation, or the kernel versions shipped with its release image.
+func (p *preflight) GetKernelVersions(ctx context.Context, pv *kmmv1beta1.PreflightValidation) ([]Kernel, error) {
+	switch {
+	case pv.Spec.KernelVersion != "" && pv.Spec.ReleaseImage != "":
+		return nil, errors.New("only one of kernelVersion and releaseImage can be set")
+	case pv.Spec.KernelVersion != "":
+		return []Kernel{{Version: pv.Spec.KernelVersion}}, nil
+	case pv.Spec.ReleaseImage == "":
+		return nil, errors.New("one of kernelVersion and releaseImage must be set")
+	}
+
+	var registryAuthGetter auth.RegistryAuthGetter
+	if s := pv.Spec.ReleaseImagePullSecret; s != nil {
+		registryAuthGetter = auth.NewRegistryAuthGetter(p.client, types.NamespacedName{Name: s.Name, Namespace: s.Namespace})
+	}
+
+	dtk, err := p.getDriverToolkitEntry(ctx, pv.Spec.ReleaseImage, registryAuthGetter)
+	if err != nil {
+		return nil, err
+	}
+
+	kernels := []Kernel{{Version: dtk.KernelFullVersion, OSVersion: dtk.OSVersion}}
+	if dtk.RTKernelFullVersion != "" {
+		kernels = append(kernels, Kernel{Version: dtk.RTKernelFullVersion, OSVersion: dtk.OSVersion})
+	}
+
+	return kernels, nil
+}
+
+// getDriverToolkitEntry returns the driver-toolkit entry of releaseImage.
+// Release images are identified by their digest; the entry is only looked up in their layers and in the layers of
+// their driver-toolkit image if it is not cached for that digest yet.
+func (p *preflight) getDriverToolkitEntry(ctx context.Context, releaseImage string, registryAuthGetter auth.RegistryAuthGetter) (*registry.DriverToolkitEntry, error) {
+	log := ctrlruntime.LoggerFrom(ctx)
+
+	digests, repoConfig, err := p.registryAPI.GetLayersDigests(ctx, releaseImage, registryAuthGetter)
+	if err != nil {
+		return nil, fmt.Errorf("could not get the layers of release image %s: %w", releaseImage, err)
+	}
+
+	if dtk, ok := p.driverToolkits.Get(repoConfig.ImageDigest); ok {
+		log.V(1).Info("Using the cached driver-toolkit entry", "release image", releaseImage, "digest", repoConfig.ImageDigest)
+		return dtk.(*registry.DriverToolkitEntry), nil
+	}
+
+	var dtkImage string
+
+	err = p.findInLayers(ctx, releaseImage, digests, repoConfig, func(layer v1.Layer) (err error) {
+		dtkImage, err = p.registryAPI.ReleaseManifests(ctx, layer)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not find the driver-toolkit image in release image %s: %w", releaseImage, err)
+	}
+
+	var dtk *registry.DriverToolkitEntry
+
+	err = p.findInImageLayers(ctx, dtkImage, registryAuthGetter, func(layer v1.Layer) (err error) {
+		dtk, err = p.registryAPI.ExtractToolkitRelease(ctx, layer)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not find the kernel versions in driver-toolkit image %s: %w", dtkImage, err)
+	}
+
+	log.Info(
+		"Found kernel versions in release image",
+		"release image", releaseImage,
+		"driver-toolkit image", dtkImage,
+		"kernel version", dtk.KernelFullVersion,
+		"RT kernel version", dtk.RTKernelFullVersion,
+	)
+
+	if repoConfig.ImageDigest != "" {
+		p.driverToolkits.Add(repoConfig.ImageDigest, dtk, p.cfgProvider.Current().RegistryCacheTTL.Duration)
+	}
+
+	return dtk, nil
 }
 
-func (p *preflight) PreflightUpgradeCheck(ctx context.Context, mod *kmmv1beta1.Module, kernelVersion string) (bool, string) {
+// PreflightUpgradeCheck checks mod against all kernels. The Module is verified if it is verified for each of
+// them.
+// PreflightUpgradeCheck does not modify pv, so that Modules can be checked concurrently.
+func (p *preflight) PreflightUpgradeCheck(ctx context.Context, pv *kmmv1beta1.PreflightValidation, mod *kmmv1beta1.Module, kernels []Kernel) Result {
+	if len(kernels) == 1 {
+		return p.checkKernel(ctx, pv, mod, kernels[0])
+	}
+
+	res := Result{Verified: true, Stage: kmmv1beta1.VerificationStageImage}
+	messages := make([]string, 0, len(kernels))
+
+	for _, kernel := range kernels {
+		kernelRes := p.checkKernel(ctx, pv, mod, kernel)
+
+		res.Verified = res.Verified && kernelRes.Verified
+		res.Requeue = res.Requeue || kernelRes.Requeue
+		res.CheckedImages = append(res.CheckedImages, kernelRes.CheckedImages...)
+
+		if kernelRes.Stage == kmmv1beta1.VerificationStageBuild {
+			res.Stage = kmmv1beta1.VerificationStageBuild
+		}
+
+		messages = append(messages, fmt.Sprintf("kernel %s: %s", kernel.Version, kernelRes.Message))
+	}
+
+	res.Message = strings.Join(messages, "; ")
+
+	return res
+}
+
+func (p *preflight) checkKernel(ctx context.Context, pv *kmmv1beta1.PreflightValidation, mod *kmmv1beta1.Module, kernel Kernel) Result {
+	log := ctrlruntime.LoggerFrom(ctx)
+	kernelVersion := kernel.Version
+
+	imageStageFailure := func(message string) Result {
+		return Result{Message: message, Stage: kmmv1beta1.VerificationStageImage}
+	}
+
 	mapping, err := p.kernelAPI.FindMappingForKernel(mod.Spec.ModuleLoader.Container.KernelMappings, kernelVersion)
 	if err != nil {
-		return false, fmt.Sprintf("Failed to find kernel mapping in the module %s for kernel version %s", mod.Name, kernelVersion)
+		return imageStageFailure(
+			fmt.Sprintf("Failed to find kernel mapping in the module %s for kernel version %s", mod.Name, kernelVersion),
+		)
+	}
+
+	osConfig, err := p.kernelAPI.GetOSConfigForKernel(kernelVersion)
+	if err != nil {
+		return imageStageFailure(
+			fmt.Sprintf("Failed to parse kernel version %s: %v", kernelVersion, err),
+		)
+	}
+
+	// a node running this kernel would have the OS version of the driver-toolkit image as well
+	osConfig.OSVersion = kernel.OSVersion
+
+	mapping, err = p.kernelAPI.PrepareKernelMapping(mapping, osConfig)
+	if err != nil {
+		return imageStageFailure(
+			fmt.Sprintf("Failed to substitute template in kernel mapping in the module %s for kernel version %s", mod.Name, kernelVersion),
+		)
+	}
+
+	if !shouldBeBuilt(mod, mapping) {
+		return p.verifyImage(ctx, mapping, mod, kernelVersion)
+	}
+
+	// Once the build stage is reached for a kernel, its image is known not to be usable as-is; only the build is
+	// checked again.
+	checkedImages := buildStageImages(pv, CRStatusKey(mod), kernelVersion)
+	if checkedImages == nil {
+		res := p.verifyImage(ctx, mapping, mod, kernelVersion)
+		if res.Verified {
+			return res
+		}
+
+		log.Info("image verification failed; verifying the build", "module name", mod.Name, "reason", res.Message)
+
+		checkedImages = res.CheckedImages
 	}
 
-	osConfig := module.NodeOSConfig{KernelFullVersion: kernelVersion}
-	mapping, err = p.kernelAPI.PrepareKernelMapping(mapping, &osConfig)
+	res := p.verifyBuild(ctx, pv, mapping, mod, kernelVersion)
+
+	// the images that were not verified tell the next verification that this kernel is in the build stage
+	res.CheckedImages = checkedImages
+
+	return res
+}
+
+// findInImageLayers calls fn on the layers of image, starting from the topmost one, until fn returns no error.
+func (p *preflight) findInImageLayers(ctx context.Context, image string, registryAuthGetter auth.RegistryAuthGetter, fn func(v1.Layer) error) error {
+	digests, repoConfig, err := p.registryAPI.GetLayersDigests(ctx, image, registryAuthGetter)
 	if err != nil {
-		return false, fmt.Sprintf("Failed to substitute template in kernel mapping in the module %s for kernel version %s", mod.Name, kernelVersion)
+		return fmt.Errorf("could not get the layers of image %s: %w", image, err)
 	}
 
-	return p.verifyImage(ctx, mapping, mod, kernelVersion)
+	return p.findInLayers(ctx, image, digests, repoConfig, fn)
 }
 
-func (p *preflight) verifyImage(ctx context.Context, mapping *kmmv1beta1.KernelMapping, mod *kmmv1beta1.Module, kernelVersion string) (bool, string) {
+// findInLayers calls fn on the layers of image identified by digests, starting from the topmost one, until fn returns
+// no error.
+func (p *preflight) findInLayers(ctx context.Context, image string, digests []string, repoConfig *registry.RepoPullConfig, fn func(v1.Layer) error) error {
+	for i := len(digests) - 1; i >= 0; i-- {
+		layer, err := p.registryAPI.GetLayerByDigest(digests[i], repoConfig)
+		if err != nil {
+			return fmt.Errorf("could not get layer %s of image %s: %w", digests[i], image, err)
+		}
+
+		if err = fn(layer); err == nil {
+			return nil
+		}
+
+		ctrlruntime.LoggerFrom(ctx).V(1).Info("not found in the current layer", "image", image, "layer", digests[i], "error", err)
+	}
+
+	return fmt.Errorf("not found in any layer of image %s", image)
+}
+
+func (p *preflight) verifyImage(ctx context.Context, mapping *kmmv1beta1.KernelMapping, mod *kmmv1beta1.Module, kernelVersion string) Result {
 	log := ctrlruntime.LoggerFrom(ctx)
 	image := mapping.ContainerImage
 	moduleName := mod.Spec.ModuleLoader.Container.Modprobe.ModuleName
@@ -74,26 +322,141 @@ func (p *preflight) verifyImage(ctx context.Context, mapping *kmmv1beta1.KernelM
 		registryAuthGetter = auth.NewRegistryAuthGetter(p.client, namespacedName)
 	}
 
+	checkedImage := kmmv1beta1.CheckedImage{KernelVersion: kernelVersion, Image: image}
+
+	result := func(verified bool, message string) Result {
+		return Result{
+			Verified:      verified,
+			Message:       message,
+			Stage:         kmmv1beta1.VerificationStageImage,
+			CheckedImages: []kmmv1beta1.CheckedImage{checkedImage},
+		}
+	}
+
 	digests, repoConfig, err := p.registryAPI.GetLayersDigests(ctx, image, registryAuthGetter)
 	if err != nil {
 		log.Info("image layers inaccessible, image probably does not exists", "module name", mod.Name, "image", image)
-		return false, fmt.Sprintf("image %s inaccessible or does not exists", image)
+		return result(false, fmt.Sprintf("image %s inaccessible or does not exists", image))
 	}
 
+	checkedImage.Digest = repoConfig.ImageDigest
+
 	for i := len(digests) - 1; i >= 0; i-- {
-		layer, err := p.registryAPI.GetLayerByDigest(digests[i], repoConfig)
-		if err != nil {
-			log.Info("layer from image inaccessible", "layer", digests[i], "repo", repoConfig, "image", image)
-			return false, fmt.Sprintf("image %s, layer %s is inaccessible", image, digests[i])
+		cacheKey := strings.Join([]string{digests[i], baseDir, kernelVersion, moduleName}, ":")
+
+		found, scanned := p.scannedLayers.Get(cacheKey)
+		if !scanned {
+			var err error
+
+			found, err = p.scanLayer(ctx, mod, image, digests[i], repoConfig, baseDir, kernelVersion, moduleName)
+			if err != nil {
+				log.Info("layer from image inaccessible", "layer", digests[i], "repo", repoConfig, "image", image)
+				return result(false, fmt.Sprintf("image %s, layer %s is inaccessible", image, digests[i]))
+			}
+
+			// do not cache the result of a scan interrupted by a timeout
+			if ctx.Err() == nil {
+				p.scannedLayers.Add(cacheKey, found, p.cfgProvider.Current().RegistryCacheTTL.Duration)
+			}
 		}
 
-		// check kernel module file present in the directory of the kernel lib modules
-		if p.registryAPI.VerifyModuleExists(layer, baseDir, kernelVersion, moduleName) {
-			return true, VerificationStatusReasonVerified
+		if found.(bool) {
+			checkedImage.Layer = digests[i]
+			return result(true, VerificationStatusReasonVerified)
 		}
 		log.V(1).Info("module is not present in the current layer", "image", image, "module name", moduleName, "kernel", kernelVersion, "dir", baseDir)
 	}
 
 	log.Info("driver for kernel is not present in the image", "kernel", kernelVersion, "image", image)
-	return false, fmt.Sprintf("image %s does not contain kernel module for kernel %s on any layer", image, kernelVersion)
+	return result(false, fmt.Sprintf("image %s does not contain kernel module for kernel %s on any layer", image, kernelVersion))
+}
+
+// scanLayer looks for the kernel module file in the layer of image identified by digest.
+func (p *preflight) scanLayer(
+	ctx context.Context,
+	mod *kmmv1beta1.Module,
+	image string,
+	digest string,
+	repoConfig *registry.RepoPullConfig,
+	baseDir string,
+	kernelVersion string,
+	moduleName string) (bool, error) {
+	attrs := append(
+		tracing.ModuleAttributes(mod.Name, mod.Namespace),
+		tracing.ImageKey.String(image),
+		tracing.LayerDigestKey.String(digest),
+		tracing.KernelVersionKey.String(kernelVersion),
+	)
+
+	ctx, span := p.tracer.Start(ctx, "preflight.layerScan", trace.WithAttributes(attrs...))
+	defer span.End()
+
+	layer, err := p.registryAPI.GetLayerByDigest(digest, repoConfig)
+	if err != nil {
+		span.RecordError(err)
+		return false, err
+	}
+
+	// check kernel module file present in the directory of the kernel lib modules
+	found, err := p.registryAPI.VerifyModuleExists(ctx, layer, baseDir, kernelVersion, moduleName)
+	if err != nil {
+		span.RecordError(err)
+		return false, err
+	}
+
+	span.SetAttributes(attribute.Bool("kmm.module.found", found))
+
+	return found, nil
+}
+
+func (p *preflight) verifyBuild(ctx context.Context, pv *kmmv1beta1.PreflightValidation, mapping *kmmv1beta1.KernelMapping, mod *kmmv1beta1.Module, kernelVersion string) Result {
+	res := Result{Stage: kmmv1beta1.VerificationStageBuild}
+
+	buildRes, err := p.buildAPI.Sync(ctx, *mod, *mapping, kernelVersion, pv.Spec.PushBuiltImage)
+	if err != nil {
+		res.Message = fmt.Sprintf("Failed to verify build for module %s, kernel version %s: %v", mod.Name, kernelVersion, err)
+		return res
+	}
+
+	switch {
+	case buildRes.Status != build.StatusCompleted:
+		res.Message = VerificationStatusReasonBuildInProgress
+		res.Requeue = true
+	case pv.Spec.PushBuiltImage:
+		res.Verified = true
+		res.Message = VerificationStatusReasonBuildPushed
+	default:
+		res.Verified = true
+		res.Message = VerificationStatusReasonBuildVerified
+	}
+
+	return res
+}
+
+// CRStatusKey returns the key of mod in the CRStatuses of a PreflightValidation.
+func CRStatusKey(mod *kmmv1beta1.Module) string {
+	return types.NamespacedName{Namespace: mod.Namespace, Name: mod.Name}.String()
+}
+
+// buildStageImages returns the images that were checked for kernelVersion without being verified if the Module with
+// moduleKey is in the build stage, or nil if that kernel has not reached the build stage.
+func buildStageImages(pv *kmmv1beta1.PreflightValidation, moduleKey, kernelVersion string) []kmmv1beta1.CheckedImage {
+	status, ok := pv.Status.CRStatuses[moduleKey]
+	if !ok || status.VerificationStage != kmmv1beta1.VerificationStageBuild {
+		return nil
+	}
+
+	var images []kmmv1beta1.CheckedImage
+
+	for _, ci := range status.CheckedImages {
+		if ci.KernelVersion == kernelVersion && ci.Layer == "" {
+			images = append(images, ci)
+		}
+	}
+
+	return images
+}
+
+func shouldBeBuilt(mod *kmmv1beta1.Module, mapping *kmmv1beta1.KernelMapping) bool {
+	return mod.Spec.ModuleLoader.Container.Build != nil || mapping.Build != nil
 }
@@ -10,25 +10,34 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	"github.com/qbarrand/oot-operator/internal/build"
 	"github.com/qbarrand/oot-operator/internal/client"
+	"github.com/qbarrand/oot-operator/internal/config"
 	"github.com/qbarrand/oot-operator/internal/module"
 	"github.com/qbarrand/oot-operator/internal/registry"
+	"github.com/qbarrand/oot-operator/internal/test"
+	"github.com/qbarrand/oot-operator/internal/tracing"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 const (
-	moduleName     = "module name"
-	containerImage = "container image"
-	kernelVersion  = "kernel version"
+	moduleName      = "module name"
+	moduleNamespace = "module-namespace"
+	containerImage  = "container image"
+	kernelVersion   = "kernel version"
 )
 
 var (
 	ctrl            *gomock.Controller
+	mockBuildAPI    *build.MockManager
 	mockRegistryAPI *registry.MockRegistry
 	mockKernelAPI   *module.MockKernelMapper
 	clnt            *client.MockClient
 	p               *preflight
 	mod             *kmmv1beta1.Module
+	pv              *kmmv1beta1.PreflightValidation
+	spanRecorder    *tracetest.SpanRecorder
 )
 
 func TestPreflight(t *testing.T) {
@@ -37,11 +46,13 @@ func TestPreflight(t *testing.T) {
 	BeforeEach(func() {
 		ctrl = gomock.NewController(GinkgoT())
 		clnt = client.NewMockClient(ctrl)
+		mockBuildAPI = build.NewMockManager(ctrl)
 		mockRegistryAPI = registry.NewMockRegistry(ctrl)
 		mockKernelAPI = module.NewMockKernelMapper(ctrl)
 		mod = &kmmv1beta1.Module{
 			ObjectMeta: metav1.ObjectMeta{
-				Name: moduleName,
+				Name:      moduleName,
+				Namespace: moduleNamespace,
 			},
 			Spec: kmmv1beta1.ModuleSpec{
 				ModuleLoader: kmmv1beta1.ModuleLoaderSpec{
@@ -54,9 +65,24 @@ func TestPreflight(t *testing.T) {
 				},
 			},
 		}
+		pv = &kmmv1beta1.PreflightValidation{
+			Spec: kmmv1beta1.PreflightValidationSpec{
+				KernelVersion: kernelVersion,
+			},
+			Status: kmmv1beta1.PreflightValidationStatus{
+				CRStatuses: map[string]*kmmv1beta1.CRStatus{
+					moduleNamespace + "/" + moduleName: {VerificationStage: kmmv1beta1.VerificationStageImage},
+				},
+			},
+		}
+		tracer, sr := test.TestTracer()
+		spanRecorder = sr
 		p = NewPreflightAPI(clnt,
+			mockBuildAPI,
 			mockRegistryAPI,
-			mockKernelAPI).(*preflight)
+			mockKernelAPI,
+			config.NewStaticProvider(config.NewDefault()),
+			tracer).(*preflight)
 	})
 
 	AfterEach(func() {
@@ -72,10 +98,10 @@ var _ = Describe("preflight upgrade", func() {
 		mod.Spec.ModuleLoader.Container.KernelMappings = []kmmv1beta1.KernelMapping{}
 		mockKernelAPI.EXPECT().FindMappingForKernel(mod.Spec.ModuleLoader.Container.KernelMappings, kernelVersion).Return(nil, fmt.Errorf("some error"))
 
-		res, message := p.PreflightUpgradeCheck(context.Background(), mod, kernelVersion)
+		res := p.PreflightUpgradeCheck(context.Background(), pv, mod, []Kernel{{Version: kernelVersion}})
 
-		Expect(res).To(BeFalse())
-		Expect(message).To(Equal(fmt.Sprintf("Failed to find kernel mapping in the module %s for kernel version %s", mod.Name, kernelVersion)))
+		Expect(res.Verified).To(BeFalse())
+		Expect(res.Message).To(Equal(fmt.Sprintf("Failed to find kernel mapping in the module %s for kernel version %s", mod.Name, kernelVersion)))
 	})
 
 	It("failed to prepare kernel mapping", func() {
@@ -83,40 +109,376 @@ var _ = Describe("preflight upgrade", func() {
 		mod.Spec.ModuleLoader.Container.KernelMappings = []kmmv1beta1.KernelMapping{}
 
 		mockKernelAPI.EXPECT().FindMappingForKernel(mod.Spec.ModuleLoader.Container.KernelMappings, kernelVersion).Return(&mapping, nil)
-		mockKernelAPI.EXPECT().PrepareKernelMapping(&mapping, gomock.Any()).Return(nil, fmt.Errorf("some error"))
+		mockKernelAPI.EXPECT().GetOSConfigForKernel(kernelVersion).Return(&module.NodeOSConfig{}, nil)
+		mockKernelAPI.EXPECT().PrepareKernelMapping(&mapping, &module.NodeOSConfig{}).Return(nil, fmt.Errorf("some error"))
+
+		res := p.PreflightUpgradeCheck(context.Background(), pv, mod, []Kernel{{Version: kernelVersion}})
+
+		Expect(res.Verified).To(BeFalse())
+		Expect(res.Message).To(Equal(fmt.Sprintf("Failed to substitute template in kernel mapping in the module %s for kernel version %s", mod.Name, kernelVersion)))
+	})
+
+	It("failed to parse the kernel version", func() {
+		mapping := kmmv1beta1.KernelMapping{ContainerImage: containerImage}
+		mod.Spec.ModuleLoader.Container.KernelMappings = []kmmv1beta1.KernelMapping{}
+
+		mockKernelAPI.EXPECT().FindMappingForKernel(mod.Spec.ModuleLoader.Container.KernelMappings, kernelVersion).Return(&mapping, nil)
+		mockKernelAPI.EXPECT().GetOSConfigForKernel(kernelVersion).Return(nil, fmt.Errorf("some error"))
+
+		res := p.PreflightUpgradeCheck(context.Background(), pv, mod, []Kernel{{Version: kernelVersion}})
+
+		Expect(res.Verified).To(BeFalse())
+		Expect(res.Message).To(Equal(fmt.Sprintf("Failed to parse kernel version %s: some error", kernelVersion)))
+	})
+
+	It("should check all kernel versions", func() {
+		const otherKernelVersion = "other kernel version"
+
+		mockKernelAPI.EXPECT().FindMappingForKernel(gomock.Any(), kernelVersion).Return(nil, fmt.Errorf("some error"))
+		mockKernelAPI.EXPECT().FindMappingForKernel(gomock.Any(), otherKernelVersion).Return(nil, fmt.Errorf("some error"))
 
-		res, message := p.PreflightUpgradeCheck(context.Background(), mod, kernelVersion)
+		res := p.PreflightUpgradeCheck(context.Background(), pv, mod, []Kernel{{Version: kernelVersion}, {Version: otherKernelVersion}})
 
-		Expect(res).To(BeFalse())
-		Expect(message).To(Equal(fmt.Sprintf("Failed to substitute template in kernel mapping in the module %s for kernel version %s", mod.Name, kernelVersion)))
+		Expect(res.Verified).To(BeFalse())
+		Expect(res.Message).To(Equal(
+			fmt.Sprintf(
+				"kernel %s: Failed to find kernel mapping in the module %s for kernel version %s; kernel %s: Failed to find kernel mapping in the module %s for kernel version %s",
+				kernelVersion, mod.Name, kernelVersion, otherKernelVersion, mod.Name, otherKernelVersion,
+			),
+		))
+	})
+
+	It("should not verify the build if the image is verified", func() {
+		mapping := kmmv1beta1.KernelMapping{ContainerImage: containerImage, Build: &kmmv1beta1.Build{}}
+		digests := []string{"digest0"}
+		repoConfig := &registry.RepoPullConfig{}
+		digestLayer := v1stream.Layer{}
+
+		gomock.InOrder(
+			mockKernelAPI.EXPECT().FindMappingForKernel(gomock.Any(), kernelVersion).Return(&mapping, nil),
+			mockKernelAPI.EXPECT().GetOSConfigForKernel(kernelVersion).Return(&module.NodeOSConfig{}, nil),
+			mockKernelAPI.EXPECT().PrepareKernelMapping(&mapping, &module.NodeOSConfig{}).Return(&mapping, nil),
+			mockRegistryAPI.EXPECT().GetLayersDigests(context.Background(), containerImage, gomock.Any()).Return(digests, repoConfig, nil),
+			mockRegistryAPI.EXPECT().GetLayerByDigest(digests[0], repoConfig).Return(&digestLayer, nil),
+			mockRegistryAPI.EXPECT().VerifyModuleExists(gomock.Any(), &digestLayer, "/opt", kernelVersion, "simple-kmod.ko").Return(true, nil),
+		)
+
+		res := p.PreflightUpgradeCheck(context.Background(), pv, mod, []Kernel{{Version: kernelVersion}})
+
+		Expect(res.Verified).To(BeTrue())
+		Expect(res.Message).To(Equal(VerificationStatusReasonVerified))
+	})
+
+	It("should substitute the OS version of the kernel in the container image", func() {
+		mod.Spec.ModuleLoader.Container.KernelMappings = []kmmv1beta1.KernelMapping{
+			{Literal: "4.18.0-372.19.1.el8_6.x86_64", ContainerImage: "some-image:${OS_VERSION}-${KERNEL_FULL_VERSION}"},
+		}
+		p.kernelAPI = module.NewKernelMapper()
+
+		mockRegistryAPI.
+			EXPECT().
+			GetLayersDigests(context.Background(), "some-image:8.6-4.18.0-372.19.1.el8_6.x86_64", gomock.Any()).
+			Return(nil, nil, fmt.Errorf("some error"))
+
+		res := p.PreflightUpgradeCheck(
+			context.Background(),
+			pv,
+			mod,
+			[]Kernel{{Version: "4.18.0-372.19.1.el8_6.x86_64", OSVersion: "8.6"}},
+		)
+
+		Expect(res.Verified).To(BeFalse())
+	})
+
+	It("should not verify the build if the image is not verified and no build is configured", func() {
+		mapping := kmmv1beta1.KernelMapping{ContainerImage: containerImage}
+
+		gomock.InOrder(
+			mockKernelAPI.EXPECT().FindMappingForKernel(gomock.Any(), kernelVersion).Return(&mapping, nil),
+			mockKernelAPI.EXPECT().GetOSConfigForKernel(kernelVersion).Return(&module.NodeOSConfig{}, nil),
+			mockKernelAPI.EXPECT().PrepareKernelMapping(&mapping, &module.NodeOSConfig{}).Return(&mapping, nil),
+			mockRegistryAPI.EXPECT().GetLayersDigests(context.Background(), containerImage, gomock.Any()).Return(nil, nil, fmt.Errorf("some error")),
+		)
+
+		res := p.PreflightUpgradeCheck(context.Background(), pv, mod, []Kernel{{Version: kernelVersion}})
+
+		Expect(res.Verified).To(BeFalse())
+		Expect(res.Message).To(Equal(fmt.Sprintf("image %s inaccessible or does not exists", containerImage)))
+	})
+
+	It("should move to the build stage if the image is not verified and a build is configured", func() {
+		mapping := kmmv1beta1.KernelMapping{ContainerImage: containerImage, Build: &kmmv1beta1.Build{}}
+
+		gomock.InOrder(
+			mockKernelAPI.EXPECT().FindMappingForKernel(gomock.Any(), kernelVersion).Return(&mapping, nil),
+			mockKernelAPI.EXPECT().GetOSConfigForKernel(kernelVersion).Return(&module.NodeOSConfig{}, nil),
+			mockKernelAPI.EXPECT().PrepareKernelMapping(&mapping, &module.NodeOSConfig{}).Return(&mapping, nil),
+			mockRegistryAPI.EXPECT().GetLayersDigests(context.Background(), containerImage, gomock.Any()).Return(nil, nil, fmt.Errorf("some error")),
+			mockBuildAPI.EXPECT().Sync(context.Background(), *mod, mapping, kernelVersion, false).Return(build.Result{Status: build.StatusCreated, Requeue: true}, nil),
+		)
+
+		res := p.PreflightUpgradeCheck(context.Background(), pv, mod, []Kernel{{Version: kernelVersion}})
+
+		Expect(res.Verified).To(BeFalse())
+		Expect(res.Message).To(Equal(VerificationStatusReasonBuildInProgress))
+	})
+
+	It("should only verify the build if the kernel is already in the build stage", func() {
+		mapping := kmmv1beta1.KernelMapping{ContainerImage: containerImage}
+		mod.Spec.ModuleLoader.Container.Build = &kmmv1beta1.Build{}
+		checkedImage := kmmv1beta1.CheckedImage{KernelVersion: kernelVersion, Image: containerImage}
+		pv.Status.CRStatuses[CRStatusKey(mod)].VerificationStage = kmmv1beta1.VerificationStageBuild
+		pv.Status.CRStatuses[CRStatusKey(mod)].CheckedImages = []kmmv1beta1.CheckedImage{checkedImage}
+
+		gomock.InOrder(
+			mockKernelAPI.EXPECT().FindMappingForKernel(gomock.Any(), kernelVersion).Return(&mapping, nil),
+			mockKernelAPI.EXPECT().GetOSConfigForKernel(kernelVersion).Return(&module.NodeOSConfig{}, nil),
+			mockKernelAPI.EXPECT().PrepareKernelMapping(&mapping, &module.NodeOSConfig{}).Return(&mapping, nil),
+			mockBuildAPI.EXPECT().Sync(context.Background(), *mod, mapping, kernelVersion, false).Return(build.Result{Status: build.StatusCompleted}, nil),
+		)
+
+		res := p.PreflightUpgradeCheck(context.Background(), pv, mod, []Kernel{{Version: kernelVersion}})
+
+		Expect(res.Verified).To(BeTrue())
+		Expect(res.Message).To(Equal(VerificationStatusReasonBuildVerified))
+		Expect(res.CheckedImages).To(Equal([]kmmv1beta1.CheckedImage{checkedImage}))
+	})
+
+	It("should verify the image of kernels that are not in the build stage yet", func() {
+		mapping := kmmv1beta1.KernelMapping{ContainerImage: containerImage}
+		mod.Spec.ModuleLoader.Container.Build = &kmmv1beta1.Build{}
+		pv.Status.CRStatuses[CRStatusKey(mod)].VerificationStage = kmmv1beta1.VerificationStageBuild
+		pv.Status.CRStatuses[CRStatusKey(mod)].CheckedImages = []kmmv1beta1.CheckedImage{
+			{KernelVersion: "other kernel version", Image: containerImage},
+		}
+
+		digests := []string{"digest0"}
+		repoConfig := &registry.RepoPullConfig{}
+		digestLayer := v1stream.Layer{}
+
+		gomock.InOrder(
+			mockKernelAPI.EXPECT().FindMappingForKernel(gomock.Any(), kernelVersion).Return(&mapping, nil),
+			mockKernelAPI.EXPECT().GetOSConfigForKernel(kernelVersion).Return(&module.NodeOSConfig{}, nil),
+			mockKernelAPI.EXPECT().PrepareKernelMapping(&mapping, &module.NodeOSConfig{}).Return(&mapping, nil),
+			mockRegistryAPI.EXPECT().GetLayersDigests(context.Background(), containerImage, gomock.Any()).Return(digests, repoConfig, nil),
+			mockRegistryAPI.EXPECT().GetLayerByDigest(digests[0], repoConfig).Return(&digestLayer, nil),
+			mockRegistryAPI.EXPECT().VerifyModuleExists(gomock.Any(), &digestLayer, "/opt", kernelVersion, "simple-kmod.ko").Return(true, nil),
+		)
+
+		res := p.PreflightUpgradeCheck(context.Background(), pv, mod, []Kernel{{Version: kernelVersion}})
+
+		Expect(res.Verified).To(BeTrue())
+		Expect(res.Message).To(Equal(VerificationStatusReasonVerified))
 	})
 })
 
+var _ = Describe("GetKernelVersions", func() {
+
+	const (
+		releaseImage = "quay.io/openshift-release-dev/ocp-release:4.11.0-x86_64"
+		dtkImage     = "quay.io/openshift-release-dev/ocp-v4.0-art-dev@sha256:1234"
+	)
+
+	It("should return the kernel version of the PreflightValidation", func() {
+		res, err := p.GetKernelVersions(context.Background(), pv)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(Equal([]Kernel{{Version: kernelVersion}}))
+	})
+
+	It("should return an error if both the kernel version and the release image are set", func() {
+		pv.Spec.ReleaseImage = releaseImage
+
+		_, err := p.GetKernelVersions(context.Background(), pv)
+
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should return an error if neither the kernel version nor the release image are set", func() {
+		pv.Spec.KernelVersion = ""
+
+		_, err := p.GetKernelVersions(context.Background(), pv)
+
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should return an error if the driver-toolkit image cannot be found", func() {
+		pv.Spec.KernelVersion = ""
+		pv.Spec.ReleaseImage = releaseImage
+		digests := []string{"digest0"}
+		repoConfig := &registry.RepoPullConfig{}
+		digestLayer := v1stream.Layer{}
+
+		gomock.InOrder(
+			mockRegistryAPI.EXPECT().GetLayersDigests(context.Background(), releaseImage, nil).Return(digests, repoConfig, nil),
+			mockRegistryAPI.EXPECT().GetLayerByDigest(digests[0], repoConfig).Return(&digestLayer, nil),
+			mockRegistryAPI.EXPECT().ReleaseManifests(gomock.Any(), &digestLayer).Return("", fmt.Errorf("some error")),
+		)
+
+		_, err := p.GetKernelVersions(context.Background(), pv)
+
+		Expect(err).To(HaveOccurred())
+	})
+
+	DescribeTable("should return the kernel versions from the release image",
+		func(dtk registry.DriverToolkitEntry, expected []Kernel) {
+			pv.Spec.KernelVersion = ""
+			pv.Spec.ReleaseImage = releaseImage
+			releaseDigests := []string{"digest0", "digest1"}
+			dtkDigests := []string{"digest2"}
+			releaseRepoConfig := &registry.RepoPullConfig{ImageDigest: "sha256:release"}
+			dtkRepoConfig := &registry.RepoPullConfig{}
+			releaseLayer0 := v1stream.Layer{}
+			releaseLayer1 := v1stream.Layer{}
+			dtkLayer := v1stream.Layer{}
+
+			gomock.InOrder(
+				mockRegistryAPI.EXPECT().GetLayersDigests(context.Background(), releaseImage, nil).Return(releaseDigests, releaseRepoConfig, nil),
+				mockRegistryAPI.EXPECT().GetLayerByDigest(releaseDigests[1], releaseRepoConfig).Return(&releaseLayer1, nil),
+				mockRegistryAPI.EXPECT().ReleaseManifests(gomock.Any(), &releaseLayer1).Return("", fmt.Errorf("some error")),
+				mockRegistryAPI.EXPECT().GetLayerByDigest(releaseDigests[0], releaseRepoConfig).Return(&releaseLayer0, nil),
+				mockRegistryAPI.EXPECT().ReleaseManifests(gomock.Any(), &releaseLayer0).Return(dtkImage, nil),
+				mockRegistryAPI.EXPECT().GetLayersDigests(context.Background(), dtkImage, nil).Return(dtkDigests, dtkRepoConfig, nil),
+				mockRegistryAPI.EXPECT().GetLayerByDigest(dtkDigests[0], dtkRepoConfig).Return(&dtkLayer, nil),
+				mockRegistryAPI.EXPECT().ExtractToolkitRelease(gomock.Any(), &dtkLayer).Return(&dtk, nil),
+			)
+
+			res, err := p.GetKernelVersions(context.Background(), pv)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).To(Equal(expected))
+		},
+		Entry(
+			"no RT kernel",
+			registry.DriverToolkitEntry{KernelFullVersion: "4.18.0-372.19.1.el8_6.x86_64", OSVersion: "8.6"},
+			[]Kernel{{Version: "4.18.0-372.19.1.el8_6.x86_64", OSVersion: "8.6"}},
+		),
+		Entry(
+			"with RT kernel",
+			registry.DriverToolkitEntry{
+				KernelFullVersion:   "4.18.0-372.19.1.el8_6.x86_64",
+				RTKernelFullVersion: "4.18.0-372.19.1.rt7.176.el8_6.x86_64",
+				OSVersion:           "8.6",
+			},
+			[]Kernel{
+				{Version: "4.18.0-372.19.1.el8_6.x86_64", OSVersion: "8.6"},
+				{Version: "4.18.0-372.19.1.rt7.176.el8_6.x86_64", OSVersion: "8.6"},
+			},
+		),
+	)
+
+	It("should not pull the layers of a release image again", func() {
+		pv.Spec.KernelVersion = ""
+		pv.Spec.ReleaseImage = releaseImage
+		releaseDigests := []string{"digest0"}
+		dtkDigests := []string{"digest1"}
+		releaseRepoConfig := &registry.RepoPullConfig{ImageDigest: "sha256:release"}
+		dtkRepoConfig := &registry.RepoPullConfig{}
+		releaseLayer := v1stream.Layer{}
+		dtkLayer := v1stream.Layer{}
+		dtk := registry.DriverToolkitEntry{KernelFullVersion: "4.18.0-372.19.1.el8_6.x86_64"}
+
+		gomock.InOrder(
+			mockRegistryAPI.EXPECT().GetLayersDigests(context.Background(), releaseImage, nil).Return(releaseDigests, releaseRepoConfig, nil),
+			mockRegistryAPI.EXPECT().GetLayerByDigest(releaseDigests[0], releaseRepoConfig).Return(&releaseLayer, nil),
+			mockRegistryAPI.EXPECT().ReleaseManifests(gomock.Any(), &releaseLayer).Return(dtkImage, nil),
+			mockRegistryAPI.EXPECT().GetLayersDigests(context.Background(), dtkImage, nil).Return(dtkDigests, dtkRepoConfig, nil),
+			mockRegistryAPI.EXPECT().GetLayerByDigest(dtkDigests[0], dtkRepoConfig).Return(&dtkLayer, nil),
+			mockRegistryAPI.EXPECT().ExtractToolkitRelease(gomock.Any(), &dtkLayer).Return(&dtk, nil),
+			mockRegistryAPI.EXPECT().GetLayersDigests(context.Background(), releaseImage, nil).Return(releaseDigests, releaseRepoConfig, nil),
+		)
+
+		for i := 0; i < 2; i++ {
+			res, err := p.GetKernelVersions(context.Background(), pv)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).To(Equal([]Kernel{{Version: dtk.KernelFullVersion}}))
+		}
+	})
+})
+
+var _ = Describe("verifyBuild", func() {
+
+	mapping := kmmv1beta1.KernelMapping{ContainerImage: containerImage, Build: &kmmv1beta1.Build{}}
+
+	DescribeTable("should return the verification result depending on the build result",
+		func(pushImage bool, buildRes build.Result, buildErr error, expectedRes bool, expectedRequeue bool, expectedMessage string) {
+			pv.Spec.PushBuiltImage = pushImage
+
+			mockBuildAPI.EXPECT().Sync(context.Background(), *mod, mapping, kernelVersion, pushImage).Return(buildRes, buildErr)
+
+			res := p.verifyBuild(context.Background(), pv, &mapping, mod, kernelVersion)
+
+			Expect(res).To(Equal(Result{
+				Verified: expectedRes,
+				Message:  expectedMessage,
+				Stage:    kmmv1beta1.VerificationStageBuild,
+				Requeue:  expectedRequeue,
+			}))
+		},
+		Entry("build failed", false, build.Result{}, fmt.Errorf("some error"), false, false,
+			fmt.Sprintf("Failed to verify build for module %s, kernel version %s: some error", moduleName, kernelVersion)),
+		Entry("build in progress", false, build.Result{Status: build.StatusInProgress, Requeue: true}, nil, false, true, VerificationStatusReasonBuildInProgress),
+		Entry("build completed", false, build.Result{Status: build.StatusCompleted}, nil, true, false, VerificationStatusReasonBuildVerified),
+		Entry("build completed and pushed", true, build.Result{Status: build.StatusCompleted}, nil, true, false, VerificationStatusReasonBuildPushed),
+	)
+})
+
 var _ = Describe("verifyImage", func() {
 
 	It("good flow", func() {
+		mapping := kmmv1beta1.KernelMapping{ContainerImage: containerImage}
+		digests := []string{"digest0", "digest1"}
+		repoConfig := &registry.RepoPullConfig{ImageDigest: "sha256:image"}
+		digestLayer := v1stream.Layer{}
+		mockRegistryAPI.EXPECT().GetLayersDigests(context.Background(), containerImage, gomock.Any()).Return(digests, repoConfig, nil)
+		mockRegistryAPI.EXPECT().GetLayerByDigest(digests[1], repoConfig).Return(&digestLayer, nil)
+		mockRegistryAPI.EXPECT().VerifyModuleExists(gomock.Any(), &digestLayer, "/opt", kernelVersion, "simple-kmod.ko").Return(true, nil)
+
+		res := p.verifyImage(context.Background(), &mapping, mod, kernelVersion)
+
+		Expect(res.Verified).To(BeTrue())
+		Expect(res.Message).To(Equal(VerificationStatusReasonVerified))
+		Expect(res.CheckedImages).To(Equal([]kmmv1beta1.CheckedImage{
+			{KernelVersion: kernelVersion, Image: containerImage, Digest: "sha256:image", Layer: digests[1]},
+		}))
+	})
+
+	It("should record a span for each scanned layer", func() {
 		mapping := kmmv1beta1.KernelMapping{ContainerImage: containerImage}
 		digests := []string{"digest0", "digest1"}
 		repoConfig := &registry.RepoPullConfig{}
 		digestLayer := v1stream.Layer{}
 		mockRegistryAPI.EXPECT().GetLayersDigests(context.Background(), containerImage, gomock.Any()).Return(digests, repoConfig, nil)
 		mockRegistryAPI.EXPECT().GetLayerByDigest(digests[1], repoConfig).Return(&digestLayer, nil)
-		mockRegistryAPI.EXPECT().VerifyModuleExists(&digestLayer, "/opt", kernelVersion, "simple-kmod.ko").Return(true)
+		mockRegistryAPI.EXPECT().VerifyModuleExists(gomock.Any(), &digestLayer, "/opt", kernelVersion, "simple-kmod.ko").Return(false, nil)
+		mockRegistryAPI.EXPECT().GetLayerByDigest(digests[0], repoConfig).Return(&digestLayer, nil)
+		mockRegistryAPI.EXPECT().VerifyModuleExists(gomock.Any(), &digestLayer, "/opt", kernelVersion, "simple-kmod.ko").Return(true, nil)
+
+		p.verifyImage(context.Background(), &mapping, mod, kernelVersion)
 
-		res, message := p.verifyImage(context.Background(), &mapping, mod, kernelVersion)
+		spans := spanRecorder.Ended()
+		Expect(spans).To(HaveLen(2))
 
-		Expect(res).To(BeTrue())
-		Expect(message).To(Equal(VerificationStatusReasonVerified))
+		for i, s := range spans {
+			Expect(s.Name()).To(Equal("preflight.layerScan"))
+			Expect(s.Attributes()).To(ContainElements(
+				tracing.ModuleNameKey.String(moduleName),
+				tracing.ImageKey.String(containerImage),
+				tracing.KernelVersionKey.String(kernelVersion),
+				tracing.LayerDigestKey.String(digests[1-i]),
+			))
+		}
 	})
 
 	It("get layers digest failed", func() {
 		mapping := kmmv1beta1.KernelMapping{ContainerImage: containerImage}
 		mockRegistryAPI.EXPECT().GetLayersDigests(context.Background(), containerImage, gomock.Any()).Return(nil, nil, fmt.Errorf("some error"))
 
-		res, message := p.verifyImage(context.Background(), &mapping, mod, kernelVersion)
+		res := p.verifyImage(context.Background(), &mapping, mod, kernelVersion)
 
-		Expect(res).To(BeFalse())
-		Expect(message).To(Equal(fmt.Sprintf("image %s inaccessible or does not exists", containerImage)))
+		Expect(res.Verified).To(BeFalse())
+		Expect(res.Message).To(Equal(fmt.Sprintf("image %s inaccessible or does not exists", containerImage)))
 	})
 
 	It("failed to get specific layer", func() {
@@ -126,10 +488,10 @@ var _ = Describe("verifyImage", func() {
 		mockRegistryAPI.EXPECT().GetLayersDigests(context.Background(), containerImage, gomock.Any()).Return(digests, repoConfig, nil)
 		mockRegistryAPI.EXPECT().GetLayerByDigest(digests[1], repoConfig).Return(nil, fmt.Errorf("some error"))
 
-		res, message := p.verifyImage(context.Background(), &mapping, mod, kernelVersion)
+		res := p.verifyImage(context.Background(), &mapping, mod, kernelVersion)
 
-		Expect(res).To(BeFalse())
-		Expect(message).To(Equal(fmt.Sprintf("image %s, layer %s is inaccessible", containerImage, digests[1])))
+		Expect(res.Verified).To(BeFalse())
+		Expect(res.Message).To(Equal(fmt.Sprintf("image %s, layer %s is inaccessible", containerImage, digests[1])))
 	})
 
 	It("kernel module not present in the correct path", func() {
@@ -139,12 +501,48 @@ var _ = Describe("verifyImage", func() {
 		digestLayer := v1stream.Layer{}
 		mockRegistryAPI.EXPECT().GetLayersDigests(context.Background(), containerImage, gomock.Any()).Return(digests, repoConfig, nil)
 		mockRegistryAPI.EXPECT().GetLayerByDigest(digests[0], repoConfig).Return(&digestLayer, nil)
-		mockRegistryAPI.EXPECT().VerifyModuleExists(&digestLayer, "/opt", kernelVersion, "simple-kmod.ko").Return(false)
+		mockRegistryAPI.EXPECT().VerifyModuleExists(gomock.Any(), &digestLayer, "/opt", kernelVersion, "simple-kmod.ko").Return(false, nil)
+
+		res := p.verifyImage(context.Background(), &mapping, mod, kernelVersion)
+
+		Expect(res.Verified).To(BeFalse())
+		Expect(res.Message).To(Equal(fmt.Sprintf("image %s does not contain kernel module for kernel %s on any layer", containerImage, kernelVersion)))
+	})
+
+	It("should not fetch layers that were already scanned", func() {
+		mapping := kmmv1beta1.KernelMapping{ContainerImage: containerImage}
+		digests := []string{"digest0"}
+		repoConfig := &registry.RepoPullConfig{}
+		digestLayer := v1stream.Layer{}
+		mockRegistryAPI.EXPECT().GetLayersDigests(context.Background(), containerImage, gomock.Any()).Return(digests, repoConfig, nil).Times(2)
+		mockRegistryAPI.EXPECT().GetLayerByDigest(digests[0], repoConfig).Return(&digestLayer, nil)
+		mockRegistryAPI.EXPECT().VerifyModuleExists(gomock.Any(), &digestLayer, "/opt", kernelVersion, "simple-kmod.ko").Return(false, nil)
+
+		res := p.verifyImage(context.Background(), &mapping, mod, kernelVersion)
+		Expect(res.Verified).To(BeFalse())
+
+		res = p.verifyImage(context.Background(), &mapping, mod, kernelVersion)
+		Expect(res.Verified).To(BeFalse())
+	})
+
+	It("should scan the layer again if it could not be read", func() {
+		mapping := kmmv1beta1.KernelMapping{ContainerImage: containerImage}
+		digests := []string{"digest0"}
+		repoConfig := &registry.RepoPullConfig{}
+		digestLayer := v1stream.Layer{}
+		mockRegistryAPI.EXPECT().GetLayersDigests(context.Background(), containerImage, gomock.Any()).Return(digests, repoConfig, nil).Times(2)
+		mockRegistryAPI.EXPECT().GetLayerByDigest(digests[0], repoConfig).Return(&digestLayer, nil).Times(2)
+		gomock.InOrder(
+			mockRegistryAPI.EXPECT().VerifyModuleExists(gomock.Any(), &digestLayer, "/opt", kernelVersion, "simple-kmod.ko").Return(false, fmt.Errorf("some error")),
+			mockRegistryAPI.EXPECT().VerifyModuleExists(gomock.Any(), &digestLayer, "/opt", kernelVersion, "simple-kmod.ko").Return(true, nil),
+		)
 
-		res, message := p.verifyImage(context.Background(), &mapping, mod, kernelVersion)
+		res := p.verifyImage(context.Background(), &mapping, mod, kernelVersion)
+		Expect(res.Verified).To(BeFalse())
+		Expect(res.Message).To(Equal(fmt.Sprintf("image %s, layer %s is inaccessible", containerImage, digests[0])))
 
-		Expect(res).To(BeFalse())
-		Expect(message).To(Equal(fmt.Sprintf("image %s does not contain kernel module for kernel %s on any layer", containerImage, kernelVersion)))
+		res = p.verifyImage(context.Background(), &mapping, mod, kernelVersion)
+		Expect(res.Verified).To(BeTrue())
 	})
 
 })
@@ -0,0 +1,153 @@
+package preflight
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+
+	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	ReportJSONKey  = "report.json"
+	ReportJUnitKey = "report.xml"
+
+	ModuleReportStatusVerified   = "Verified"
+	ModuleReportStatusFailed     = "Failed"
+	ModuleReportStatusInProgress = "InProgress"
+)
+
+// ModuleReport is the verification result of a single Module.
+type ModuleReport struct {
+	Namespace     string                    `json:"namespace"`
+	Name          string                    `json:"name"`
+	Status        string                    `json:"status"`
+	Stage         string                    `json:"stage"`
+	Reason        string                    `json:"reason,omitempty"`
+	CheckedImages []kmmv1beta1.CheckedImage `json:"checkedImages,omitempty"`
+}
+
+// ValidationReport is the machine-readable result of a PreflightValidation.
+type ValidationReport struct {
+	Name          string                      `json:"name"`
+	Namespace     string                      `json:"namespace"`
+	KernelVersion string                      `json:"kernelVersion,omitempty"`
+	ReleaseImage  string                      `json:"releaseImage,omitempty"`
+	Phase         string                      `json:"phase"`
+	Summary       kmmv1beta1.PreflightSummary `json:"summary"`
+	Modules       []ModuleReport              `json:"modules"`
+}
+
+// NewValidationReport builds the report of pv from its status.
+// inProgress holds the CRStatuses keys of the Modules whose verification is not over yet.
+func NewValidationReport(pv *kmmv1beta1.PreflightValidation, inProgress sets.String) *ValidationReport {
+	r := ValidationReport{
+		Name:          pv.Name,
+		Namespace:     pv.Namespace,
+		KernelVersion: pv.Spec.KernelVersion,
+		ReleaseImage:  pv.Spec.ReleaseImage,
+		Phase:         pv.Status.Phase,
+		Summary:       pv.Status.Summary,
+		Modules:       make([]ModuleReport, 0, len(pv.Status.CRStatuses)),
+	}
+
+	keys := make([]string, 0, len(pv.Status.CRStatuses))
+	for key := range pv.Status.CRStatuses {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		crStatus := pv.Status.CRStatuses[key]
+
+		status := ModuleReportStatusFailed
+		switch {
+		case crStatus.VerificationStatus == kmmv1beta1.VerificationTrue:
+			status = ModuleReportStatusVerified
+		case inProgress.Has(key):
+			status = ModuleReportStatusInProgress
+		}
+
+		namespace, name, _ := strings.Cut(key, "/")
+
+		r.Modules = append(r.Modules, ModuleReport{
+			Namespace:     namespace,
+			Name:          name,
+			Status:        status,
+			Stage:         crStatus.VerificationStage,
+			Reason:        crStatus.StatusReason,
+			CheckedImages: crStatus.CheckedImages,
+		})
+	}
+
+	return &r
+}
+
+func (r *ValidationReport) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+}
+
+// JUnit renders the report as a JUnit XML document, with one test case per Module.
+// Modules whose verification is not over yet are reported as skipped.
+func (r *ValidationReport) JUnit() ([]byte, error) {
+	suite := junitTestSuite{
+		Name:      fmt.Sprintf("%s/%s", r.Namespace, r.Name),
+		Tests:     len(r.Modules),
+		TestCases: make([]junitTestCase, 0, len(r.Modules)),
+	}
+
+	for _, m := range r.Modules {
+		tc := junitTestCase{
+			Name:      m.Name,
+			ClassName: m.Namespace,
+			SystemOut: m.Reason,
+		}
+
+		switch m.Status {
+		case ModuleReportStatusFailed:
+			tc.Failure = &junitMessage{Message: m.Reason}
+			suite.Failures++
+		case ModuleReportStatusInProgress:
+			tc.Skipped = &junitMessage{Message: m.Reason}
+			suite.Skipped++
+		}
+
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+
+	b, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal the JUnit report: %w", err)
+	}
+
+	return append([]byte(xml.Header), b...), nil
+}
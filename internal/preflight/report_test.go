@@ -0,0 +1,117 @@
+package preflight
+
+import (
+	"encoding/json"
+	"encoding/xml"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+var _ = Describe("ValidationReport", func() {
+	checkedImages := []kmmv1beta1.CheckedImage{
+		{KernelVersion: "kernel", Image: "image", Digest: "sha256:image", Layer: "sha256:layer"},
+	}
+
+	pv := &kmmv1beta1.PreflightValidation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "preflight",
+			Namespace: "preflight-namespace",
+		},
+		Spec: kmmv1beta1.PreflightValidationSpec{
+			KernelVersion: "kernel",
+		},
+		Status: kmmv1beta1.PreflightValidationStatus{
+			CRStatuses: map[string]*kmmv1beta1.CRStatus{
+				"ns2/verified": {
+					VerificationStatus: kmmv1beta1.VerificationTrue,
+					VerificationStage:  kmmv1beta1.VerificationStageImage,
+					StatusReason:       "verified",
+					CheckedImages:      checkedImages,
+				},
+				"ns1/failed": {
+					VerificationStatus: kmmv1beta1.VerificationFalse,
+					VerificationStage:  kmmv1beta1.VerificationStageImage,
+					StatusReason:       "failed",
+				},
+				"ns1/building": {
+					VerificationStatus: kmmv1beta1.VerificationFalse,
+					VerificationStage:  kmmv1beta1.VerificationStageBuild,
+					StatusReason:       "building",
+				},
+			},
+			Phase:   kmmv1beta1.PreflightPhaseInProgress,
+			Summary: kmmv1beta1.PreflightSummary{Verified: 1, Failed: 1, InProgress: 1},
+		},
+	}
+
+	It("should report all Modules, sorted by namespace and name", func() {
+		report := NewValidationReport(pv, sets.NewString("ns1/building"))
+
+		Expect(report.Name).To(Equal("preflight"))
+		Expect(report.Namespace).To(Equal("preflight-namespace"))
+		Expect(report.KernelVersion).To(Equal("kernel"))
+		Expect(report.Phase).To(Equal(kmmv1beta1.PreflightPhaseInProgress))
+		Expect(report.Summary).To(Equal(pv.Status.Summary))
+		Expect(report.Modules).To(Equal([]ModuleReport{
+			{
+				Namespace: "ns1",
+				Name:      "building",
+				Status:    ModuleReportStatusInProgress,
+				Stage:     kmmv1beta1.VerificationStageBuild,
+				Reason:    "building",
+			},
+			{
+				Namespace: "ns1",
+				Name:      "failed",
+				Status:    ModuleReportStatusFailed,
+				Stage:     kmmv1beta1.VerificationStageImage,
+				Reason:    "failed",
+			},
+			{
+				Namespace:     "ns2",
+				Name:          "verified",
+				Status:        ModuleReportStatusVerified,
+				Stage:         kmmv1beta1.VerificationStageImage,
+				Reason:        "verified",
+				CheckedImages: checkedImages,
+			},
+		}))
+	})
+
+	It("should render the report as JSON", func() {
+		report := NewValidationReport(pv, sets.NewString("ns1/building"))
+
+		b, err := report.JSON()
+		Expect(err).NotTo(HaveOccurred())
+
+		decoded := ValidationReport{}
+		Expect(json.Unmarshal(b, &decoded)).To(Succeed())
+		Expect(&decoded).To(Equal(report))
+	})
+
+	It("should render the report as JUnit", func() {
+		report := NewValidationReport(pv, sets.NewString("ns1/building"))
+
+		b, err := report.JUnit()
+		Expect(err).NotTo(HaveOccurred())
+
+		decoded := junitTestSuites{}
+		Expect(xml.Unmarshal(b, &decoded)).To(Succeed())
+		Expect(decoded.Suites).To(HaveLen(1))
+
+		suite := decoded.Suites[0]
+		Expect(suite.Name).To(Equal("preflight-namespace/preflight"))
+		Expect(suite.Tests).To(Equal(3))
+		Expect(suite.Failures).To(Equal(1))
+		Expect(suite.Skipped).To(Equal(1))
+		Expect(suite.TestCases).To(HaveLen(3))
+		Expect(suite.TestCases[0].Skipped).To(Equal(&junitMessage{Message: "building"}))
+		Expect(suite.TestCases[1].Failure).To(Equal(&junitMessage{Message: "failed"}))
+		Expect(suite.TestCases[2].Failure).To(BeNil())
+		Expect(suite.TestCases[2].Skipped).To(BeNil())
+	})
+})
@@ -37,6 +37,21 @@ func (m *MockRegistry) EXPECT() *MockRegistryMockRecorder {
 	return m.recorder
 }
 
+// ExtractToolkitRelease mocks base method.
+func (m *MockRegistry) ExtractToolkitRelease(ctx context.Context, layer v1.Layer) (*DriverToolkitEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExtractToolkitRelease", ctx, layer)
+	ret0, _ := ret[0].(*DriverToolkitEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExtractToolkitRelease indicates an expected call of ExtractToolkitRelease.
+func (mr *MockRegistryMockRecorder) ExtractToolkitRelease(ctx, layer interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExtractToolkitRelease", reflect.TypeOf((*MockRegistry)(nil).ExtractToolkitRelease), ctx, layer)
+}
+
 // GetLayerByDigest mocks base method.
 func (m *MockRegistry) GetLayerByDigest(digest string, pullConfig *RepoPullConfig) (v1.Layer, error) {
 	m.ctrl.T.Helper()
@@ -83,16 +98,32 @@ func (mr *MockRegistryMockRecorder) ImageExists(ctx, image, po, registryAuthGett
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImageExists", reflect.TypeOf((*MockRegistry)(nil).ImageExists), ctx, image, po, registryAuthGetter)
 }
 
+// ReleaseManifests mocks base method.
+func (m *MockRegistry) ReleaseManifests(ctx context.Context, layer v1.Layer) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseManifests", ctx, layer)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReleaseManifests indicates an expected call of ReleaseManifests.
+func (mr *MockRegistryMockRecorder) ReleaseManifests(ctx, layer interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseManifests", reflect.TypeOf((*MockRegistry)(nil).ReleaseManifests), ctx, layer)
+}
+
 // VerifyModuleExists mocks base method.
-func (m *MockRegistry) VerifyModuleExists(layer v1.Layer, pathPrefix, kernelVersion, moduleFileName string) bool {
+func (m *MockRegistry) VerifyModuleExists(ctx context.Context, layer v1.Layer, pathPrefix, kernelVersion, moduleFileName string) (bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "VerifyModuleExists", layer, pathPrefix, kernelVersion, moduleFileName)
+	ret := m.ctrl.Call(m, "VerifyModuleExists", ctx, layer, pathPrefix, kernelVersion, moduleFileName)
 	ret0, _ := ret[0].(bool)
-	return ret0
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // VerifyModuleExists indicates an expected call of VerifyModuleExists.
-func (mr *MockRegistryMockRecorder) VerifyModuleExists(layer, pathPrefix, kernelVersion, moduleFileName interface{}) *gomock.Call {
+func (mr *MockRegistryMockRecorder) VerifyModuleExists(ctx, layer, pathPrefix, kernelVersion, moduleFileName interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyModuleExists", reflect.TypeOf((*MockRegistry)(nil).VerifyModuleExists), layer, pathPrefix, kernelVersion, moduleFileName)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyModuleExists", reflect.TypeOf((*MockRegistry)(nil).VerifyModuleExists), ctx, layer, pathPrefix, kernelVersion, moduleFileName)
 }
@@ -2,6 +2,7 @@ package registry
 
 import (
 	"archive/tar"
+	"bytes"
 	"compress/gzip"
 	"context"
 	"encoding/json"
@@ -12,19 +13,28 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/google/go-containerregistry/pkg/crane"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
 	"github.com/qbarrand/oot-operator/internal/auth"
+	"github.com/qbarrand/oot-operator/internal/metrics"
+	"github.com/qbarrand/oot-operator/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 const (
-	modulesLocationPath = "lib/modules"
+	modulesLocationPath         = "lib/modules"
+	releaseImageReferencesPath  = "release-manifests/image-references"
+	driverToolkitReleasePath    = "etc/driver-toolkit-release.json"
+	driverToolkitImageStreamTag = "driver-toolkit"
 )
 
+var errFileNotFound = errors.New("not found in the layer")
+
 type DriverToolkitEntry struct {
 	ImageURL            string `json:"imageURL"`
 	KernelFullVersion   string `json:"kernelFullVersion"`
@@ -33,6 +43,10 @@ type DriverToolkitEntry struct {
 }
 
 type RepoPullConfig struct {
+	// ImageDigest is the digest of the manifest that the layers digests were read from.
+	// It is only set by GetLayersDigests.
+	ImageDigest string
+
 	repo        string
 	authOptions []crane.Option
 }
@@ -41,15 +55,23 @@ type RepoPullConfig struct {
 
 type Registry interface {
 	ImageExists(ctx context.Context, image string, po kmmv1beta1.PullOptions, registryAuthGetter auth.RegistryAuthGetter) (bool, error)
-	VerifyModuleExists(layer v1.Layer, pathPrefix, kernelVersion, moduleFileName string) bool
+	VerifyModuleExists(ctx context.Context, layer v1.Layer, pathPrefix, kernelVersion, moduleFileName string) (bool, error)
 	GetLayersDigests(ctx context.Context, image string, registryAuthGetter auth.RegistryAuthGetter) ([]string, *RepoPullConfig, error)
 	GetLayerByDigest(digest string, pullConfig *RepoPullConfig) (v1.Layer, error)
+	ReleaseManifests(ctx context.Context, layer v1.Layer) (string, error)
+	ExtractToolkitRelease(ctx context.Context, layer v1.Layer) (*DriverToolkitEntry, error)
 }
 
-type registry struct{}
+type registry struct {
+	metricsAPI metrics.Metrics
+	tracer     trace.Tracer
+}
 
-func NewRegistry() Registry {
-	return &registry{}
+func NewRegistry(metricsAPI metrics.Metrics, tracer trace.Tracer) Registry {
+	return &registry{
+		metricsAPI: metricsAPI,
+		tracer:     tracer,
+	}
 }
 
 func (r *registry) ImageExists(ctx context.Context, image string, po kmmv1beta1.PullOptions, registryAuthGetter auth.RegistryAuthGetter) (bool, error) {
@@ -83,6 +105,13 @@ func (r *registry) GetLayersDigests(ctx context.Context, image string, registryA
 		return nil, nil, fmt.Errorf("failed to get layers digests from manifest of the image %s: %w", image, err)
 	}
 
+	imageDigest, _, err := v1.SHA256(bytes.NewReader(manifest))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to compute the digest of the manifest of the image %s: %w", image, err)
+	}
+
+	pullConfig.ImageDigest = imageDigest.String()
+
 	return digests, pullConfig, nil
 }
 
@@ -90,10 +119,93 @@ func (r *registry) GetLayerByDigest(digest string, pullConfig *RepoPullConfig) (
 	return crane.PullLayer(pullConfig.repo+"@"+digest, pullConfig.authOptions...)
 }
 
-func (r *registry) VerifyModuleExists(layer v1.Layer, pathPrefix, kernelVersion, moduleFileName string) bool {
+// VerifyModuleExists returns whether the kernel module file is in layer.
+// An error is returned if the layer could not be read.
+func (r *registry) VerifyModuleExists(ctx context.Context, layer v1.Layer, pathPrefix, kernelVersion, moduleFileName string) (bool, error) {
 	fullPath := filepath.Join(pathPrefix, modulesLocationPath, kernelVersion, moduleFileName)
-	_, err := r.getHeaderStreamFromLayer(layer, fullPath)
-	return err == nil
+
+	err := r.findFileInLayer(ctx, layer, fullPath, func(io.Reader) error { return nil })
+	if err != nil {
+		if errors.Is(err, errFileNotFound) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ReleaseManifests looks for the image references of an OpenShift release payload in layer and returns the
+// driver-toolkit image of that release.
+func (r *registry) ReleaseManifests(ctx context.Context, layer v1.Layer) (string, error) {
+	data, err := r.getFileFromLayer(ctx, layer, releaseImageReferencesPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to get %s from the layer: %w", releaseImageReferencesPath, err)
+	}
+
+	imageReferences := unstructured.Unstructured{}
+	if err = json.Unmarshal(data, &imageReferences.Object); err != nil {
+		return "", fmt.Errorf("failed to unmarshal %s: %w", releaseImageReferencesPath, err)
+	}
+
+	tags, _, err := unstructured.NestedSlice(imageReferences.Object, "spec", "tags")
+	if err != nil {
+		return "", fmt.Errorf("invalid tags in %s: %w", releaseImageReferencesPath, err)
+	}
+
+	for _, t := range tags {
+		tag, ok := t.(map[string]interface{})
+		if !ok || tag["name"] != driverToolkitImageStreamTag {
+			continue
+		}
+
+		image, found, err := unstructured.NestedString(tag, "from", "name")
+		if err != nil || !found {
+			return "", fmt.Errorf("%s tag in %s has no valid image name", driverToolkitImageStreamTag, releaseImageReferencesPath)
+		}
+
+		return image, nil
+	}
+
+	return "", fmt.Errorf("%s tag not found in %s", driverToolkitImageStreamTag, releaseImageReferencesPath)
+}
+
+// ExtractToolkitRelease looks for the driver-toolkit release file in layer and returns the kernel and OS versions
+// it describes.
+func (r *registry) ExtractToolkitRelease(ctx context.Context, layer v1.Layer) (*DriverToolkitEntry, error) {
+	data, err := r.getFileFromLayer(ctx, layer, driverToolkitReleasePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s from the layer: %w", driverToolkitReleasePath, err)
+	}
+
+	release := unstructured.Unstructured{}
+	if err = json.Unmarshal(data, &release.Object); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s: %w", driverToolkitReleasePath, err)
+	}
+
+	dtk := &DriverToolkitEntry{}
+
+	fields := []struct {
+		name  string
+		value *string
+	}{
+		{name: "KERNEL_VERSION", value: &dtk.KernelFullVersion},
+		{name: "RT_KERNEL_VERSION", value: &dtk.RTKernelFullVersion},
+		{name: "RHEL_VERSION", value: &dtk.OSVersion},
+	}
+
+	for _, f := range fields {
+		if *f.value, _, err = unstructured.NestedString(release.Object, f.name); err != nil {
+			return nil, fmt.Errorf("invalid %s in %s: %w", f.name, driverToolkitReleasePath, err)
+		}
+	}
+
+	if dtk.KernelFullVersion == "" {
+		return nil, fmt.Errorf("KERNEL_VERSION is missing from %s", driverToolkitReleasePath)
+	}
+
+	return dtk, nil
 }
 
 func (r *registry) getPullOptions(ctx context.Context, image string, po *kmmv1beta1.PullOptions, registryAuthGetter auth.RegistryAuthGetter) (*RepoPullConfig, error) {
@@ -143,7 +255,7 @@ func (r *registry) getPullOptions(ctx context.Context, image string, po *kmmv1be
 }
 
 func (r *registry) getImageManifest(ctx context.Context, image string, pullConfig *RepoPullConfig) ([]byte, error) {
-	manifest, err := r.getManifestStreamFromImage(image, pullConfig.repo, pullConfig.authOptions)
+	manifest, err := r.getManifestStreamFromImage(ctx, image, pullConfig.repo, pullConfig.authOptions)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get manifest stream from image %s: %w", image, err)
 	}
@@ -151,8 +263,22 @@ func (r *registry) getImageManifest(ctx context.Context, image string, pullConfi
 	return manifest, nil
 }
 
-func (r *registry) getManifestStreamFromImage(image, repo string, options []crane.Option) ([]byte, error) {
-	manifest, err := crane.Manifest(image, options...)
+// fetchManifest wraps crane.Manifest to record the request duration and a span.
+func (r *registry) fetchManifest(ctx context.Context, ref string, options []crane.Option) ([]byte, error) {
+	_, span := r.tracer.Start(ctx, "registry.manifest", trace.WithAttributes(tracing.ImageKey.String(ref)))
+
+	start := time.Now()
+
+	manifest, err := crane.Manifest(ref, options...)
+
+	r.metricsAPI.ObserveRegistryRequestDuration(metrics.RegistryOperationManifest, time.Since(start))
+	tracing.End(span, err)
+
+	return manifest, err
+}
+
+func (r *registry) getManifestStreamFromImage(ctx context.Context, image, repo string, options []crane.Option) ([]byte, error) {
+	manifest, err := r.fetchManifest(ctx, image, options)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get crane manifest from image %s: %w", image, err)
 	}
@@ -176,7 +302,7 @@ func (r *registry) getManifestStreamFromImage(image, repo string, options []cran
 			return nil, fmt.Errorf("failed to get arch digets from multi arch image: %w", err)
 		}
 		// get the manifest stream for the image of the architecture
-		manifest, err = crane.Manifest(repo+"@"+archDigest, options...)
+		manifest, err = r.fetchManifest(ctx, repo+"@"+archDigest, options)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get crane manifest for the arch image: %w", err)
 		}
@@ -198,18 +324,44 @@ func (r *registry) getLayersDigestsFromManifestStream(manifestStream []byte) ([]
 	return digests, nil
 }
 
-func (r *registry) getHeaderStreamFromLayer(layer v1.Layer, headerName string) (io.Reader, error) {
+func (r *registry) getFileFromLayer(ctx context.Context, layer v1.Layer, fileName string) ([]byte, error) {
+	var data []byte
+
+	err := r.findFileInLayer(ctx, layer, fileName, func(tr io.Reader) error {
+		var err error
+		data, err = io.ReadAll(tr)
+		return err
+	})
+
+	return data, err
+}
+
+// findFileInLayer reads the entries of layer until it finds headerName, and then calls fn with a reader on that entry.
+// fn is called before the layer is closed.
+// Layers are fetched lazily, so the time spent reading the layer is recorded as the layer request duration.
+func (r *registry) findFileInLayer(ctx context.Context, layer v1.Layer, headerName string, fn func(io.Reader) error) error {
+	_, span := r.tracer.Start(ctx, "registry.layer")
+	defer span.End()
+
+	if digest, err := layer.Digest(); err == nil {
+		span.SetAttributes(tracing.LayerDigestKey.String(digest.String()))
+	}
+
+	start := time.Now()
+	defer func() {
+		r.metricsAPI.ObserveRegistryRequestDuration(metrics.RegistryOperationLayer, time.Since(start))
+	}()
 
 	targz, err := layer.Compressed()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get targz from layer: %w", err)
+		return fmt.Errorf("failed to get targz from layer: %w", err)
 	}
 	// err ignored because we're only reading
 	defer targz.Close()
 
 	gr, err := gzip.NewReader(targz)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create reader from targz: %w", err)
+		return fmt.Errorf("failed to create reader from targz: %w", err)
 	}
 	// err ignored because we're only reading
 	defer gr.Close()
@@ -223,14 +375,14 @@ func (r *registry) getHeaderStreamFromLayer(layer v1.Layer, headerName string) (
 				break
 			}
 
-			return nil, fmt.Errorf("failed to get next entry from targz: %w", err)
+			return fmt.Errorf("failed to get next entry from targz: %w", err)
 		}
 		if header.Name == headerName {
-			return tr, nil
+			return fn(tr)
 		}
 	}
 
-	return nil, fmt.Errorf("header %s not found in the layer", headerName)
+	return fmt.Errorf("header %s: %w", headerName, errFileNotFound)
 }
 
 func (r *registry) getImageDigestFromMultiImage(manifestListStream []byte) (string, error) {
@@ -2,6 +2,7 @@ package registry
 
 import (
 	context "context"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,10 +13,17 @@ import (
 
 	"github.com/golang/mock/gomock"
 	"github.com/google/go-containerregistry/pkg/authn"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/types"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
 	"github.com/qbarrand/oot-operator/internal/auth"
+	"github.com/qbarrand/oot-operator/internal/metrics"
+	"github.com/qbarrand/oot-operator/internal/test"
+	"github.com/qbarrand/oot-operator/internal/tracing"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
@@ -41,7 +49,7 @@ var _ = Describe("ImageExists", func() {
 		ctrl = gomock.NewController(GinkgoT())
 		ctx = context.TODO()
 		mockRegistryAuthGetter = auth.NewMockRegistryAuthGetter(ctrl)
-		reg = NewRegistry()
+		reg = NewRegistry(metrics.New(), test.NoopTracer())
 	})
 
 	AfterEach(func() {
@@ -199,6 +207,7 @@ var _ = Describe("GetLayersDigests", func() {
 		ctx                    context.Context
 		mockRegistryAuthGetter *auth.MockRegistryAuthGetter
 		reg                    Registry
+		spanRecorder           *tracetest.SpanRecorder
 		validImage             = fmt.Sprintf("%s/%s/%s:%s", validImageHost, validImageOrg, validImageName, validImageTag)
 	)
 
@@ -206,7 +215,9 @@ var _ = Describe("GetLayersDigests", func() {
 		ctrl = gomock.NewController(GinkgoT())
 		ctx = context.TODO()
 		mockRegistryAuthGetter = auth.NewMockRegistryAuthGetter(ctrl)
-		reg = NewRegistry()
+		tracer, sr := test.TestTracer()
+		spanRecorder = sr
+		reg = NewRegistry(metrics.New(), tracer)
 	})
 
 	AfterEach(func() {
@@ -321,14 +332,26 @@ var _ = Describe("GetLayersDigests", func() {
 			mockRegistryAuthGetter.EXPECT().GetKeyChain(ctx).Return(authn.DefaultKeychain, nil)
 		}
 
-		var err error
+		var (
+			err        error
+			pullConfig *RepoPullConfig
+		)
 		image := fmt.Sprintf("%s/%s/%s:%s", u.Host, validImageOrg, validImageName, validImageTag)
 		if withRegistryAuthGetter {
-			_, _, err = reg.GetLayersDigests(ctx, image, mockRegistryAuthGetter)
+			_, pullConfig, err = reg.GetLayersDigests(ctx, image, mockRegistryAuthGetter)
 		} else {
-			_, _, err = reg.GetLayersDigests(ctx, image, nil)
+			_, pullConfig, err = reg.GetLayersDigests(ctx, image, nil)
 		}
 		Expect(err).ToNot(HaveOccurred())
+
+		manifest, err := os.ReadFile("testdata/image_manifest.json")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pullConfig.ImageDigest).To(Equal(fmt.Sprintf("sha256:%x", sha256.Sum256(manifest))))
+
+		spans := spanRecorder.Ended()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].Name()).To(Equal("registry.manifest"))
+		Expect(spans[0].Attributes()).To(ContainElement(tracing.ImageKey.String(image)))
 	},
 		Entry("with public registry", false),
 		Entry("with private registry", true),
@@ -336,14 +359,15 @@ var _ = Describe("GetLayersDigests", func() {
 })
 
 var _ = Describe("VerifyModuleExists", func() {
-	reg := NewRegistry()
+	reg := NewRegistry(metrics.New(), test.NoopTracer())
 
 	It("file is not present", func() {
 		const fileName = "/etc/fileName"
 		layer, err := prepareLayer(fileName, []byte("some data"))
 		Expect(err).ToNot(HaveOccurred())
 
-		res := reg.VerifyModuleExists(layer, "", "somekernel", "module_name.ko")
+		res, err := reg.VerifyModuleExists(context.TODO(), layer, "", "somekernel", "module_name.ko")
+		Expect(err).ToNot(HaveOccurred())
 		Expect(res).To(BeFalse())
 	})
 
@@ -352,9 +376,120 @@ var _ = Describe("VerifyModuleExists", func() {
 		layer, err := prepareLayer(fileName, []byte("some data"))
 		Expect(err).ToNot(HaveOccurred())
 
-		res := reg.VerifyModuleExists(layer, "/opt", "somekernel", "module_name.ko")
+		res, err := reg.VerifyModuleExists(context.TODO(), layer, "/opt", "somekernel", "module_name.ko")
+		Expect(err).ToNot(HaveOccurred())
 		Expect(res).To(BeTrue())
 	})
+
+	It("should return an error if the layer cannot be read", func() {
+		layer, err := partial.UncompressedToLayer(&uncompressedLayer{
+			diffID:    v1.Hash{Algorithm: "sha256", Hex: fmt.Sprintf("%x", sha256.Sum256([]byte("not a tar archive")))},
+			mediaType: types.DockerLayer,
+			content:   []byte("not a tar archive"),
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = reg.VerifyModuleExists(context.TODO(), layer, "", "somekernel", "module_name.ko")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should record a span for the layer", func() {
+		tracer, sr := test.TestTracer()
+
+		layer, err := prepareLayer("/etc/fileName", []byte("some data"))
+		Expect(err).ToNot(HaveOccurred())
+
+		digest, err := layer.Digest()
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = NewRegistry(metrics.New(), tracer).VerifyModuleExists(context.TODO(), layer, "", "somekernel", "module_name.ko")
+		Expect(err).ToNot(HaveOccurred())
+
+		spans := sr.Ended()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].Name()).To(Equal("registry.layer"))
+		Expect(spans[0].Attributes()).To(ContainElement(tracing.LayerDigestKey.String(digest.String())))
+	})
+})
+
+var _ = Describe("ReleaseManifests", func() {
+	reg := NewRegistry(metrics.New(), test.NoopTracer())
+
+	It("should return an error if the image references are not present", func() {
+		layer, err := prepareLayer("/etc/fileName", []byte("some data"))
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = reg.ReleaseManifests(context.TODO(), layer)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should return an error if there is no driver-toolkit tag", func() {
+		const imageReferences = `{"kind":"ImageStream","spec":{"tags":[{"name":"other","from":{"name":"quay.io/org/other@sha256:1234"}}]}}`
+
+		layer, err := prepareLayer("release-manifests/image-references", []byte(imageReferences))
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = reg.ReleaseManifests(context.TODO(), layer)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should return the driver-toolkit image", func() {
+		const imageReferences = `{
+  "kind": "ImageStream",
+  "spec": {
+    "tags": [
+      {"name": "other", "from": {"name": "quay.io/org/other@sha256:1234"}},
+      {"name": "driver-toolkit", "from": {"name": "quay.io/org/dtk@sha256:5678"}}
+    ]
+  }
+}`
+
+		layer, err := prepareLayer("release-manifests/image-references", []byte(imageReferences))
+		Expect(err).ToNot(HaveOccurred())
+
+		res, err := reg.ReleaseManifests(context.TODO(), layer)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal("quay.io/org/dtk@sha256:5678"))
+	})
+})
+
+var _ = Describe("ExtractToolkitRelease", func() {
+	reg := NewRegistry(metrics.New(), test.NoopTracer())
+
+	It("should return an error if the release file is not present", func() {
+		layer, err := prepareLayer("/etc/fileName", []byte("some data"))
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = reg.ExtractToolkitRelease(context.TODO(), layer)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should return an error if the kernel version is missing", func() {
+		layer, err := prepareLayer("etc/driver-toolkit-release.json", []byte(`{"RHEL_VERSION": "8.6"}`))
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = reg.ExtractToolkitRelease(context.TODO(), layer)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should return the kernel and OS versions", func() {
+		const release = `{
+  "KERNEL_VERSION": "4.18.0-372.19.1.el8_6.x86_64",
+  "RT_KERNEL_VERSION": "4.18.0-372.19.1.rt7.176.el8_6.x86_64",
+  "RHEL_VERSION": "8.6"
+}`
+
+		layer, err := prepareLayer("etc/driver-toolkit-release.json", []byte(release))
+		Expect(err).ToNot(HaveOccurred())
+
+		res, err := reg.ExtractToolkitRelease(context.TODO(), layer)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*res).To(Equal(DriverToolkitEntry{
+			KernelFullVersion:   "4.18.0-372.19.1.el8_6.x86_64",
+			RTKernelFullVersion: "4.18.0-372.19.1.rt7.176.el8_6.x86_64",
+			OSVersion:           "8.6",
+		}))
+	})
 })
 
 func mustParseURL(rawURL string) *url.URL {
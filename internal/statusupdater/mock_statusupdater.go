@@ -39,17 +39,54 @@ func (m *MockModuleStatusUpdater) EXPECT() *MockModuleStatusUpdaterMockRecorder
 }
 
 // ModuleUpdateStatus mocks base method.
-func (m *MockModuleStatusUpdater) ModuleUpdateStatus(ctx context.Context, mod *v1beta1.Module, kernelMappingNodes, targetedNodes []v10.Node, dsByKernelVersion map[string]*v1.DaemonSet) error {
+func (m *MockModuleStatusUpdater) ModuleUpdateStatus(ctx context.Context, mod *v1beta1.Module, kernelMappingNodes, targetedNodes []v10.Node, dsByKernelVersion, devicePluginDSByKernelVersion map[string]*v1.DaemonSet, conflicts []v1beta1.ModuleConflict) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ModuleUpdateStatus", ctx, mod, kernelMappingNodes, targetedNodes, dsByKernelVersion)
+	ret := m.ctrl.Call(m, "ModuleUpdateStatus", ctx, mod, kernelMappingNodes, targetedNodes, dsByKernelVersion, devicePluginDSByKernelVersion, conflicts)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // ModuleUpdateStatus indicates an expected call of ModuleUpdateStatus.
-func (mr *MockModuleStatusUpdaterMockRecorder) ModuleUpdateStatus(ctx, mod, kernelMappingNodes, targetedNodes, dsByKernelVersion interface{}) *gomock.Call {
+func (mr *MockModuleStatusUpdaterMockRecorder) ModuleUpdateStatus(ctx, mod, kernelMappingNodes, targetedNodes, dsByKernelVersion, devicePluginDSByKernelVersion, conflicts interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ModuleUpdateStatus", reflect.TypeOf((*MockModuleStatusUpdater)(nil).ModuleUpdateStatus), ctx, mod, kernelMappingNodes, targetedNodes, dsByKernelVersion)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ModuleUpdateStatus", reflect.TypeOf((*MockModuleStatusUpdater)(nil).ModuleUpdateStatus), ctx, mod, kernelMappingNodes, targetedNodes, dsByKernelVersion, devicePluginDSByKernelVersion, conflicts)
+}
+
+// MockClusterModuleStatusUpdater is a mock of ClusterModuleStatusUpdater interface.
+type MockClusterModuleStatusUpdater struct {
+	ctrl     *gomock.Controller
+	recorder *MockClusterModuleStatusUpdaterMockRecorder
+}
+
+// MockClusterModuleStatusUpdaterMockRecorder is the mock recorder for MockClusterModuleStatusUpdater.
+type MockClusterModuleStatusUpdaterMockRecorder struct {
+	mock *MockClusterModuleStatusUpdater
+}
+
+// NewMockClusterModuleStatusUpdater creates a new mock instance.
+func NewMockClusterModuleStatusUpdater(ctrl *gomock.Controller) *MockClusterModuleStatusUpdater {
+	mock := &MockClusterModuleStatusUpdater{ctrl: ctrl}
+	mock.recorder = &MockClusterModuleStatusUpdaterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockClusterModuleStatusUpdater) EXPECT() *MockClusterModuleStatusUpdaterMockRecorder {
+	return m.recorder
+}
+
+// ClusterModuleUpdateStatus mocks base method.
+func (m *MockClusterModuleStatusUpdater) ClusterModuleUpdateStatus(ctx context.Context, cm *v1beta1.ClusterModule, mod *v1beta1.Module, conflicts []v1beta1.ModuleConflict) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClusterModuleUpdateStatus", ctx, cm, mod, conflicts)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ClusterModuleUpdateStatus indicates an expected call of ClusterModuleUpdateStatus.
+func (mr *MockClusterModuleStatusUpdaterMockRecorder) ClusterModuleUpdateStatus(ctx, cm, mod, conflicts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClusterModuleUpdateStatus", reflect.TypeOf((*MockClusterModuleStatusUpdater)(nil).ClusterModuleUpdateStatus), ctx, cm, mod, conflicts)
 }
 
 // MockPreflightStatusUpdater is a mock of PreflightStatusUpdater interface.
@@ -76,7 +113,7 @@ func (m *MockPreflightStatusUpdater) EXPECT() *MockPreflightStatusUpdaterMockRec
 }
 
 // PreflightPresetStatuses mocks base method.
-func (m *MockPreflightStatusUpdater) PreflightPresetStatuses(ctx context.Context, pv *v1beta1.PreflightValidation, existingModules sets.String, newModules []string) error {
+func (m *MockPreflightStatusUpdater) PreflightPresetStatuses(ctx context.Context, pv *v1beta1.PreflightValidation, existingModules sets.String, newModules map[string]int64) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "PreflightPresetStatuses", ctx, pv, existingModules, newModules)
 	ret0, _ := ret[0].(error)
@@ -89,6 +126,34 @@ func (mr *MockPreflightStatusUpdaterMockRecorder) PreflightPresetStatuses(ctx, p
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PreflightPresetStatuses", reflect.TypeOf((*MockPreflightStatusUpdater)(nil).PreflightPresetStatuses), ctx, pv, existingModules, newModules)
 }
 
+// PreflightSetReportConfigMap mocks base method.
+func (m *MockPreflightStatusUpdater) PreflightSetReportConfigMap(ctx context.Context, preflight *v1beta1.PreflightValidation, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PreflightSetReportConfigMap", ctx, preflight, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PreflightSetReportConfigMap indicates an expected call of PreflightSetReportConfigMap.
+func (mr *MockPreflightStatusUpdaterMockRecorder) PreflightSetReportConfigMap(ctx, preflight, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PreflightSetReportConfigMap", reflect.TypeOf((*MockPreflightStatusUpdater)(nil).PreflightSetReportConfigMap), ctx, preflight, name)
+}
+
+// PreflightSetSummary mocks base method.
+func (m *MockPreflightStatusUpdater) PreflightSetSummary(ctx context.Context, preflight *v1beta1.PreflightValidation, summary v1beta1.PreflightSummary) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PreflightSetSummary", ctx, preflight, summary)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PreflightSetSummary indicates an expected call of PreflightSetSummary.
+func (mr *MockPreflightStatusUpdaterMockRecorder) PreflightSetSummary(ctx, preflight, summary interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PreflightSetSummary", reflect.TypeOf((*MockPreflightStatusUpdater)(nil).PreflightSetSummary), ctx, preflight, summary)
+}
+
 // PreflightSetVerificationStage mocks base method.
 func (m *MockPreflightStatusUpdater) PreflightSetVerificationStage(ctx context.Context, preflight *v1beta1.PreflightValidation, moduleName, stage string) error {
 	m.ctrl.T.Helper()
@@ -104,15 +169,15 @@ func (mr *MockPreflightStatusUpdaterMockRecorder) PreflightSetVerificationStage(
 }
 
 // PreflightSetVerificationStatus mocks base method.
-func (m *MockPreflightStatusUpdater) PreflightSetVerificationStatus(ctx context.Context, preflight *v1beta1.PreflightValidation, moduleName, verificationStatus, message string) error {
+func (m *MockPreflightStatusUpdater) PreflightSetVerificationStatus(ctx context.Context, preflight *v1beta1.PreflightValidation, moduleName, verificationStatus, message string, checkedImages []v1beta1.CheckedImage) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "PreflightSetVerificationStatus", ctx, preflight, moduleName, verificationStatus, message)
+	ret := m.ctrl.Call(m, "PreflightSetVerificationStatus", ctx, preflight, moduleName, verificationStatus, message, checkedImages)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // PreflightSetVerificationStatus indicates an expected call of PreflightSetVerificationStatus.
-func (mr *MockPreflightStatusUpdaterMockRecorder) PreflightSetVerificationStatus(ctx, preflight, moduleName, verificationStatus, message interface{}) *gomock.Call {
+func (mr *MockPreflightStatusUpdaterMockRecorder) PreflightSetVerificationStatus(ctx, preflight, moduleName, verificationStatus, message, checkedImages interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PreflightSetVerificationStatus", reflect.TypeOf((*MockPreflightStatusUpdater)(nil).PreflightSetVerificationStatus), ctx, preflight, moduleName, verificationStatus, message)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PreflightSetVerificationStatus", reflect.TypeOf((*MockPreflightStatusUpdater)(nil).PreflightSetVerificationStatus), ctx, preflight, moduleName, verificationStatus, message, checkedImages)
 }
@@ -3,6 +3,7 @@ package statusupdater
 import (
 	"context"
 	"fmt"
+	"sort"
 	"time"
 
 	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
@@ -19,18 +20,29 @@ import (
 
 type ModuleStatusUpdater interface {
 	ModuleUpdateStatus(ctx context.Context, mod *kmmv1beta1.Module, kernelMappingNodes []v1.Node,
-		targetedNodes []v1.Node, dsByKernelVersion map[string]*appsv1.DaemonSet) error
+		targetedNodes []v1.Node, dsByKernelVersion map[string]*appsv1.DaemonSet,
+		devicePluginDSByKernelVersion map[string]*appsv1.DaemonSet, conflicts []kmmv1beta1.ModuleConflict) error
+}
+
+//go:generate mockgen -source=statusupdater.go -package=statusupdater -destination=mock_statusupdater.go
+
+type ClusterModuleStatusUpdater interface {
+	ClusterModuleUpdateStatus(ctx context.Context, cm *kmmv1beta1.ClusterModule, mod *kmmv1beta1.Module,
+		conflicts []kmmv1beta1.ModuleConflict) error
 }
 
 //go:generate mockgen -source=statusupdater.go -package=statusupdater -destination=mock_statusupdater.go
 
 type PreflightStatusUpdater interface {
 	PreflightPresetStatuses(ctx context.Context, pv *kmmv1beta1.PreflightValidation,
-		existingModules sets.String, newModules []string) error
+		existingModules sets.String, newModules map[string]int64) error
 	PreflightSetVerificationStatus(ctx context.Context, preflight *kmmv1beta1.PreflightValidation, moduleName string,
-		verificationStatus string, message string) error
+		verificationStatus string, message string, checkedImages []kmmv1beta1.CheckedImage) error
 	PreflightSetVerificationStage(ctx context.Context, preflight *kmmv1beta1.PreflightValidation,
 		moduleName string, stage string) error
+	PreflightSetSummary(ctx context.Context, preflight *kmmv1beta1.PreflightValidation,
+		summary kmmv1beta1.PreflightSummary) error
+	PreflightSetReportConfigMap(ctx context.Context, preflight *kmmv1beta1.PreflightValidation, name string) error
 }
 
 type moduleStatusUpdater struct {
@@ -39,6 +51,10 @@ type moduleStatusUpdater struct {
 	metricsAPI metrics.Metrics
 }
 
+type clusterModuleStatusUpdater struct {
+	client client.Client
+}
+
 type preflightStatusUpdater struct {
 	client client.Client
 }
@@ -51,6 +67,12 @@ func NewModuleStatusUpdater(client client.Client, daemonAPI daemonset.DaemonSetC
 	}
 }
 
+func NewClusterModuleStatusUpdater(client client.Client) ClusterModuleStatusUpdater {
+	return &clusterModuleStatusUpdater{
+		client: client,
+	}
+}
+
 func NewPreflightStatusUpdater(client client.Client) PreflightStatusUpdater {
 	return &preflightStatusUpdater{
 		client: client,
@@ -61,7 +83,9 @@ func (m *moduleStatusUpdater) ModuleUpdateStatus(ctx context.Context,
 	mod *kmmv1beta1.Module,
 	kernelMappingNodes []v1.Node,
 	targetedNodes []v1.Node,
-	dsByKernelVersion map[string]*appsv1.DaemonSet) error {
+	dsByKernelVersion map[string]*appsv1.DaemonSet,
+	devicePluginDSByKernelVersion map[string]*appsv1.DaemonSet,
+	conflicts []kmmv1beta1.ModuleConflict) error {
 
 	nodesMatchingSelectorNumber := int32(len(targetedNodes))
 	numDesired := int32(len(kernelMappingNodes))
@@ -74,6 +98,11 @@ func (m *moduleStatusUpdater) ModuleUpdateStatus(ctx context.Context,
 			numAvailableKernelModule += ds.Status.NumberAvailable
 		}
 	}
+	for _, ds := range devicePluginDSByKernelVersion {
+		numAvailableDevicePlugin += ds.Status.NumberAvailable
+	}
+	mod.Status.UnmappedNodes = unmappedNodes(kernelMappingNodes, targetedNodes)
+	mod.Status.Conflicts = conflicts
 	mod.Status.ModuleLoader.NodesMatchingSelectorNumber = nodesMatchingSelectorNumber
 	mod.Status.ModuleLoader.DesiredNumber = numDesired
 	mod.Status.ModuleLoader.AvailableNumber = numAvailableKernelModule
@@ -82,12 +111,53 @@ func (m *moduleStatusUpdater) ModuleUpdateStatus(ctx context.Context,
 		mod.Status.DevicePlugin.DesiredNumber = numDesired
 		mod.Status.DevicePlugin.AvailableNumber = numAvailableDevicePlugin
 	}
-	m.updateMetrics(ctx, mod, dsByKernelVersion)
+	m.updateMetrics(ctx, mod, dsByKernelVersion, devicePluginDSByKernelVersion)
 	return m.client.Status().Update(ctx, mod)
 }
 
+// unmappedNodes returns the targeted nodes that are not in kernelMappingNodes, sorted by name.
+func unmappedNodes(kernelMappingNodes []v1.Node, targetedNodes []v1.Node) []kmmv1beta1.UnmappedNode {
+	mapped := sets.NewString()
+
+	for _, n := range kernelMappingNodes {
+		mapped.Insert(n.Name)
+	}
+
+	unmapped := make([]kmmv1beta1.UnmappedNode, 0)
+
+	for _, n := range targetedNodes {
+		if !mapped.Has(n.Name) {
+			unmapped = append(unmapped, kmmv1beta1.UnmappedNode{Name: n.Name, KernelVersion: n.Status.NodeInfo.KernelVersion})
+		}
+	}
+
+	if len(unmapped) == 0 {
+		return nil
+	}
+
+	sort.Slice(unmapped, func(i, j int) bool {
+		return unmapped[i].Name < unmapped[j].Name
+	})
+
+	return unmapped
+}
+
+// ClusterModuleUpdateStatus sets the status of cm to the one of the Module it was deployed as, with the conflicts
+// found for cm.
+func (c *clusterModuleStatusUpdater) ClusterModuleUpdateStatus(ctx context.Context,
+	cm *kmmv1beta1.ClusterModule,
+	mod *kmmv1beta1.Module,
+	conflicts []kmmv1beta1.ModuleConflict) error {
+
+	mod.Status.DeepCopyInto(&cm.Status)
+	cm.Status.Conflicts = conflicts
+	return c.client.Status().Update(ctx, cm)
+}
+
+// PreflightPresetStatuses removes the statuses of the Modules that are not in existingModules, and resets those of
+// newModules, which map the status keys of the Modules to their generation.
 func (p *preflightStatusUpdater) PreflightPresetStatuses(ctx context.Context,
-	pv *kmmv1beta1.PreflightValidation, existingModules sets.String, newModules []string) error {
+	pv *kmmv1beta1.PreflightValidation, existingModules sets.String, newModules map[string]int64) error {
 
 	modulesInStatus := sets.StringKeySet(pv.Status.CRStatuses)
 	modulesToDelete := modulesInStatus.Difference(existingModules).UnsortedList()
@@ -95,24 +165,29 @@ func (p *preflightStatusUpdater) PreflightPresetStatuses(ctx context.Context,
 		delete(pv.Status.CRStatuses, moduleName)
 	}
 
-	for _, moduleName := range newModules {
+	for moduleName, generation := range newModules {
 		pv.Status.CRStatuses[moduleName] = &kmmv1beta1.CRStatus{
 			VerificationStatus: kmmv1beta1.VerificationFalse,
 			VerificationStage:  kmmv1beta1.VerificationStageImage,
 			LastTransitionTime: metav1.NewTime(time.Now()),
+			ModuleGeneration:   generation,
 		}
 	}
 	return p.client.Status().Update(ctx, pv)
 }
 
 func (p *preflightStatusUpdater) PreflightSetVerificationStatus(ctx context.Context, pv *kmmv1beta1.PreflightValidation, moduleName string,
-	verificationStatus string, message string) error {
+	verificationStatus string, message string, checkedImages []kmmv1beta1.CheckedImage) error {
 	if _, ok := pv.Status.CRStatuses[moduleName]; !ok {
 		return fmt.Errorf("failed to find module status %s in preflight %s", moduleName, pv.Name)
 	}
 	pv.Status.CRStatuses[moduleName].VerificationStatus = verificationStatus
 	pv.Status.CRStatuses[moduleName].StatusReason = message
 	pv.Status.CRStatuses[moduleName].LastTransitionTime = metav1.NewTime(time.Now())
+	// the build stage does not look images up; keep the results of the image stage
+	if len(checkedImages) > 0 {
+		pv.Status.CRStatuses[moduleName].CheckedImages = checkedImages
+	}
 	return p.client.Status().Update(ctx, pv)
 }
 
@@ -126,7 +201,36 @@ func (p *preflightStatusUpdater) PreflightSetVerificationStage(ctx context.Conte
 	return p.client.Status().Update(ctx, pv)
 }
 
-func (m *moduleStatusUpdater) updateMetrics(ctx context.Context, mod *kmmv1beta1.Module, dsByKernelVersion map[string]*appsv1.DaemonSet) {
+func (p *preflightStatusUpdater) PreflightSetSummary(ctx context.Context, pv *kmmv1beta1.PreflightValidation,
+	summary kmmv1beta1.PreflightSummary) error {
+	phase := kmmv1beta1.PreflightPhaseInProgress
+	if summary.InProgress == 0 {
+		phase = kmmv1beta1.PreflightPhaseCompleted
+	}
+
+	if phase == kmmv1beta1.PreflightPhaseCompleted && pv.Status.Phase != kmmv1beta1.PreflightPhaseCompleted {
+		now := metav1.NewTime(time.Now())
+		pv.Status.CompletionTime = &now
+	}
+
+	pv.Status.Phase = phase
+	pv.Status.Summary = summary
+	pv.Status.ObservedGeneration = pv.Generation
+	return p.client.Status().Update(ctx, pv)
+}
+
+func (p *preflightStatusUpdater) PreflightSetReportConfigMap(ctx context.Context, pv *kmmv1beta1.PreflightValidation, name string) error {
+	pv.Status.ReportConfigMap = &v1.LocalObjectReference{Name: name}
+	return p.client.Status().Update(ctx, pv)
+}
+
+func (m *moduleStatusUpdater) updateMetrics(ctx context.Context,
+	mod *kmmv1beta1.Module,
+	dsByKernelVersion map[string]*appsv1.DaemonSet,
+	devicePluginDSByKernelVersion map[string]*appsv1.DaemonSet) {
+	m.metricsAPI.SetUnmappedNodes(mod.Name, mod.Namespace, len(mod.Status.UnmappedNodes))
+	m.metricsAPI.SetNodesLoaded(mod.Name, mod.Namespace, int(mod.Status.ModuleLoader.AvailableNumber))
+
 	for kernelVersion, ds := range dsByKernelVersion {
 		stage := metrics.ModuleLoaderStage
 		if daemonset.IsDevicePluginKernelVersion(kernelVersion) {
@@ -138,4 +242,12 @@ func (m *moduleStatusUpdater) updateMetrics(ctx context.Context, mod *kmmv1beta1
 			stage,
 			ds.Status.DesiredNumberScheduled == ds.Status.NumberAvailable)
 	}
+
+	for kernelVersion, ds := range devicePluginDSByKernelVersion {
+		m.metricsAPI.SetCompletedStage(mod.Name,
+			mod.Namespace,
+			kernelVersion,
+			metrics.DevicePluginStage,
+			ds.Status.DesiredNumberScheduled == ds.Status.NumberAvailable)
+	}
 }
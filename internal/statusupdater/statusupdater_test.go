@@ -55,6 +55,8 @@ var _ = Describe("module status update", func() {
 			var moduleLoaderAvailable int32
 			var devicePluginAvailable int32
 
+			mockMetrics.EXPECT().SetUnmappedNodes(name, namespace, 0)
+
 			for kernelVersion, ds := range dsMap {
 				if daemonset.IsDevicePluginKernelVersion(kernelVersion) {
 					devicePluginAvailable = ds.Status.NumberAvailable
@@ -72,11 +74,12 @@ var _ = Describe("module status update", func() {
 						ds.Status.NumberAvailable == ds.Status.DesiredNumberScheduled)
 				}
 			}
+			mockMetrics.EXPECT().SetNodesLoaded(name, namespace, int(moduleLoaderAvailable))
 			statusWrite := client.NewMockStatusWriter(ctrl)
 			clnt.EXPECT().Status().Return(statusWrite)
 			statusWrite.EXPECT().Update(context.Background(), mod).Return(nil)
 
-			res := su.ModuleUpdateStatus(context.Background(), mod, mappingsNodes, targetedNodes, dsMap)
+			res := su.ModuleUpdateStatus(context.Background(), mod, mappingsNodes, targetedNodes, dsMap, nil, nil)
 
 			Expect(res).To(BeNil())
 			Expect(mod.Status.ModuleLoader.NodesMatchingSelectorNumber).To(Equal(int32(len(targetedNodes))))
@@ -117,6 +120,94 @@ var _ = Describe("module status update", func() {
 			true,
 		),
 	)
+
+	It("should list the targeted nodes without a kernel mapping", func() {
+		node := func(name, kernel string) v1.Node {
+			return v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				Status: v1.NodeStatus{
+					NodeInfo: v1.NodeSystemInfo{KernelVersion: kernel},
+				},
+			}
+		}
+
+		mapped := node("mapped", "kernel-1")
+		targetedNodes := []v1.Node{node("unmapped-b", "kernel-3"), mapped, node("unmapped-a", "kernel-2")}
+
+		statusWrite := client.NewMockStatusWriter(ctrl)
+
+		gomock.InOrder(
+			mockMetrics.EXPECT().SetUnmappedNodes(name, namespace, 2),
+			mockMetrics.EXPECT().SetNodesLoaded(name, namespace, 0),
+			clnt.EXPECT().Status().Return(statusWrite),
+			statusWrite.EXPECT().Update(context.Background(), mod),
+		)
+
+		Expect(
+			su.ModuleUpdateStatus(context.Background(), mod, []v1.Node{mapped}, targetedNodes, nil, nil, nil),
+		).To(
+			Succeed(),
+		)
+
+		Expect(mod.Status.UnmappedNodes).To(Equal([]kmmv1beta1.UnmappedNode{
+			{Name: "unmapped-a", KernelVersion: "kernel-2"},
+			{Name: "unmapped-b", KernelVersion: "kernel-3"},
+		}))
+	})
+
+	It("should set the conflicts", func() {
+		conflicts := []kmmv1beta1.ModuleConflict{{Name: "other", Namespace: "other-ns", NodesNumber: 1, Yielded: true}}
+
+		statusWrite := client.NewMockStatusWriter(ctrl)
+
+		gomock.InOrder(
+			mockMetrics.EXPECT().SetUnmappedNodes(name, namespace, 0),
+			mockMetrics.EXPECT().SetNodesLoaded(name, namespace, 0),
+			clnt.EXPECT().Status().Return(statusWrite),
+			statusWrite.EXPECT().Update(context.Background(), mod),
+		)
+
+		Expect(
+			su.ModuleUpdateStatus(context.Background(), mod, nil, nil, nil, nil, conflicts),
+		).To(
+			Succeed(),
+		)
+
+		Expect(mod.Status.Conflicts).To(Equal(conflicts))
+	})
+})
+
+var _ = Describe("ClusterModuleUpdateStatus", func() {
+	It("should copy the status of the Module and set the conflicts", func() {
+		ctrl := gomock.NewController(GinkgoT())
+		clnt := client.NewMockClient(ctrl)
+		statusWrite := client.NewMockStatusWriter(ctrl)
+
+		cm := &kmmv1beta1.ClusterModule{ObjectMeta: metav1.ObjectMeta{Name: "cluster-module"}}
+		mod := &kmmv1beta1.Module{
+			Status: kmmv1beta1.ModuleStatus{
+				ModuleLoader:  kmmv1beta1.DaemonSetStatus{NodesMatchingSelectorNumber: 3, DesiredNumber: 2, AvailableNumber: 1},
+				UnmappedNodes: []kmmv1beta1.UnmappedNode{{Name: "node", KernelVersion: "kernel"}},
+			},
+		}
+		conflicts := []kmmv1beta1.ModuleConflict{{Name: "other", Namespace: "ns", NodesNumber: 1}}
+
+		gomock.InOrder(
+			clnt.EXPECT().Status().Return(statusWrite),
+			statusWrite.EXPECT().Update(context.Background(), cm),
+		)
+
+		Expect(
+			NewClusterModuleStatusUpdater(clnt).ClusterModuleUpdateStatus(context.Background(), cm, mod, conflicts),
+		).To(
+			Succeed(),
+		)
+
+		expected := *mod.Status.DeepCopy()
+		expected.Conflicts = conflicts
+
+		Expect(cm.Status).To(Equal(expected))
+	})
 })
 
 var _ = Describe("preflight status updates", func() {
@@ -146,7 +237,7 @@ var _ = Describe("preflight status updates", func() {
 		pv.Status.CRStatuses["moduleName2"] = &kmmv1beta1.CRStatus{VerificationStage: kmmv1beta1.VerificationStageBuild}
 		pv.Status.CRStatuses["moduleName3"] = &kmmv1beta1.CRStatus{VerificationStage: kmmv1beta1.VerificationStageImage}
 		existingModules := sets.NewString("moduleName1", "moduleName2")
-		newModules := []string{"moduleName4"}
+		newModules := map[string]int64{"moduleName4": 2}
 
 		statusWrite := client.NewMockStatusWriter(ctrl)
 		clnt.EXPECT().Status().Return(statusWrite)
@@ -158,6 +249,7 @@ var _ = Describe("preflight status updates", func() {
 		Expect(pv.Status.CRStatuses["moduleName2"].VerificationStage).To(Equal(kmmv1beta1.VerificationStageBuild))
 		Expect(pv.Status.CRStatuses["moduleName4"].VerificationStage).To(Equal(kmmv1beta1.VerificationStageImage))
 		Expect(pv.Status.CRStatuses["moduleName4"].VerificationStatus).To(Equal(kmmv1beta1.VerificationFalse))
+		Expect(pv.Status.CRStatuses["moduleName4"].ModuleGeneration).To(BeEquivalentTo(2))
 		_, ok := pv.Status.CRStatuses["moduleName3"]
 		Expect(ok).To(BeFalse())
 	})
@@ -168,10 +260,35 @@ var _ = Describe("preflight status updates", func() {
 		clnt.EXPECT().Status().Return(statusWrite)
 		statusWrite.EXPECT().Update(context.Background(), pv).Return(nil)
 
-		res := su.PreflightSetVerificationStatus(context.Background(), pv, moduleName, "verificationStatus", "verificationReason")
+		checkedImages := []kmmv1beta1.CheckedImage{{KernelVersion: "kernel", Image: "image", Digest: "digest"}}
+
+		res := su.PreflightSetVerificationStatus(context.Background(), pv, moduleName, "verificationStatus", "verificationReason", checkedImages)
 		Expect(res).To(BeNil())
 		Expect(pv.Status.CRStatuses[moduleName].VerificationStatus).To(Equal("verificationStatus"))
 		Expect(pv.Status.CRStatuses[moduleName].StatusReason).To(Equal("verificationReason"))
+		Expect(pv.Status.CRStatuses[moduleName].CheckedImages).To(Equal(checkedImages))
+	})
+
+	It("set preflight verification status, should keep the checked images if none were checked", func() {
+		checkedImages := []kmmv1beta1.CheckedImage{{KernelVersion: "kernel", Image: "image", Digest: "digest"}}
+		pv.Status.CRStatuses[moduleName] = &kmmv1beta1.CRStatus{CheckedImages: checkedImages}
+		statusWrite := client.NewMockStatusWriter(ctrl)
+		clnt.EXPECT().Status().Return(statusWrite)
+		statusWrite.EXPECT().Update(context.Background(), pv).Return(nil)
+
+		res := su.PreflightSetVerificationStatus(context.Background(), pv, moduleName, "verificationStatus", "verificationReason", nil)
+		Expect(res).To(BeNil())
+		Expect(pv.Status.CRStatuses[moduleName].CheckedImages).To(Equal(checkedImages))
+	})
+
+	It("set preflight report ConfigMap", func() {
+		statusWrite := client.NewMockStatusWriter(ctrl)
+		clnt.EXPECT().Status().Return(statusWrite)
+		statusWrite.EXPECT().Update(context.Background(), pv).Return(nil)
+
+		res := su.PreflightSetReportConfigMap(context.Background(), pv, "report")
+		Expect(res).To(BeNil())
+		Expect(pv.Status.ReportConfigMap).To(Equal(&v1.LocalObjectReference{Name: "report"}))
 	})
 
 	It("set preflight verification stage", func() {
@@ -184,6 +301,34 @@ var _ = Describe("preflight status updates", func() {
 		Expect(res).To(BeNil())
 		Expect(pv.Status.CRStatuses[moduleName].VerificationStage).To(Equal("verificationStage"))
 	})
+
+	It("set preflight summary, some modules in progress", func() {
+		summary := kmmv1beta1.PreflightSummary{Verified: 1, Failed: 1, InProgress: 1}
+		statusWrite := client.NewMockStatusWriter(ctrl)
+		clnt.EXPECT().Status().Return(statusWrite)
+		statusWrite.EXPECT().Update(context.Background(), pv).Return(nil)
+
+		res := su.PreflightSetSummary(context.Background(), pv, summary)
+		Expect(res).To(BeNil())
+		Expect(pv.Status.Summary).To(Equal(summary))
+		Expect(pv.Status.Phase).To(Equal(kmmv1beta1.PreflightPhaseInProgress))
+		Expect(pv.Status.CompletionTime).To(BeNil())
+	})
+
+	It("set preflight summary, all modules checked", func() {
+		pv.Generation = 3
+		summary := kmmv1beta1.PreflightSummary{Verified: 2, Failed: 1}
+		statusWrite := client.NewMockStatusWriter(ctrl)
+		clnt.EXPECT().Status().Return(statusWrite)
+		statusWrite.EXPECT().Update(context.Background(), pv).Return(nil)
+
+		res := su.PreflightSetSummary(context.Background(), pv, summary)
+		Expect(res).To(BeNil())
+		Expect(pv.Status.Summary).To(Equal(summary))
+		Expect(pv.Status.Phase).To(Equal(kmmv1beta1.PreflightPhaseCompleted))
+		Expect(pv.Status.CompletionTime).NotTo(BeNil())
+		Expect(pv.Status.ObservedGeneration).To(Equal(pv.Generation))
+	})
 })
 
 func getDaemonSet(kernelNumber int, dsConfig daemonSetConfig) (string, *appsv1.DaemonSet) {
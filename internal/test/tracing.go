@@ -0,0 +1,19 @@
+package test
+
+import (
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TestTracer returns a Tracer whose spans are kept in memory by the returned SpanRecorder.
+func TestTracer() (trace.Tracer, *tracetest.SpanRecorder) {
+	sr := tracetest.NewSpanRecorder()
+
+	return sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)).Tracer("test"), sr
+}
+
+// NoopTracer returns a Tracer that does not record any span.
+func NoopTracer() trace.Tracer {
+	return trace.NewNoopTracerProvider().Tracer("")
+}
@@ -0,0 +1,89 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/qbarrand/oot-operator/internal/config"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	TracerName = "github.com/qbarrand/oot-operator"
+
+	serviceName = "kmm-operator"
+)
+
+// Attributes set on the spans of the operator.
+const (
+	ModuleNameKey      = attribute.Key("kmm.module.name")
+	ModuleNamespaceKey = attribute.Key("kmm.module.namespace")
+	KernelVersionKey   = attribute.Key("kmm.kernel.version")
+	ImageKey           = attribute.Key("kmm.image")
+	LayerDigestKey     = attribute.Key("kmm.layer.digest")
+)
+
+// ModuleAttributes returns the attributes identifying a Module.
+func ModuleAttributes(name, namespace string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		ModuleNameKey.String(name),
+		ModuleNamespaceKey.String(namespace),
+	}
+}
+
+// NewTracerProvider returns a TracerProvider that exports spans to the OTLP receiver configured in cfg, and a
+// function that flushes and stops the exporter.
+// If no endpoint is configured, the TracerProvider does not record any span.
+func NewTracerProvider(ctx context.Context, cfg config.Tracing, version string) (trace.TracerProvider, func(context.Context) error, error) {
+	if cfg.Endpoint == "" {
+		return trace.NewNoopTracerProvider(), func(context.Context) error { return nil }, nil
+	}
+
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.Endpoint)}
+
+	if cfg.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create the OTLP exporter: %w", err)
+	}
+
+	ratio := 1.0
+	if cfg.SampleRatio != nil {
+		ratio = *cfg.SampleRatio
+	}
+
+	res := resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceNameKey.String(serviceName),
+		semconv.ServiceVersionKey.String(version),
+	)
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(
+			sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio)),
+		),
+	)
+
+	return tp, tp.Shutdown, nil
+}
+
+// End records err in span, if it is not nil, and ends span.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
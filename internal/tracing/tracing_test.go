@@ -0,0 +1,46 @@
+package tracing
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/qbarrand/oot-operator/internal/config"
+)
+
+var _ = Describe("NewTracerProvider", func() {
+	ctx := context.Background()
+
+	It("should not record spans if no endpoint is configured", func() {
+		tp, shutdown, err := NewTracerProvider(ctx, config.Tracing{}, "version")
+		Expect(err).NotTo(HaveOccurred())
+
+		_, span := tp.Tracer(TracerName).Start(ctx, "test")
+		Expect(span.IsRecording()).To(BeFalse())
+		span.End()
+
+		Expect(shutdown(ctx)).To(Succeed())
+	})
+
+	It("should record spans if an endpoint is configured", func() {
+		tp, shutdown, err := NewTracerProvider(ctx, config.Tracing{Endpoint: "127.0.0.1:4317", Insecure: true}, "version")
+		Expect(err).NotTo(HaveOccurred())
+
+		_, span := tp.Tracer(TracerName).Start(ctx, "test")
+		Expect(span.IsRecording()).To(BeTrue())
+
+		Expect(shutdown(ctx)).To(Succeed())
+	})
+
+	It("should not sample spans with a ratio of 0", func() {
+		ratio := 0.0
+
+		tp, shutdown, err := NewTracerProvider(ctx, config.Tracing{Endpoint: "127.0.0.1:4317", SampleRatio: &ratio}, "version")
+		Expect(err).NotTo(HaveOccurred())
+
+		_, span := tp.Tracer(TracerName).Start(ctx, "test")
+		Expect(span.IsRecording()).To(BeFalse())
+
+		Expect(shutdown(ctx)).To(Succeed())
+	})
+})
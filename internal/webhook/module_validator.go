@@ -0,0 +1,165 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	"github.com/qbarrand/oot-operator/internal/conflicts"
+	"github.com/qbarrand/oot-operator/internal/daemonset"
+	"github.com/qbarrand/oot-operator/internal/module"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+//+kubebuilder:webhook:path=/validate-kmm-sigs-k8s-io-v1beta1-module,mutating=false,failurePolicy=fail,sideEffects=None,groups=kmm.sigs.k8s.io,resources=modules,verbs=create;update,versions=v1beta1,name=vmodule.kb.io,admissionReviewVersions=v1
+
+// ModuleValidator rejects Modules with an invalid node selector or module loader container, or that would load a
+// kernel module on nodes where another Module already loads it.
+type ModuleValidator struct {
+	conflictsAPI conflicts.Detector
+}
+
+var _ admission.CustomValidator = &ModuleValidator{}
+
+func NewModuleValidator(conflictsAPI conflicts.Detector) *ModuleValidator {
+	return &ModuleValidator{conflictsAPI: conflictsAPI}
+}
+
+// SetupWebhookWithManager registers the webhook with the Manager.
+func (v *ModuleValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&kmmv1beta1.Module{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate rejects the Module if it conflicts with any existing Module.
+func (v *ModuleValidator) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	mod, ok := obj.(*kmmv1beta1.Module)
+	if !ok {
+		return fmt.Errorf("unexpected object of type %T", obj)
+	}
+
+	if err := validateSpec(&mod.Spec); err != nil {
+		return err
+	}
+
+	found, err := v.conflictsAPI.FindConflicts(ctx, mod)
+	if err != nil {
+		return fmt.Errorf("could not look for conflicting Modules: %w", err)
+	}
+
+	return conflictsError(mod, found)
+}
+
+// ValidateUpdate rejects changes to the selector or kernel module name that add conflicts or make existing ones
+// cover more nodes.
+// Conflicts that were already there are accepted, so that they can be fixed by updating the Module.
+func (v *ModuleValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) error {
+	oldMod, ok := oldObj.(*kmmv1beta1.Module)
+	if !ok {
+		return fmt.Errorf("unexpected object of type %T", oldObj)
+	}
+
+	newMod, ok := newObj.(*kmmv1beta1.Module)
+	if !ok {
+		return fmt.Errorf("unexpected object of type %T", newObj)
+	}
+
+	if newMod.DeletionTimestamp != nil {
+		return nil
+	}
+
+	if err := validateSpec(&newMod.Spec); err != nil {
+		return err
+	}
+
+	oldKernelModule := oldMod.Spec.ModuleLoader.Container.Modprobe.ModuleName
+	newKernelModule := newMod.Spec.ModuleLoader.Container.Modprobe.ModuleName
+
+	if oldKernelModule == newKernelModule &&
+		reflect.DeepEqual(oldMod.Spec.Selector, newMod.Spec.Selector) &&
+		reflect.DeepEqual(oldMod.Spec.SelectorExpressions, newMod.Spec.SelectorExpressions) {
+		return nil
+	}
+
+	found, err := v.conflictsAPI.FindConflicts(ctx, newMod)
+	if err != nil {
+		return fmt.Errorf("could not look for conflicting Modules: %w", err)
+	}
+
+	if len(found) == 0 || oldKernelModule != newKernelModule {
+		return conflictsError(newMod, found)
+	}
+
+	existing, err := v.conflictsAPI.FindConflicts(ctx, oldMod)
+	if err != nil {
+		return fmt.Errorf("could not look for conflicting Modules: %w", err)
+	}
+
+	nodesNumber := make(map[string]int32, len(existing))
+
+	for _, c := range existing {
+		nodesNumber[c.Namespace+"/"+c.Name] = c.NodesNumber
+	}
+
+	added := make([]kmmv1beta1.ModuleConflict, 0, len(found))
+
+	for _, c := range found {
+		if c.NodesNumber > nodesNumber[c.Namespace+"/"+c.Name] {
+			added = append(added, c)
+		}
+	}
+
+	return conflictsError(newMod, added)
+}
+
+// ValidateDelete accepts all deletions.
+func (v *ModuleValidator) ValidateDelete(_ context.Context, _ runtime.Object) error {
+	return nil
+}
+
+// validateSpec checks the properties of spec that the API server cannot validate.
+func validateSpec(spec *kmmv1beta1.ModuleSpec) error {
+	if _, err := module.NodeSelector(spec); err != nil {
+		return err
+	}
+
+	if err := daemonset.ValidateModuleLoaderContainer(spec.ModuleLoader.Container); err != nil {
+		return fmt.Errorf("invalid module loader container: %w", err)
+	}
+
+	if spec.DevicePlugin == nil || !spec.DevicePlugin.PerKernel {
+		for _, km := range spec.ModuleLoader.Container.KernelMappings {
+			if km.DevicePluginImage != "" {
+				return errors.New("devicePluginImage can only be set in kernel mappings if devicePlugin.perKernel is true")
+			}
+		}
+	}
+
+	return nil
+}
+
+// conflictsError returns an error listing conflicts, or nil if there are none.
+func conflictsError(mod *kmmv1beta1.Module, conflicts []kmmv1beta1.ModuleConflict) error {
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	others := make([]string, 0, len(conflicts))
+
+	for _, c := range conflicts {
+		others = append(others, fmt.Sprintf("%s/%s on %d node(s)", c.Namespace, c.Name, c.NodesNumber))
+	}
+
+	return fmt.Errorf(
+		"kernel module %s is already loaded by other Modules: %s",
+		mod.Spec.ModuleLoader.Container.Modprobe.ModuleName,
+		strings.Join(others, ", "),
+	)
+}
@@ -0,0 +1,177 @@
+package webhook
+
+import (
+	"context"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	kmmv1beta1 "github.com/qbarrand/oot-operator/api/v1beta1"
+	"github.com/qbarrand/oot-operator/internal/conflicts"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("ModuleValidator", func() {
+	var (
+		ctx    context.Context
+		mockCD *conflicts.MockDetector
+		v      *ModuleValidator
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		mockCD = conflicts.NewMockDetector(gomock.NewController(GinkgoT()))
+		v = NewModuleValidator(mockCD)
+	})
+
+	makeModule := func(kernelModule string, selector map[string]string) *kmmv1beta1.Module {
+		return &kmmv1beta1.Module{
+			ObjectMeta: metav1.ObjectMeta{Name: "mod", Namespace: "ns"},
+			Spec: kmmv1beta1.ModuleSpec{
+				ModuleLoader: kmmv1beta1.ModuleLoaderSpec{
+					Container: kmmv1beta1.ModuleLoaderContainerSpec{
+						Modprobe: kmmv1beta1.ModprobeSpec{ModuleName: kernelModule},
+					},
+				},
+				Selector: selector,
+			},
+		}
+	}
+
+	conflict := kmmv1beta1.ModuleConflict{Name: "other", Namespace: "other-ns", NodesNumber: 2}
+
+	Describe("ValidateCreate", func() {
+		It("should accept Modules without conflicts", func() {
+			mod := makeModule("kmod", nil)
+
+			mockCD.EXPECT().FindConflicts(ctx, mod)
+
+			Expect(v.ValidateCreate(ctx, mod)).To(Succeed())
+		})
+
+		It("should reject Modules with conflicts", func() {
+			mod := makeModule("kmod", nil)
+
+			mockCD.EXPECT().FindConflicts(ctx, mod).Return([]kmmv1beta1.ModuleConflict{conflict}, nil)
+
+			Expect(
+				v.ValidateCreate(ctx, mod),
+			).To(
+				MatchError("kernel module kmod is already loaded by other Modules: other-ns/other on 2 node(s)"),
+			)
+		})
+
+		It("should reject invalid selector expressions", func() {
+			mod := makeModule("kmod", nil)
+			mod.Spec.SelectorExpressions = []metav1.LabelSelectorRequirement{
+				{Key: "gpu", Operator: metav1.LabelSelectorOpExists, Values: []string{"none"}},
+			}
+
+			Expect(v.ValidateCreate(ctx, mod)).NotTo(Succeed())
+		})
+
+		It("should reject volume mounts shadowing the kernel modules directories", func() {
+			mod := makeModule("kmod", nil)
+			mod.Spec.ModuleLoader.Container.VolumeMounts = []v1.VolumeMount{
+				{Name: "extra", MountPath: "/lib/modules"},
+			}
+
+			Expect(v.ValidateCreate(ctx, mod)).NotTo(Succeed())
+		})
+
+		It("should reject device plugin images in kernel mappings if the device plugin is not per kernel", func() {
+			mod := makeModule("kmod", nil)
+			mod.Spec.ModuleLoader.Container.KernelMappings = []kmmv1beta1.KernelMapping{
+				{Regexp: ".*", ContainerImage: "some-image", DevicePluginImage: "some-device-plugin"},
+			}
+			mod.Spec.DevicePlugin = &kmmv1beta1.DevicePluginSpec{}
+
+			Expect(v.ValidateCreate(ctx, mod)).NotTo(Succeed())
+
+			mod.Spec.DevicePlugin.PerKernel = true
+
+			mockCD.EXPECT().FindConflicts(ctx, mod)
+
+			Expect(v.ValidateCreate(ctx, mod)).To(Succeed())
+		})
+
+		It("should reject other objects", func() {
+			Expect(v.ValidateCreate(ctx, &v1.Pod{})).NotTo(Succeed())
+		})
+	})
+
+	Describe("ValidateUpdate", func() {
+		It("should not look for conflicts if the selector and kernel module did not change", func() {
+			oldMod := makeModule("kmod", map[string]string{"a": "b"})
+			newMod := makeModule("kmod", map[string]string{"a": "b"})
+			newMod.Spec.ModuleLoader.Container.Modprobe.Parameters = []string{"param=1"}
+
+			Expect(v.ValidateUpdate(ctx, oldMod, newMod)).To(Succeed())
+		})
+
+		It("should reject volume mounts shadowing the kernel modules directories", func() {
+			oldMod := makeModule("kmod", nil)
+			newMod := makeModule("kmod", nil)
+			newMod.Spec.ModuleLoader.Container.VolumeMounts = []v1.VolumeMount{
+				{Name: "extra", MountPath: "/usr/lib/modules/extra"},
+			}
+
+			Expect(v.ValidateUpdate(ctx, oldMod, newMod)).NotTo(Succeed())
+		})
+
+		It("should look for conflicts if the selector expressions changed", func() {
+			oldMod := makeModule("kmod", nil)
+			newMod := makeModule("kmod", nil)
+			newMod.Spec.SelectorExpressions = []metav1.LabelSelectorRequirement{
+				{Key: "gpu", Operator: metav1.LabelSelectorOpDoesNotExist},
+			}
+
+			mockCD.EXPECT().FindConflicts(ctx, newMod)
+
+			Expect(v.ValidateUpdate(ctx, oldMod, newMod)).To(Succeed())
+		})
+
+		It("should reject a new kernel module name with conflicts", func() {
+			oldMod := makeModule("kmod", nil)
+			newMod := makeModule("other-kmod", nil)
+
+			mockCD.EXPECT().FindConflicts(ctx, newMod).Return([]kmmv1beta1.ModuleConflict{conflict}, nil)
+
+			Expect(v.ValidateUpdate(ctx, oldMod, newMod)).NotTo(Succeed())
+		})
+
+		It("should accept a selector keeping the existing conflicts", func() {
+			oldMod := makeModule("kmod", map[string]string{"a": "b"})
+			newMod := makeModule("kmod", map[string]string{"a": "c"})
+
+			shrunk := conflict
+			shrunk.NodesNumber = 1
+
+			gomock.InOrder(
+				mockCD.EXPECT().FindConflicts(ctx, newMod).Return([]kmmv1beta1.ModuleConflict{shrunk}, nil),
+				mockCD.EXPECT().FindConflicts(ctx, oldMod).Return([]kmmv1beta1.ModuleConflict{conflict}, nil),
+			)
+
+			Expect(v.ValidateUpdate(ctx, oldMod, newMod)).To(Succeed())
+		})
+
+		It("should reject a selector extending the conflicts", func() {
+			oldMod := makeModule("kmod", map[string]string{"a": "b"})
+			newMod := makeModule("kmod", nil)
+
+			added := kmmv1beta1.ModuleConflict{Name: "added", Namespace: "ns", NodesNumber: 1}
+
+			gomock.InOrder(
+				mockCD.EXPECT().FindConflicts(ctx, newMod).Return([]kmmv1beta1.ModuleConflict{added, conflict}, nil),
+				mockCD.EXPECT().FindConflicts(ctx, oldMod).Return([]kmmv1beta1.ModuleConflict{conflict}, nil),
+			)
+
+			Expect(
+				v.ValidateUpdate(ctx, oldMod, newMod),
+			).To(
+				MatchError("kernel module kmod is already loaded by other Modules: ns/added on 1 node(s)"),
+			)
+		})
+	})
+})
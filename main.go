@@ -17,21 +17,29 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"runtime/debug"
+	"time"
 
 	"github.com/qbarrand/oot-operator/internal/build"
 	"github.com/qbarrand/oot-operator/internal/build/job"
+	"github.com/qbarrand/oot-operator/internal/config"
+	"github.com/qbarrand/oot-operator/internal/conflicts"
 	"github.com/qbarrand/oot-operator/internal/daemonset"
 	"github.com/qbarrand/oot-operator/internal/filter"
 	"github.com/qbarrand/oot-operator/internal/metrics"
 	"github.com/qbarrand/oot-operator/internal/module"
+	"github.com/qbarrand/oot-operator/internal/nodemarker"
 	"github.com/qbarrand/oot-operator/internal/preflight"
 	"github.com/qbarrand/oot-operator/internal/registry"
 	"github.com/qbarrand/oot-operator/internal/statusupdater"
+	"github.com/qbarrand/oot-operator/internal/tracing"
+	"github.com/qbarrand/oot-operator/internal/webhook"
+	componentconfigv1alpha1 "k8s.io/component-base/config/v1alpha1"
 	"k8s.io/klog/v2/klogr"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
@@ -45,10 +53,13 @@ import (
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	//+kubebuilder:scaffold:imports
 )
 
+const eventRecorderName = "kmm"
+
 var scheme = runtime.NewScheme()
 
 func init() {
@@ -64,6 +75,9 @@ func main() {
 		metricsAddr          string
 		enableLeaderElection bool
 		probeAddr            string
+
+		preflightConcurrency   int
+		preflightModuleTimeout time.Duration
 	)
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
@@ -72,7 +86,10 @@ func main() {
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
 
-	flag.StringVar(&configFile, "config", "", "The path to the configuration file.")
+	flag.StringVar(&configFile, "config", "", "The path to the configuration file. Flags that are set take precedence over it.")
+
+	flag.IntVar(&preflightConcurrency, "preflight-concurrency", 4, "The number of Modules checked in parallel by a PreflightValidation.")
+	flag.DurationVar(&preflightModuleTimeout, "preflight-module-timeout", 5*time.Minute, "The maximum duration of a single Module check in a PreflightValidation.")
 
 	klog.InitFlags(flag.CommandLine)
 
@@ -90,28 +107,96 @@ func main() {
 		commit = "<undefined>"
 	}
 
+	// overrideFromFlags applies the flags that were explicitly set on top of the configuration file.
+	overrideFromFlags := func(cfg *config.Config) {
+		flag.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "metrics-bind-address":
+				cfg.Metrics.BindAddress = metricsAddr
+			case "health-probe-bind-address":
+				cfg.Health.HealthProbeBindAddress = probeAddr
+			case "leader-elect":
+				if cfg.LeaderElection == nil {
+					cfg.LeaderElection = &componentconfigv1alpha1.LeaderElectionConfiguration{}
+				}
+
+				cfg.LeaderElection.LeaderElect = &enableLeaderElection
+			case "preflight-concurrency":
+				cfg.Preflight.Concurrency = preflightConcurrency
+			case "preflight-module-timeout":
+				cfg.Preflight.ModuleTimeout.Duration = preflightModuleTimeout
+			}
+		})
+	}
+
+	cfg := config.NewDefault()
+
+	if configFile != "" {
+		setupLogger.Info("Reading configuration", "path", configFile)
+
+		if cfg, err = config.ParseFile(configFile); err != nil {
+			setupLogger.Error(err, "could not read the configuration file")
+			os.Exit(1)
+		}
+	}
+
+	overrideFromFlags(cfg)
+
+	if err = cfg.Validate(); err != nil {
+		setupLogger.Error(err, "invalid configuration")
+		os.Exit(1)
+	}
+
+	var (
+		cfgProvider = config.NewStaticProvider(cfg)
+		cfgWatcher  *config.Watcher
+	)
+
+	if configFile != "" {
+		cfgWatcher = config.NewWatcher(configFile, cfg, overrideFromFlags, logger.WithName("config"))
+		cfgProvider = cfgWatcher
+	}
+
+	tracerProvider, shutdownTracing, err := tracing.NewTracerProvider(context.Background(), cfg.Tracing, commit)
+	if err != nil {
+		setupLogger.Error(err, "could not set up tracing")
+		os.Exit(1)
+	}
+
+	tracer := tracerProvider.Tracer(tracing.TracerName)
+
 	setupLogger.Info("Creating manager", "git commit", commit)
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:                 scheme,
-		MetricsBindAddress:     metricsAddr,
-		Port:                   9443,
-		HealthProbeBindAddress: probeAddr,
-		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "c5baf8af.sigs.k8s.io",
-	})
+	options, err := ctrl.Options{Scheme: scheme}.AndFrom(cfg)
+	if err != nil {
+		setupLogger.Error(err, "could not get the manager options")
+		os.Exit(1)
+	}
+
+	if len(cfg.WatchedNamespaces) > 0 {
+		setupLogger.Info("Only watching some namespaces", "namespaces", cfg.WatchedNamespaces)
+		options.NewCache = cache.MultiNamespacedCacheBuilder(cfg.WatchedNamespaces)
+	}
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), options)
 	if err != nil {
 		setupLogger.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
-	client := mgr.GetClient()
+	if cfgWatcher != nil {
+		if err = mgr.Add(cfgWatcher); err != nil {
+			setupLogger.Error(err, "could not watch the configuration file")
+			os.Exit(1)
+		}
+	}
 
-	filter := filter.New(client, mgr.GetLogger())
+	client := mgr.GetClient()
+	recorder := mgr.GetEventRecorderFor(eventRecorderName)
 
-	const kernelLabel = "kmm.node.kubernetes.io/kernel-version.full"
+	filter := filter.New(client, mgr.GetLogger(), cfg.WatchedNamespaces, cfg.AllowedModuleNamespaces)
 
-	nodeKernelReconciler := controllers.NewNodeKernelReconciler(client, kernelLabel, filter)
+	nodeKernelReconciler := controllers.NewNodeKernelReconciler(client, cfg.KernelLabel, filter)
 
 	if err = nodeKernelReconciler.SetupWithManager(mgr); err != nil {
 		setupLogger.Error(err, "unable to create controller", "controller", "NodeKernel")
@@ -120,33 +205,89 @@ func main() {
 
 	metricsAPI := metrics.New()
 	metricsAPI.Register()
-	registryAPI := registry.NewRegistry()
+	registryAPI := registry.NewRegistry(metricsAPI, tracer)
 	helperAPI := build.NewHelper()
-	makerAPI := job.NewMaker(helperAPI, scheme)
-	buildAPI := job.NewBuildManager(client, registryAPI, makerAPI, helperAPI)
-	daemonAPI := daemonset.NewCreator(client, kernelLabel, scheme)
+	makerAPI := job.NewMaker(helperAPI, cfgProvider, scheme)
+	buildAPI := job.NewBuildManager(client, registryAPI, makerAPI, helperAPI, recorder)
+	daemonAPI := daemonset.NewCreator(client, cfg.KernelLabel, scheme)
 	kernelAPI := module.NewKernelMapper()
 	moduleStatusUpdaterAPI := statusupdater.NewModuleStatusUpdater(client, daemonAPI, metricsAPI)
 	preflightStatusUpdaterAPI := statusupdater.NewPreflightStatusUpdater(client)
-	preflightAPI := preflight.NewPreflightAPI(client, registryAPI, kernelAPI)
+	preflightAPI := preflight.NewPreflightAPI(client, buildAPI, registryAPI, kernelAPI, cfgProvider, tracer)
 
-	mc := controllers.NewModuleReconciler(client, buildAPI, daemonAPI, kernelAPI, metricsAPI, filter, moduleStatusUpdaterAPI)
+	conflictsAPI := conflicts.NewDetector(client)
 
-	if err = mc.SetupWithManager(mgr, kernelLabel); err != nil {
+	mc := controllers.NewModuleReconciler(client, buildAPI, conflictsAPI, daemonAPI, kernelAPI, metricsAPI, filter, moduleStatusUpdaterAPI, nodemarker.NewNodeMarker(client), tracer, recorder)
+
+	if err = mc.SetupWithManager(mgr, cfg.KernelLabel); err != nil {
 		setupLogger.Error(err, "unable to create controller", "controller", "Module")
 		os.Exit(1)
 	}
 
-	if err = controllers.NewPodNodeModuleReconciler(client, daemonAPI).SetupWithManager(mgr); err != nil {
+	if err = controllers.NewPodNodeModuleReconciler(client, daemonAPI, metricsAPI, cfg.KernelLabel, recorder).SetupWithManager(mgr); err != nil {
 		setupLogger.Error(err, "unable to create controller", "controller", "PodNodeModule")
 		os.Exit(1)
 	}
 
-	if err = controllers.NewPreflightValidationReconciler(client, filter, preflightStatusUpdaterAPI, preflightAPI).SetupWithManager(mgr); err != nil {
+	if cfg.NodeLabelResyncPeriod.Duration > 0 {
+		if err = controllers.NewNodeLabelResyncReconciler(
+			client,
+			daemonAPI,
+			metricsAPI,
+			filter,
+			recorder,
+			cfg.NodeLabelResyncPeriod.Duration,
+		).SetupWithManager(mgr); err != nil {
+			setupLogger.Error(err, "unable to create controller", "controller", "NodeLabelResync")
+			os.Exit(1)
+		}
+	}
+
+	if err = controllers.NewBuildMetricsReconciler(client, metricsAPI, recorder).SetupWithManager(mgr); err != nil {
+		setupLogger.Error(err, "unable to create controller", "controller", "BuildMetrics")
+		os.Exit(1)
+	}
+
+	if err = controllers.NewPreflightValidationReconciler(
+		client,
+		filter,
+		preflightStatusUpdaterAPI,
+		preflightAPI,
+		metricsAPI,
+		scheme,
+		cfgProvider,
+		recorder,
+	).SetupWithManager(mgr); err != nil {
 		setupLogger.Error(err, "unable to create controller", "controller", "Preflight")
 		os.Exit(1)
 	}
 
+	if err = controllers.NewClusterModuleReconciler(
+		client,
+		conflictsAPI,
+		filter,
+		statusupdater.NewClusterModuleStatusUpdater(client),
+		scheme,
+		recorder,
+	).SetupWithManager(mgr); err != nil {
+		setupLogger.Error(err, "unable to create controller", "controller", "ClusterModule")
+		os.Exit(1)
+	}
+
+	if cfg.ManagePodSecurityLabels {
+		if err = controllers.NewPodSecurityLabelReconciler(client, filter, recorder).SetupWithManager(mgr); err != nil {
+			setupLogger.Error(err, "unable to create controller", "controller", "PodSecurityLabel")
+			os.Exit(1)
+		}
+	}
+
+	if cfg.EnableWebhooks {
+		if err = webhook.NewModuleValidator(conflictsAPI).SetupWebhookWithManager(mgr); err != nil {
+			setupLogger.Error(err, "unable to create webhook", "webhook", "Module")
+			os.Exit(1)
+		}
+	}
+
 	//+kubebuilder:scaffold:builder
 
 	if err = mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -159,7 +300,13 @@ func main() {
 	}
 
 	setupLogger.Info("starting manager")
-	if err = mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	err = mgr.Start(ctrl.SetupSignalHandler())
+
+	if tracingErr := shutdownTracing(context.Background()); tracingErr != nil {
+		setupLogger.Error(tracingErr, "could not flush the remaining spans")
+	}
+
+	if err != nil {
 		setupLogger.Error(err, "problem running manager")
 		os.Exit(1)
 	}